@@ -0,0 +1,40 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/oalexander6/passman/config"
+	"github.com/oalexander6/passman/pkg/blob"
+	"github.com/oalexander6/passman/pkg/logger"
+)
+
+// runBlobs implements the blobs serve-s3 command.
+func runBlobs(c *config.Config, args []string) {
+	if len(args) == 0 {
+		logger.Log.Fatal().Msgf("Missing blobs command\n%s", usage)
+	}
+
+	switch args[0] {
+	case "serve-s3":
+		if len(args) != 3 {
+			logger.Log.Fatal().Msgf("Usage: passman blobs serve-s3 <addr> <dir>")
+		}
+
+		store, err := blob.NewFileStore(args[2])
+		if err != nil {
+			logger.Log.Fatal().Msgf("Failed to open blob directory: %s", err)
+		}
+
+		s3 := c.Attachments.S3
+		if s3.Bucket == "" || s3.AccessKeyID == "" || s3.SecretAccessKey == "" {
+			logger.Log.Fatal().Msgf("S3_BUCKET, S3_ACCESS_KEY_ID and S3_SECRET_ACCESS_KEY must be set")
+		}
+
+		logger.Log.Info().Msgf("S3 stand-in serving bucket %s on %s", s3.Bucket, args[1])
+		err = http.ListenAndServe(args[1], blob.NewS3Handler(store, s3.Bucket, s3.Region, s3.AccessKeyID, s3.SecretAccessKey))
+		logger.Log.Fatal().Msgf("S3 stand-in stopped: %s", err)
+
+	default:
+		logger.Log.Fatal().Msgf("Unknown blobs command: %s\n%s", args[0], usage)
+	}
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/oalexander6/passman/config"
+	"github.com/oalexander6/passman/pkg/keys"
+	"github.com/oalexander6/passman/pkg/logger"
+	"github.com/oalexander6/passman/pkg/models"
+)
+
+// runKeys implements the keys generate|add|rotate|serve-kms commands.
+func runKeys(c *config.Config, args []string) {
+	if len(args) == 0 {
+		logger.Log.Fatal().Msgf("Missing keys command\n%s", usage)
+	}
+
+	switch args[0] {
+	case "generate":
+		if len(args) != 2 {
+			logger.Log.Fatal().Msgf("Usage: passman keys generate <file>")
+		}
+
+		masterKey, err := keys.GenerateMasterKey()
+		if err != nil {
+			logger.Log.Fatal().Msgf("Failed to generate key: %s", err)
+		}
+
+		// O_EXCL prevents replacing an existing key, which would make its data unreadable
+		f, err := os.OpenFile(args[1], os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+		if err != nil {
+			logger.Log.Fatal().Msgf("Failed to create key file: %s", err)
+		}
+		defer f.Close()
+
+		if _, err := fmt.Fprintln(f, masterKey); err != nil {
+			logger.Log.Fatal().Msgf("Failed to write key file: %s", err)
+		}
+		logger.Log.Info().Msgf("Wrote new master key to %s", args[1])
+
+	case "add":
+		if len(args) != 2 {
+			logger.Log.Fatal().Msgf("Usage: passman keys add <file>")
+		}
+
+		masterKeys, err := keys.ReadKeyFile(args[1])
+		if err != nil {
+			logger.Log.Fatal().Msgf("Failed to read key file: %s", err)
+		}
+
+		masterKey, err := keys.GenerateMasterKey()
+		if err != nil {
+			logger.Log.Fatal().Msgf("Failed to generate key: %s", err)
+		}
+
+		// the new key is the primary key, so it goes first
+		lines := []string{masterKey}
+		for _, existing := range masterKeys {
+			lines = append(lines, base64.StdEncoding.EncodeToString(existing))
+		}
+
+		if err := os.WriteFile(args[1], []byte(strings.Join(lines, "\n")+"\n"), 0o600); err != nil {
+			logger.Log.Fatal().Msgf("Failed to write key file: %s", err)
+		}
+		logger.Log.Info().Msgf("Added new primary master key to %s, run `passman keys rotate` to start using it", args[1])
+
+	case "rotate":
+		flags := flag.NewFlagSet("keys rotate", flag.ExitOnError)
+		batchSize := flags.Int("batch-size", 100, "number of accounts processed between checkpoints")
+		workers := flags.Int("workers", 4, "number of accounts processed concurrently")
+		flags.Parse(args[1:])
+
+		keyProvider, err := keys.New(c.Encryption)
+		if err != nil {
+			logger.Log.Fatal().Msgf("Failed to create key provider: %s", err)
+		}
+
+		s := openStore(c)
+		defer s.Close()
+
+		result, err := models.New(s, c, keyProvider, nil, nil).RotateKeys(context.Background(), models.KeyRotationOptions{
+			BatchSize: *batchSize,
+			Workers:   *workers,
+		})
+		if err != nil {
+			logger.Log.Fatal().Msgf("Key rotation failed, run the command again to resume: %s", err)
+		}
+
+		logger.Log.Info().Msgf("Rotated %d accounts to key %s: %d data keys and %d index keys rewrapped, %d notes re-encrypted",
+			result.Accounts, result.KeyID, result.DataKeysRewrapped, result.IndexKeysRewrapped, result.NotesReencrypted)
+
+	case "serve-kms":
+		if len(args) != 4 {
+			logger.Log.Fatal().Msgf("Usage: passman keys serve-kms <addr> <file> <key-id>")
+		}
+
+		provider, err := keys.NewFileProvider(args[2])
+		if err != nil {
+			logger.Log.Fatal().Msgf("Failed to load master key: %s", err)
+		}
+
+		logger.Log.Info().Msgf("KMS stand-in serving key %s on %s", args[3], args[1])
+		err = http.ListenAndServe(args[1], keys.NewKMSHandler(provider, args[3], c.Encryption.KMS.Token))
+		logger.Log.Fatal().Msgf("KMS stand-in stopped: %s", err)
+
+	default:
+		logger.Log.Fatal().Msgf("Unknown keys command: %s\n%s", args[0], usage)
+	}
+}
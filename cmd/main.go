@@ -1,16 +1,45 @@
 package main
 
 import (
+	"context"
 	"os"
+	"time"
 
 	"github.com/oalexander6/passman/config"
+	"github.com/oalexander6/passman/pkg/blob"
+	"github.com/oalexander6/passman/pkg/breach"
 	"github.com/oalexander6/passman/pkg/httpserver"
+	"github.com/oalexander6/passman/pkg/keys"
 	"github.com/oalexander6/passman/pkg/logger"
 	"github.com/oalexander6/passman/pkg/models"
+	"github.com/oalexander6/passman/pkg/store/migrate"
 	"github.com/oalexander6/passman/pkg/store/postgres"
+	"github.com/oalexander6/passman/pkg/store/sqlite"
 	"github.com/rs/zerolog"
 )
 
+const usage = `usage: passman [command]
+
+commands:
+  serve                           run the HTTP server (default)
+  migrate up                      apply all pending migrations
+  migrate down [steps]            revert the last applied migrations (default 1)
+  migrate status                  list migrations and whether they are applied
+  keys generate <file>            write a new random master key to a key file
+  keys add <file>                 add a new primary master key to a key file
+  keys rotate [-batch-size n] [-workers n]
+                                  move all data onto the primary master key, resuming
+                                  an interrupted rotation
+  keys serve-kms <addr> <file> <key-id>
+                                  run a local KMS stand-in using the master key in file
+  blobs serve-s3 <addr> <dir>     run a local S3 stand-in storing objects in dir`
+
+// store is implemented by every store backend.
+type store interface {
+	models.Store
+	Migrator() (*migrate.Migrator, error)
+}
+
 func main() {
 	logger.Init(zerolog.DebugLevel, os.Stdout)
 
@@ -19,17 +48,74 @@ func main() {
 		logger.Log.Fatal().Msgf("Invalid configuration: %s", err.Error())
 	}
 
-	var store models.Store
+	command, args := "serve", os.Args[1:]
+	if len(args) > 0 {
+		command, args = args[0], args[1:]
+	}
+
+	switch command {
+	case "serve":
+		s := openStore(c)
+		defer s.Close()
+		serve(c, s)
+	case "migrate":
+		s := openStore(c)
+		defer s.Close()
+		runMigrate(s, args)
+	case "keys":
+		runKeys(c, args)
+	case "blobs":
+		runBlobs(c, args)
+	default:
+		logger.Log.Fatal().Msgf("Unknown command: %s\n%s", command, usage)
+	}
+}
 
+// openStore connects to the configured store backend.
+func openStore(c *config.Config) store {
 	switch c.StoreType {
 	case config.STORE_TYPE_POSTGRES:
-		store = postgres.New(c.PostgresOpts)
+		return postgres.New(c.PostgresOpts)
+	case config.STORE_TYPE_SQLITE:
+		return sqlite.New(c.SqliteOpts)
 	default:
 		logger.Log.Fatal().Msgf("Invalid store type: %s", c.StoreType)
+		return nil
+	}
+}
+
+// serve starts the HTTP server once the database schema matches this build.
+func serve(c *config.Config, s store) {
+	migrator, err := s.Migrator()
+	if err != nil {
+		logger.Log.Fatal().Msgf("Failed to load migrations: %s", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	if err := migrator.Check(ctx); err != nil {
+		logger.Log.Fatal().Msgf("Refusing to start: %s. Run `passman migrate up` to update the schema.", err)
 	}
 
-	defer store.Close()
+	keyProvider, err := keys.New(c.Encryption)
+	if err != nil {
+		logger.Log.Fatal().Msgf("Failed to create key provider: %s", err)
+	}
+
+	var breaches breach.Checker
+	if c.Breach.Path != "" {
+		breaches, err = breach.Open(c.Breach.Path)
+		if err != nil {
+			logger.Log.Fatal().Msgf("Failed to open breach data: %s", err)
+		}
+	}
+
+	blobs, err := blob.New(c.Attachments)
+	if err != nil {
+		logger.Log.Fatal().Msgf("Failed to open attachment store: %s", err)
+	}
 
-	app := httpserver.New(c, store)
+	app := httpserver.New(c, s, keyProvider, breaches, blobs)
 	logger.Log.Fatal().Msgf("Application crashed: %s", app.Run().Error())
 }
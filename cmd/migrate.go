@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/oalexander6/passman/pkg/logger"
+)
+
+// runMigrate implements the migrate up|down|status commands.
+func runMigrate(s store, args []string) {
+	if len(args) == 0 {
+		logger.Log.Fatal().Msgf("Missing migrate command\n%s", usage)
+	}
+
+	migrator, err := s.Migrator()
+	if err != nil {
+		logger.Log.Fatal().Msgf("Failed to load migrations: %s", err)
+	}
+
+	ctx := context.Background()
+
+	switch args[0] {
+	case "up":
+		applied, err := migrator.Up(ctx)
+		for _, m := range applied {
+			logger.Log.Info().Msgf("Applied migration %d_%s", m.Version, m.Name)
+		}
+		if err != nil {
+			logger.Log.Fatal().Msgf("Migration failed: %s", err)
+		}
+		logger.Log.Info().Msgf("Schema is at version %d", migrator.LatestVersion())
+
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			steps, err = strconv.Atoi(args[1])
+			if err != nil || steps < 1 {
+				logger.Log.Fatal().Msgf("Invalid number of steps: %s", args[1])
+			}
+		}
+
+		reverted, err := migrator.Down(ctx, steps)
+		for _, m := range reverted {
+			logger.Log.Info().Msgf("Reverted migration %d_%s", m.Version, m.Name)
+		}
+		if err != nil {
+			logger.Log.Fatal().Msgf("Migration failed: %s", err)
+		}
+
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			logger.Log.Fatal().Msgf("Failed to get migration status: %s", err)
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED AT")
+		for _, status := range statuses {
+			appliedAt := "pending"
+			if status.Applied {
+				appliedAt = status.AppliedAt.Local().Format(time.RFC3339)
+			}
+			fmt.Fprintf(w, "%d\t%s\t%s\n", status.Version, status.Name, appliedAt)
+		}
+		w.Flush()
+
+	default:
+		logger.Log.Fatal().Msgf("Unknown migrate command: %s\n%s", args[0], usage)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"slices"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/go-playground/validator/v10"
 	"github.com/joho/godotenv"
@@ -18,6 +19,17 @@ const (
 	PROD_ENV            = "PROD"
 	STORE_TYPE_POSTGRES = "postgres"
 	STORE_TYPE_SQLITE   = "sqlite"
+	KEY_PROVIDER_ENV    = "env"
+	KEY_PROVIDER_FILE   = "file"
+	KEY_PROVIDER_KMS    = "kms"
+	BLOB_STORE_FILE     = "file"
+	BLOB_STORE_S3       = "s3"
+
+	// default per-account attachment quota, 1 GiB
+	DEFAULT_ATTACHMENT_QUOTA = 1 << 30
+
+	DEFAULT_TRASH_RETENTION_DAYS = 30
+	DEFAULT_TRASH_PURGE_INTERVAL = time.Hour
 )
 
 type PostgresConfig struct {
@@ -25,11 +37,83 @@ type PostgresConfig struct {
 	URI string `json:"DB_URI" validate:"required"`
 }
 
+type SqliteConfig struct {
+	// path to the SQLite database file
+	Path string `json:"SQLITE_PATH" validate:"required"`
+}
+
+type KMSConfig struct {
+	// base URL of the key management service
+	URL string `json:"KMS_URL" validate:"required,url"`
+	// ID of the primary master key held by the KMS
+	KeyID string `json:"KMS_KEY_ID" validate:"required"`
+	// IDs of older master keys that may still be needed to unwrap data keys
+	PreviousKeyIDs []string `json:"KMS_PREVIOUS_KEY_IDS"`
+	// bearer token used to authenticate with the KMS
+	Token string `json:"-"`
+}
+
 type EncryptionConfig struct {
-	// Initialization vector for AES encryption
-	EncIV string `json:"ENCRYPTION_IV" validate:"required,len=16"`
-	// AES encryption secret key
-	EncSecret string `json:"ENCRYPTION_SECERET" validate:"required,len=32"`
+	// Initialization vector used by the legacy AES-CBC scheme. Only required to read values
+	// written before AES-GCM was introduced.
+	EncIV string `json:"ENCRYPTION_IV" validate:"omitempty,len=16"`
+	// AES encryption secret key. Used as the master key by the env key provider, and to read
+	// values written before envelope encryption was introduced.
+	EncSecret string `json:"ENCRYPTION_SECERET" validate:"required_if=KeyProvider env,omitempty,len=32"`
+	// Older encryption secrets that are still accepted for decryption while keys are rotated
+	PreviousSecrets []string `json:"-" validate:"dive,len=32"`
+	// source of the master key that wraps account data keys - env, file, kms
+	KeyProvider string `json:"KEY_PROVIDER" validate:"required,oneof=env file kms"`
+	// path to the master key file used by the file key provider
+	KeyFile string `json:"KEY_FILE" validate:"required_if=KeyProvider file"`
+	// KMS configuration used by the kms key provider, validated only when it is selected
+	KMS KMSConfig `json:"KMS" validate:"-"`
+}
+
+type BreachConfig struct {
+	// path to a Have I Been Pwned SHA-1 hash file sorted by hash, or a directory of range
+	// files. Breach checks are disabled when empty.
+	Path string `json:"BREACH_DATA_PATH" validate:"omitempty,file|dir"`
+}
+
+type S3Config struct {
+	// base URL of the S3-compatible object store
+	Endpoint string `json:"S3_ENDPOINT" validate:"required,url"`
+	// bucket that attachment chunks are stored in
+	Bucket string `json:"S3_BUCKET" validate:"required"`
+	// region used to sign requests
+	Region string `json:"S3_REGION" validate:"required"`
+	// access key used to sign requests
+	AccessKeyID string `json:"S3_ACCESS_KEY_ID" validate:"required"`
+	// secret access key used to sign requests
+	SecretAccessKey string `json:"-" validate:"required"`
+	// address the bucket in the URL path rather than the host name, as MinIO expects
+	PathStyle bool `json:"S3_PATH_STYLE"`
+}
+
+type AttachmentConfig struct {
+	// blob store that holds encrypted attachments - file, s3. Attachments are disabled when empty.
+	Store string `json:"ATTACHMENT_STORE" validate:"omitempty,oneof=file s3"`
+	// directory used by the file blob store
+	Path string `json:"ATTACHMENT_PATH" validate:"required_if=Store file"`
+	// most bytes of attachments each account may store, 0 for no limit
+	QuotaBytes int64 `json:"ATTACHMENT_QUOTA_BYTES" validate:"min=0"`
+	// S3 configuration used by the s3 blob store, validated only when it is selected
+	S3 S3Config `json:"S3" validate:"-"`
+}
+
+type TrashConfig struct {
+	// days a deleted note stays in the trash before it is purged, 0 to keep it until it is
+	// deleted permanently
+	RetentionDays int `json:"TRASH_RETENTION_DAYS" validate:"min=0"`
+	// how often the server looks for notes to purge
+	PurgeInterval time.Duration `json:"TRASH_PURGE_INTERVAL" validate:"min=1m"`
+}
+
+type URLMatchConfig struct {
+	// groups of base domains treated as one site when matching login URIs, such as google.com
+	// and youtube.com. Nil uses the built-in groups.
+	EquivalentDomains [][]string `json:"EQUIVALENT_DOMAINS" validate:"dive,min=2,dive,fqdn"`
 }
 
 type Config struct {
@@ -48,9 +132,19 @@ type Config struct {
 	// store type to use - postgres, sqlite
 	StoreType string `json:"STORE_TYPE" validate:"required,oneof=postgres sqlite"`
 	// Postgres configuration
-	PostgresOpts PostgresConfig `json:"POSTGRES" validate:"required_if=StoreType postgres"`
+	PostgresOpts PostgresConfig `json:"POSTGRES" validate:"required_if=StoreType postgres,omitempty"`
+	// SQLite configuration
+	SqliteOpts SqliteConfig `json:"SQLITE" validate:"required_if=StoreType sqlite,omitempty"`
 	// Note encryption config
 	Encryption EncryptionConfig `json:"ENCRYPTION" validate:"required"`
+	// Breached password data
+	Breach BreachConfig `json:"BREACH"`
+	// Attachment storage
+	Attachments AttachmentConfig `json:"ATTACHMENTS"`
+	// Deleted note retention
+	Trash TrashConfig `json:"TRASH"`
+	// Login URI matching
+	URLMatch URLMatchConfig `json:"URL_MATCH"`
 }
 
 func New() *Config {
@@ -74,25 +168,103 @@ func New() *Config {
 		PostgresOpts: PostgresConfig{
 			URI: os.Getenv("DB_URI"),
 		},
+		SqliteOpts: SqliteConfig{
+			Path: os.Getenv("SQLITE_PATH"),
+		},
 		Encryption: EncryptionConfig{
-			EncIV:     secretVals["ENCRYPTION_IV"],
-			EncSecret: secretVals["ENCRYPTION_SECRET"],
+			EncIV:           secretVals["ENCRYPTION_IV"],
+			EncSecret:       secretVals["ENCRYPTION_SECRET"],
+			PreviousSecrets: splitList(secretVals["ENCRYPTION_PREVIOUS_SECRETS"]),
+			KeyProvider:     os.Getenv("KEY_PROVIDER"),
+			KeyFile:         os.Getenv("KEY_FILE"),
+			KMS: KMSConfig{
+				URL:            os.Getenv("KMS_URL"),
+				KeyID:          os.Getenv("KMS_KEY_ID"),
+				PreviousKeyIDs: splitList(os.Getenv("KMS_PREVIOUS_KEY_IDS")),
+				Token:          secretVals["KMS_TOKEN"],
+			},
+		},
+		Breach: BreachConfig{
+			Path: os.Getenv("BREACH_DATA_PATH"),
+		},
+		Attachments: AttachmentConfig{
+			Store:      os.Getenv("ATTACHMENT_STORE"),
+			Path:       os.Getenv("ATTACHMENT_PATH"),
+			QuotaBytes: DEFAULT_ATTACHMENT_QUOTA,
+			S3: S3Config{
+				Endpoint:        os.Getenv("S3_ENDPOINT"),
+				Bucket:          os.Getenv("S3_BUCKET"),
+				Region:          os.Getenv("S3_REGION"),
+				AccessKeyID:     os.Getenv("S3_ACCESS_KEY_ID"),
+				SecretAccessKey: secretVals["S3_SECRET_ACCESS_KEY"],
+			},
+		},
+		Trash: TrashConfig{
+			RetentionDays: DEFAULT_TRASH_RETENTION_DAYS,
+			PurgeInterval: DEFAULT_TRASH_PURGE_INTERVAL,
 		},
 	}
 
+	// the env provider matches the behavior from before envelope encryption was introduced
+	if c.Encryption.KeyProvider == "" {
+		c.Encryption.KeyProvider = KEY_PROVIDER_ENV
+	}
+
 	useCSRF, err := strconv.ParseBool(os.Getenv("ENABLE_CSRF_PROTECTION"))
 	if err != nil {
 		panic("Failed to parse value for ENABLE_CSRF_PROTECTION as a bool")
 	}
 	c.EnableCSRFProtection = useCSRF
 
+	if quota := os.Getenv("ATTACHMENT_QUOTA_BYTES"); quota != "" {
+		c.Attachments.QuotaBytes, err = strconv.ParseInt(quota, 10, 64)
+		if err != nil {
+			panic("Failed to parse value for ATTACHMENT_QUOTA_BYTES as an integer")
+		}
+	}
+
+	if pathStyle := os.Getenv("S3_PATH_STYLE"); pathStyle != "" {
+		c.Attachments.S3.PathStyle, err = strconv.ParseBool(pathStyle)
+		if err != nil {
+			panic("Failed to parse value for S3_PATH_STYLE as a bool")
+		}
+	}
+
+	if retention := os.Getenv("TRASH_RETENTION_DAYS"); retention != "" {
+		c.Trash.RetentionDays, err = strconv.Atoi(retention)
+		if err != nil {
+			panic("Failed to parse value for TRASH_RETENTION_DAYS as an integer")
+		}
+	}
+
+	if interval := os.Getenv("TRASH_PURGE_INTERVAL"); interval != "" {
+		c.Trash.PurgeInterval, err = time.ParseDuration(interval)
+		if err != nil {
+			panic("Failed to parse value for TRASH_PURGE_INTERVAL as a duration")
+		}
+	}
+
+	// an empty value turns equivalent domains off rather than using the built-in groups
+	if groups, ok := os.LookupEnv("EQUIVALENT_DOMAINS"); ok {
+		c.URLMatch.EquivalentDomains = [][]string{}
+		for _, group := range strings.Split(groups, ";") {
+			if domains := splitList(group); len(domains) > 0 {
+				c.URLMatch.EquivalentDomains = append(c.URLMatch.EquivalentDomains, domains)
+			}
+		}
+	}
+
+	if c.Attachments.S3.Region == "" {
+		c.Attachments.S3.Region = "us-east-1"
+	}
+
 	return c
 }
 
 func loadSecrets() (map[string]string, error) {
 	loadedVals := make(map[string]string)
 
-	secrets := []string{"SECRET_KEY", "POSTGRES_USER", "POSTGRES_PASSWORD", "CSRF_KEY", "ENCRYPTION_IV", "ENCRYPTION_SECRET"}
+	secrets := []string{"SECRET_KEY", "POSTGRES_USER", "POSTGRES_PASSWORD", "CSRF_KEY", "ENCRYPTION_IV", "ENCRYPTION_SECRET", "ENCRYPTION_PREVIOUS_SECRETS", "KMS_TOKEN", "S3_SECRET_ACCESS_KEY"}
 
 	for _, baseEnvName := range secrets {
 		// default to non-file variable if provided
@@ -118,6 +290,19 @@ func loadSecrets() (map[string]string, error) {
 	return loadedVals, nil
 }
 
+// splitList parses a comma separated list, ignoring empty entries.
+func splitList(val string) []string {
+	var items []string
+
+	for _, item := range strings.Split(val, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+
+	return items
+}
+
 func (c Config) Validate() error {
 	var Validate *validator.Validate = validator.New(validator.WithRequiredStructEnabled())
 
@@ -125,6 +310,18 @@ func (c Config) Validate() error {
 		return err
 	}
 
+	if c.Encryption.KeyProvider == KEY_PROVIDER_KMS {
+		if err := Validate.Struct(c.Encryption.KMS); err != nil {
+			return err
+		}
+	}
+
+	if c.Attachments.Store == BLOB_STORE_S3 {
+		if err := Validate.Struct(c.Attachments.S3); err != nil {
+			return err
+		}
+	}
+
 	if !slices.Contains([]string{LOCAL_ENV, DEV_ENV, STAGE_ENV, PROD_ENV}, c.Env) {
 		return fmt.Errorf("invalid env: %s", c.Env)
 	}
@@ -2,7 +2,10 @@ module github.com/oalexander6/passman
 
 go 1.22.0
 
-require github.com/go-playground/validator/v10 v10.22.0
+require (
+	github.com/go-playground/validator/v10 v10.22.0
+	github.com/makiuchi-d/gozxing v0.1.1
+)
 
 require (
 	github.com/jackc/pgpassfile v1.0.0 // indirect
@@ -11,6 +14,7 @@ require (
 	github.com/mattn/go-colorable v0.1.13 // indirect
 	github.com/mattn/go-isatty v0.0.19 // indirect
 	golang.org/x/sync v0.1.0 // indirect
+	golang.org/x/xerrors v0.0.0-20200804184101-5ec99f83aff1 // indirect
 )
 
 require (
@@ -25,8 +29,8 @@ require (
 	github.com/mattn/go-sqlite3 v1.14.22
 	github.com/rs/zerolog v1.33.0
 	github.com/urfave/negroni v1.0.0
-	golang.org/x/crypto v0.19.0 // indirect
-	golang.org/x/net v0.21.0 // indirect
+	golang.org/x/crypto v0.19.0
+	golang.org/x/net v0.21.0
 	golang.org/x/sys v0.17.0 // indirect
 	golang.org/x/text v0.14.0 // indirect
 )
@@ -0,0 +1,67 @@
+// Package blob stores opaque binary objects, such as encrypted attachment chunks, under string
+// keys. Blobs are written whole and never modified, so implementations only need to put, get
+// and delete them.
+package blob
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/oalexander6/passman/config"
+)
+
+var (
+	ErrNotFound   = errors.New("blob not found")
+	ErrInvalidKey = errors.New("invalid blob key")
+)
+
+// BlobStore stores blobs under keys made of slash separated segments of letters, digits, dots,
+// dashes and underscores.
+type BlobStore interface {
+	// Put stores the size bytes read from r under key, replacing any existing blob.
+	Put(ctx context.Context, key string, r io.Reader, size int64) error
+	// Get opens the blob stored under key. Returns ErrNotFound if there is none.
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	// Delete removes the blob stored under key. Deleting a missing blob is not an error.
+	Delete(ctx context.Context, key string) error
+}
+
+// New creates the blob store selected by the attachment configuration, or returns nil if none
+// is configured.
+func New(c config.AttachmentConfig) (BlobStore, error) {
+	switch c.Store {
+	case "":
+		return nil, nil
+	case config.BLOB_STORE_FILE:
+		return NewFileStore(c.Path)
+	case config.BLOB_STORE_S3:
+		return NewS3Store(c.S3), nil
+	default:
+		return nil, fmt.Errorf("unknown blob store: %s", c.Store)
+	}
+}
+
+// checkKey returns ErrInvalidKey unless every segment of the key is made of safe characters,
+// so keys can be used directly as file paths and URL paths.
+func checkKey(key string) error {
+	if key == "" {
+		return ErrInvalidKey
+	}
+
+	for _, segment := range strings.Split(key, "/") {
+		if segment == "" || segment == "." || segment == ".." {
+			return fmt.Errorf("%w: %q", ErrInvalidKey, key)
+		}
+
+		for _, r := range segment {
+			if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '.' || r == '-' || r == '_') {
+				return fmt.Errorf("%w: %q", ErrInvalidKey, key)
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,147 @@
+package blob
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/oalexander6/passman/config"
+)
+
+// s3RequestTimeout bounds every call to the object store. Blobs are small enough that a whole
+// download fits well within it.
+const s3RequestTimeout = time.Minute
+
+// S3Store keeps blobs as objects in a bucket of an S3-compatible object store, such as AWS S3
+// or MinIO. Requests are signed with Signature Version 4. NewS3Handler implements the same
+// subset of the API and can be used as a local stand-in.
+type S3Store struct {
+	endpoint        *url.URL
+	bucket          string
+	region          string
+	accessKeyID     string
+	secretAccessKey string
+	pathStyle       bool
+	client          *http.Client
+}
+
+// NewS3Store creates a store for the configured bucket.
+func NewS3Store(c config.S3Config) *S3Store {
+	// the URL was validated with the rest of the configuration
+	endpoint, _ := url.Parse(strings.TrimSuffix(c.Endpoint, "/"))
+
+	return &S3Store{
+		endpoint:        endpoint,
+		bucket:          c.Bucket,
+		region:          c.Region,
+		accessKeyID:     c.AccessKeyID,
+		secretAccessKey: c.SecretAccessKey,
+		pathStyle:       c.PathStyle,
+		client:          &http.Client{Timeout: s3RequestTimeout},
+	}
+}
+
+// Put implements BlobStore.
+func (s *S3Store) Put(ctx context.Context, key string, r io.Reader, size int64) error {
+	resp, err := s.do(ctx, http.MethodPut, key, io.LimitReader(r, size), size)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return s3Error(resp)
+	}
+
+	return nil
+}
+
+// Get implements BlobStore.
+func (s *S3Store) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	resp, err := s.do(ctx, http.MethodGet, key, nil, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		return nil, s3Error(resp)
+	}
+
+	return resp.Body, nil
+}
+
+// Delete implements BlobStore.
+func (s *S3Store) Delete(ctx context.Context, key string) error {
+	resp, err := s.do(ctx, http.MethodDelete, key, nil, 0)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK {
+		if err := s3Error(resp); !errors.Is(err, ErrNotFound) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// do sends a signed request for the object stored under key.
+func (s *S3Store) do(ctx context.Context, method string, key string, body io.Reader, size int64) (*http.Response, error) {
+	if err := checkKey(key); err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, s.objectURL(key), body)
+	if err != nil {
+		return nil, err
+	}
+
+	if body != nil {
+		req.ContentLength = size
+		// an empty body must still be sent with a Content-Length of 0
+		if size == 0 {
+			req.Body = http.NoBody
+		}
+	}
+
+	signRequest(req, s.accessKeyID, s.secretAccessKey, s.region, time.Now())
+
+	return s.client.Do(req)
+}
+
+// objectURL returns the URL of the object, with the bucket in the path or the host name.
+func (s *S3Store) objectURL(key string) string {
+	u := *s.endpoint
+
+	if s.pathStyle {
+		u.Path += "/" + s.bucket + "/" + key
+	} else {
+		u.Host = s.bucket + "." + u.Host
+		u.Path += "/" + key
+	}
+
+	return u.String()
+}
+
+// s3Error describes an unexpected response, including the start of its body which holds the
+// S3 error code. Returns ErrNotFound only for a missing key, since a missing bucket is also
+// reported as not found.
+func s3Error(resp *http.Response) error {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+
+	var s3Err s3ErrorResponse
+	if resp.StatusCode == http.StatusNotFound && xml.Unmarshal(body, &s3Err) == nil && s3Err.Code == "NoSuchKey" {
+		return ErrNotFound
+	}
+
+	return fmt.Errorf("object store returned %s: %s", resp.Status, strings.TrimSpace(string(body)))
+}
@@ -0,0 +1,156 @@
+package blob
+
+import (
+	"crypto/subtle"
+	"encoding/xml"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// s3MaxClockSkew is how far a request's signing time may be from the server's clock.
+const s3MaxClockSkew = 15 * time.Minute
+
+// s3ErrorResponse is the XML error body returned by S3.
+type s3ErrorResponse struct {
+	XMLName xml.Name `xml:"Error"`
+	Code    string   `xml:"Code"`
+	Message string   `xml:"Message"`
+}
+
+// NewS3Handler returns an http.Handler implementing the subset of the S3 API used by S3Store,
+// with path style addressing, on top of another store. It is intended as a local stand-in for
+// an S3-compatible object store during development and testing. Requests must be signed with
+// the provided access key.
+func NewS3Handler(store BlobStore, bucket string, region string, accessKeyID string, secretAccessKey string) http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("PUT /{bucket}/{key...}", func(w http.ResponseWriter, r *http.Request) {
+		if !s3Authorize(w, r, bucket, region, accessKeyID, secretAccessKey) {
+			return
+		}
+
+		if r.ContentLength < 0 {
+			s3Respond(w, http.StatusLengthRequired, "MissingContentLength", "You must provide the Content-Length HTTP header.")
+			return
+		}
+
+		if err := store.Put(r.Context(), r.PathValue("key"), r.Body, r.ContentLength); err != nil {
+			s3RespondError(w, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+	})
+
+	mux.HandleFunc("GET /{bucket}/{key...}", func(w http.ResponseWriter, r *http.Request) {
+		if !s3Authorize(w, r, bucket, region, accessKeyID, secretAccessKey) {
+			return
+		}
+
+		body, err := store.Get(r.Context(), r.PathValue("key"))
+		if err != nil {
+			s3RespondError(w, err)
+			return
+		}
+		defer body.Close()
+
+		w.Header().Set("Content-Type", "application/octet-stream")
+		io.Copy(w, body)
+	})
+
+	mux.HandleFunc("DELETE /{bucket}/{key...}", func(w http.ResponseWriter, r *http.Request) {
+		if !s3Authorize(w, r, bucket, region, accessKeyID, secretAccessKey) {
+			return
+		}
+
+		if err := store.Delete(r.Context(), r.PathValue("key")); err != nil {
+			s3RespondError(w, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	return mux
+}
+
+// s3Authorize checks the request's Signature Version 4 signature and bucket.
+func s3Authorize(w http.ResponseWriter, r *http.Request, bucket string, region string, accessKeyID string, secretAccessKey string) bool {
+	credential, signedHeaders, signature, ok := parseAuthorization(r.Header.Get("Authorization"))
+	if !ok {
+		s3Respond(w, http.StatusForbidden, "AccessDenied", "The request is not signed.")
+		return false
+	}
+
+	signedAt, err := time.Parse(sigV4TimeFormat, r.Header.Get(headerAmzDate))
+	if err != nil || time.Since(signedAt).Abs() > s3MaxClockSkew {
+		s3Respond(w, http.StatusForbidden, "RequestTimeTooSkewed", "The difference between the request time and the server's time is too large.")
+		return false
+	}
+
+	if credential != accessKeyID+"/"+sigV4Scope(signedAt, region) {
+		s3Respond(w, http.StatusForbidden, "InvalidAccessKeyId", "The access key ID or credential scope is not recognized.")
+		return false
+	}
+
+	expected := sigV4Signature(r, secretAccessKey, region, signedAt, signedHeaders)
+	if subtle.ConstantTimeCompare([]byte(signature), []byte(expected)) != 1 {
+		s3Respond(w, http.StatusForbidden, "SignatureDoesNotMatch", "The request signature does not match.")
+		return false
+	}
+
+	if r.PathValue("bucket") != bucket {
+		s3Respond(w, http.StatusNotFound, "NoSuchBucket", "The specified bucket does not exist.")
+		return false
+	}
+
+	return true
+}
+
+// parseAuthorization splits a Signature Version 4 Authorization header into its credential,
+// signed headers and signature.
+func parseAuthorization(header string) (string, []string, string, bool) {
+	params, ok := strings.CutPrefix(header, sigV4Algorithm+" ")
+	if !ok {
+		return "", nil, "", false
+	}
+
+	var credential, signedHeaders, signature string
+	for _, param := range strings.Split(params, ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+		switch name {
+		case "Credential":
+			credential = value
+		case "SignedHeaders":
+			signedHeaders = value
+		case "Signature":
+			signature = value
+		}
+	}
+
+	if credential == "" || signedHeaders == "" || signature == "" {
+		return "", nil, "", false
+	}
+
+	return credential, strings.Split(signedHeaders, ";"), signature, true
+}
+
+func s3RespondError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrNotFound):
+		s3Respond(w, http.StatusNotFound, "NoSuchKey", "The specified key does not exist.")
+	case errors.Is(err, ErrInvalidKey):
+		s3Respond(w, http.StatusBadRequest, "InvalidArgument", err.Error())
+	default:
+		s3Respond(w, http.StatusInternalServerError, "InternalError", err.Error())
+	}
+}
+
+func s3Respond(w http.ResponseWriter, status int, code string, message string) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	xml.NewEncoder(w).Encode(s3ErrorResponse{Code: code, Message: message})
+}
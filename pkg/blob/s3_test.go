@@ -0,0 +1,184 @@
+package blob
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/oalexander6/passman/config"
+)
+
+// newTestS3 starts NewS3Handler for a bucket kept in a temporary directory and returns a store
+// configured to use it.
+func newTestS3(t *testing.T) (config.S3Config, *FileStore) {
+	t.Helper()
+
+	files, err := NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := httptest.NewServer(NewS3Handler(files, "passman", "us-east-1", "access-key", "secret-key"))
+	t.Cleanup(server.Close)
+
+	return config.S3Config{
+		Endpoint:        server.URL,
+		Bucket:          "passman",
+		Region:          "us-east-1",
+		AccessKeyID:     "access-key",
+		SecretAccessKey: "secret-key",
+		PathStyle:       true,
+	}, files
+}
+
+func getBlob(ctx context.Context, store BlobStore, key string) ([]byte, error) {
+	body, err := store.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	return io.ReadAll(body)
+}
+
+func TestS3StoreRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	c, files := newTestS3(t)
+	store := NewS3Store(c)
+
+	tests := []struct {
+		name     string
+		key      string
+		contents []byte
+	}{
+		{"small", "attachments/1/2/0", []byte("encrypted chunk")},
+		{"empty", "attachments/1/2/1", []byte{}},
+		{"large", "attachments/1/3/0", bytes.Repeat([]byte{0xab}, 1<<20+28)},
+		{"escaped characters", "attachments/a.b-c_d/0", []byte("escaped")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := store.Put(ctx, tt.key, bytes.NewReader(tt.contents), int64(len(tt.contents))); err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := getBlob(ctx, store, tt.key)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !bytes.Equal(got, tt.contents) {
+				t.Fatalf("expected %d bytes, got %d", len(tt.contents), len(got))
+			}
+
+			// the object is stored in the bucket under its key
+			stored, err := getBlob(ctx, files, tt.key)
+			if err != nil || !bytes.Equal(stored, tt.contents) {
+				t.Fatalf("expected the object to be stored under %s, got %v", tt.key, err)
+			}
+
+			if err := store.Delete(ctx, tt.key); err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err := store.Get(ctx, tt.key); !errors.Is(err, ErrNotFound) {
+				t.Fatalf("expected ErrNotFound after deleting, got %v", err)
+			}
+		})
+	}
+
+	// only size bytes are sent
+	if err := store.Put(ctx, "limited", strings.NewReader("abcdef"), 3); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, err := getBlob(ctx, store, "limited"); err != nil || string(got) != "abc" {
+		t.Fatalf("expected abc, got %q and %v", got, err)
+	}
+}
+
+func TestS3StoreMissing(t *testing.T) {
+	ctx := context.Background()
+	c, _ := newTestS3(t)
+	store := NewS3Store(c)
+
+	if _, err := store.Get(ctx, "missing"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+
+	if err := store.Delete(ctx, "missing"); err != nil {
+		t.Fatalf("expected deleting a missing object to succeed, got %v", err)
+	}
+
+	for _, key := range []string{"", "../escape", "a//b", "a/./b", "spaces not allowed"} {
+		if err := store.Put(ctx, key, strings.NewReader("x"), 1); !errors.Is(err, ErrInvalidKey) {
+			t.Errorf("%q: expected ErrInvalidKey, got %v", key, err)
+		}
+	}
+}
+
+func TestS3StoreRejected(t *testing.T) {
+	ctx := context.Background()
+	c, _ := newTestS3(t)
+
+	tests := []struct {
+		name   string
+		modify func(c *config.S3Config)
+		code   string
+	}{
+		{"wrong secret key", func(c *config.S3Config) { c.SecretAccessKey = "wrong" }, "SignatureDoesNotMatch"},
+		{"unknown access key", func(c *config.S3Config) { c.AccessKeyID = "other" }, "InvalidAccessKeyId"},
+		{"wrong region", func(c *config.S3Config) { c.Region = "eu-west-1" }, "InvalidAccessKeyId"},
+		{"unknown bucket", func(c *config.S3Config) { c.Bucket = "other" }, "NoSuchBucket"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			modified := c
+			tt.modify(&modified)
+			store := NewS3Store(modified)
+
+			err := store.Put(ctx, "key", strings.NewReader("x"), 1)
+			if err == nil || !strings.Contains(err.Error(), tt.code) {
+				t.Fatalf("expected a put to fail with %s, got %v", tt.code, err)
+			}
+
+			_, err = store.Get(ctx, "key")
+			if err == nil || errors.Is(err, ErrNotFound) || !strings.Contains(err.Error(), tt.code) {
+				t.Fatalf("expected a get to fail with %s, got %v", tt.code, err)
+			}
+
+			if err := store.Delete(ctx, "key"); err == nil || !strings.Contains(err.Error(), tt.code) {
+				t.Fatalf("expected a delete to fail with %s, got %v", tt.code, err)
+			}
+		})
+	}
+}
+
+func TestS3StoreObjectURL(t *testing.T) {
+	tests := []struct {
+		name      string
+		endpoint  string
+		pathStyle bool
+		expected  string
+	}{
+		{"path style", "http://localhost:9000", true, "http://localhost:9000/passman/attachments/1/0"},
+		{"path style with trailing slash", "http://localhost:9000/", true, "http://localhost:9000/passman/attachments/1/0"},
+		{"path style with base path", "https://minio.example.com/s3", true, "https://minio.example.com/s3/passman/attachments/1/0"},
+		{"virtual host", "https://s3.us-east-1.amazonaws.com", false, "https://passman.s3.us-east-1.amazonaws.com/attachments/1/0"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := NewS3Store(config.S3Config{Endpoint: tt.endpoint, Bucket: "passman", PathStyle: tt.pathStyle})
+			if url := store.objectURL("attachments/1/0"); url != tt.expected {
+				t.Fatalf("expected %s, got %s", tt.expected, url)
+			}
+		})
+	}
+}
@@ -0,0 +1,70 @@
+// Package breach checks passwords against a local copy of the Have I Been Pwned Pwned Passwords
+// corpus, so they never have to be sent to a third party. Two layouts of the SHA-1 data are
+// supported:
+//   - a single file of HASH:COUNT lines sorted by hash, as published in the "ordered by hash"
+//     download, which is searched with a binary search over byte offsets
+//   - a directory of range files named by the first five characters of the hash (with or
+//     without a .txt extension), each holding SUFFIX:COUNT lines, as produced by the official
+//     downloader
+//
+// Neither layout is read into memory, so the corpus can be many gigabytes.
+package breach
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const (
+	hashLength   = sha1.Size * 2
+	prefixLength = 5
+)
+
+var ErrInvalidData = errors.New("invalid breach data")
+
+// Checker reports how often passwords appear in a breach corpus.
+type Checker interface {
+	// Count returns how many times the password appears in the corpus, or 0 if it doesn't.
+	Count(password string) (int, error)
+	Close() error
+}
+
+// Open returns a Checker for the sorted hash file or range file directory at path.
+func Open(path string) (Checker, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if info.IsDir() {
+		return newRangeDirectory(path)
+	}
+
+	return newSortedFile(path, info.Size())
+}
+
+// hashPassword returns the upper case hex SHA-1 hash of the password, matching the corpus.
+func hashPassword(password string) string {
+	sum := sha1.Sum([]byte(password))
+	return strings.ToUpper(hex.EncodeToString(sum[:]))
+}
+
+// parseLine splits a HASH:COUNT or SUFFIX:COUNT line. Lines may end with \r\n.
+func parseLine(line string) (string, int, error) {
+	hash, countStr, ok := strings.Cut(strings.TrimRight(line, "\r\n"), ":")
+	if !ok {
+		return "", 0, fmt.Errorf("%w: %q is not in HASH:COUNT form", ErrInvalidData, line)
+	}
+
+	count, err := strconv.Atoi(countStr)
+	if err != nil {
+		return "", 0, fmt.Errorf("%w: %q has an invalid count", ErrInvalidData, line)
+	}
+
+	return strings.ToUpper(hash), count, nil
+}
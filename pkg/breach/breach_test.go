@@ -0,0 +1,193 @@
+package breach
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+const corpusSize = 5000
+
+type corpusEntry struct {
+	password string
+	hash     string
+	count    int
+}
+
+// testCorpus returns corpusSize passwords sorted by hash, each with its own count.
+func testCorpus() []corpusEntry {
+	entries := make([]corpusEntry, corpusSize)
+	for i := range entries {
+		password := fmt.Sprintf("password-%d", i)
+		entries[i] = corpusEntry{password: password, hash: hashPassword(password), count: i + 1}
+	}
+
+	sort.Slice(entries, func(i, j int) bool { return entries[i].hash < entries[j].hash })
+
+	return entries
+}
+
+// writeSortedFile writes the entries as HASH:COUNT lines, and returns the path of the file.
+func writeSortedFile(t *testing.T, entries []corpusEntry, newline string, trailingNewline bool) string {
+	t.Helper()
+
+	var b strings.Builder
+	for i, entry := range entries {
+		fmt.Fprintf(&b, "%s:%d", entry.hash, entry.count)
+		if i < len(entries)-1 || trailingNewline {
+			b.WriteString(newline)
+		}
+	}
+
+	path := filepath.Join(t.TempDir(), "pwned-passwords-sha1-ordered-by-hash.txt")
+	if err := os.WriteFile(path, []byte(b.String()), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func openChecker(t *testing.T, path string) Checker {
+	t.Helper()
+
+	checker, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { checker.Close() })
+
+	return checker
+}
+
+func expectCount(t *testing.T, checker Checker, password string, expected int) {
+	t.Helper()
+
+	count, err := checker.Count(password)
+	if err != nil {
+		t.Fatalf("%s: %v", password, err)
+	}
+
+	if count != expected {
+		t.Fatalf("%s: expected %d, got %d", password, expected, count)
+	}
+}
+
+func TestSortedFile(t *testing.T) {
+	corpus := testCorpus()
+
+	// the first and last hashes are left out of the file, to look up hashes before its first
+	// line and after its last one
+	first, last, entries := corpus[0], corpus[len(corpus)-1], corpus[1:len(corpus)-1]
+
+	tests := []struct {
+		name            string
+		newline         string
+		trailingNewline bool
+	}{
+		{name: "LF", newline: "\n", trailingNewline: true},
+		{name: "CRLF", newline: "\r\n", trailingNewline: true},
+		{name: "no trailing newline", newline: "\n"},
+		{name: "CRLF without trailing newline", newline: "\r\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checker := openChecker(t, writeSortedFile(t, entries, tt.newline, tt.trailingNewline))
+
+			for _, entry := range entries {
+				expectCount(t, checker, entry.password, entry.count)
+			}
+
+			expectCount(t, checker, first.password, 0)
+			expectCount(t, checker, last.password, 0)
+			expectCount(t, checker, "not in the corpus", 0)
+		})
+	}
+}
+
+func TestSortedFileSingleLine(t *testing.T) {
+	corpus := testCorpus()
+
+	checker := openChecker(t, writeSortedFile(t, corpus[:1], "\n", false))
+
+	expectCount(t, checker, corpus[0].password, corpus[0].count)
+	expectCount(t, checker, corpus[1].password, 0)
+}
+
+func TestSortedFileEmpty(t *testing.T) {
+	checker := openChecker(t, writeSortedFile(t, nil, "\n", false))
+
+	expectCount(t, checker, "password", 0)
+}
+
+func TestSortedFileInvalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "passwords.txt")
+	if err := os.WriteFile(path, []byte("password\nhunter2\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Open(path); !errors.Is(err, ErrInvalidData) {
+		t.Fatalf("expected ErrInvalidData, got %v", err)
+	}
+}
+
+// writeRangeDirectory writes the entries as range files, alternating between names with and
+// without a .txt extension and between LF and CRLF lines, and returns the directory.
+func writeRangeDirectory(t *testing.T, entries []corpusEntry) string {
+	t.Helper()
+
+	ranges := map[string][]string{}
+	prefixes := []string{}
+	for _, entry := range entries {
+		prefix := entry.hash[:prefixLength]
+		if _, ok := ranges[prefix]; !ok {
+			prefixes = append(prefixes, prefix)
+		}
+		ranges[prefix] = append(ranges[prefix], fmt.Sprintf("%s:%d", entry.hash[prefixLength:], entry.count))
+	}
+
+	dir := t.TempDir()
+	for i, prefix := range prefixes {
+		name, newline := prefix, "\n"
+		if i%2 == 0 {
+			name, newline = prefix+".txt", "\r\n"
+		}
+
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(strings.Join(ranges[prefix], newline)+newline), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return dir
+}
+
+func TestRangeDirectory(t *testing.T) {
+	corpus := testCorpus()
+	missing, entries := corpus[len(corpus)/2], append(append([]corpusEntry{}, corpus[:len(corpus)/2]...), corpus[len(corpus)/2+1:]...)
+
+	checker := openChecker(t, writeRangeDirectory(t, entries))
+
+	for _, entry := range entries {
+		expectCount(t, checker, entry.password, entry.count)
+	}
+
+	expectCount(t, checker, corpus[0].password, corpus[0].count)
+	expectCount(t, checker, corpus[len(corpus)-1].password, corpus[len(corpus)-1].count)
+	expectCount(t, checker, missing.password, 0)
+	expectCount(t, checker, "not in the corpus", 0)
+}
+
+func TestRangeDirectoryWithoutRanges(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "README.txt"), []byte("hello"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Open(dir); !errors.Is(err, ErrInvalidData) {
+		t.Fatalf("expected ErrInvalidData, got %v", err)
+	}
+}
@@ -0,0 +1,98 @@
+package breach
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// rangeDirectory looks up hashes in a directory of range files, where the file named by the
+// first five characters of a hash lists the remaining characters of every hash with that prefix.
+// The file names act as the index, and each range file is only a few kilobytes.
+type rangeDirectory struct {
+	path string
+}
+
+func newRangeDirectory(path string) (*rangeDirectory, error) {
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return nil, err
+	}
+
+	// catch the wrong directory at startup rather than on the first lookup
+	for _, entry := range entries {
+		if name := entry.Name(); !entry.IsDir() && isRangeFileName(name) {
+			return &rangeDirectory{path: path}, nil
+		}
+	}
+
+	return nil, fmt.Errorf("%w: %s contains no range files", ErrInvalidData, path)
+}
+
+// Count implements Checker.
+func (d *rangeDirectory) Count(password string) (int, error) {
+	hash := hashPassword(password)
+	prefix, suffix := hash[:prefixLength], hash[prefixLength:]
+
+	file, err := d.openRange(prefix)
+	if errors.Is(err, fs.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		lineSuffix, count, err := parseLine(scanner.Text())
+		if err != nil {
+			return 0, err
+		}
+
+		// range files are sorted, so the suffix can't appear after a larger one
+		if lineSuffix == suffix {
+			return count, nil
+		}
+		if lineSuffix > suffix {
+			break
+		}
+	}
+
+	return 0, scanner.Err()
+}
+
+// Close implements Checker.
+func (d *rangeDirectory) Close() error {
+	return nil
+}
+
+func (d *rangeDirectory) openRange(prefix string) (*os.File, error) {
+	file, err := os.Open(filepath.Join(d.path, prefix+".txt"))
+	if errors.Is(err, fs.ErrNotExist) {
+		file, err = os.Open(filepath.Join(d.path, prefix))
+	}
+
+	return file, err
+}
+
+func isRangeFileName(name string) bool {
+	if ext := filepath.Ext(name); ext == ".txt" {
+		name = name[:len(name)-len(ext)]
+	}
+
+	if len(name) != prefixLength {
+		return false
+	}
+
+	for _, c := range name {
+		if !(c >= '0' && c <= '9' || c >= 'A' && c <= 'F' || c >= 'a' && c <= 'f') {
+			return false
+		}
+	}
+
+	return true
+}
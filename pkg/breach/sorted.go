@@ -0,0 +1,160 @@
+package breach
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+)
+
+// maxLineLength is well over the length of a HASH:COUNT line, and bounds each read.
+const maxLineLength = 128
+
+// sortedFile searches a file of HASH:COUNT lines sorted by hash. Every lookup reads about
+// log2(size) lines with ReadAt, so it is safe for concurrent use.
+type sortedFile struct {
+	file *os.File
+	size int64
+}
+
+func newSortedFile(path string, size int64) (*sortedFile, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &sortedFile{file: file, size: size}
+
+	// catch the wrong kind of file at startup rather than on the first lookup
+	if size > 0 {
+		line, err := s.lineAtOrAfter(0)
+		if err == nil {
+			_, _, err = s.parseHashLine(line)
+		}
+		if err != nil {
+			file.Close()
+			return nil, err
+		}
+	}
+
+	return s, nil
+}
+
+// Count implements Checker.
+func (s *sortedFile) Count(password string) (int, error) {
+	target := hashPassword(password)
+
+	// find the first offset whose line is at or after the target. Every offset maps to the
+	// first line starting at or after it, so the predicate only ever changes from false to true.
+	var searchErr error
+	offset := sort.Search(int(s.size), func(offset int) bool {
+		if searchErr != nil {
+			return true
+		}
+
+		line, err := s.lineAtOrAfter(int64(offset))
+		if err == io.EOF {
+			return true
+		}
+		if err != nil {
+			searchErr = err
+			return true
+		}
+
+		hash, _, err := s.parseHashLine(line)
+		if err != nil {
+			searchErr = err
+			return true
+		}
+
+		return hash >= target
+	})
+	if searchErr != nil {
+		return 0, searchErr
+	}
+
+	line, err := s.lineAtOrAfter(int64(offset))
+	if err == io.EOF {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	hash, count, err := s.parseHashLine(line)
+	if err != nil || hash != target {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+// Close implements Checker.
+func (s *sortedFile) Close() error {
+	return s.file.Close()
+}
+
+// lineAtOrAfter returns the first complete line that starts at or after offset. Returns io.EOF
+// if there is none.
+func (s *sortedFile) lineAtOrAfter(offset int64) (string, error) {
+	start := offset
+	if offset > 0 {
+		// the line starts after the first newline at or after offset-1
+		buf, err := s.readAt(offset-1, maxLineLength)
+		if err != nil {
+			return "", err
+		}
+
+		newline := bytes.IndexByte(buf, '\n')
+		if newline < 0 {
+			if offset-1+int64(len(buf)) >= s.size {
+				return "", io.EOF
+			}
+			return "", fmt.Errorf("%w: line longer than %d bytes near offset %d", ErrInvalidData, maxLineLength, offset)
+		}
+
+		start = offset + int64(newline)
+	}
+
+	if start >= s.size {
+		return "", io.EOF
+	}
+
+	buf, err := s.readAt(start, maxLineLength)
+	if err != nil {
+		return "", err
+	}
+
+	if newline := bytes.IndexByte(buf, '\n'); newline >= 0 {
+		buf = buf[:newline]
+	} else if start+int64(len(buf)) < s.size {
+		return "", fmt.Errorf("%w: line longer than %d bytes at offset %d", ErrInvalidData, maxLineLength, start)
+	}
+
+	return string(buf), nil
+}
+
+func (s *sortedFile) readAt(offset int64, length int) ([]byte, error) {
+	buf := make([]byte, length)
+
+	n, err := s.file.ReadAt(buf, offset)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	return buf[:n], nil
+}
+
+func (s *sortedFile) parseHashLine(line string) (string, int, error) {
+	hash, count, err := parseLine(line)
+	if err != nil {
+		return "", 0, err
+	}
+
+	if len(hash) != hashLength {
+		return "", 0, fmt.Errorf("%w: %q does not start with a SHA-1 hash", ErrInvalidData, line)
+	}
+
+	return hash, count, nil
+}
@@ -0,0 +1,117 @@
+package httpserver
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/oalexander6/passman/pkg/models"
+)
+
+func (s *Server) handleAccountRegister(w http.ResponseWriter, r *http.Request) {
+	var input models.AccountCreateRequest
+	if err := decodeAndValidate(w, r, &input); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	account, err := s.models.AccountRegister(r.Context(), input)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, account)
+}
+
+func (s *Server) handleAccountLogin(w http.ResponseWriter, r *http.Request) {
+	var input models.AccountLoginRequest
+	if err := decodeAndValidate(w, r, &input); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	account, err := s.models.AccountLogin(r.Context(), input)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	epoch, err := s.models.AccountSessionEpoch(r.Context(), account.ID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	if err := s.setSessionCookie(w, account.ID, epoch); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, account)
+}
+
+func (s *Server) handleAccountPrelogin(w http.ResponseWriter, r *http.Request) {
+	var input models.AccountPreloginRequest
+	if err := decodeAndValidate(w, r, &input); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	prelogin, err := s.models.AccountPrelogin(r.Context(), input)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, prelogin)
+}
+
+// handleAccountLogout clears the session cookie and, if the request has a valid session, ends
+// every session of its account so a copy of the cookie can't be used either.
+func (s *Server) handleAccountLogout(w http.ResponseWriter, r *http.Request) {
+	s.clearSessionCookie(w)
+
+	sess, err := s.authenticate(r)
+	if err == nil {
+		err = s.models.AccountLogout(r.Context(), sess.AccountID)
+	}
+	if err != nil && !errors.Is(err, errUnauthorized) && !errors.Is(err, models.ErrNotFound) {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, models.SuccessResponse{Success: true})
+}
+
+func (s *Server) handleAccountMe(w http.ResponseWriter, r *http.Request) {
+	account, err := s.models.AccountGetByID(r.Context(), accountIDFromContext(r.Context()))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, account)
+}
+
+func (s *Server) handleAccountUpdatePolicy(w http.ResponseWriter, r *http.Request) {
+	account, err := s.models.AccountGetByID(r.Context(), accountIDFromContext(r.Context()))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	// rules left out of the request keep their current values
+	input := account.Policy
+	if err := decodeAndValidate(w, r, &input); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	policy, err := s.models.AccountUpdatePolicy(r.Context(), accountIDFromContext(r.Context()), input)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, policy)
+}
@@ -0,0 +1,140 @@
+package httpserver
+
+import (
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+
+	"github.com/oalexander6/passman/pkg/logger"
+	"github.com/oalexander6/passman/pkg/models"
+)
+
+// attachmentFormField is the multipart form field holding an uploaded file.
+const attachmentFormField = "file"
+
+func (s *Server) handleAttachmentCreate(w http.ResponseWriter, r *http.Request) {
+	noteID, err := pathID(r, "id")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	// the file is streamed from the request, so it must be read before any other part
+	reader, err := r.MultipartReader()
+	if err != nil {
+		writeError(w, errInvalidBody)
+		return
+	}
+
+	var part io.ReadCloser
+	var input models.AttachmentCreateRequest
+	for {
+		p, err := reader.NextPart()
+		if err != nil {
+			writeError(w, errInvalidBody)
+			return
+		}
+
+		if p.FormName() == attachmentFormField {
+			part = p
+			input.Name = p.FileName()
+			input.ContentType = p.Header.Get("Content-Type")
+			break
+		}
+	}
+	defer part.Close()
+
+	if err := validate.Struct(input); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	attachment, err := s.models.AttachmentCreate(r.Context(), accountIDFromContext(r.Context()), noteID, input, part)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, attachment)
+}
+
+func (s *Server) handleAttachmentList(w http.ResponseWriter, r *http.Request) {
+	noteID, err := pathID(r, "id")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	attachments, err := s.models.AttachmentGetByNoteID(r.Context(), accountIDFromContext(r.Context()), noteID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, attachments)
+}
+
+func (s *Server) handleAttachmentGet(w http.ResponseWriter, r *http.Request) {
+	noteID, err := pathID(r, "id")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	attachmentID, err := pathID(r, "attachmentID")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	attachment, body, err := s.models.AttachmentOpen(r.Context(), accountIDFromContext(r.Context()), noteID, attachmentID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	defer body.Close()
+
+	w.Header().Set("Content-Type", attachment.ContentType)
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": attachment.Name}))
+	w.Header().Set("Content-Length", strconv.FormatInt(attachment.Size, 10))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(http.StatusOK)
+
+	// the status is already sent, so a failure part way can only cut the response short
+	if _, err := io.Copy(w, body); err != nil && !errors.Is(err, r.Context().Err()) {
+		logger.Log.Error().Msgf("Failed to send attachment %d: %s", attachment.ID, err)
+	}
+}
+
+func (s *Server) handleAttachmentDelete(w http.ResponseWriter, r *http.Request) {
+	noteID, err := pathID(r, "id")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	attachmentID, err := pathID(r, "attachmentID")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	if err := s.models.AttachmentDelete(r.Context(), accountIDFromContext(r.Context()), noteID, attachmentID); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleAttachmentUsage(w http.ResponseWriter, r *http.Request) {
+	usage, err := s.models.AttachmentUsage(r.Context(), accountIDFromContext(r.Context()))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, usage)
+}
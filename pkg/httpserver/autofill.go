@@ -0,0 +1,21 @@
+package httpserver
+
+import (
+	"net/http"
+)
+
+func (s *Server) handleNoteMatch(w http.ResponseWriter, r *http.Request) {
+	reveal, err := queryBool(r, "reveal")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	matches, err := s.models.NoteMatchURL(r.Context(), accountIDFromContext(r.Context()), r.URL.Query().Get("url"), reveal)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, matches)
+}
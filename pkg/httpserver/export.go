@@ -0,0 +1,33 @@
+package httpserver
+
+import (
+	"net/http"
+
+	"github.com/oalexander6/passman/pkg/models"
+)
+
+func (s *Server) handleVaultExport(w http.ResponseWriter, r *http.Request) {
+	export, err := s.models.VaultExport(r.Context(), accountIDFromContext(r.Context()))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, export)
+}
+
+func (s *Server) handleVaultImport(w http.ResponseWriter, r *http.Request) {
+	var input models.VaultExport
+	if err := decodeAndValidateLimit(w, r, &input, maxImportBodySize); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	imported, err := s.models.VaultImport(r.Context(), accountIDFromContext(r.Context()), input)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, imported)
+}
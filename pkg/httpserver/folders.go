@@ -0,0 +1,75 @@
+package httpserver
+
+import (
+	"net/http"
+
+	"github.com/oalexander6/passman/pkg/models"
+)
+
+func (s *Server) handleFolderList(w http.ResponseWriter, r *http.Request) {
+	folders, err := s.models.FolderGetByAccountID(r.Context(), accountIDFromContext(r.Context()))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, folders)
+}
+
+func (s *Server) handleFolderCreate(w http.ResponseWriter, r *http.Request) {
+	var input models.FolderRequest
+	if err := decodeAndValidate(w, r, &input); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	folder, err := s.models.FolderCreate(r.Context(), accountIDFromContext(r.Context()), input)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, folder)
+}
+
+func (s *Server) handleFolderUpdate(w http.ResponseWriter, r *http.Request) {
+	folderID, err := pathID(r, "id")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	var input models.FolderRequest
+	if err := decodeAndValidate(w, r, &input); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	folder, err := s.models.FolderUpdate(r.Context(), accountIDFromContext(r.Context()), folderID, input)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, folder)
+}
+
+func (s *Server) handleFolderDelete(w http.ResponseWriter, r *http.Request) {
+	folderID, err := pathID(r, "id")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	mode := r.URL.Query().Get("mode")
+	if mode == "" {
+		mode = models.FolderDeleteModeParent
+	}
+
+	if err := s.models.FolderDelete(r.Context(), accountIDFromContext(r.Context()), folderID, mode); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
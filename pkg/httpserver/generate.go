@@ -0,0 +1,23 @@
+package httpserver
+
+import (
+	"net/http"
+
+	"github.com/oalexander6/passman/pkg/models"
+)
+
+func (s *Server) handlePasswordGenerate(w http.ResponseWriter, r *http.Request) {
+	var input models.PasswordGenerateRequest
+	if err := decodeAndValidate(w, r, &input); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	generated, err := s.models.GeneratePassword(input)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, generated)
+}
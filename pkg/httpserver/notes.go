@@ -0,0 +1,117 @@
+package httpserver
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/oalexander6/passman/pkg/models"
+)
+
+func (s *Server) handleNoteList(w http.ResponseWriter, r *http.Request) {
+	reveal, err := queryBool(r, "reveal")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	recursive, err := queryBool(r, "recursive")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	input := models.NoteListRequest{Recursive: recursive, Tags: r.URL.Query()["tag"]}
+
+	if val := r.URL.Query().Get("folder_id"); val != "" {
+		folderID, err := strconv.ParseInt(val, 10, 64)
+		if err != nil || folderID < 0 {
+			writeError(w, fmt.Errorf("%w: folder_id must be a folder ID, or 0 for notes outside folders", errInvalidQuery))
+			return
+		}
+		input.FolderID = &folderID
+	}
+
+	notes, err := s.models.NoteGetByAccountID(r.Context(), accountIDFromContext(r.Context()), input, reveal)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, notes)
+}
+
+func (s *Server) handleNoteGet(w http.ResponseWriter, r *http.Request) {
+	noteID, err := pathID(r, "id")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	reveal, err := queryBool(r, "reveal")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	note, err := s.models.NoteGetByID(r.Context(), accountIDFromContext(r.Context()), noteID, reveal)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, note)
+}
+
+func (s *Server) handleNoteCreate(w http.ResponseWriter, r *http.Request) {
+	var input models.NoteCreateRequest
+	if err := decodeAndValidate(w, r, &input); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	note, err := s.models.NoteCreate(r.Context(), accountIDFromContext(r.Context()), input)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, note)
+}
+
+func (s *Server) handleNoteUpdate(w http.ResponseWriter, r *http.Request) {
+	noteID, err := pathID(r, "id")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	var input models.NoteCreateRequest
+	if err := decodeAndValidate(w, r, &input); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	note, err := s.models.NoteUpdate(r.Context(), accountIDFromContext(r.Context()), noteID, input)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, note)
+}
+
+func (s *Server) handleNoteDelete(w http.ResponseWriter, r *http.Request) {
+	noteID, err := pathID(r, "id")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	if err := s.models.NoteDeleteByID(r.Context(), accountIDFromContext(r.Context()), noteID); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, models.SuccessResponse{Success: true})
+}
@@ -0,0 +1,42 @@
+package httpserver
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+)
+
+// maxQRImageSize limits the size of QR code images accepted for scanning.
+const maxQRImageSize = 5 << 20
+
+func (s *Server) handleNoteOTP(w http.ResponseWriter, r *http.Request) {
+	noteID, err := pathID(r, "id")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	code, err := s.models.NoteOTP(r.Context(), accountIDFromContext(r.Context()), noteID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, code)
+}
+
+func (s *Server) handleOTPScan(w http.ResponseWriter, r *http.Request) {
+	image, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxQRImageSize))
+	if err != nil {
+		writeError(w, errInvalidBody)
+		return
+	}
+
+	key, err := s.models.OTPScan(bytes.NewReader(image))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, key)
+}
@@ -0,0 +1,43 @@
+package httpserver
+
+import (
+	"net/http"
+
+	"github.com/oalexander6/passman/pkg/models"
+)
+
+// defaultMaxAgeDays is how long a note can go without being updated before the health report
+// calls it old, unless the request says otherwise.
+const defaultMaxAgeDays = 365
+
+func (s *Server) handleBreachReport(w http.ResponseWriter, r *http.Request) {
+	report, err := s.models.BreachReport(r.Context(), accountIDFromContext(r.Context()))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, report)
+}
+
+func (s *Server) handleHealthReport(w http.ResponseWriter, r *http.Request) {
+	maxAgeDays, err := queryInt(r, "max_age_days", defaultMaxAgeDays)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	input := models.HealthReportRequest{MaxAgeDays: maxAgeDays}
+	if err := validate.Struct(input); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	report, err := s.models.HealthReport(r.Context(), accountIDFromContext(r.Context()), input)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, report)
+}
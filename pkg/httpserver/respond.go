@@ -0,0 +1,127 @@
+package httpserver
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/oalexander6/passman/pkg/logger"
+	"github.com/oalexander6/passman/pkg/models"
+)
+
+const (
+	// maxRequestBodySize limits the size of JSON request bodies accepted by the API.
+	maxRequestBodySize = 1 << 20
+
+	// maxImportBodySize limits the size of vault imports, which hold every note in a vault.
+	maxImportBodySize = 64 << 20
+)
+
+var (
+	errInvalidBody  = errors.New("invalid request body")
+	errInvalidID    = errors.New("invalid id")
+	errInvalidQuery = errors.New("invalid query parameter")
+)
+
+var validate = validator.New(validator.WithRequiredStructEnabled())
+
+// decodeAndValidate reads the JSON request body into dst and checks it against its
+// validator struct tags.
+func decodeAndValidate(w http.ResponseWriter, r *http.Request, dst any) error {
+	return decodeAndValidateLimit(w, r, dst, maxRequestBodySize)
+}
+
+// decodeAndValidateLimit is decodeAndValidate for request bodies of up to limit bytes.
+func decodeAndValidateLimit(w http.ResponseWriter, r *http.Request, dst any, limit int64) error {
+	r.Body = http.MaxBytesReader(w, r.Body, limit)
+
+	if err := json.NewDecoder(r.Body).Decode(dst); err != nil {
+		return errInvalidBody
+	}
+
+	return validate.Struct(dst)
+}
+
+// pathID parses the named path value as a positive int64 ID.
+func pathID(r *http.Request, name string) (int64, error) {
+	id, err := strconv.ParseInt(r.PathValue(name), 10, 64)
+	if err != nil || id <= 0 {
+		return 0, errInvalidID
+	}
+
+	return id, nil
+}
+
+// queryInt parses the named query parameter as an int, returning fallback if it is not set.
+func queryInt(r *http.Request, name string, fallback int) (int, error) {
+	val := r.URL.Query().Get(name)
+	if val == "" {
+		return fallback, nil
+	}
+
+	n, err := strconv.Atoi(val)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %s must be an integer", errInvalidQuery, name)
+	}
+
+	return n, nil
+}
+
+// queryBool parses the named query parameter as a bool, returning false if it is not set.
+func queryBool(r *http.Request, name string) (bool, error) {
+	val := r.URL.Query().Get(name)
+	if val == "" {
+		return false, nil
+	}
+
+	b, err := strconv.ParseBool(val)
+	if err != nil {
+		return false, fmt.Errorf("%w: %s must be true or false", errInvalidQuery, name)
+	}
+
+	return b, nil
+}
+
+// writeJSON writes the provided value as a JSON response body with the given status code.
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		logger.Log.Error().Msgf("Failed to write response: %s", err)
+	}
+}
+
+// writeError maps the provided error to a status code and writes it as a JSON error
+// response. Unexpected errors are logged and hidden from the client.
+func writeError(w http.ResponseWriter, err error) {
+	var validationErrs validator.ValidationErrors
+
+	switch {
+	case errors.As(err, &validationErrs):
+		writeJSON(w, http.StatusBadRequest, models.ErrorResponse{Error: validationErrs.Error()})
+	case errors.Is(err, errInvalidBody), errors.Is(err, errInvalidID), errors.Is(err, errInvalidQuery),
+		errors.Is(err, models.ErrInvalidInput), errors.Is(err, models.ErrZeroKnowledgeVault):
+		writeJSON(w, http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+	case errors.Is(err, errUnauthorized), errors.Is(err, models.ErrInvalidCredentials):
+		writeJSON(w, http.StatusUnauthorized, models.ErrorResponse{Error: err.Error()})
+	case errors.Is(err, models.ErrReadOnlyShare):
+		writeJSON(w, http.StatusForbidden, models.ErrorResponse{Error: err.Error()})
+	case errors.Is(err, models.ErrNotFound):
+		writeJSON(w, http.StatusNotFound, models.ErrorResponse{Error: err.Error()})
+	case errors.Is(err, models.ErrAlreadyExists):
+		writeJSON(w, http.StatusConflict, models.ErrorResponse{Error: err.Error()})
+	case errors.Is(err, models.ErrWeakPassword):
+		writeJSON(w, http.StatusUnprocessableEntity, models.ErrorResponse{Error: err.Error()})
+	case errors.Is(err, models.ErrQuotaExceeded):
+		writeJSON(w, http.StatusRequestEntityTooLarge, models.ErrorResponse{Error: err.Error()})
+	case errors.Is(err, models.ErrBreachCheckUnavailable), errors.Is(err, models.ErrAttachmentsUnavailable):
+		writeJSON(w, http.StatusServiceUnavailable, models.ErrorResponse{Error: err.Error()})
+	default:
+		logger.Log.Error().Msgf("Request failed: %s", err)
+		writeJSON(w, http.StatusInternalServerError, models.ErrorResponse{Error: http.StatusText(http.StatusInternalServerError)})
+	}
+}
@@ -0,0 +1,71 @@
+package httpserver
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/oalexander6/passman/pkg/models"
+)
+
+func TestWriteError(t *testing.T) {
+	type validated struct {
+		Name string `validate:"required"`
+	}
+
+	tests := []struct {
+		name   string
+		err    error
+		status int
+	}{
+		{"validation", validate.Struct(validated{}), http.StatusBadRequest},
+		{"invalid body", errInvalidBody, http.StatusBadRequest},
+		{"invalid id", errInvalidID, http.StatusBadRequest},
+		{"invalid query", fmt.Errorf("%w: limit must be an integer", errInvalidQuery), http.StatusBadRequest},
+		{"invalid input", fmt.Errorf("%w: unknown folder", models.ErrInvalidInput), http.StatusBadRequest},
+		{"zero-knowledge vault", models.ErrZeroKnowledgeVault, http.StatusBadRequest},
+		{"unauthorized", errUnauthorized, http.StatusUnauthorized},
+		{"invalid credentials", models.ErrInvalidCredentials, http.StatusUnauthorized},
+		{"read-only share", models.ErrReadOnlyShare, http.StatusForbidden},
+		{"not found", fmt.Errorf("%w: note", models.ErrNotFound), http.StatusNotFound},
+		{"already exists", models.ErrAlreadyExists, http.StatusConflict},
+		{"weak password", models.ErrWeakPassword, http.StatusUnprocessableEntity},
+		{"quota exceeded", models.ErrQuotaExceeded, http.StatusRequestEntityTooLarge},
+		{"breach check unavailable", models.ErrBreachCheckUnavailable, http.StatusServiceUnavailable},
+		{"attachments unavailable", models.ErrAttachmentsUnavailable, http.StatusServiceUnavailable},
+		{"unexpected", errors.New("connection refused"), http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			writeError(w, tt.err)
+
+			if w.Code != tt.status {
+				t.Fatalf("expected status %d, got %d", tt.status, w.Code)
+			}
+
+			if contentType := w.Header().Get("Content-Type"); contentType != "application/json" {
+				t.Fatalf("expected a JSON response, got %s", contentType)
+			}
+
+			var response models.ErrorResponse
+			if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+				t.Fatal(err)
+			}
+
+			// unexpected errors are hidden from the client
+			expected := tt.err.Error()
+			if tt.status == http.StatusInternalServerError {
+				expected = http.StatusText(http.StatusInternalServerError)
+			}
+
+			if response.Error != expected {
+				t.Fatalf("expected error %q, got %q", expected, response.Error)
+			}
+		})
+	}
+}
@@ -0,0 +1,84 @@
+package httpserver
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/oalexander6/passman/pkg/models"
+)
+
+func (s *Server) handleNoteSearch(w http.ResponseWriter, r *http.Request) {
+	fuzzy, err := queryBool(r, "fuzzy")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	reveal, err := queryBool(r, "reveal")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	limit, err := queryInt(r, "limit", models.DefaultSearchLimit)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	blind, err := queryBlindTerms(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	input := models.NoteSearchRequest{
+		Query:    r.URL.Query().Get("q"),
+		Username: r.URL.Query().Get("username"),
+		Host:     r.URL.Query().Get("host"),
+		Blind:    blind,
+		Fuzzy:    fuzzy,
+		Limit:    limit,
+		Cursor:   r.URL.Query().Get("cursor"),
+	}
+
+	results, err := s.models.NoteSearch(r.Context(), accountIDFromContext(r.Context()), input, reveal)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, results)
+}
+
+// queryBlindTerms parses the blind index terms of a search from zero-knowledge clients. Each
+// exact parameter is a field and a token, as in exact=username:<token>, and each ngrams
+// parameter a field and comma separated tokens, as in ngrams=host:<token>,<token>.
+func queryBlindTerms(r *http.Request) ([]models.BlindTerm, error) {
+	terms := []models.BlindTerm{}
+
+	for _, param := range []string{"exact", "ngrams"} {
+		for _, value := range r.URL.Query()[param] {
+			field, tokens, ok := strings.Cut(value, ":")
+			if !ok {
+				return nil, fmt.Errorf("%w: %s must be a field and tokens separated by a colon", errInvalidQuery, param)
+			}
+
+			term := models.BlindTerm{Field: field}
+			if param == "exact" {
+				term.Exact = tokens
+			} else {
+				term.Ngrams = strings.Split(tokens, ",")
+			}
+
+			if err := validate.Struct(term); err != nil {
+				return nil, err
+			}
+
+			terms = append(terms, term)
+		}
+	}
+
+	return terms, nil
+}
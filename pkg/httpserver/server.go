@@ -1,40 +1,115 @@
 package httpserver
 
 import (
+	"context"
 	"net/http"
 
 	"github.com/oalexander6/passman/config"
+	"github.com/oalexander6/passman/pkg/blob"
+	"github.com/oalexander6/passman/pkg/breach"
+	"github.com/oalexander6/passman/pkg/keys"
 	"github.com/oalexander6/passman/pkg/logger"
 	"github.com/oalexander6/passman/pkg/models"
 	"github.com/urfave/negroni"
 )
 
 type Server struct {
-	config *config.Config
-	models *models.Models
-	server http.Handler
+	config   *config.Config
+	models   *models.Models
+	server   http.Handler
+	sessions *sessionCodec
 }
 
-func New(conf *config.Config, store models.Store) *Server {
+func New(conf *config.Config, store models.Store, keyProvider keys.KeyProvider, breaches breach.Checker, blobs blob.BlobStore) *Server {
+	sessions, err := newSessionCodec(conf.SecretKey)
+	if err != nil {
+		logger.Log.Fatal().Msgf("Failed to create session codec: %s", err)
+	}
+
+	s := &Server{
+		config:   conf,
+		models:   models.New(store, conf, keyProvider, breaches, blobs),
+		sessions: sessions,
+	}
+
 	mux := http.NewServeMux()
-	mux.Handle("/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	mux.Handle("GET /{$}", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
 		w.Write([]byte("OK"))
 	}))
 
+	mux.HandleFunc("POST /api/v1/accounts/register", s.handleAccountRegister)
+	mux.HandleFunc("POST /api/v1/accounts/prelogin", s.handleAccountPrelogin)
+	mux.HandleFunc("POST /api/v1/accounts/login", s.handleAccountLogin)
+	mux.HandleFunc("POST /api/v1/accounts/logout", s.handleAccountLogout)
+	mux.HandleFunc("GET /api/v1/accounts/me", s.requireAuth(s.handleAccountMe))
+	mux.HandleFunc("PUT /api/v1/accounts/me/policy", s.requireAuth(s.handleAccountUpdatePolicy))
+
+	mux.HandleFunc("POST /api/v1/generate", s.requireAuth(s.handlePasswordGenerate))
+	mux.HandleFunc("POST /api/v1/otp/scan", s.requireAuth(s.handleOTPScan))
+
+	mux.HandleFunc("GET /api/v1/reports/breaches", s.requireAuth(s.handleBreachReport))
+	mux.HandleFunc("GET /api/v1/reports/health", s.requireAuth(s.handleHealthReport))
+
+	mux.HandleFunc("GET /api/v1/attachments/usage", s.requireAuth(s.handleAttachmentUsage))
+
+	mux.HandleFunc("GET /api/v1/export", s.requireAuth(s.handleVaultExport))
+	mux.HandleFunc("POST /api/v1/import", s.requireAuth(s.handleVaultImport))
+
+	mux.HandleFunc("GET /api/v1/search", s.requireAuth(s.handleNoteSearch))
+	mux.HandleFunc("GET /api/v1/match", s.requireAuth(s.handleNoteMatch))
+
+	mux.HandleFunc("GET /api/v1/folders", s.requireAuth(s.handleFolderList))
+	mux.HandleFunc("POST /api/v1/folders", s.requireAuth(s.handleFolderCreate))
+	mux.HandleFunc("PUT /api/v1/folders/{id}", s.requireAuth(s.handleFolderUpdate))
+	mux.HandleFunc("DELETE /api/v1/folders/{id}", s.requireAuth(s.handleFolderDelete))
+
+	mux.HandleFunc("GET /api/v1/tags", s.requireAuth(s.handleTagList))
+	mux.HandleFunc("POST /api/v1/tags", s.requireAuth(s.handleTagCreate))
+	mux.HandleFunc("PUT /api/v1/tags/{id}", s.requireAuth(s.handleTagUpdate))
+	mux.HandleFunc("DELETE /api/v1/tags/{id}", s.requireAuth(s.handleTagDelete))
+
+	mux.HandleFunc("GET /api/v1/trash", s.requireAuth(s.handleTrashList))
+	mux.HandleFunc("DELETE /api/v1/trash", s.requireAuth(s.handleTrashEmpty))
+	mux.HandleFunc("POST /api/v1/trash/{id}/restore", s.requireAuth(s.handleTrashRestore))
+	mux.HandleFunc("DELETE /api/v1/trash/{id}", s.requireAuth(s.handleTrashDelete))
+
+	mux.HandleFunc("GET /api/v1/notes", s.requireAuth(s.handleNoteList))
+	mux.HandleFunc("POST /api/v1/notes", s.requireAuth(s.handleNoteCreate))
+	mux.HandleFunc("GET /api/v1/notes/{id}", s.requireAuth(s.handleNoteGet))
+	mux.HandleFunc("PUT /api/v1/notes/{id}", s.requireAuth(s.handleNoteUpdate))
+	mux.HandleFunc("DELETE /api/v1/notes/{id}", s.requireAuth(s.handleNoteDelete))
+	mux.HandleFunc("POST /api/v1/notes/{id}/otp", s.requireAuth(s.handleNoteOTP))
+	mux.HandleFunc("GET /api/v1/notes/{id}/versions", s.requireAuth(s.handleNoteVersionList))
+	mux.HandleFunc("GET /api/v1/notes/{id}/versions/{versionID}", s.requireAuth(s.handleNoteVersionGet))
+	mux.HandleFunc("POST /api/v1/notes/{id}/versions/{versionID}/restore", s.requireAuth(s.handleNoteVersionRestore))
+	mux.HandleFunc("GET /api/v1/notes/{id}/attachments", s.requireAuth(s.handleAttachmentList))
+	mux.HandleFunc("POST /api/v1/notes/{id}/attachments", s.requireAuth(s.handleAttachmentCreate))
+	mux.HandleFunc("GET /api/v1/notes/{id}/attachments/{attachmentID}", s.requireAuth(s.handleAttachmentGet))
+	mux.HandleFunc("DELETE /api/v1/notes/{id}/attachments/{attachmentID}", s.requireAuth(s.handleAttachmentDelete))
+	mux.HandleFunc("GET /api/v1/notes/{id}/shares", s.requireAuth(s.handleNoteShareList))
+	mux.HandleFunc("POST /api/v1/notes/{id}/shares", s.requireAuth(s.handleNoteShareCreate))
+	mux.HandleFunc("PUT /api/v1/notes/{id}/shares/{shareID}", s.requireAuth(s.handleNoteShareUpdate))
+	mux.HandleFunc("DELETE /api/v1/notes/{id}/shares/{shareID}", s.requireAuth(s.handleNoteShareRevoke))
+
+	mux.HandleFunc("GET /api/v1/shared", s.requireAuth(s.handleSharedNoteList))
+	mux.HandleFunc("GET /api/v1/shared/{id}", s.requireAuth(s.handleSharedNoteGet))
+	mux.HandleFunc("PUT /api/v1/shared/{id}", s.requireAuth(s.handleSharedNoteUpdate))
+
 	mw := negroni.New()
 	mw.Use(negroni.NewRecovery())
 	mw.Use(negroni.HandlerFunc(logMiddleware))
 	mw.UseHandler(mux)
 
-	return &Server{
-		config: conf,
-		models: models.New(store, conf),
-		server: mw,
-	}
+	s.server = mw
+
+	return s
 }
 
 func (s *Server) Run() error {
+	go s.models.RunTrashPurge(context.Background())
+	go s.models.RunSearchIndex(context.Background())
+
 	logger.Log.Info().Msgf("listening on %s\n", s.config.Port)
 	if err := http.ListenAndServe(":"+s.config.Port, s.server); err != nil && err != http.ErrServerClosed {
 		logger.Log.Info().Msgf("error listening and serving: %s\n", err)
@@ -0,0 +1,283 @@
+package httpserver
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/oalexander6/passman/config"
+	"github.com/oalexander6/passman/pkg/keys"
+	"github.com/oalexander6/passman/pkg/models"
+	"github.com/oalexander6/passman/pkg/store/sqlite"
+)
+
+// newTestServer returns a server backed by an empty SQLite database with every migration
+// applied, and the store so tests can change it directly.
+func newTestServer(t *testing.T) (*Server, *sqlite.SqliteStore) {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "passman.db")
+
+	// sqlite.New exits without FTS5, so check for it first
+	db, err := sql.Open("sqlite3", "file:"+path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var fts5 bool
+	err = db.QueryRow(`SELECT sqlite_compileoption_used('ENABLE_FTS5');`).Scan(&fts5)
+	db.Close()
+	if err != nil || !fts5 {
+		t.Skip("SQLite was built without FTS5, run the tests with -tags sqlite_fts5")
+	}
+
+	store := sqlite.New(config.SqliteConfig{Path: path})
+	t.Cleanup(store.Close)
+
+	migrator, err := store.Migrator()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := migrator.Up(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	masterKey, err := keys.NewDataKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	provider, err := keys.NewStaticProvider(masterKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conf := &config.Config{
+		Env:        config.LOCAL_ENV,
+		SecretKey:  "secret-key",
+		Encryption: config.EncryptionConfig{EncSecret: "0123456789abcdef0123456789abcdef", EncIV: "1234567890abcdef"},
+	}
+
+	return New(conf, store, provider, nil, nil), store
+}
+
+// serve sends a request with the provided JSON body and session cookie to the server.
+func serve(t *testing.T, s *Server, method string, path string, body any, cookie *http.Cookie) *httptest.ResponseRecorder {
+	t.Helper()
+
+	var buf bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&buf).Encode(body); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	r := httptest.NewRequest(method, path, &buf)
+	if cookie != nil {
+		r.AddCookie(cookie)
+	}
+
+	w := httptest.NewRecorder()
+	s.server.ServeHTTP(w, r)
+
+	return w
+}
+
+// login registers an account for the email if it doesn't exist, logs it in and returns its
+// ID and session cookie.
+func login(t *testing.T, s *Server, email string) (int64, *http.Cookie) {
+	t.Helper()
+
+	password := "correct horse battery staple"
+
+	serve(t, s, http.MethodPost, "/api/v1/accounts/register",
+		models.AccountCreateRequest{Email: email, Password: password, Name: "Test"}, nil)
+
+	w := serve(t, s, http.MethodPost, "/api/v1/accounts/login",
+		models.AccountLoginRequest{Email: email, Password: password}, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected to log in, got %d: %s", w.Code, w.Body)
+	}
+
+	var account models.IDResponse
+	if err := json.NewDecoder(w.Body).Decode(&account); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, cookie := range w.Result().Cookies() {
+		if cookie.Name == sessionCookieName {
+			return account.ID, cookie
+		}
+	}
+
+	t.Fatal("expected a session cookie")
+	return 0, nil
+}
+
+func TestRequireAuth(t *testing.T) {
+	s, store := newTestServer(t)
+
+	accountID, cookie := login(t, s, "alice@example.com")
+
+	other, err := newSessionCodec("other-secret-key")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	forged, err := other.seal(session{AccountID: accountID, ExpiresAt: cookie.Expires.Unix()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		cookie *http.Cookie
+		status int
+	}{
+		{"no cookie", nil, http.StatusUnauthorized},
+		{"garbage cookie", &http.Cookie{Name: sessionCookieName, Value: "garbage"}, http.StatusUnauthorized},
+		{"cookie sealed with another key", &http.Cookie{Name: sessionCookieName, Value: forged}, http.StatusUnauthorized},
+		{"valid cookie", cookie, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if w := serve(t, s, http.MethodGet, "/api/v1/accounts/me", nil, tt.cookie); w.Code != tt.status {
+				t.Fatalf("expected status %d, got %d: %s", tt.status, w.Code, w.Body)
+			}
+		})
+	}
+
+	// the session of a deleted account is no longer accepted
+	if err := store.AccountDelete(context.Background(), accountID); err != nil {
+		t.Fatal(err)
+	}
+
+	if w := serve(t, s, http.MethodGet, "/api/v1/accounts/me", nil, cookie); w.Code != http.StatusUnauthorized {
+		t.Fatalf("expected a deleted account's session to be rejected, got %d", w.Code)
+	}
+}
+
+func TestLogoutRevokesSessions(t *testing.T) {
+	s, _ := newTestServer(t)
+
+	_, first := login(t, s, "alice@example.com")
+	_, second := login(t, s, "alice@example.com")
+	_, bob := login(t, s, "bob@example.com")
+
+	w := serve(t, s, http.MethodPost, "/api/v1/accounts/logout", nil, first)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected to log out, got %d: %s", w.Code, w.Body)
+	}
+
+	cleared := false
+	for _, cookie := range w.Result().Cookies() {
+		cleared = cleared || (cookie.Name == sessionCookieName && cookie.MaxAge < 0)
+	}
+
+	if !cleared {
+		t.Fatal("expected the session cookie to be cleared")
+	}
+
+	// a copy of the cookie, and every other session of the account, is no longer accepted
+	for _, cookie := range []*http.Cookie{first, second} {
+		if w := serve(t, s, http.MethodGet, "/api/v1/accounts/me", nil, cookie); w.Code != http.StatusUnauthorized {
+			t.Fatalf("expected a logged out session to be rejected, got %d", w.Code)
+		}
+	}
+
+	// other accounts stay logged in
+	if w := serve(t, s, http.MethodGet, "/api/v1/accounts/me", nil, bob); w.Code != http.StatusOK {
+		t.Fatalf("expected another account's session to be accepted, got %d", w.Code)
+	}
+
+	// logging out without a valid session only clears the cookie
+	if w := serve(t, s, http.MethodPost, "/api/v1/accounts/logout", nil, first); w.Code != http.StatusOK {
+		t.Fatalf("expected to log out without a session, got %d: %s", w.Code, w.Body)
+	}
+
+	_, again := login(t, s, "alice@example.com")
+	if w := serve(t, s, http.MethodGet, "/api/v1/accounts/me", nil, again); w.Code != http.StatusOK {
+		t.Fatalf("expected a new session to be accepted after logging out, got %d", w.Code)
+	}
+}
+
+func TestNotesScopedToAccount(t *testing.T) {
+	s, _ := newTestServer(t)
+
+	_, alice := login(t, s, "alice@example.com")
+	_, mallory := login(t, s, "mallory@example.com")
+
+	note := models.NoteCreateRequest{
+		Name:       "bank",
+		Type:       models.NoteTypeLogin,
+		NoteFields: models.NoteFields{Login: &models.LoginFields{Username: "alice", Password: "alice-password"}},
+	}
+
+	w := serve(t, s, http.MethodPost, "/api/v1/notes", note, alice)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected to create a note, got %d: %s", w.Code, w.Body)
+	}
+
+	var created models.NoteGetResponse
+	if err := json.NewDecoder(w.Body).Decode(&created); err != nil {
+		t.Fatal(err)
+	}
+
+	path := fmt.Sprintf("/api/v1/notes/%d", created.ID)
+	update := note
+	update.Name = "stolen"
+
+	tests := []struct {
+		name   string
+		method string
+		body   any
+	}{
+		{"get", http.MethodGet, nil},
+		{"update", http.MethodPut, update},
+		{"delete", http.MethodDelete, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if w := serve(t, s, tt.method, path, tt.body, mallory); w.Code != http.StatusNotFound {
+				t.Fatalf("expected status 404 for another account's note, got %d: %s", w.Code, w.Body)
+			}
+		})
+	}
+
+	listNames := func(cookie *http.Cookie) []string {
+		w := serve(t, s, http.MethodGet, "/api/v1/notes", nil, cookie)
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected to list notes, got %d: %s", w.Code, w.Body)
+		}
+
+		var notes []models.NoteGetResponse
+		if err := json.NewDecoder(w.Body).Decode(&notes); err != nil {
+			t.Fatal(err)
+		}
+
+		names := []string{}
+		for _, note := range notes {
+			names = append(names, note.Name)
+		}
+
+		return names
+	}
+
+	if names := listNames(mallory); len(names) != 0 {
+		t.Fatalf("expected no notes for another account, got %v", names)
+	}
+
+	// the note is unchanged for its owner
+	if names := listNames(alice); len(names) != 1 || names[0] != "bank" {
+		t.Fatalf("expected the owner's note to be unchanged, got %v", names)
+	}
+}
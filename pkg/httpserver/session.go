@@ -0,0 +1,199 @@
+package httpserver
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/oalexander6/passman/config"
+	"github.com/oalexander6/passman/pkg/models"
+)
+
+const (
+	sessionCookieName = "passman_session"
+	sessionTTL        = 24 * time.Hour
+)
+
+var errUnauthorized = errors.New("unauthorized")
+
+type contextKey string
+
+const accountIDContextKey contextKey = "accountID"
+
+// session is the payload stored in the session cookie. Epoch is the account's session epoch
+// when the session was issued, and the session is only accepted while it is unchanged.
+type session struct {
+	AccountID int64 `json:"aid"`
+	Epoch     int64 `json:"ep"`
+	ExpiresAt int64 `json:"exp"`
+}
+
+// sessionCodec seals and opens session cookies. AES-GCM both encrypts the payload and
+// authenticates it, so a cookie that was modified or created without the key is rejected.
+type sessionCodec struct {
+	aead cipher.AEAD
+}
+
+func newSessionCodec(secretKey string) (*sessionCodec, error) {
+	key := sha256.Sum256([]byte(secretKey))
+
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, err
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	return &sessionCodec{aead: aead}, nil
+}
+
+// seal encrypts the session and returns it encoded for use as a cookie value.
+func (c *sessionCodec) seal(sess session) (string, error) {
+	plaintext, err := json.Marshal(sess)
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, c.aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", err
+	}
+
+	sealed := c.aead.Seal(nonce, nonce, plaintext, []byte(sessionCookieName))
+
+	return base64.RawURLEncoding.EncodeToString(sealed), nil
+}
+
+// open decrypts and verifies a cookie value created by seal. Returns errUnauthorized if the
+// value has been tampered with or the session has expired.
+func (c *sessionCodec) open(value string) (session, error) {
+	sealed, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil || len(sealed) < c.aead.NonceSize() {
+		return session{}, errUnauthorized
+	}
+
+	nonce, ciphertext := sealed[:c.aead.NonceSize()], sealed[c.aead.NonceSize():]
+
+	plaintext, err := c.aead.Open(nil, nonce, ciphertext, []byte(sessionCookieName))
+	if err != nil {
+		return session{}, errUnauthorized
+	}
+
+	var sess session
+	if err := json.Unmarshal(plaintext, &sess); err != nil {
+		return session{}, errUnauthorized
+	}
+
+	if time.Now().Unix() >= sess.ExpiresAt {
+		return session{}, errUnauthorized
+	}
+
+	return sess, nil
+}
+
+// setSessionCookie issues a new session cookie for the provided account in its current session
+// epoch.
+func (s *Server) setSessionCookie(w http.ResponseWriter, accountID int64, epoch int64) error {
+	expiresAt := time.Now().Add(sessionTTL)
+
+	value, err := s.sessions.seal(session{
+		AccountID: accountID,
+		Epoch:     epoch,
+		ExpiresAt: expiresAt.Unix(),
+	})
+	if err != nil {
+		return err
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookieName,
+		Value:    value,
+		Path:     "/",
+		Expires:  expiresAt,
+		HttpOnly: true,
+		Secure:   s.secureCookies(),
+		SameSite: http.SameSiteStrictMode,
+	})
+
+	return nil
+}
+
+// clearSessionCookie instructs the client to remove the session cookie.
+func (s *Server) clearSessionCookie(w http.ResponseWriter) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookieName,
+		Value:    "",
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   s.secureCookies(),
+		SameSite: http.SameSiteStrictMode,
+	})
+}
+
+// secureCookies reports whether cookies should only be sent over HTTPS. Local development
+// is served over plain HTTP.
+func (s *Server) secureCookies() bool {
+	return s.config.Env != config.LOCAL_ENV
+}
+
+// authenticate returns the session in the request's cookie. Returns errUnauthorized if there is
+// no valid session, its account has been deleted, or its account has logged out since it was
+// issued.
+func (s *Server) authenticate(r *http.Request) (session, error) {
+	cookie, err := r.Cookie(sessionCookieName)
+	if err != nil {
+		return session{}, errUnauthorized
+	}
+
+	sess, err := s.sessions.open(cookie.Value)
+	if err != nil {
+		return session{}, err
+	}
+
+	epoch, err := s.models.AccountSessionEpoch(r.Context(), sess.AccountID)
+	if errors.Is(err, models.ErrNotFound) {
+		return session{}, errUnauthorized
+	}
+	if err != nil {
+		return session{}, err
+	}
+
+	if sess.Epoch != epoch {
+		return session{}, errUnauthorized
+	}
+
+	return sess, nil
+}
+
+// requireAuth rejects requests without a valid session cookie and stores the authenticated
+// account ID on the request context.
+func (s *Server) requireAuth(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		sess, err := s.authenticate(r)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), accountIDContextKey, sess.AccountID)
+		next(w, r.WithContext(ctx))
+	}
+}
+
+// accountIDFromContext returns the authenticated account ID set by requireAuth.
+func accountIDFromContext(ctx context.Context) int64 {
+	accountID, _ := ctx.Value(accountIDContextKey).(int64)
+	return accountID
+}
@@ -0,0 +1,64 @@
+package httpserver
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestSessionCodec(t *testing.T) {
+	codec, err := newSessionCodec("secret-key")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	other, err := newSessionCodec("other-secret-key")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expiresAt := time.Now().Add(time.Hour).Unix()
+
+	value, err := codec.seal(session{AccountID: 7, Epoch: 3, ExpiresAt: expiresAt})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sess, err := codec.open(value)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if sess.AccountID != 7 || sess.Epoch != 3 || sess.ExpiresAt != expiresAt {
+		t.Fatalf("expected the sealed session, got %+v", sess)
+	}
+
+	expired, err := codec.seal(session{AccountID: 7, ExpiresAt: time.Now().Add(-time.Second).Unix()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tampered := []byte(value)
+	tampered[len(tampered)/2] ^= 1
+
+	tests := []struct {
+		name  string
+		codec *sessionCodec
+		value string
+	}{
+		{"empty", codec, ""},
+		{"not base64", codec, "not a session!"},
+		{"too short", codec, "AAAA"},
+		{"tampered", codec, string(tampered)},
+		{"expired", codec, expired},
+		{"other key", other, value},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := tt.codec.open(tt.value); !errors.Is(err, errUnauthorized) {
+				t.Fatalf("expected errUnauthorized, got %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,161 @@
+package httpserver
+
+import (
+	"net/http"
+
+	"github.com/oalexander6/passman/pkg/models"
+)
+
+func (s *Server) handleNoteShareList(w http.ResponseWriter, r *http.Request) {
+	noteID, err := pathID(r, "id")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	shares, err := s.models.NoteShareGetByNoteID(r.Context(), accountIDFromContext(r.Context()), noteID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, shares)
+}
+
+func (s *Server) handleNoteShareCreate(w http.ResponseWriter, r *http.Request) {
+	noteID, err := pathID(r, "id")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	var input models.NoteShareRequest
+	if err := decodeAndValidate(w, r, &input); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	share, err := s.models.NoteShareCreate(r.Context(), accountIDFromContext(r.Context()), noteID, input)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, share)
+}
+
+func (s *Server) handleNoteShareUpdate(w http.ResponseWriter, r *http.Request) {
+	noteID, err := pathID(r, "id")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	shareID, err := pathID(r, "shareID")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	var input models.NoteSharePermissionRequest
+	if err := decodeAndValidate(w, r, &input); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	share, err := s.models.NoteShareUpdate(r.Context(), accountIDFromContext(r.Context()), noteID, shareID, input)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, share)
+}
+
+func (s *Server) handleNoteShareRevoke(w http.ResponseWriter, r *http.Request) {
+	noteID, err := pathID(r, "id")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	shareID, err := pathID(r, "shareID")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	rotate, err := queryBool(r, "rotate")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	revoked, err := s.models.NoteShareRevoke(r.Context(), accountIDFromContext(r.Context()), noteID, shareID, rotate)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, revoked)
+}
+
+func (s *Server) handleSharedNoteList(w http.ResponseWriter, r *http.Request) {
+	reveal, err := queryBool(r, "reveal")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	notes, err := s.models.SharedNoteGetByRecipientID(r.Context(), accountIDFromContext(r.Context()), reveal)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, notes)
+}
+
+func (s *Server) handleSharedNoteGet(w http.ResponseWriter, r *http.Request) {
+	noteID, err := pathID(r, "id")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	reveal, err := queryBool(r, "reveal")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	note, err := s.models.SharedNoteGetByID(r.Context(), accountIDFromContext(r.Context()), noteID, reveal)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, note)
+}
+
+func (s *Server) handleSharedNoteUpdate(w http.ResponseWriter, r *http.Request) {
+	noteID, err := pathID(r, "id")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	var input models.NoteCreateRequest
+	if err := decodeAndValidate(w, r, &input); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	note, err := s.models.SharedNoteUpdate(r.Context(), accountIDFromContext(r.Context()), noteID, input)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, note)
+}
@@ -0,0 +1,70 @@
+package httpserver
+
+import (
+	"net/http"
+
+	"github.com/oalexander6/passman/pkg/models"
+)
+
+func (s *Server) handleTagList(w http.ResponseWriter, r *http.Request) {
+	tags, err := s.models.TagGetByAccountID(r.Context(), accountIDFromContext(r.Context()))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, tags)
+}
+
+func (s *Server) handleTagCreate(w http.ResponseWriter, r *http.Request) {
+	var input models.TagRequest
+	if err := decodeAndValidate(w, r, &input); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	tag, err := s.models.TagCreate(r.Context(), accountIDFromContext(r.Context()), input)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, tag)
+}
+
+func (s *Server) handleTagUpdate(w http.ResponseWriter, r *http.Request) {
+	tagID, err := pathID(r, "id")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	var input models.TagRequest
+	if err := decodeAndValidate(w, r, &input); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	tag, err := s.models.TagUpdate(r.Context(), accountIDFromContext(r.Context()), tagID, input)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, tag)
+}
+
+func (s *Server) handleTagDelete(w http.ResponseWriter, r *http.Request) {
+	tagID, err := pathID(r, "id")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	if err := s.models.TagDelete(r.Context(), accountIDFromContext(r.Context()), tagID); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,56 @@
+package httpserver
+
+import (
+	"net/http"
+)
+
+func (s *Server) handleTrashList(w http.ResponseWriter, r *http.Request) {
+	items, err := s.models.TrashGetByAccountID(r.Context(), accountIDFromContext(r.Context()))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, items)
+}
+
+func (s *Server) handleTrashRestore(w http.ResponseWriter, r *http.Request) {
+	noteID, err := pathID(r, "id")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	note, err := s.models.TrashRestore(r.Context(), accountIDFromContext(r.Context()), noteID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, note)
+}
+
+func (s *Server) handleTrashDelete(w http.ResponseWriter, r *http.Request) {
+	noteID, err := pathID(r, "id")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	if err := s.models.TrashDelete(r.Context(), accountIDFromContext(r.Context()), noteID); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleTrashEmpty(w http.ResponseWriter, r *http.Request) {
+	purged, err := s.models.TrashEmpty(r.Context(), accountIDFromContext(r.Context()))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, purged)
+}
@@ -0,0 +1,71 @@
+package httpserver
+
+import (
+	"net/http"
+)
+
+func (s *Server) handleNoteVersionList(w http.ResponseWriter, r *http.Request) {
+	noteID, err := pathID(r, "id")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	versions, err := s.models.NoteVersionGetByNoteID(r.Context(), accountIDFromContext(r.Context()), noteID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, versions)
+}
+
+func (s *Server) handleNoteVersionGet(w http.ResponseWriter, r *http.Request) {
+	noteID, err := pathID(r, "id")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	versionID, err := pathID(r, "versionID")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	reveal, err := queryBool(r, "reveal")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	version, err := s.models.NoteVersionGetByID(r.Context(), accountIDFromContext(r.Context()), noteID, versionID, reveal)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, version)
+}
+
+func (s *Server) handleNoteVersionRestore(w http.ResponseWriter, r *http.Request) {
+	noteID, err := pathID(r, "id")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	versionID, err := pathID(r, "versionID")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	note, err := s.models.NoteVersionRestore(r.Context(), accountIDFromContext(r.Context()), noteID, versionID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, note)
+}
@@ -0,0 +1,119 @@
+// Package keys implements envelope encryption key management. Every account has its own
+// data encryption key (DEK) which is stored wrapped by a master key. The master key never
+// leaves its KeyProvider.
+//
+// A provider may hold several master keys at once to allow rotation without downtime. New
+// data keys are always wrapped with the primary (newest) key, and every wrapped key records
+// the ID of the master key that wrapped it so it can be unwrapped by any older key that is
+// still configured.
+package keys
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/oalexander6/passman/config"
+)
+
+// DataKeySize is the size in bytes of the AES-256 data encryption keys.
+const DataKeySize = 32
+
+// wrappedKeyPrefix marks wrapped keys that carry the ID of their master key, in the form
+// wk1:<base64url key ID>:<provider ciphertext>. Keys wrapped before master key IDs were
+// introduced have no prefix.
+const wrappedKeyPrefix = "wk1:"
+
+var (
+	ErrWrapFailed   = errors.New("failed to wrap data key")
+	ErrUnwrapFailed = errors.New("failed to unwrap data key")
+	ErrUnknownKey   = errors.New("master key is not configured")
+)
+
+// KeyProvider wraps and unwraps data encryption keys with master keys it controls.
+type KeyProvider interface {
+	// WrapKey encrypts the data key with the primary master key and returns a value that is
+	// safe to store.
+	WrapKey(ctx context.Context, dataKey []byte) (string, error)
+	// UnwrapKey decrypts a value returned by WrapKey using whichever configured master key
+	// wrapped it.
+	UnwrapKey(ctx context.Context, wrapped string) ([]byte, error)
+	// PrimaryKeyID returns the ID of the master key used by WrapKey.
+	PrimaryKeyID() string
+}
+
+// New creates the key provider selected by the encryption configuration.
+func New(c config.EncryptionConfig) (KeyProvider, error) {
+	switch c.KeyProvider {
+	case config.KEY_PROVIDER_ENV:
+		masterKeys := [][]byte{[]byte(c.EncSecret)}
+		for _, previous := range c.PreviousSecrets {
+			masterKeys = append(masterKeys, []byte(previous))
+		}
+		return NewStaticProvider(masterKeys...)
+	case config.KEY_PROVIDER_FILE:
+		return NewFileProvider(c.KeyFile)
+	case config.KEY_PROVIDER_KMS:
+		return NewKMSProvider(c.KMS), nil
+	default:
+		return nil, fmt.Errorf("invalid key provider: %s", c.KeyProvider)
+	}
+}
+
+// NewDataKey returns a new random data encryption key.
+func NewDataKey() ([]byte, error) {
+	key := make([]byte, DataKeySize)
+	if _, err := io.ReadFull(rand.Reader, key); err != nil {
+		return nil, err
+	}
+
+	return key, nil
+}
+
+// WrappedKeyID returns the ID of the master key that wrapped the provided key, or an empty
+// string if the key was wrapped before master key IDs were introduced.
+func WrappedKeyID(wrapped string) string {
+	keyID, _, ok := parseWrapped(wrapped)
+	if !ok {
+		return ""
+	}
+
+	return keyID
+}
+
+// formatWrapped prefixes a provider ciphertext with the ID of the master key that produced it.
+func formatWrapped(keyID string, ciphertext string) string {
+	return wrappedKeyPrefix + base64.RawURLEncoding.EncodeToString([]byte(keyID)) + ":" + ciphertext
+}
+
+// parseWrapped splits a value created by formatWrapped. Returns false for values without a
+// master key ID.
+func parseWrapped(wrapped string) (keyID string, ciphertext string, ok bool) {
+	if !strings.HasPrefix(wrapped, wrappedKeyPrefix) {
+		return "", wrapped, false
+	}
+
+	encodedID, ciphertext, found := strings.Cut(strings.TrimPrefix(wrapped, wrappedKeyPrefix), ":")
+	if !found {
+		return "", wrapped, false
+	}
+
+	id, err := base64.RawURLEncoding.DecodeString(encodedID)
+	if err != nil {
+		return "", wrapped, false
+	}
+
+	return string(id), ciphertext, true
+}
+
+// fingerprint derives a stable, non-secret ID for a master key held in memory.
+func fingerprint(masterKey []byte) string {
+	sum := sha256.Sum256(append([]byte("passman:master-key-id:"), masterKey...))
+	return hex.EncodeToString(sum[:8])
+}
@@ -0,0 +1,150 @@
+package keys
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/oalexander6/passman/config"
+)
+
+// kmsRequestTimeout bounds every call to the KMS.
+const kmsRequestTimeout = 10 * time.Second
+
+// KMSProvider wraps data keys by calling an HTTP key management service, so the master key
+// is never available to passman. The service must implement:
+//
+//	POST {url}/v1/keys/{key_id}/encrypt  {"plaintext": "<base64>"}  -> {"ciphertext": "<string>"}
+//	POST {url}/v1/keys/{key_id}/decrypt  {"ciphertext": "<string>"}  -> {"plaintext": "<base64>"}
+//
+// Requests are authenticated with a bearer token when one is configured. NewKMSHandler
+// implements the same API and can be used as a local stand-in.
+type KMSProvider struct {
+	baseURL string
+	// keyIDs are ordered from newest to oldest, the first is the primary key
+	keyIDs []string
+	token  string
+	client *http.Client
+}
+
+type kmsEncryptRequest struct {
+	Plaintext string `json:"plaintext"`
+}
+
+type kmsEncryptResponse struct {
+	Ciphertext string `json:"ciphertext"`
+}
+
+type kmsDecryptRequest struct {
+	Ciphertext string `json:"ciphertext"`
+}
+
+type kmsDecryptResponse struct {
+	Plaintext string `json:"plaintext"`
+}
+
+// NewKMSProvider creates a provider that uses the KMS at the configured URL.
+func NewKMSProvider(c config.KMSConfig) *KMSProvider {
+	return &KMSProvider{
+		baseURL: strings.TrimSuffix(c.URL, "/"),
+		keyIDs:  append([]string{c.KeyID}, c.PreviousKeyIDs...),
+		token:   c.Token,
+		client:  &http.Client{Timeout: kmsRequestTimeout},
+	}
+}
+
+// PrimaryKeyID implements KeyProvider.
+func (p *KMSProvider) PrimaryKeyID() string {
+	return p.keyIDs[0]
+}
+
+// WrapKey implements KeyProvider.
+func (p *KMSProvider) WrapKey(ctx context.Context, dataKey []byte) (string, error) {
+	var resp kmsEncryptResponse
+
+	err := p.call(ctx, p.PrimaryKeyID(), "encrypt", kmsEncryptRequest{
+		Plaintext: base64.StdEncoding.EncodeToString(dataKey),
+	}, &resp)
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", ErrWrapFailed, err)
+	}
+	if resp.Ciphertext == "" {
+		return "", ErrWrapFailed
+	}
+
+	return formatWrapped(p.PrimaryKeyID(), resp.Ciphertext), nil
+}
+
+// UnwrapKey implements KeyProvider. Keys wrapped before master key IDs were introduced are
+// tried against every configured key.
+func (p *KMSProvider) UnwrapKey(ctx context.Context, wrapped string) ([]byte, error) {
+	keyID, ciphertext, hasID := parseWrapped(wrapped)
+
+	keyIDs := p.keyIDs
+	if hasID {
+		if !slices.Contains(p.keyIDs, keyID) {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownKey, keyID)
+		}
+		keyIDs = []string{keyID}
+	}
+
+	var (
+		resp kmsDecryptResponse
+		err  error
+	)
+
+	for _, id := range keyIDs {
+		if err = p.call(ctx, id, "decrypt", kmsDecryptRequest{Ciphertext: ciphertext}, &resp); err == nil {
+			break
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrUnwrapFailed, err)
+	}
+
+	dataKey, err := base64.StdEncoding.DecodeString(resp.Plaintext)
+	if err != nil {
+		return nil, ErrUnwrapFailed
+	}
+
+	return dataKey, nil
+}
+
+// call sends a JSON request to the named KMS operation and decodes the JSON response.
+func (p *KMSProvider) call(ctx context.Context, keyID string, operation string, body any, dst any) error {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	endpoint := fmt.Sprintf("%s/v1/keys/%s/%s", p.baseURL, url.PathEscape(keyID), operation)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	if p.token != "" {
+		req.Header.Set("Authorization", "Bearer "+p.token)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("kms returned %s", resp.Status)
+	}
+
+	return json.NewDecoder(resp.Body).Decode(dst)
+}
@@ -0,0 +1,81 @@
+package keys
+
+import (
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+)
+
+// NewKMSHandler returns an http.Handler implementing the API expected by KMSProvider on top
+// of another provider. It is intended as a local stand-in for a real KMS during development
+// and testing. Requests must present the provided bearer token when it is not empty.
+func NewKMSHandler(provider KeyProvider, keyID string, token string) http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("POST /v1/keys/{key_id}/encrypt", func(w http.ResponseWriter, r *http.Request) {
+		var req kmsEncryptRequest
+		if !kmsAuthorize(w, r, keyID, token) || !kmsDecode(w, r, &req) {
+			return
+		}
+
+		dataKey, err := base64.StdEncoding.DecodeString(req.Plaintext)
+		if err != nil {
+			http.Error(w, "plaintext must be base64 encoded", http.StatusBadRequest)
+			return
+		}
+
+		wrapped, err := provider.WrapKey(r.Context(), dataKey)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		kmsRespond(w, kmsEncryptResponse{Ciphertext: wrapped})
+	})
+
+	mux.HandleFunc("POST /v1/keys/{key_id}/decrypt", func(w http.ResponseWriter, r *http.Request) {
+		var req kmsDecryptRequest
+		if !kmsAuthorize(w, r, keyID, token) || !kmsDecode(w, r, &req) {
+			return
+		}
+
+		dataKey, err := provider.UnwrapKey(r.Context(), req.Ciphertext)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		kmsRespond(w, kmsDecryptResponse{Plaintext: base64.StdEncoding.EncodeToString(dataKey)})
+	})
+
+	return mux
+}
+
+func kmsAuthorize(w http.ResponseWriter, r *http.Request, keyID string, token string) bool {
+	if token != "" && subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), []byte("Bearer "+token)) != 1 {
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return false
+	}
+
+	if r.PathValue("key_id") != keyID {
+		http.Error(w, "unknown key", http.StatusNotFound)
+		return false
+	}
+
+	return true
+}
+
+func kmsDecode(w http.ResponseWriter, r *http.Request, dst any) bool {
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<16)).Decode(dst); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return false
+	}
+
+	return true
+}
+
+func kmsRespond(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,176 @@
+package keys
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/oalexander6/passman/config"
+)
+
+// newTestKMS starts NewKMSHandler for a key with the provided ID and returns its URL.
+func newTestKMS(t *testing.T, keyID string, token string) string {
+	t.Helper()
+
+	server := httptest.NewServer(NewKMSHandler(newTestStaticProvider(t, testMasterKey(1)), keyID, token))
+	t.Cleanup(server.Close)
+
+	return server.URL
+}
+
+// legacyKMSCiphertext returns a key wrapped by the KMS stand-in as it was stored before master
+// key IDs were introduced, without the IDs of the KMS key or of the stand-in's own key.
+func legacyKMSCiphertext(wrapped string) string {
+	_, ciphertext, _ := parseWrapped(wrapped)
+	_, ciphertext, _ = parseWrapped(ciphertext)
+
+	return ciphertext
+}
+
+func TestKMSProviderRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	provider := NewKMSProvider(config.KMSConfig{URL: newTestKMS(t, "primary", "secret") + "/", KeyID: "primary", Token: "secret"})
+
+	dataKey := testMasterKey(9)
+
+	wrapped, err := provider.WrapKey(ctx, dataKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if WrappedKeyID(wrapped) != "primary" {
+		t.Fatalf("expected the wrapped key to name the primary key, got %q", WrappedKeyID(wrapped))
+	}
+
+	unwrapped, err := provider.UnwrapKey(ctx, wrapped)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(unwrapped, dataKey) {
+		t.Fatal("expected the unwrapped key to equal the data key")
+	}
+
+	// keys wrapped before key IDs were introduced are sent to every configured key
+	rotated := NewKMSProvider(config.KMSConfig{URL: provider.baseURL, KeyID: "newer", PreviousKeyIDs: []string{"primary"}, Token: "secret"})
+	if unwrapped, err := rotated.UnwrapKey(ctx, legacyKMSCiphertext(wrapped)); err != nil || !bytes.Equal(unwrapped, dataKey) {
+		t.Fatalf("expected a key without an ID to unwrap, got %v", err)
+	}
+}
+
+func TestKMSProviderErrors(t *testing.T) {
+	ctx := context.Background()
+	url := newTestKMS(t, "primary", "secret")
+
+	wrapped, err := NewKMSProvider(config.KMSConfig{URL: url, KeyID: "primary", Token: "secret"}).WrapKey(ctx, testMasterKey(9))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, ciphertext, _ := parseWrapped(wrapped)
+
+	tests := []struct {
+		name    string
+		config  config.KMSConfig
+		wrapped string
+		err     error
+		status  string
+	}{
+		{
+			name:   "wrap without a token",
+			config: config.KMSConfig{URL: url, KeyID: "primary"},
+			err:    ErrWrapFailed,
+			status: "401",
+		},
+		{
+			name:   "wrap with the wrong token",
+			config: config.KMSConfig{URL: url, KeyID: "primary", Token: "wrong"},
+			err:    ErrWrapFailed,
+			status: "401",
+		},
+		{
+			name:   "wrap with an unknown key",
+			config: config.KMSConfig{URL: url, KeyID: "other", Token: "secret"},
+			err:    ErrWrapFailed,
+			status: "404",
+		},
+		{
+			name:    "unwrap with the wrong token",
+			config:  config.KMSConfig{URL: url, KeyID: "primary", Token: "wrong"},
+			wrapped: wrapped,
+			err:     ErrUnwrapFailed,
+			status:  "401",
+		},
+		{
+			name:    "unwrap a tampered key",
+			config:  config.KMSConfig{URL: url, KeyID: "primary", Token: "secret"},
+			wrapped: formatWrapped("primary", ciphertext[:len(ciphertext)-4]+"AAAA"),
+			err:     ErrUnwrapFailed,
+			status:  "400",
+		},
+		{
+			name:    "unwrap a key the KMS doesn't hold",
+			config:  config.KMSConfig{URL: url, KeyID: "other", PreviousKeyIDs: []string{"older"}, Token: "secret"},
+			wrapped: legacyKMSCiphertext(wrapped),
+			err:     ErrUnwrapFailed,
+			status:  "404",
+		},
+		{
+			name:    "unwrap a key that isn't configured",
+			config:  config.KMSConfig{URL: url, KeyID: "other", Token: "secret"},
+			wrapped: wrapped,
+			err:     ErrUnknownKey,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider := NewKMSProvider(tt.config)
+
+			var err error
+			if tt.wrapped == "" {
+				_, err = provider.WrapKey(ctx, testMasterKey(9))
+			} else {
+				_, err = provider.UnwrapKey(ctx, tt.wrapped)
+			}
+
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("expected %v, got %v", tt.err, err)
+			}
+
+			if !strings.Contains(err.Error(), tt.status) {
+				t.Fatalf("expected the error to report status %s, got %v", tt.status, err)
+			}
+		})
+	}
+}
+
+func TestKMSProviderServerError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	provider := NewKMSProvider(config.KMSConfig{URL: server.URL, KeyID: "primary"})
+
+	if _, err := provider.WrapKey(context.Background(), testMasterKey(9)); !errors.Is(err, ErrWrapFailed) ||
+		!strings.Contains(err.Error(), "503") {
+		t.Fatalf("expected ErrWrapFailed with status 503, got %v", err)
+	}
+
+	// a successful response without a ciphertext isn't a wrapped key
+	empty := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		kmsRespond(w, kmsEncryptResponse{})
+	}))
+	defer empty.Close()
+
+	provider = NewKMSProvider(config.KMSConfig{URL: empty.URL, KeyID: "primary"})
+
+	if _, err := provider.WrapKey(context.Background(), testMasterKey(9)); !errors.Is(err, ErrWrapFailed) {
+		t.Fatalf("expected ErrWrapFailed for an empty ciphertext, got %v", err)
+	}
+}
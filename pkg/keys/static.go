@@ -0,0 +1,167 @@
+package keys
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// wrapAssociatedData is authenticated with every wrapped key so a wrapped key can't be
+// confused with any other value encrypted under the master key.
+var wrapAssociatedData = []byte("passman:data-key")
+
+// StaticProvider wraps data keys with AES-256-GCM using master keys held in memory. It
+// backs both the env provider, which uses ENCRYPTION_SECRET, and the file provider. Each
+// key is identified by a fingerprint derived from the key itself.
+type StaticProvider struct {
+	// keys are ordered from newest to oldest, the first is the primary key
+	keys []staticKey
+}
+
+type staticKey struct {
+	id   string
+	aead cipher.AEAD
+}
+
+// NewStaticProvider creates a provider for the provided 32 byte master keys. The first key
+// is the primary key, the rest are only used to unwrap keys that have not been rotated yet.
+func NewStaticProvider(masterKeys ...[]byte) (*StaticProvider, error) {
+	if len(masterKeys) == 0 {
+		return nil, fmt.Errorf("at least one master key is required")
+	}
+
+	p := &StaticProvider{}
+
+	for _, masterKey := range masterKeys {
+		if len(masterKey) != DataKeySize {
+			return nil, fmt.Errorf("master key must be %d bytes, got %d", DataKeySize, len(masterKey))
+		}
+
+		block, err := aes.NewCipher(masterKey)
+		if err != nil {
+			return nil, err
+		}
+
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, err
+		}
+
+		p.keys = append(p.keys, staticKey{id: fingerprint(masterKey), aead: aead})
+	}
+
+	return p, nil
+}
+
+// NewFileProvider creates a provider using the base64 encoded master keys stored in the file
+// at path, one per line from newest to oldest. A key file can be created with
+// `passman keys generate` and a new primary key added with `passman keys add`.
+func NewFileProvider(path string) (*StaticProvider, error) {
+	masterKeys, err := ReadKeyFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewStaticProvider(masterKeys...)
+}
+
+// ReadKeyFile returns the master keys stored in a key file, ignoring blank lines.
+func ReadKeyFile(path string) ([][]byte, error) {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key file: %w", err)
+	}
+
+	var masterKeys [][]byte
+
+	scanner := bufio.NewScanner(bytes.NewReader(contents))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		masterKey, err := base64.StdEncoding.DecodeString(line)
+		if err != nil {
+			return nil, fmt.Errorf("key file must contain base64 encoded keys: %w", err)
+		}
+
+		masterKeys = append(masterKeys, masterKey)
+	}
+
+	return masterKeys, scanner.Err()
+}
+
+// PrimaryKeyID implements KeyProvider.
+func (p *StaticProvider) PrimaryKeyID() string {
+	return p.keys[0].id
+}
+
+// WrapKey implements KeyProvider.
+func (p *StaticProvider) WrapKey(ctx context.Context, dataKey []byte) (string, error) {
+	primary := p.keys[0]
+
+	nonce := make([]byte, primary.aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", ErrWrapFailed
+	}
+
+	sealed := primary.aead.Seal(nonce, nonce, dataKey, wrapAssociatedData)
+
+	return formatWrapped(primary.id, base64.StdEncoding.EncodeToString(sealed)), nil
+}
+
+// UnwrapKey implements KeyProvider. Keys wrapped before master key IDs were introduced are
+// tried against every configured key.
+func (p *StaticProvider) UnwrapKey(ctx context.Context, wrapped string) ([]byte, error) {
+	keyID, ciphertext, hasID := parseWrapped(wrapped)
+
+	sealed, err := base64.StdEncoding.DecodeString(ciphertext)
+	if err != nil {
+		return nil, ErrUnwrapFailed
+	}
+
+	matched := false
+
+	for _, key := range p.keys {
+		if hasID && key.id != keyID {
+			continue
+		}
+		matched = true
+
+		if len(sealed) < key.aead.NonceSize() {
+			return nil, ErrUnwrapFailed
+		}
+
+		nonce, ciphertext := sealed[:key.aead.NonceSize()], sealed[key.aead.NonceSize():]
+
+		dataKey, err := key.aead.Open(nil, nonce, ciphertext, wrapAssociatedData)
+		if err == nil {
+			return dataKey, nil
+		}
+	}
+
+	if !matched {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownKey, keyID)
+	}
+
+	return nil, ErrUnwrapFailed
+}
+
+// GenerateMasterKey returns a new random master key encoded for use in a key file.
+func GenerateMasterKey() (string, error) {
+	key, err := NewDataKey()
+	if err != nil {
+		return "", err
+	}
+
+	return base64.StdEncoding.EncodeToString(key), nil
+}
@@ -0,0 +1,169 @@
+package keys
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func testMasterKey(b byte) []byte {
+	return bytes.Repeat([]byte{b}, DataKeySize)
+}
+
+func newTestStaticProvider(t *testing.T, masterKeys ...[]byte) *StaticProvider {
+	t.Helper()
+
+	provider, err := NewStaticProvider(masterKeys...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return provider
+}
+
+func TestStaticProviderRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	provider := newTestStaticProvider(t, testMasterKey(1))
+
+	dataKey, err := NewDataKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wrapped, err := provider.WrapKey(ctx, dataKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.Contains(wrapped, base64.StdEncoding.EncodeToString(dataKey)) {
+		t.Fatal("expected the wrapped key not to contain the data key")
+	}
+
+	unwrapped, err := provider.UnwrapKey(ctx, wrapped)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(unwrapped, dataKey) {
+		t.Fatal("expected the unwrapped key to equal the data key")
+	}
+
+	again, err := provider.WrapKey(ctx, dataKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if again == wrapped {
+		t.Fatal("expected every wrap to use a new nonce")
+	}
+}
+
+func TestWrappedKeyFormat(t *testing.T) {
+	provider := newTestStaticProvider(t, testMasterKey(1))
+
+	wrapped, err := provider.WrapKey(context.Background(), testMasterKey(9))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parts := strings.SplitN(wrapped, ":", 3)
+	if len(parts) != 3 || parts[0]+":" != wrappedKeyPrefix {
+		t.Fatalf("expected wk1:<key ID>:<ciphertext>, got %q", wrapped)
+	}
+
+	keyID, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil || string(keyID) != provider.PrimaryKeyID() {
+		t.Fatalf("expected the encoded key ID %s, got %q", provider.PrimaryKeyID(), parts[1])
+	}
+
+	if WrappedKeyID(wrapped) != provider.PrimaryKeyID() {
+		t.Fatalf("expected WrappedKeyID to return %s, got %s", provider.PrimaryKeyID(), WrappedKeyID(wrapped))
+	}
+
+	// key IDs are fingerprints, so they are stable and differ between keys
+	if provider.PrimaryKeyID() != fingerprint(testMasterKey(1)) || provider.PrimaryKeyID() == fingerprint(testMasterKey(2)) {
+		t.Fatalf("expected the key ID to be the key's fingerprint, got %s", provider.PrimaryKeyID())
+	}
+
+	tests := []struct {
+		name    string
+		wrapped string
+		keyID   string
+	}{
+		{"without a key ID", "c2VhbGVk", ""},
+		{"without a ciphertext separator", "wk1:a2V5", ""},
+		{"invalid key ID", "wk1:!!!:c2VhbGVk", ""},
+		{"ciphertext with separators", "wk1:a2V5:wk1:b3RoZXI:c2VhbGVk", "key"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if keyID := WrappedKeyID(tt.wrapped); keyID != tt.keyID {
+				t.Fatalf("expected key ID %q, got %q", tt.keyID, keyID)
+			}
+		})
+	}
+}
+
+func TestStaticProviderKeyIDs(t *testing.T) {
+	ctx := context.Background()
+	dataKey := testMasterKey(9)
+
+	old := newTestStaticProvider(t, testMasterKey(1))
+	wrapped, err := old.WrapKey(ctx, dataKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// keys wrapped by an older master key still unwrap after a new primary key is added
+	rotated := newTestStaticProvider(t, testMasterKey(2), testMasterKey(1))
+	if unwrapped, err := rotated.UnwrapKey(ctx, wrapped); err != nil || !bytes.Equal(unwrapped, dataKey) {
+		t.Fatalf("expected the old key to unwrap, got %v", err)
+	}
+
+	rewrapped, err := rotated.WrapKey(ctx, dataKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if WrappedKeyID(rewrapped) != fingerprint(testMasterKey(2)) {
+		t.Fatal("expected new keys to be wrapped by the primary key")
+	}
+
+	if _, err := old.UnwrapKey(ctx, rewrapped); !errors.Is(err, ErrUnknownKey) {
+		t.Fatalf("expected ErrUnknownKey for a key that isn't configured, got %v", err)
+	}
+
+	// a wrapped key naming the wrong master key is rejected, even though that key is configured
+	_, ciphertext, _ := parseWrapped(wrapped)
+	mislabelled := formatWrapped(fingerprint(testMasterKey(2)), ciphertext)
+	if _, err := rotated.UnwrapKey(ctx, mislabelled); !errors.Is(err, ErrUnwrapFailed) {
+		t.Fatalf("expected ErrUnwrapFailed for the wrong key ID, got %v", err)
+	}
+
+	// keys wrapped before key IDs were introduced are tried against every key
+	if unwrapped, err := rotated.UnwrapKey(ctx, ciphertext); err != nil || !bytes.Equal(unwrapped, dataKey) {
+		t.Fatalf("expected a key without an ID to unwrap, got %v", err)
+	}
+
+	tampered := []byte(ciphertext)
+	tampered[len(tampered)-2] ^= 1
+	for _, value := range []string{string(tampered), "not base64", "c2hvcnQ", ""} {
+		if _, err := rotated.UnwrapKey(ctx, value); !errors.Is(err, ErrUnwrapFailed) {
+			t.Errorf("%q: expected ErrUnwrapFailed, got %v", value, err)
+		}
+	}
+}
+
+func TestNewStaticProviderKeySize(t *testing.T) {
+	if _, err := NewStaticProvider(); err == nil {
+		t.Fatal("expected an error without master keys")
+	}
+
+	if _, err := NewStaticProvider(testMasterKey(1), []byte("too short")); err == nil {
+		t.Fatal("expected an error for a short master key")
+	}
+}
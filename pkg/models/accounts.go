@@ -2,7 +2,12 @@ package models
 
 import (
 	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
 	"errors"
+	"fmt"
+	"sync"
 
 	"github.com/alexedwards/argon2id"
 )
@@ -11,34 +16,117 @@ var (
 	ErrInvalidCredentials = errors.New("invalid credentials")
 )
 
-// Account represents a user account of any type. An account may be stored with an
-// empty string for a password, indicating that they must log in with OAuth.
+// dummyPasswordHash is compared against when logging in to an unknown email, so the response
+// takes as long as it does for a registered one.
+var dummyPasswordHash = sync.OnceValue(func() string {
+	hash, err := argon2id.CreateHash("passman:dummy-password", argon2id.DefaultParams)
+	if err != nil {
+		panic(err)
+	}
+	return hash
+})
+
+const (
+	// VaultModeServer accounts send their password to the server, which encrypts and
+	// decrypts their notes.
+	VaultModeServer = "server"
+	// VaultModeZeroKnowledge accounts derive their keys on the client from the master
+	// password. The server only ever sees a derived auth hash and client side ciphertext.
+	VaultModeZeroKnowledge = "zero_knowledge"
+
+	// preloginSaltSize is the size of the salts made up for unknown emails, matching the salts
+	// zero-knowledge clients generate.
+	preloginSaltSize = 16
+	// preloginSaltInfo is prefixed to the email when making up a salt for it.
+	preloginSaltInfo = "passman:prelogin-salt:"
+)
+
+// Account represents a user account of any type. An account may be stored with an empty
+// string for a password, indicating that they must log in with OAuth. DataKey holds the
+// account's data encryption key wrapped by the master key, and IndexKey the key its blind
+// indexes are hashed with, wrapped the same way. PublicKey and PrivateKey are the X25519 key
+// pair notes are shared with it by, with the private key encrypted with the data key. For
+// zero-knowledge accounts Password holds a hash of the client derived auth hash, and KDFParams
+// holds the parameters the client needs to derive its keys. SessionEpoch is recorded in every
+// session issued to the account, and sessions from an earlier epoch are no longer accepted.
 type Account struct {
-	ID       int64  `db:"id"`
-	Email    string `db:"email"`
-	Password string `db:"password"`
-	Name     string `db:"name"`
+	ID           int64  `db:"id"`
+	Email        string `db:"email"`
+	Password     string `db:"password"`
+	Name         string `db:"name"`
+	DataKey      string `db:"data_key"`
+	IndexKey     string `db:"index_key"`
+	PublicKey    string `db:"public_key"`
+	PrivateKey   string `db:"private_key"`
+	VaultMode    string `db:"vault_mode"`
+	SessionEpoch int64  `db:"session_epoch"`
+	KDFParams
+	AccountPolicy
 	Base
 }
 
-// Represents the required input to the register method.
+// AccountPolicy holds the rules an account applies to the notes it saves. MinPasswordStrength
+// is the lowest strength score (0-4) a note value may have, where 0 accepts anything.
+// MaxNoteVersions is how many previous versions of each note are kept, where 0 keeps none.
+type AccountPolicy struct {
+	MinPasswordStrength int `json:"min_password_strength" db:"min_password_strength" validate:"min=0,max=4"`
+	MaxNoteVersions     int `json:"max_note_versions" db:"max_note_versions" validate:"min=0,max=100"`
+}
+
+// KDFParams are the Argon2id parameters a zero-knowledge client uses to derive its keys from
+// the master password. They are chosen by the client at registration and returned before
+// login. Memory is in KiB, and the minimums follow the OWASP recommendations for Argon2id.
+type KDFParams struct {
+	Salt        string `json:"salt" db:"kdf_salt" validate:"required,base64"`
+	Memory      uint32 `json:"memory" db:"kdf_memory" validate:"min=19456,max=4194304"`
+	Iterations  uint32 `json:"iterations" db:"kdf_iterations" validate:"min=2,max=64"`
+	Parallelism uint8  `json:"parallelism" db:"kdf_parallelism" validate:"min=1,max=16"`
+}
+
+// DefaultKDFParams returns the recommended Argon2id cost settings for zero-knowledge clients,
+// without a salt.
+func DefaultKDFParams() KDFParams {
+	return KDFParams{Memory: 64 * 1024, Iterations: 3, Parallelism: 4}
+}
+
+// Represents the required input to the register method. Zero-knowledge accounts provide an
+// auth hash and KDF parameters instead of a password.
 type AccountCreateRequest struct {
-	Email    string `json:"email"`
-	Password string `json:"password"`
-	Name     string `json:"name"`
+	Email     string     `json:"email" validate:"required,email"`
+	Password  string     `json:"password" validate:"excluded_if=VaultMode zero_knowledge,required_unless=VaultMode zero_knowledge,omitempty,min=8,max=128"`
+	Name      string     `json:"name" validate:"required,max=255"`
+	VaultMode string     `json:"vault_mode" validate:"omitempty,oneof=server zero_knowledge"`
+	AuthHash  string     `json:"auth_hash" validate:"required_if=VaultMode zero_knowledge,omitempty,base64,max=512"`
+	KDF       *KDFParams `json:"kdf" validate:"required_if=VaultMode zero_knowledge,omitempty"`
 }
 
-// Represents the required input to the login method.
+// Represents the required input to the login method. Zero-knowledge accounts log in with
+// their auth hash instead of a password.
 type AccountLoginRequest struct {
-	Email    string `json:"email"`
-	Password string `json:"password"`
+	Email    string `json:"email" validate:"required"`
+	Password string `json:"password" validate:"required_without=AuthHash"`
+	AuthHash string `json:"auth_hash" validate:"required_without=Password"`
+}
+
+// Represents the required input to the prelogin method.
+type AccountPreloginRequest struct {
+	Email string `json:"email" validate:"required"`
+}
+
+// Tells the client how to log in. KDF is only set for zero-knowledge accounts.
+type AccountPreloginResponse struct {
+	VaultMode string     `json:"vault_mode"`
+	KDF       *KDFParams `json:"kdf,omitempty"`
 }
 
 // Represents the type of the response from the get all and get one methods.
 type AccountGetResponse struct {
-	ID    int64  `db:"id"`
-	Email string `db:"email"`
-	Name  string `db:"name"`
+	ID        int64         `json:"id" db:"id"`
+	Email     string        `json:"email" db:"email"`
+	Name      string        `json:"name" db:"name"`
+	VaultMode string        `json:"vault_mode" db:"vault_mode"`
+	KDF       *KDFParams    `json:"kdf,omitempty"`
+	Policy    AccountPolicy `json:"policy"`
 }
 
 // Defines the required interface to implement an account store.
@@ -46,10 +134,24 @@ type accountStore interface {
 	AccountCreate(ctx context.Context, account Account) (Account, error)
 	AccountGetByID(ctx context.Context, id int64) (Account, error)
 	AccountGetByEmail(ctx context.Context, email string) (Account, error)
+	// AccountUpdateDataKey replaces the wrapped data key of an account, but only if it still
+	// matches currentDataKey. Returns ErrNotFound if the account does not exist or its data key
+	// has changed.
+	AccountUpdateDataKey(ctx context.Context, id int64, currentDataKey string, newDataKey string) error
+	// AccountUpdateIndexKey is AccountUpdateDataKey for the wrapped blind index key.
+	AccountUpdateIndexKey(ctx context.Context, id int64, currentIndexKey string, newIndexKey string) error
+	AccountUpdatePolicy(ctx context.Context, id int64, policy AccountPolicy) error
+	// AccountRevokeSessions increments the account's session epoch. Returns ErrNotFound if the
+	// account does not exist.
+	AccountRevokeSessions(ctx context.Context, id int64) error
+	// AccountListIDs returns up to limit account IDs greater than afterID in ascending order.
+	AccountListIDs(ctx context.Context, afterID int64, limit int) ([]int64, error)
 	AccountDelete(ctx context.Context, id int64) error
 }
 
 // Checks for existing account, creates a new account and saves it with the password hashed.
+// Zero-knowledge accounts save their auth hash hashed in place of the password, so a leaked
+// database can't be used to log in.
 func (m *Models) AccountRegister(ctx context.Context, account AccountCreateRequest) (IDResponse, error) {
 	_, err := m.store.AccountGetByEmail(ctx, account.Email)
 	if err == nil {
@@ -59,15 +161,32 @@ func (m *Models) AccountRegister(ctx context.Context, account AccountCreateReque
 		return IDResponse{}, err
 	}
 
-	hashedPassword, err := argon2id.CreateHash(account.Password, argon2id.DefaultParams)
+	secret := account.Password
+	if account.VaultMode == VaultModeZeroKnowledge {
+		secret = account.AuthHash
+	}
+
+	hashedPassword, err := argon2id.CreateHash(secret, argon2id.DefaultParams)
 	if err != nil {
 		return IDResponse{}, errors.New("password hash failed")
 	}
 
+	dataKey, err := m.newWrappedDataKey(ctx)
+	if err != nil {
+		return IDResponse{}, err
+	}
+
 	accountToStore := Account{
-		Name:     account.Name,
-		Email:    account.Email,
-		Password: hashedPassword,
+		Name:      account.Name,
+		Email:     account.Email,
+		Password:  hashedPassword,
+		DataKey:   dataKey,
+		VaultMode: VaultModeServer,
+	}
+
+	if account.VaultMode == VaultModeZeroKnowledge {
+		accountToStore.VaultMode = VaultModeZeroKnowledge
+		accountToStore.KDFParams = *account.KDF
 	}
 
 	savedAccount, err := m.store.AccountCreate(ctx, accountToStore)
@@ -81,14 +200,29 @@ func (m *Models) AccountRegister(ctx context.Context, account AccountCreateReque
 }
 
 // Checks the provided credentials against the existing account and it's password hash. Returns an error
-// if the credentials are invalid.
+// if the credentials are invalid. An unknown email is reported as invalid credentials after
+// checking the password against a dummy hash, so neither the response nor how long it takes
+// reveals which emails are registered.
 func (m *Models) AccountLogin(ctx context.Context, credentials AccountLoginRequest) (IDResponse, error) {
 	account, err := m.store.AccountGetByEmail(ctx, credentials.Email)
+	if errors.Is(err, ErrNotFound) {
+		argon2id.ComparePasswordAndHash(credentials.Password+credentials.AuthHash, dummyPasswordHash())
+		return IDResponse{}, ErrInvalidCredentials
+	}
 	if err != nil {
 		return IDResponse{}, err
 	}
 
-	match, err := argon2id.ComparePasswordAndHash(credentials.Password, account.Password)
+	secret := credentials.Password
+	if account.VaultMode == VaultModeZeroKnowledge {
+		secret = credentials.AuthHash
+	}
+	if secret == "" {
+		argon2id.ComparePasswordAndHash(credentials.Password+credentials.AuthHash, dummyPasswordHash())
+		return IDResponse{}, ErrInvalidCredentials
+	}
+
+	match, err := argon2id.ComparePasswordAndHash(secret, account.Password)
 	if err != nil {
 		return IDResponse{}, err
 	}
@@ -106,9 +240,100 @@ func (m *Models) AccountGetByID(ctx context.Context, id int64) (AccountGetRespon
 		return AccountGetResponse{}, err
 	}
 
-	return AccountGetResponse{
-		ID:    account.ID,
-		Email: account.Email,
-		Name:  account.Name,
+	response := AccountGetResponse{
+		ID:        account.ID,
+		Email:     account.Email,
+		Name:      account.Name,
+		VaultMode: account.VaultMode,
+		Policy:    account.AccountPolicy,
+	}
+
+	if account.VaultMode == VaultModeZeroKnowledge {
+		response.KDF = &account.KDFParams
+	}
+
+	return response, nil
+}
+
+// AccountSessionEpoch returns the session epoch of the account with the provided ID, which
+// sessions must have been issued in to be accepted. Returns ErrNotFound if the account does not
+// exist or has been deleted.
+func (m *Models) AccountSessionEpoch(ctx context.Context, id int64) (int64, error) {
+	account, err := m.store.AccountGetByID(ctx, id)
+	if err != nil {
+		return 0, err
+	}
+
+	return account.SessionEpoch, nil
+}
+
+// AccountLogout ends every session of the account, on every device, by moving it to a new
+// session epoch.
+func (m *Models) AccountLogout(ctx context.Context, id int64) error {
+	return m.store.AccountRevokeSessions(ctx, id)
+}
+
+// AccountUpdatePolicy replaces the account's note policy. Zero-knowledge accounts can't require
+// a password strength since the server never sees their note values. Versions beyond a lowered
+// MaxNoteVersions are removed straight away.
+func (m *Models) AccountUpdatePolicy(ctx context.Context, id int64, policy AccountPolicy) (AccountPolicy, error) {
+	account, err := m.store.AccountGetByID(ctx, id)
+	if err != nil {
+		return AccountPolicy{}, err
+	}
+
+	if account.VaultMode == VaultModeZeroKnowledge && policy.MinPasswordStrength > 0 {
+		return AccountPolicy{}, fmt.Errorf("%w: password strength can't be checked for zero-knowledge vaults", ErrInvalidInput)
+	}
+
+	if err := m.store.AccountUpdatePolicy(ctx, id, policy); err != nil {
+		return AccountPolicy{}, err
+	}
+
+	if policy.MaxNoteVersions < account.MaxNoteVersions {
+		if err := m.store.NoteVersionPrune(ctx, id, policy.MaxNoteVersions); err != nil {
+			return AccountPolicy{}, err
+		}
+	}
+
+	return policy, nil
+}
+
+// AccountPrelogin returns the vault mode of the account with the provided email, along with
+// the KDF parameters for zero-knowledge accounts. Unknown emails are reported as zero-knowledge
+// accounts with the default parameters and a salt derived from the email and SECRET_KEY, so
+// they can't be told apart from real zero-knowledge accounts and the same email always gets the
+// same salt. Server mode accounts are still reported as such, since their clients have to send
+// the password.
+func (m *Models) AccountPrelogin(ctx context.Context, input AccountPreloginRequest) (AccountPreloginResponse, error) {
+	account, err := m.store.AccountGetByEmail(ctx, input.Email)
+	if errors.Is(err, ErrNotFound) {
+		return AccountPreloginResponse{
+			VaultMode: VaultModeZeroKnowledge,
+			KDF:       m.unknownEmailKDFParams(input.Email),
+		}, nil
+	}
+	if err != nil {
+		return AccountPreloginResponse{}, err
+	}
+
+	if account.VaultMode != VaultModeZeroKnowledge {
+		return AccountPreloginResponse{VaultMode: VaultModeServer}, nil
+	}
+
+	return AccountPreloginResponse{
+		VaultMode: VaultModeZeroKnowledge,
+		KDF:       &account.KDFParams,
 	}, nil
 }
+
+// unknownEmailKDFParams makes up the KDF parameters of an email with no account.
+func (m *Models) unknownEmailKDFParams(email string) *KDFParams {
+	mac := hmac.New(sha256.New, []byte(m.config.SecretKey))
+	mac.Write([]byte(preloginSaltInfo + email))
+
+	params := DefaultKDFParams()
+	params.Salt = base64.StdEncoding.EncodeToString(mac.Sum(nil)[:preloginSaltSize])
+
+	return &params
+}
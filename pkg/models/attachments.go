@@ -0,0 +1,449 @@
+package models
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"mime"
+	"time"
+
+	"github.com/oalexander6/passman/pkg/keys"
+	"github.com/oalexander6/passman/pkg/logger"
+)
+
+const (
+	// attachmentChunkSize is the size of every plaintext chunk but the last. Each chunk is
+	// encrypted and stored as its own blob, so uploads and downloads hold one chunk in memory.
+	attachmentChunkSize = 1 << 20
+
+	// attachmentChunkOverhead is the nonce and tag AES-GCM adds to each chunk.
+	attachmentChunkOverhead = 12 + 16
+
+	defaultContentType = "application/octet-stream"
+)
+
+// Attachment is a file attached to a note. Its contents are split into chunks which are
+// encrypted with the attachment's own file key and kept in the blob store. The file key is
+// stored wrapped by the account's data key.
+type Attachment struct {
+	ID          int64     `db:"id"`
+	AccountID   int64     `db:"account_id"`
+	NoteID      int64     `db:"note_id"`
+	Name        string    `db:"name"`
+	ContentType string    `db:"content_type"`
+	Size        int64     `db:"size"`
+	Chunks      int64     `db:"chunks"`
+	FileKey     string    `db:"file_key"`
+	Complete    bool      `db:"complete"`
+	CreatedAt   time.Time `db:"created_at"`
+}
+
+// AttachmentCreateRequest represents the details of an uploaded file.
+type AttachmentCreateRequest struct {
+	Name        string `validate:"required,max=255"`
+	ContentType string `validate:"max=255"`
+}
+
+// AttachmentResponse describes an attachment without its contents.
+type AttachmentResponse struct {
+	ID          int64     `json:"id"`
+	NoteID      int64     `json:"note_id"`
+	Name        string    `json:"name"`
+	ContentType string    `json:"content_type"`
+	Size        int64     `json:"size"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// AttachmentUsageResponse reports how many bytes of attachments the account stores, out of its
+// quota. A quota of 0 means there is no limit.
+type AttachmentUsageResponse struct {
+	Used  int64 `json:"used"`
+	Quota int64 `json:"quota"`
+}
+
+// Defines the required interface to implement attachment storage. Every method is scoped to
+// the owning account, and only complete attachments are returned.
+type attachmentStore interface {
+	// AttachmentCreate inserts an incomplete attachment, before any of its chunks are written.
+	AttachmentCreate(ctx context.Context, attachment Attachment) (Attachment, error)
+	// AttachmentComplete records the size, chunk count and wrapped file key of an incomplete
+	// attachment and marks it complete. Returns ErrQuotaExceeded if the account's complete
+	// attachments would then take more than quota bytes, unless quota is 0.
+	AttachmentComplete(ctx context.Context, attachment Attachment, quota int64) error
+	AttachmentGetByID(ctx context.Context, accountID int64, noteID int64, id int64) (Attachment, error)
+	AttachmentGetByNoteID(ctx context.Context, accountID int64, noteID int64) ([]Attachment, error)
+	// AttachmentDelete removes the attachment's row, whether or not it is complete.
+	AttachmentDelete(ctx context.Context, accountID int64, id int64) error
+	// AttachmentUsage returns the total size of the account's complete attachments.
+	AttachmentUsage(ctx context.Context, accountID int64) (int64, error)
+}
+
+// AttachmentCreate encrypts the file read from r and attaches it to the account's note. The file
+// is streamed into the blob store one chunk at a time. Returns ErrAttachmentsUnavailable if no
+// blob store is configured, ErrQuotaExceeded if the file would take the account over its quota,
+// in which case nothing is kept, and ErrZeroKnowledgeVault for zero-knowledge accounts, whose
+// files and file names the server could read.
+func (m *Models) AttachmentCreate(ctx context.Context, accountID int64, noteID int64, input AttachmentCreateRequest, r io.Reader) (AttachmentResponse, error) {
+	if m.blobs == nil {
+		return AttachmentResponse{}, ErrAttachmentsUnavailable
+	}
+
+	account, err := m.store.AccountGetByID(ctx, accountID)
+	if err != nil {
+		return AttachmentResponse{}, err
+	}
+
+	if account.VaultMode == VaultModeZeroKnowledge {
+		return AttachmentResponse{}, ErrZeroKnowledgeVault
+	}
+
+	if _, err := m.store.NoteGetByID(ctx, accountID, noteID); err != nil {
+		return AttachmentResponse{}, err
+	}
+
+	quota := m.config.Attachments.QuotaBytes
+	used, err := m.store.AttachmentUsage(ctx, accountID)
+	if err != nil {
+		return AttachmentResponse{}, err
+	}
+	if quota > 0 && used >= quota {
+		return AttachmentResponse{}, ErrQuotaExceeded
+	}
+
+	dataKey, err := m.dataKeyForAccount(ctx, account)
+	if err != nil {
+		return AttachmentResponse{}, err
+	}
+
+	attachment, err := m.store.AttachmentCreate(ctx, Attachment{
+		AccountID:   accountID,
+		NoteID:      noteID,
+		Name:        input.Name,
+		ContentType: normalizeContentType(input.ContentType),
+	})
+	if err != nil {
+		return AttachmentResponse{}, err
+	}
+
+	fileKey := make([]byte, keys.DataKeySize)
+	if _, err := io.ReadFull(rand.Reader, fileKey); err != nil {
+		m.discardAttachment(ctx, attachment)
+		return AttachmentResponse{}, ErrEncryptFailed
+	}
+
+	attachment.Size, attachment.Chunks, err = m.writeAttachmentChunks(ctx, attachment, fileKey, r, quota-used)
+	if err == nil {
+		attachment.FileKey, err = wrapFileKey(dataKey, attachment, fileKey)
+	}
+	if err == nil {
+		err = m.store.AttachmentComplete(ctx, attachment, quota)
+	}
+	if err != nil {
+		m.discardAttachment(ctx, attachment)
+		return AttachmentResponse{}, err
+	}
+
+	return attachmentResponse(attachment), nil
+}
+
+// AttachmentGetByNoteID lists the attachments of the account's note.
+func (m *Models) AttachmentGetByNoteID(ctx context.Context, accountID int64, noteID int64) ([]AttachmentResponse, error) {
+	if _, err := m.store.NoteGetByID(ctx, accountID, noteID); err != nil {
+		return []AttachmentResponse{}, err
+	}
+
+	attachments, err := m.store.AttachmentGetByNoteID(ctx, accountID, noteID)
+	if err != nil {
+		return []AttachmentResponse{}, err
+	}
+
+	responses := make([]AttachmentResponse, len(attachments))
+	for i, attachment := range attachments {
+		responses[i] = attachmentResponse(attachment)
+	}
+
+	return responses, nil
+}
+
+// AttachmentOpen returns the attachment of the account's note with the provided ID, along with a
+// reader that decrypts its contents chunk by chunk. The first chunk is read before returning, so
+// a missing or corrupt attachment is reported before anything is sent. The reader must be
+// closed. Returns ErrZeroKnowledgeVault for zero-knowledge accounts.
+func (m *Models) AttachmentOpen(ctx context.Context, accountID int64, noteID int64, attachmentID int64) (AttachmentResponse, io.ReadCloser, error) {
+	if m.blobs == nil {
+		return AttachmentResponse{}, nil, ErrAttachmentsUnavailable
+	}
+
+	account, err := m.store.AccountGetByID(ctx, accountID)
+	if err != nil {
+		return AttachmentResponse{}, nil, err
+	}
+
+	if account.VaultMode == VaultModeZeroKnowledge {
+		return AttachmentResponse{}, nil, ErrZeroKnowledgeVault
+	}
+
+	if _, err := m.store.NoteGetByID(ctx, accountID, noteID); err != nil {
+		return AttachmentResponse{}, nil, err
+	}
+
+	attachment, err := m.store.AttachmentGetByID(ctx, accountID, noteID, attachmentID)
+	if err != nil {
+		return AttachmentResponse{}, nil, err
+	}
+
+	dataKey, err := m.dataKeyForAccount(ctx, account)
+	if err != nil {
+		return AttachmentResponse{}, nil, err
+	}
+
+	fileKey, err := unwrapFileKey(dataKey, attachment)
+	if err != nil {
+		return AttachmentResponse{}, nil, err
+	}
+
+	reader := &attachmentReader{ctx: ctx, models: m, attachment: attachment, fileKey: fileKey}
+	if err := reader.nextChunk(); err != nil {
+		return AttachmentResponse{}, nil, err
+	}
+
+	return attachmentResponse(attachment), reader, nil
+}
+
+// AttachmentDelete removes the attachment of the account's note with the provided ID, along with
+// its chunks.
+func (m *Models) AttachmentDelete(ctx context.Context, accountID int64, noteID int64, attachmentID int64) error {
+	if m.blobs == nil {
+		return ErrAttachmentsUnavailable
+	}
+
+	attachment, err := m.store.AttachmentGetByID(ctx, accountID, noteID, attachmentID)
+	if err != nil {
+		return err
+	}
+
+	if err := m.store.AttachmentDelete(ctx, accountID, attachment.ID); err != nil {
+		return err
+	}
+
+	m.deleteAttachmentChunks(ctx, attachment)
+
+	return nil
+}
+
+// AttachmentUsage reports the account's attachment storage against its quota.
+func (m *Models) AttachmentUsage(ctx context.Context, accountID int64) (AttachmentUsageResponse, error) {
+	used, err := m.store.AttachmentUsage(ctx, accountID)
+	if err != nil {
+		return AttachmentUsageResponse{}, err
+	}
+
+	return AttachmentUsageResponse{Used: used, Quota: m.config.Attachments.QuotaBytes}, nil
+}
+
+// writeAttachmentChunks encrypts r into chunks and puts each one in the blob store. Returns the
+// plaintext size and number of chunks, or ErrQuotaExceeded once more than remaining bytes are
+// read when the quota is enabled. Every file has at least one chunk, and the last chunk is
+// marked as final so a truncated file can't be passed off as complete.
+func (m *Models) writeAttachmentChunks(ctx context.Context, attachment Attachment, fileKey []byte, r io.Reader, remaining int64) (int64, int64, error) {
+	quota := m.config.Attachments.QuotaBytes
+	reader := bufio.NewReader(r)
+	chunk := make([]byte, attachmentChunkSize)
+
+	var size, index int64
+	for {
+		n, err := io.ReadFull(reader, chunk)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return 0, 0, err
+		}
+
+		size += int64(n)
+		if quota > 0 && size > remaining {
+			return 0, 0, ErrQuotaExceeded
+		}
+
+		// the chunk is final if nothing follows it
+		_, peekErr := reader.Peek(1)
+		if peekErr != nil && peekErr != io.EOF {
+			return 0, 0, peekErr
+		}
+		final := peekErr == io.EOF
+
+		sealed, err := sealGCM(fileKey, chunk[:n], attachmentChunkAssociatedData(attachment, index, final))
+		if err != nil {
+			return 0, 0, err
+		}
+
+		if err := m.blobs.Put(ctx, attachmentChunkKey(attachment, index), bytes.NewReader(sealed), int64(len(sealed))); err != nil {
+			return 0, 0, err
+		}
+
+		index++
+		if final {
+			return size, index, nil
+		}
+	}
+}
+
+// discardAttachment removes an attachment that failed to upload, along with any chunks that
+// were written. It runs even if the request was cancelled.
+func (m *Models) discardAttachment(ctx context.Context, attachment Attachment) {
+	ctx = context.WithoutCancel(ctx)
+
+	if err := m.store.AttachmentDelete(ctx, attachment.AccountID, attachment.ID); err != nil {
+		logger.Log.Error().Msgf("Failed to remove incomplete attachment %d: %s", attachment.ID, err)
+	}
+
+	// the chunk count isn't known for a failed upload, so delete until a chunk is missing
+	for index := int64(0); ; index++ {
+		key := attachmentChunkKey(attachment, index)
+
+		body, err := m.blobs.Get(ctx, key)
+		if err != nil {
+			return
+		}
+		body.Close()
+
+		if err := m.blobs.Delete(ctx, key); err != nil {
+			logger.Log.Error().Msgf("Failed to remove chunk %s: %s", key, err)
+			return
+		}
+	}
+}
+
+// deleteAttachmentChunks removes every chunk of a complete attachment. Failures are logged,
+// since the attachment is already gone and leftover chunks can't be decrypted without its key.
+func (m *Models) deleteAttachmentChunks(ctx context.Context, attachment Attachment) {
+	ctx = context.WithoutCancel(ctx)
+
+	for index := int64(0); index < attachment.Chunks; index++ {
+		key := attachmentChunkKey(attachment, index)
+		if err := m.blobs.Delete(ctx, key); err != nil {
+			logger.Log.Error().Msgf("Failed to remove chunk %s: %s", key, err)
+		}
+	}
+}
+
+// attachmentReader decrypts an attachment one chunk at a time.
+type attachmentReader struct {
+	ctx        context.Context
+	models     *Models
+	attachment Attachment
+	fileKey    []byte
+	next       int64
+	buf        []byte
+}
+
+func (r *attachmentReader) Read(p []byte) (int, error) {
+	for len(r.buf) == 0 {
+		if r.next >= r.attachment.Chunks {
+			return 0, io.EOF
+		}
+
+		if err := r.nextChunk(); err != nil {
+			return 0, err
+		}
+	}
+
+	n := copy(p, r.buf)
+	r.buf = r.buf[n:]
+
+	return n, nil
+}
+
+func (r *attachmentReader) Close() error {
+	return nil
+}
+
+// nextChunk reads and decrypts the next chunk into the buffer.
+func (r *attachmentReader) nextChunk() error {
+	index := r.next
+	final := index == r.attachment.Chunks-1
+
+	body, err := r.models.blobs.Get(r.ctx, attachmentChunkKey(r.attachment, index))
+	if err != nil {
+		return fmt.Errorf("attachment %d chunk %d: %w", r.attachment.ID, index, err)
+	}
+	defer body.Close()
+
+	sealed, err := io.ReadAll(io.LimitReader(body, attachmentChunkSize+attachmentChunkOverhead+1))
+	if err != nil {
+		return err
+	}
+
+	chunk, err := openGCM(r.fileKey, sealed, attachmentChunkAssociatedData(r.attachment, index, final))
+	if err != nil || (!final && len(chunk) != attachmentChunkSize) {
+		return ErrDecryptFailed
+	}
+
+	r.buf = chunk
+	r.next++
+
+	return nil
+}
+
+// wrapFileKey encrypts an attachment's file key with the account's data key.
+func wrapFileKey(dataKey []byte, attachment Attachment, fileKey []byte) (string, error) {
+	sealed, err := sealGCM(dataKey, fileKey, attachmentAssociatedData(attachment))
+	if err != nil {
+		return "", err
+	}
+
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// unwrapFileKey reverses wrapFileKey.
+func unwrapFileKey(dataKey []byte, attachment Attachment) ([]byte, error) {
+	sealed, err := base64.StdEncoding.DecodeString(attachment.FileKey)
+	if err != nil {
+		return nil, ErrDecryptFailed
+	}
+
+	return openGCM(dataKey, sealed, attachmentAssociatedData(attachment))
+}
+
+// attachmentAssociatedData binds a wrapped file key to the attachment and account it belongs to.
+func attachmentAssociatedData(attachment Attachment) []byte {
+	return []byte(fmt.Sprintf("passman:attachment:%d:account:%d", attachment.ID, attachment.AccountID))
+}
+
+// attachmentChunkAssociatedData binds a chunk to its position in the attachment, so chunks can't
+// be reordered, swapped between attachments or dropped from the end.
+func attachmentChunkAssociatedData(attachment Attachment, index int64, final bool) []byte {
+	return []byte(fmt.Sprintf("passman:attachment:%d:chunk:%d:final:%t", attachment.ID, index, final))
+}
+
+// attachmentChunkKey is the blob key of a chunk.
+func attachmentChunkKey(attachment Attachment, index int64) string {
+	return fmt.Sprintf("attachments/%d/%d/%d", attachment.AccountID, attachment.ID, index)
+}
+
+// normalizeContentType returns the content type in canonical form, or the default type if it is
+// missing or invalid.
+func normalizeContentType(contentType string) string {
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return defaultContentType
+	}
+
+	if formatted := mime.FormatMediaType(mediaType, params); formatted != "" {
+		return formatted
+	}
+
+	return defaultContentType
+}
+
+func attachmentResponse(attachment Attachment) AttachmentResponse {
+	return AttachmentResponse{
+		ID:          attachment.ID,
+		NoteID:      attachment.NoteID,
+		Name:        attachment.Name,
+		ContentType: attachment.ContentType,
+		Size:        attachment.Size,
+		CreatedAt:   attachment.CreatedAt,
+	}
+}
@@ -0,0 +1,228 @@
+package models
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/oalexander6/passman/config"
+	"github.com/oalexander6/passman/pkg/blob"
+)
+
+var testAttachment = Attachment{ID: 7, AccountID: 3, NoteID: 5}
+
+// attachmentModels returns models that keep attachment chunks in a temporary directory.
+func attachmentModels(t *testing.T, quota int64) *Models {
+	t.Helper()
+
+	blobs, err := blob.NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &Models{config: &config.Config{Attachments: config.AttachmentConfig{QuotaBytes: quota}}, blobs: blobs}
+}
+
+func randomBytes(t *testing.T, size int) []byte {
+	t.Helper()
+
+	contents := make([]byte, size)
+	if _, err := rand.Read(contents); err != nil {
+		t.Fatal(err)
+	}
+
+	return contents
+}
+
+// writeTestAttachment writes contents as the chunks of testAttachment, and returns the
+// attachment as it would be completed.
+func writeTestAttachment(t *testing.T, m *Models, contents []byte) Attachment {
+	t.Helper()
+
+	attachment := testAttachment
+
+	var err error
+	attachment.Size, attachment.Chunks, err = m.writeAttachmentChunks(context.Background(), attachment, testDataKey(),
+		bytes.NewReader(contents), m.config.Attachments.QuotaBytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return attachment
+}
+
+func readTestAttachment(m *Models, attachment Attachment) ([]byte, error) {
+	reader := &attachmentReader{ctx: context.Background(), models: m, attachment: attachment, fileKey: testDataKey()}
+	return io.ReadAll(reader)
+}
+
+func TestAttachmentChunksRoundTrip(t *testing.T) {
+	tests := []struct {
+		name   string
+		size   int
+		chunks int64
+	}{
+		{"empty", 0, 1},
+		{"one byte", 1, 1},
+		{"just under a chunk", attachmentChunkSize - 1, 1},
+		{"exactly one chunk", attachmentChunkSize, 1},
+		{"just over a chunk", attachmentChunkSize + 1, 2},
+		{"exactly three chunks", attachmentChunkSize * 3, 3},
+		{"part of a third chunk", attachmentChunkSize*2 + 100, 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := attachmentModels(t, 0)
+			contents := randomBytes(t, tt.size)
+
+			attachment := writeTestAttachment(t, m, contents)
+			if attachment.Size != int64(tt.size) || attachment.Chunks != tt.chunks {
+				t.Fatalf("expected %d bytes in %d chunks, got %d bytes in %d chunks", tt.size, tt.chunks,
+					attachment.Size, attachment.Chunks)
+			}
+
+			// no chunk is written after the final one
+			if _, err := m.blobs.Get(context.Background(), attachmentChunkKey(attachment, tt.chunks)); !errors.Is(err, blob.ErrNotFound) {
+				t.Fatalf("expected no chunk %d, got %v", tt.chunks, err)
+			}
+
+			read, err := readTestAttachment(m, attachment)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !bytes.Equal(read, contents) {
+				t.Fatal("expected the read contents to equal the written contents")
+			}
+		})
+	}
+}
+
+func TestAttachmentChunksTampered(t *testing.T) {
+	ctx := context.Background()
+
+	// copyChunk stores the chunk at index from of one attachment under index to of another
+	copyChunk := func(t *testing.T, m *Models, src Attachment, from int64, dst Attachment, to int64) {
+		body, err := m.blobs.Get(ctx, attachmentChunkKey(src, from))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer body.Close()
+
+		sealed, err := io.ReadAll(body)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := m.blobs.Put(ctx, attachmentChunkKey(dst, to), bytes.NewReader(sealed), int64(len(sealed))); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		tamper func(t *testing.T, m *Models, attachment *Attachment)
+		err    error
+	}{
+		{
+			name: "last chunk dropped",
+			tamper: func(t *testing.T, m *Models, attachment *Attachment) {
+				attachment.Chunks--
+			},
+			err: ErrDecryptFailed,
+		},
+		{
+			name: "last chunk missing",
+			tamper: func(t *testing.T, m *Models, attachment *Attachment) {
+				if err := m.blobs.Delete(ctx, attachmentChunkKey(*attachment, 2)); err != nil {
+					t.Fatal(err)
+				}
+			},
+			err: blob.ErrNotFound,
+		},
+		{
+			name: "chunks reordered",
+			tamper: func(t *testing.T, m *Models, attachment *Attachment) {
+				copyChunk(t, m, *attachment, 0, *attachment, 3)
+				copyChunk(t, m, *attachment, 1, *attachment, 0)
+				copyChunk(t, m, *attachment, 3, *attachment, 1)
+			},
+			err: ErrDecryptFailed,
+		},
+		{
+			name: "chunk truncated",
+			tamper: func(t *testing.T, m *Models, attachment *Attachment) {
+				body, err := m.blobs.Get(ctx, attachmentChunkKey(*attachment, 1))
+				if err != nil {
+					t.Fatal(err)
+				}
+				defer body.Close()
+
+				sealed, err := io.ReadAll(body)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				truncated := sealed[:len(sealed)-100]
+				if err := m.blobs.Put(ctx, attachmentChunkKey(*attachment, 1), bytes.NewReader(truncated), int64(len(truncated))); err != nil {
+					t.Fatal(err)
+				}
+			},
+			err: ErrDecryptFailed,
+		},
+		{
+			name: "chunk from another attachment",
+			tamper: func(t *testing.T, m *Models, attachment *Attachment) {
+				other := *attachment
+				other.ID++
+				if _, _, err := m.writeAttachmentChunks(ctx, other, testDataKey(), bytes.NewReader(randomBytes(t, attachmentChunkSize*2+100)), 0); err != nil {
+					t.Fatal(err)
+				}
+
+				copyChunk(t, m, other, 1, *attachment, 1)
+			},
+			err: ErrDecryptFailed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := attachmentModels(t, 0)
+			attachment := writeTestAttachment(t, m, randomBytes(t, attachmentChunkSize*2+100))
+
+			tt.tamper(t, m, &attachment)
+
+			if _, err := readTestAttachment(m, attachment); !errors.Is(err, tt.err) {
+				t.Fatalf("expected %v, got %v", tt.err, err)
+			}
+		})
+	}
+}
+
+func TestAttachmentChunksQuota(t *testing.T) {
+	m := attachmentModels(t, attachmentChunkSize+attachmentChunkSize/2)
+
+	// the file only goes over the quota in its second chunk
+	_, _, err := m.writeAttachmentChunks(context.Background(), testAttachment, testDataKey(),
+		bytes.NewReader(randomBytes(t, attachmentChunkSize*2)), m.config.Attachments.QuotaBytes)
+	if !errors.Is(err, ErrQuotaExceeded) {
+		t.Fatalf("expected ErrQuotaExceeded, got %v", err)
+	}
+
+	// what remains of the quota is what counts
+	_, _, err = m.writeAttachmentChunks(context.Background(), testAttachment, testDataKey(),
+		bytes.NewReader(randomBytes(t, 101)), 100)
+	if !errors.Is(err, ErrQuotaExceeded) {
+		t.Fatalf("expected ErrQuotaExceeded for the remaining quota, got %v", err)
+	}
+
+	size, chunks, err := m.writeAttachmentChunks(context.Background(), testAttachment, testDataKey(),
+		bytes.NewReader(randomBytes(t, 100)), 100)
+	if err != nil || size != 100 || chunks != 1 {
+		t.Fatalf("expected a file filling the quota to be written, got %d bytes in %d chunks and %v", size, chunks, err)
+	}
+}
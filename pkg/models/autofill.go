@@ -0,0 +1,111 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/oalexander6/passman/pkg/urlmatch"
+)
+
+// NoteMatchResponse is a login with a URI that matches the URL being filled. URI and Match are its
+// best matching URI and that URI's match mode, and Quality is how closely it matches, one of
+// exact, path, host, base_domain and equivalent_domain from best to worst.
+type NoteMatchResponse struct {
+	Note    NoteGetResponse `json:"note"`
+	URI     string          `json:"uri"`
+	Match   string          `json:"match"`
+	Quality string          `json:"quality"`
+}
+
+// NoteMatchURL returns the account's logins with a URI that matches the URL, best matches first
+// and then by name. Each URI is compared using its own match mode. Hidden custom fields are masked
+// unless reveal is set. Returns ErrInvalidInput if the URL isn't absolute with a host, and
+// ErrZeroKnowledgeVault for zero-knowledge accounts, whose URIs the server can't read. Their
+// clients match URIs themselves.
+func (m *Models) NoteMatchURL(ctx context.Context, accountID int64, rawURL string, reveal bool) ([]NoteMatchResponse, error) {
+	target, err := urlmatch.Parse(rawURL)
+	if err != nil {
+		return []NoteMatchResponse{}, fmt.Errorf("%w: %s", ErrInvalidInput, err)
+	}
+
+	account, err := m.store.AccountGetByID(ctx, accountID)
+	if err != nil {
+		return []NoteMatchResponse{}, err
+	}
+
+	if account.VaultMode == VaultModeZeroKnowledge {
+		return []NoteMatchResponse{}, ErrZeroKnowledgeVault
+	}
+
+	notes, err := m.store.NoteGetByAccountID(ctx, accountID, NoteFilter{})
+	if err != nil {
+		return []NoteMatchResponse{}, err
+	}
+
+	dataKey, err := m.dataKeyForAccount(ctx, account)
+	if err != nil {
+		return []NoteMatchResponse{}, err
+	}
+
+	type ranked struct {
+		match   NoteMatchResponse
+		quality urlmatch.Quality
+	}
+
+	matches := []ranked{}
+	for _, note := range notes {
+		// notes saved before types were introduced have no URIs
+		if note.Type != NoteTypeLogin {
+			continue
+		}
+
+		plaintext, err := m.decryptNoteValue(dataKey, note, note.Value)
+		if err != nil {
+			return []NoteMatchResponse{}, ErrDecryptFailed
+		}
+
+		payload, err := decodeNotePayload(note, plaintext)
+		if err != nil {
+			return []NoteMatchResponse{}, err
+		}
+
+		best := ranked{}
+		for _, uri := range payload.Login.URIs {
+			if quality := m.urls.Match(uri.URI, uri.Match, target); quality > best.quality {
+				best.quality = quality
+				best.match.URI = uri.URI
+				best.match.Match = uri.Match
+			}
+		}
+		if best.quality == urlmatch.None {
+			continue
+		}
+
+		if best.match.Match == "" {
+			best.match.Match = urlmatch.ModeBaseDomain
+		}
+		best.match.Quality = best.quality.String()
+
+		best.match.Note, err = noteResponse(account, note, plaintext, reveal)
+		if err != nil {
+			return []NoteMatchResponse{}, err
+		}
+
+		matches = append(matches, best)
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		if matches[i].quality != matches[j].quality {
+			return matches[i].quality > matches[j].quality
+		}
+		return matches[i].match.Note.Name < matches[j].match.Note.Name
+	})
+
+	response := make([]NoteMatchResponse, len(matches))
+	for i, match := range matches {
+		response[i] = match.match
+	}
+
+	return response, nil
+}
@@ -1,9 +1,11 @@
 package models
 
+import "time"
+
 type Base struct {
-	CreatedAt string `db:"created_at"`
-	UpdatedAt string `db:"updated_at"`
-	Deleted   bool   `db:"deleted"`
+	CreatedAt time.Time `db:"created_at"`
+	UpdatedAt time.Time `db:"updated_at"`
+	Deleted   bool      `db:"deleted"`
 }
 
 type IDResponse struct {
@@ -13,3 +15,7 @@ type IDResponse struct {
 type SuccessResponse struct {
 	Success bool `json:"success"`
 }
+
+type ErrorResponse struct {
+	Error string `json:"error"`
+}
@@ -0,0 +1,218 @@
+package models
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/oalexander6/passman/pkg/keys"
+	"github.com/oalexander6/passman/pkg/urlmatch"
+)
+
+// The fields of a note that can be blind indexed. Server vaults index usernames and hosts, and
+// zero-knowledge clients may also index names, which they encrypt.
+const (
+	BlindFieldName     = "name"
+	BlindFieldUsername = "username"
+	BlindFieldHost     = "host"
+)
+
+const (
+	// BlindNgramLength is how many letters each n-gram of a value has. Substring searches for
+	// fewer letters can't be answered from n-grams.
+	BlindNgramLength = 3
+	// blindExactDigits is how many hex digits of the HMAC an exact token keeps. Its 128 bits
+	// are enough that different values never share a token.
+	blindExactDigits = 32
+	// blindNgramDigits is how many hex digits of the HMAC an n-gram token keeps. There are about
+	// 50,000 n-grams of lowercase ASCII letters, digits and spaces, so with 12 bits each of the
+	// 4096 tokens is shared by a dozen or so n-grams. A token alone doesn't tell which of them
+	// it is, at the cost of the occasional false match.
+	blindNgramDigits = 3
+	// maxBlindNgrams is the most n-grams indexed for a value. Longer values can only be
+	// substring searched near their start.
+	maxBlindNgrams = 256
+)
+
+const (
+	blindKindExact = "exact"
+	blindKindNgram = "ngram"
+)
+
+// BlindIndex is the keyed hashes of one value of a note's field: a token of the whole value,
+// for exact matches, and truncated tokens of its n-grams, for substring matches.
+type BlindIndex struct {
+	Field  string   `json:"field" validate:"required,oneof=name username host"`
+	Exact  string   `json:"exact" validate:"required,hexadecimal,lowercase,len=32"`
+	Ngrams []string `json:"ngrams,omitempty" validate:"max=256,dive,hexadecimal,lowercase,len=3"`
+}
+
+// BlindTerm matches the notes with a blind index of Field whose exact token is Exact or, when
+// Exact is empty, that has every one of Ngrams.
+type BlindTerm struct {
+	Field  string   `validate:"required,oneof=name username host"`
+	Exact  string   `validate:"required_without=Ngrams,omitempty,hexadecimal,lowercase,len=32"`
+	Ngrams []string `validate:"required_without=Exact,max=256,dive,hexadecimal,lowercase,len=3"`
+}
+
+// NewBlindIndex returns the blind index of a value of a note's field under key.
+func NewBlindIndex(key []byte, field string, value string) BlindIndex {
+	value = blindValue(field, value)
+	index := BlindIndex{Field: field, Exact: blindToken(key, field, blindKindExact, value, blindExactDigits)}
+
+	for _, ngram := range blindNgrams(value) {
+		index.Ngrams = append(index.Ngrams, blindToken(key, field, blindKindNgram, ngram, blindNgramDigits))
+	}
+
+	// n-grams that differ can share a token
+	slices.Sort(index.Ngrams)
+	index.Ngrams = slices.Compact(index.Ngrams)
+
+	return index
+}
+
+// NewBlindExactTerm returns a term matching the notes with a value of the field equal to value,
+// ignoring case.
+func NewBlindExactTerm(key []byte, field string, value string) BlindTerm {
+	return BlindTerm{Field: field, Exact: blindToken(key, field, blindKindExact, blindValue(field, value), blindExactDigits)}
+}
+
+// NewBlindSubstringTerm returns a term matching the notes with a value of the field containing
+// value, ignoring case and punctuation. Returns false if value is shorter than an n-gram.
+func NewBlindSubstringTerm(key []byte, field string, value string) (BlindTerm, bool) {
+	ngrams := blindNgrams(value)
+	if len(ngrams) == 0 {
+		return BlindTerm{}, false
+	}
+
+	term := BlindTerm{Field: field}
+	for _, ngram := range ngrams {
+		term.Ngrams = append(term.Ngrams, blindToken(key, field, blindKindNgram, ngram, blindNgramDigits))
+	}
+
+	// n-grams that differ can share a token
+	slices.Sort(term.Ngrams)
+	term.Ngrams = slices.Compact(term.Ngrams)
+
+	return term, true
+}
+
+// blindToken returns the hex encoded HMAC-SHA256 of the value under key, truncated to digits
+// hex digits. The field and kind are hashed with the value, so equal values of different
+// fields or kinds have unrelated tokens.
+func blindToken(key []byte, field string, kind string, value string, digits int) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(field + "\x00" + kind + "\x00" + value))
+
+	return hex.EncodeToString(mac.Sum(nil))[:digits]
+}
+
+// blindValue normalizes a value for exact matching. Hosts may be given as URLs, with or without
+// a scheme.
+func blindValue(field string, value string) string {
+	value = strings.ToLower(strings.TrimSpace(value))
+
+	if field == BlindFieldHost {
+		withScheme := value
+		if !strings.Contains(value, "://") {
+			withScheme = "//" + value
+		}
+
+		if u, err := url.Parse(withScheme); err == nil && u.Hostname() != "" {
+			return u.Hostname()
+		}
+	}
+
+	return value
+}
+
+// blindNgrams returns the distinct n-grams of the value's search text, in the order they first
+// appear, up to maxBlindNgrams.
+func blindNgrams(value string) []string {
+	letters := []rune(SearchText(value))
+
+	ngrams := []string{}
+	for i := 0; i+BlindNgramLength <= len(letters) && len(ngrams) < maxBlindNgrams; i++ {
+		ngram := string(letters[i : i+BlindNgramLength])
+		if !slices.Contains(ngrams, ngram) {
+			ngrams = append(ngrams, ngram)
+		}
+	}
+
+	return ngrams
+}
+
+// blindIndexes returns the blind indexes of a server vault note: its username and the hosts of
+// its URLs. Regex URIs have no host to index.
+func (p notePayload) blindIndexes(key []byte) []BlindIndex {
+	indexes := []BlindIndex{}
+
+	if p.Login != nil {
+		if p.Login.Username != "" {
+			indexes = append(indexes, NewBlindIndex(key, BlindFieldUsername, p.Login.Username))
+		}
+		for _, uri := range p.Login.URIs {
+			if uri.Match == urlmatch.ModeRegex {
+				continue
+			}
+			indexes = append(indexes, NewBlindIndex(key, BlindFieldHost, uri.URI))
+		}
+	}
+
+	if p.Identity != nil && p.Identity.Username != "" {
+		indexes = append(indexes, NewBlindIndex(key, BlindFieldUsername, p.Identity.Username))
+	}
+
+	if p.APIToken != nil && p.APIToken.URL != "" {
+		indexes = append(indexes, NewBlindIndex(key, BlindFieldHost, p.APIToken.URL))
+	}
+
+	return indexes
+}
+
+// blindTerms returns the terms a word of a search query matches in a server vault: a username
+// or host that is the word, or contains it.
+func blindTerms(key []byte, word string) []BlindTerm {
+	terms := []BlindTerm{}
+
+	for _, field := range []string{BlindFieldUsername, BlindFieldHost} {
+		terms = append(terms, NewBlindExactTerm(key, field, word))
+		if term, ok := NewBlindSubstringTerm(key, field, word); ok {
+			terms = append(terms, term)
+		}
+	}
+
+	return terms
+}
+
+// indexKeyForAccount returns the account's plaintext blind index key, creating one the first
+// time it is needed. Index keys are wrapped by the master key like data keys, but are never used
+// for encryption, so a leaked index key only exposes what the blind indexes do.
+func (m *Models) indexKeyForAccount(ctx context.Context, account Account) ([]byte, error) {
+	if account.IndexKey == "" {
+		indexKey, err := keys.NewDataKey()
+		if err != nil {
+			return nil, ErrEncryptFailed
+		}
+
+		wrapped, err := m.keys.WrapKey(ctx, indexKey)
+		if err != nil {
+			return nil, err
+		}
+
+		account.IndexKey = wrapped
+		account, err = m.storeAccountKey(ctx, account, func(a Account) string { return a.IndexKey },
+			func(ctx context.Context, a Account) error {
+				return m.store.AccountUpdateIndexKey(ctx, a.ID, "", a.IndexKey)
+			})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return m.unwrapDataKey(ctx, account.IndexKey)
+}
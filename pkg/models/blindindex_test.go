@@ -0,0 +1,173 @@
+package models
+
+import (
+	"bytes"
+	"slices"
+	"testing"
+)
+
+func testIndexKey() []byte {
+	return bytes.Repeat([]byte{0x17}, 32)
+}
+
+func TestBlindIndexStable(t *testing.T) {
+	first := NewBlindIndex(testIndexKey(), BlindFieldUsername, "alice@example.com")
+	second := NewBlindIndex(testIndexKey(), BlindFieldUsername, "alice@example.com")
+
+	if first.Exact != second.Exact || !slices.Equal(first.Ngrams, second.Ngrams) {
+		t.Fatalf("expected equal indexes, got %+v and %+v", first, second)
+	}
+
+	if len(first.Exact) != blindExactDigits {
+		t.Fatalf("expected an exact token of %d digits, got %q", blindExactDigits, first.Exact)
+	}
+
+	for _, ngram := range first.Ngrams {
+		if len(ngram) != blindNgramDigits {
+			t.Fatalf("expected n-gram tokens of %d digits, got %q", blindNgramDigits, ngram)
+		}
+	}
+
+	other := NewBlindIndex(bytes.Repeat([]byte{0x18}, 32), BlindFieldUsername, "alice@example.com")
+	if other.Exact == first.Exact {
+		t.Fatal("expected a different key to give a different exact token")
+	}
+}
+
+func TestBlindIndexSeparatesFieldsAndKinds(t *testing.T) {
+	key := testIndexKey()
+
+	username := NewBlindIndex(key, BlindFieldUsername, "example")
+	name := NewBlindIndex(key, BlindFieldName, "example")
+	if username.Exact == name.Exact {
+		t.Fatal("expected different fields to give different exact tokens")
+	}
+
+	if blindToken(key, BlindFieldName, blindKindExact, "abc", blindExactDigits) ==
+		blindToken(key, BlindFieldName, blindKindNgram, "abc", blindExactDigits) {
+		t.Fatal("expected different kinds to give different tokens")
+	}
+
+	// a username term must not match a name index
+	term, ok := NewBlindSubstringTerm(key, BlindFieldUsername, "example")
+	if !ok {
+		t.Fatal("expected a substring term")
+	}
+
+	if containsAll(name.Ngrams, term.Ngrams) {
+		t.Fatal("expected a username term not to match a name index")
+	}
+}
+
+func TestBlindIndexNormalizes(t *testing.T) {
+	key := testIndexKey()
+
+	tests := []struct {
+		name  string
+		field string
+		a     string
+		b     string
+	}{
+		{"case", BlindFieldUsername, "Alice@Example.com", "alice@example.com"},
+		{"surrounding space", BlindFieldUsername, "  alice ", "alice"},
+		{"host from URL", BlindFieldHost, "https://Login.Example.com:8443/signin?next=/", "login.example.com"},
+		{"host without scheme", BlindFieldHost, "login.example.com/signin", "login.example.com"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, b := NewBlindIndex(key, tt.field, tt.a), NewBlindIndex(key, tt.field, tt.b)
+			if a.Exact != b.Exact {
+				t.Fatalf("expected %q and %q to have the same exact token", tt.a, tt.b)
+			}
+
+			if exact := NewBlindExactTerm(key, tt.field, tt.a); exact.Exact != b.Exact {
+				t.Fatalf("expected the exact term of %q to match %q", tt.a, tt.b)
+			}
+		})
+	}
+
+	// punctuation only separates words for n-grams
+	term, ok := NewBlindSubstringTerm(key, BlindFieldUsername, "ALICE.SMITH")
+	if !ok {
+		t.Fatal("expected a substring term")
+	}
+
+	if !containsAll(NewBlindIndex(key, BlindFieldUsername, "alice smith").Ngrams, term.Ngrams) {
+		t.Fatal("expected case and punctuation to be ignored by n-grams")
+	}
+}
+
+func TestBlindSubstringTerm(t *testing.T) {
+	key := testIndexKey()
+	index := NewBlindIndex(key, BlindFieldUsername, "alice.smith@example.com")
+
+	tests := []struct {
+		substring string
+		matches   bool
+	}{
+		{"alice", true},
+		{"smith", true},
+		{"Example", true},
+		{"ice.smi", true},
+		{"alice.smith@example.com", true},
+		{"mallory", false},
+		{"example.org", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.substring, func(t *testing.T) {
+			term, ok := NewBlindSubstringTerm(key, BlindFieldUsername, tt.substring)
+			if !ok {
+				t.Fatal("expected a substring term")
+			}
+
+			if term.Field != BlindFieldUsername {
+				t.Fatalf("expected the term's field to be %s, got %s", BlindFieldUsername, term.Field)
+			}
+
+			if matches := containsAll(index.Ngrams, term.Ngrams); matches != tt.matches {
+				t.Fatalf("expected match %t, got %t", tt.matches, matches)
+			}
+		})
+	}
+
+	hostTerm, _ := NewBlindSubstringTerm(key, BlindFieldHost, "alice")
+	if containsAll(index.Ngrams, hostTerm.Ngrams) {
+		t.Fatal("expected a host term not to match a username index")
+	}
+}
+
+func TestBlindSubstringTermTooShort(t *testing.T) {
+	for _, value := range []string{"", "a", "ab", "a.", "  ab  ", "!!!"} {
+		if term, ok := NewBlindSubstringTerm(testIndexKey(), BlindFieldUsername, value); ok {
+			t.Errorf("%q: expected no term, got %+v", value, term)
+		}
+	}
+
+	if _, ok := NewBlindSubstringTerm(testIndexKey(), BlindFieldUsername, "abc"); !ok {
+		t.Errorf("expected a term for %d letters", BlindNgramLength)
+	}
+}
+
+func TestBlindNgramsLimit(t *testing.T) {
+	long := []byte{}
+	for i := 0; i < maxBlindNgrams*2; i++ {
+		long = append(long, byte('a'+i%26), byte('a'+i/26%26))
+	}
+
+	if ngrams := blindNgrams(string(long)); len(ngrams) != maxBlindNgrams {
+		t.Fatalf("expected %d n-grams, got %d", maxBlindNgrams, len(ngrams))
+	}
+}
+
+// containsAll reports whether tokens holds every one of want.
+func containsAll(tokens []string, want []string) bool {
+	for _, token := range want {
+		if !slices.Contains(tokens, token) {
+			return false
+		}
+	}
+
+	return true
+}
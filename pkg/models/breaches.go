@@ -0,0 +1,83 @@
+package models
+
+import (
+	"context"
+
+	"github.com/oalexander6/passman/pkg/logger"
+)
+
+// BreachStatus reports whether a note value appears in the breached password data, and how
+// many times it was seen.
+type BreachStatus struct {
+	Breached bool `json:"breached"`
+	Count    int  `json:"count"`
+}
+
+// BreachReportNote is a note whose value appears in the breached password data.
+type BreachReportNote struct {
+	ID    int64  `json:"id"`
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+// BreachReportResponse lists the account's breached notes out of every note with a password.
+type BreachReportResponse struct {
+	Checked  int                `json:"checked"`
+	Breached []BreachReportNote `json:"breached"`
+}
+
+// BreachReport checks the password of every note owned by the account against the breached
+// password data. Returns ErrBreachCheckUnavailable if no breach data is configured, and
+// ErrZeroKnowledgeVault for zero-knowledge accounts.
+func (m *Models) BreachReport(ctx context.Context, accountID int64) (BreachReportResponse, error) {
+	if m.breaches == nil {
+		return BreachReportResponse{}, ErrBreachCheckUnavailable
+	}
+
+	_, notes, err := m.reportNotes(ctx, accountID)
+	if err != nil {
+		return BreachReportResponse{}, err
+	}
+
+	breached, err := m.breachedNotes(notes)
+	if err != nil {
+		return BreachReportResponse{}, err
+	}
+
+	return BreachReportResponse{Checked: len(notes), Breached: breached}, nil
+}
+
+// breachedNotes returns the decrypted notes whose values appear in the breached password data.
+func (m *Models) breachedNotes(notes []Note) ([]BreachReportNote, error) {
+	breached := []BreachReportNote{}
+
+	for _, note := range notes {
+		count, err := m.breaches.Count(note.Value)
+		if err != nil {
+			return nil, err
+		}
+
+		if count > 0 {
+			breached = append(breached, BreachReportNote{ID: note.ID, Name: note.Name, Count: count})
+		}
+	}
+
+	return breached, nil
+}
+
+// noteBreachStatus checks a note's password against the breached password data. It returns nil
+// when the note has no password, breach checks are disabled or not possible for the account, or
+// the check fails, since a missing check should never stop a note from being saved.
+func (m *Models) noteBreachStatus(account Account, noteID int64, password string) *BreachStatus {
+	if m.breaches == nil || account.VaultMode == VaultModeZeroKnowledge || password == "" {
+		return nil
+	}
+
+	count, err := m.breaches.Count(password)
+	if err != nil {
+		logger.Log.Error().Msgf("Breach check failed for note %d: %s", noteID, err)
+		return nil
+	}
+
+	return &BreachStatus{Breached: count > 0, Count: count}
+}
@@ -0,0 +1,322 @@
+package models
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/crypto/hkdf"
+)
+
+const (
+	// ciphertextPrefixDataKey marks values encrypted with AES-256-GCM under the owning
+	// account's data key.
+	ciphertextPrefixDataKey = "v3:"
+	// ciphertextPrefixGCM marks values encrypted with AES-256-GCM directly under
+	// ENCRYPTION_SECRET. Values without a version prefix were written with AES-256-CBC and a
+	// fixed IV. Both older formats can only be decrypted, and are replaced on the next write.
+	ciphertextPrefixGCM = "v2:"
+
+	// x25519KeySize is the size of X25519 public and private keys.
+	x25519KeySize = 32
+	// shareKeyInfo is the HKDF info for keys that encrypt item keys to a recipient.
+	shareKeyInfo = "passman:share"
+)
+
+// noteAssociatedData binds a ciphertext to the note and account it belongs to, so that it
+// fails to decrypt if it is copied into a different row.
+func noteAssociatedData(note Note) []byte {
+	return []byte(fmt.Sprintf("passman:note:%d:account:%d", note.ID, note.AccountID))
+}
+
+// encryptNoteValue encrypts a value belonging to the note with the account's data key, or with
+// the note's item key once it has been shared.
+func encryptNoteValue(dataKey []byte, note Note, plaintext string) (string, error) {
+	key, err := noteKey(dataKey, note)
+	if err != nil {
+		return "", ErrEncryptFailed
+	}
+
+	sealed, err := sealGCM(key, []byte(plaintext), noteAssociatedData(note))
+	if err != nil {
+		return "", err
+	}
+
+	return ciphertextPrefixDataKey + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// isCurrentNoteCiphertext reports whether a value was encrypted with an account data key,
+// rather than one of the older formats encrypted with ENCRYPTION_SECRET.
+func isCurrentNoteCiphertext(encrypted string) bool {
+	return strings.HasPrefix(encrypted, ciphertextPrefixDataKey)
+}
+
+// decryptNoteValue decrypts a value belonging to the note. Values written before envelope
+// encryption was introduced are decrypted with ENCRYPTION_SECRET or, while it is being
+// rotated, one of the previous secrets.
+func (m *Models) decryptNoteValue(dataKey []byte, note Note, encrypted string) (string, error) {
+	if isCurrentNoteCiphertext(encrypted) {
+		key, err := noteKey(dataKey, note)
+		if err != nil {
+			return "", err
+		}
+
+		return openNoteValue(key, note, strings.TrimPrefix(encrypted, ciphertextPrefixDataKey))
+	}
+
+	for _, secret := range m.legacySecrets() {
+		var (
+			plaintext string
+			err       error
+		)
+
+		if strings.HasPrefix(encrypted, ciphertextPrefixGCM) {
+			plaintext, err = openNoteValue([]byte(secret), note, strings.TrimPrefix(encrypted, ciphertextPrefixGCM))
+		} else {
+			plaintext, err = m.decryptLegacyCBC(secret, encrypted)
+		}
+
+		if err == nil {
+			return plaintext, nil
+		}
+	}
+
+	return "", ErrDecryptFailed
+}
+
+// openNoteValue decrypts a base64 encoded AES-GCM ciphertext belonging to the note.
+func openNoteValue(key []byte, note Note, encoded string) (string, error) {
+	sealed, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", ErrDecryptFailed
+	}
+
+	plaintext, err := openGCM(key, sealed, noteAssociatedData(note))
+	if err != nil {
+		return "", err
+	}
+
+	return string(plaintext), nil
+}
+
+// noteKey returns the key the note's value is encrypted with: its item key if it has one, or
+// else the account's data key.
+func noteKey(dataKey []byte, note Note) ([]byte, error) {
+	if note.ItemKey == "" {
+		return dataKey, nil
+	}
+
+	return openItemKey(dataKey, note.ID, note.AccountID, note.ItemKey)
+}
+
+// itemKeyAssociatedData binds an encrypted item key to its note and owner.
+func itemKeyAssociatedData(noteID int64, accountID int64) []byte {
+	return []byte(fmt.Sprintf("passman:item-key:note:%d:account:%d", noteID, accountID))
+}
+
+// sealItemKey encrypts a note's item key with the owner's data key.
+func sealItemKey(dataKey []byte, noteID int64, accountID int64, itemKey []byte) (string, error) {
+	sealed, err := sealGCM(dataKey, itemKey, itemKeyAssociatedData(noteID, accountID))
+	if err != nil {
+		return "", err
+	}
+
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// openItemKey reverses sealItemKey.
+func openItemKey(dataKey []byte, noteID int64, accountID int64, encoded string) ([]byte, error) {
+	sealed, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, ErrDecryptFailed
+	}
+
+	return openGCM(dataKey, sealed, itemKeyAssociatedData(noteID, accountID))
+}
+
+// sealToPublicKey encrypts plaintext so that only the holder of the X25519 private key matching
+// publicKey can decrypt it. A fresh ephemeral key pair is agreed with the public key, and
+// HKDF-SHA256 of the shared secret, salted with both public keys, is the AES-256-GCM key. The
+// ephemeral public key is prepended to the returned ciphertext.
+func sealToPublicKey(publicKey []byte, plaintext []byte, associatedData []byte) ([]byte, error) {
+	recipient, err := ecdh.X25519().NewPublicKey(publicKey)
+	if err != nil {
+		return nil, ErrEncryptFailed
+	}
+
+	ephemeral, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, ErrEncryptFailed
+	}
+
+	key, err := sharedKey(ephemeral, recipient, ephemeral.PublicKey().Bytes(), publicKey)
+	if err != nil {
+		return nil, ErrEncryptFailed
+	}
+
+	sealed, err := sealGCM(key, plaintext, associatedData)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(ephemeral.PublicKey().Bytes(), sealed...), nil
+}
+
+// openWithPrivateKey reverses sealToPublicKey.
+func openWithPrivateKey(privateKey []byte, sealed []byte, associatedData []byte) ([]byte, error) {
+	private, err := ecdh.X25519().NewPrivateKey(privateKey)
+	if err != nil || len(sealed) < x25519KeySize {
+		return nil, ErrDecryptFailed
+	}
+
+	ephemeral, err := ecdh.X25519().NewPublicKey(sealed[:x25519KeySize])
+	if err != nil {
+		return nil, ErrDecryptFailed
+	}
+
+	key, err := sharedKey(private, ephemeral, sealed[:x25519KeySize], private.PublicKey().Bytes())
+	if err != nil {
+		return nil, ErrDecryptFailed
+	}
+
+	return openGCM(key, sealed[x25519KeySize:], associatedData)
+}
+
+// sharedKey derives an AES-256 key from the X25519 agreement of a private and a public key.
+func sharedKey(private *ecdh.PrivateKey, public *ecdh.PublicKey, ephemeralPublic []byte, recipientPublic []byte) ([]byte, error) {
+	secret, err := private.ECDH(public)
+	if err != nil {
+		return nil, err
+	}
+
+	salt := append(append([]byte{}, ephemeralPublic...), recipientPublic...)
+
+	key := make([]byte, 32)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, secret, salt, []byte(shareKeyInfo)), key); err != nil {
+		return nil, err
+	}
+
+	return key, nil
+}
+
+// legacySecrets returns the configured encryption secrets, newest first.
+func (m *Models) legacySecrets() []string {
+	secrets := make([]string, 0, len(m.config.Encryption.PreviousSecrets)+1)
+
+	if m.config.Encryption.EncSecret != "" {
+		secrets = append(secrets, m.config.Encryption.EncSecret)
+	}
+
+	return append(secrets, m.config.Encryption.PreviousSecrets...)
+}
+
+// sealGCM implements AES-256-GCM encryption with a random nonce, which is prepended to the
+// returned ciphertext. The associated data is authenticated but not stored, and must be
+// provided again to decrypt.
+func sealGCM(key []byte, plaintext []byte, associatedData []byte) ([]byte, error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, ErrEncryptFailed
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, ErrEncryptFailed
+	}
+
+	return aead.Seal(nonce, nonce, plaintext, associatedData), nil
+}
+
+// openGCM reverses sealGCM.
+func openGCM(key []byte, sealed []byte, associatedData []byte) ([]byte, error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, ErrDecryptFailed
+	}
+
+	if len(sealed) < aead.NonceSize() {
+		return nil, ErrDecryptFailed
+	}
+
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+
+	plaintext, err := aead.Open(nil, nonce, ciphertext, associatedData)
+	if err != nil {
+		return nil, ErrDecryptFailed
+	}
+
+	return plaintext, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+// decryptLegacyCBC implements AES-256-CBC decryption using PKCS7 unpadding for values
+// written with the fixed ENCRYPTION_IV. CBC is not authenticated, so a value that decrypts
+// to invalid UTF-8 is assumed to have been encrypted with a different secret.
+func (m *Models) decryptLegacyCBC(secret string, encrypted string) (string, error) {
+	ciphertext, err := base64.StdEncoding.DecodeString(encrypted)
+	if err != nil {
+		return "", ErrDecryptFailed
+	}
+
+	block, err := aes.NewCipher([]byte(secret))
+	if err != nil {
+		return "", ErrDecryptFailed
+	}
+
+	if len(m.config.Encryption.EncIV) != block.BlockSize() || len(ciphertext) == 0 || len(ciphertext)%block.BlockSize() != 0 {
+		return "", ErrDecryptFailed
+	}
+
+	mode := cipher.NewCBCDecrypter(block, []byte(m.config.Encryption.EncIV))
+	mode.CryptBlocks(ciphertext, ciphertext)
+
+	plaintext, err := pkcs7UnPad(ciphertext, block.BlockSize())
+	if err != nil {
+		return "", err
+	}
+
+	if !utf8.Valid(plaintext) {
+		return "", ErrDecryptFailed
+	}
+
+	return string(plaintext), nil
+}
+
+// pkcs7UnPad implements removal of PKCS7 padding by checking the value of the
+// last byte and removing that many bytes from the end of the original buffer.
+// Every padding byte must hold the padding length.
+func pkcs7UnPad(original []byte, blockSize int) ([]byte, error) {
+	ogLength := len(original)
+	if ogLength == 0 || ogLength%blockSize != 0 {
+		return []byte{}, ErrDecryptFailed
+	}
+
+	bytesToRemove := int(original[ogLength-1])
+	if bytesToRemove == 0 || bytesToRemove > blockSize {
+		return []byte{}, ErrDecryptFailed
+	}
+
+	padding := original[ogLength-bytesToRemove:]
+	if subtle.ConstantTimeCompare(padding, bytes.Repeat([]byte{uint8(bytesToRemove)}, bytesToRemove)) != 1 {
+		return []byte{}, ErrDecryptFailed
+	}
+
+	return original[:(ogLength - bytesToRemove)], nil
+}
@@ -0,0 +1,335 @@
+package models
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"testing"
+
+	"github.com/oalexander6/passman/config"
+)
+
+const (
+	testSecret         = "0123456789abcdef0123456789abcdef"
+	testPreviousSecret = "fedcba9876543210fedcba9876543210"
+	testIV             = "1234567890abcdef"
+)
+
+func testModels() *Models {
+	return &Models{config: &config.Config{Encryption: config.EncryptionConfig{
+		EncSecret:       testSecret,
+		EncIV:           testIV,
+		PreviousSecrets: []string{testPreviousSecret},
+	}}}
+}
+
+func testDataKey() []byte {
+	return bytes.Repeat([]byte{0x42}, 32)
+}
+
+// encryptLegacyCBC encrypts a value the way notes were written before AES-GCM was introduced.
+func encryptLegacyCBC(t *testing.T, secret string, plaintext string) string {
+	t.Helper()
+
+	block, err := aes.NewCipher([]byte(secret))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	padding := block.BlockSize() - len(plaintext)%block.BlockSize()
+	padded := append([]byte(plaintext), bytes.Repeat([]byte{byte(padding)}, padding)...)
+
+	cipher.NewCBCEncrypter(block, []byte(testIV)).CryptBlocks(padded, padded)
+
+	return base64.StdEncoding.EncodeToString(padded)
+}
+
+// encryptLegacyGCM encrypts a value the way notes were written before envelope encryption.
+func encryptLegacyGCM(t *testing.T, secret string, note Note, plaintext string) string {
+	t.Helper()
+
+	sealed, err := sealGCM([]byte(secret), []byte(plaintext), noteAssociatedData(note))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return ciphertextPrefixGCM + base64.StdEncoding.EncodeToString(sealed)
+}
+
+func TestNoteValueRoundTrip(t *testing.T) {
+	m := testModels()
+	note := Note{ID: 7, AccountID: 3}
+
+	encrypted, err := encryptNoteValue(testDataKey(), note, "hunter2")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !isCurrentNoteCiphertext(encrypted) {
+		t.Fatalf("expected a %q value, got %q", ciphertextPrefixDataKey, encrypted)
+	}
+
+	plaintext, err := m.decryptNoteValue(testDataKey(), note, encrypted)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if plaintext != "hunter2" {
+		t.Fatalf("expected hunter2, got %q", plaintext)
+	}
+}
+
+func TestNoteValueItemKeyRoundTrip(t *testing.T) {
+	m := testModels()
+	note := Note{ID: 7, AccountID: 3}
+
+	itemKey, err := sealItemKey(testDataKey(), note.ID, note.AccountID, bytes.Repeat([]byte{0x24}, 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+	note.ItemKey = itemKey
+
+	encrypted, err := encryptNoteValue(testDataKey(), note, "hunter2")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := openNoteValue(testDataKey(), note, encrypted[len(ciphertextPrefixDataKey):]); !errors.Is(err, ErrDecryptFailed) {
+		t.Fatalf("expected the data key not to open an item key value, got %v", err)
+	}
+
+	plaintext, err := m.decryptNoteValue(testDataKey(), note, encrypted)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if plaintext != "hunter2" {
+		t.Fatalf("expected hunter2, got %q", plaintext)
+	}
+}
+
+func TestLegacyGCMDecrypts(t *testing.T) {
+	m := testModels()
+	note := Note{ID: 7, AccountID: 3}
+
+	for _, secret := range []string{testSecret, testPreviousSecret} {
+		plaintext, err := m.decryptNoteValue(testDataKey(), note, encryptLegacyGCM(t, secret, note, "hunter2"))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if plaintext != "hunter2" {
+			t.Fatalf("expected hunter2, got %q", plaintext)
+		}
+	}
+}
+
+func TestNoteValueAssociatedData(t *testing.T) {
+	m := testModels()
+	note := Note{ID: 7, AccountID: 3}
+
+	current, err := encryptNoteValue(testDataKey(), note, "hunter2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	legacy := encryptLegacyGCM(t, testSecret, note, "hunter2")
+
+	tests := []struct {
+		name string
+		note Note
+	}{
+		{name: "wrong note ID", note: Note{ID: 8, AccountID: 3}},
+		{name: "wrong account ID", note: Note{ID: 7, AccountID: 4}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := m.decryptNoteValue(testDataKey(), tt.note, current); !errors.Is(err, ErrDecryptFailed) {
+				t.Fatalf("expected ErrDecryptFailed for a v3 value, got %v", err)
+			}
+
+			if _, err := m.decryptNoteValue(testDataKey(), tt.note, legacy); !errors.Is(err, ErrDecryptFailed) {
+				t.Fatalf("expected ErrDecryptFailed for a v2 value, got %v", err)
+			}
+		})
+	}
+}
+
+func TestNoteValueWrongKey(t *testing.T) {
+	m := testModels()
+	note := Note{ID: 7, AccountID: 3}
+
+	encrypted, err := encryptNoteValue(testDataKey(), note, "hunter2")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := m.decryptNoteValue(bytes.Repeat([]byte{0x43}, 32), note, encrypted); !errors.Is(err, ErrDecryptFailed) {
+		t.Fatalf("expected ErrDecryptFailed, got %v", err)
+	}
+}
+
+func TestLegacyCBCDecrypts(t *testing.T) {
+	m := testModels()
+
+	tests := []struct {
+		name      string
+		secret    string
+		plaintext string
+	}{
+		{name: "current secret", secret: testSecret, plaintext: "hunter2"},
+		{name: "previous secret", secret: testPreviousSecret, plaintext: "hunter2"},
+		{name: "full block of padding", secret: testSecret, plaintext: "sixteen byte val"},
+		{name: "empty", secret: testSecret, plaintext: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plaintext, err := m.decryptNoteValue(testDataKey(), Note{ID: 1, AccountID: 1}, encryptLegacyCBC(t, tt.secret, tt.plaintext))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if plaintext != tt.plaintext {
+				t.Fatalf("expected %q, got %q", tt.plaintext, plaintext)
+			}
+		})
+	}
+}
+
+func TestLegacyCBCUnknownSecret(t *testing.T) {
+	m := testModels()
+
+	encrypted := encryptLegacyCBC(t, "00000000000000000000000000000000", "hunter2")
+	if _, err := m.decryptNoteValue(testDataKey(), Note{}, encrypted); !errors.Is(err, ErrDecryptFailed) {
+		t.Fatalf("expected ErrDecryptFailed, got %v", err)
+	}
+}
+
+func TestPKCS7UnPad(t *testing.T) {
+	block := bytes.Repeat([]byte{'a'}, 12)
+
+	tests := []struct {
+		name     string
+		input    []byte
+		expected []byte
+		err      bool
+	}{
+		{name: "valid", input: append(append([]byte{}, block...), 4, 4, 4, 4), expected: block},
+		{name: "full block", input: bytes.Repeat([]byte{16}, 16), expected: []byte{}},
+		{name: "zero padding", input: append(append([]byte{}, block...), 0, 0, 0, 0), err: true},
+		{name: "longer than a block", input: bytes.Repeat([]byte{17}, 16), err: true},
+		{name: "inconsistent bytes", input: append(append([]byte{}, block...), 4, 3, 4, 4), err: true},
+		{name: "padding longer than value", input: append(append([]byte{}, block...), 'a', 'a', 'a', 13), err: true},
+		{name: "not a whole block", input: []byte{1, 1, 1}, err: true},
+		{name: "empty", input: []byte{}, err: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			unpadded, err := pkcs7UnPad(tt.input, 16)
+			if tt.err {
+				if !errors.Is(err, ErrDecryptFailed) {
+					t.Fatalf("expected ErrDecryptFailed, got %v", err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !bytes.Equal(unpadded, tt.expected) {
+				t.Fatalf("expected %q, got %q", tt.expected, unpadded)
+			}
+		})
+	}
+}
+
+func TestLegacyCBCMalformedPadding(t *testing.T) {
+	m := testModels()
+
+	block, err := aes.NewCipher([]byte(testSecret))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// "hunter2" padded with bytes that don't all hold the padding length
+	padded := append([]byte("hunter2"), 9, 9, 9, 9, 9, 9, 9, 9, 8)
+	cipher.NewCBCEncrypter(block, []byte(testIV)).CryptBlocks(padded, padded)
+
+	if _, err := m.decryptNoteValue(testDataKey(), Note{}, base64.StdEncoding.EncodeToString(padded)); !errors.Is(err, ErrDecryptFailed) {
+		t.Fatalf("expected ErrDecryptFailed, got %v", err)
+	}
+}
+
+func TestSealToPublicKeyRoundTrip(t *testing.T) {
+	recipient, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	other, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	itemKey := testDataKey()
+	associatedData := shareAssociatedData(1, 2)
+
+	sealed, err := sealToPublicKey(recipient.PublicKey().Bytes(), itemKey, associatedData)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	opened, err := openWithPrivateKey(recipient.Bytes(), sealed, associatedData)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(opened, itemKey) {
+		t.Fatal("expected the opened key to equal the sealed key")
+	}
+
+	again, err := sealToPublicKey(recipient.PublicKey().Bytes(), itemKey, associatedData)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if bytes.Equal(again[:x25519KeySize], sealed[:x25519KeySize]) {
+		t.Fatal("expected every seal to use a new ephemeral key")
+	}
+
+	tampered := bytes.Clone(sealed)
+	tampered[len(tampered)-1] ^= 1
+
+	tests := []struct {
+		name           string
+		privateKey     []byte
+		sealed         []byte
+		associatedData []byte
+	}{
+		{"other private key", other.Bytes(), sealed, associatedData},
+		{"other note", recipient.Bytes(), sealed, shareAssociatedData(3, 2)},
+		{"other recipient", recipient.Bytes(), sealed, shareAssociatedData(1, 3)},
+		{"tampered", recipient.Bytes(), tampered, associatedData},
+		{"truncated", recipient.Bytes(), sealed[:x25519KeySize-1], associatedData},
+		{"invalid private key", []byte("short"), sealed, associatedData},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := openWithPrivateKey(tt.privateKey, tt.sealed, tt.associatedData); !errors.Is(err, ErrDecryptFailed) {
+				t.Fatalf("expected ErrDecryptFailed, got %v", err)
+			}
+		})
+	}
+
+	if _, err := sealToPublicKey([]byte("short"), itemKey, associatedData); !errors.Is(err, ErrEncryptFailed) {
+		t.Fatalf("expected ErrEncryptFailed for an invalid public key, got %v", err)
+	}
+}
@@ -0,0 +1,134 @@
+package models
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/oalexander6/passman/pkg/keys"
+)
+
+// dataKeyCacheTTL is how long an unwrapped data key is kept in memory, to avoid calling the
+// key provider on every request.
+const dataKeyCacheTTL = 5 * time.Minute
+
+// dataKeyCache holds unwrapped data keys indexed by their wrapped value.
+type dataKeyCache struct {
+	mu      sync.Mutex
+	entries map[string]cachedDataKey
+}
+
+type cachedDataKey struct {
+	key       []byte
+	expiresAt time.Time
+}
+
+func newDataKeyCache() *dataKeyCache {
+	return &dataKeyCache{entries: make(map[string]cachedDataKey)}
+}
+
+func (c *dataKeyCache) get(wrapped string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[wrapped]
+	if !ok || time.Now().After(entry.expiresAt) {
+		return nil, false
+	}
+
+	return entry.key, true
+}
+
+func (c *dataKeyCache) put(wrapped string, key []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	for k, entry := range c.entries {
+		if now.After(entry.expiresAt) {
+			delete(c.entries, k)
+		}
+	}
+
+	c.entries[wrapped] = cachedDataKey{key: key, expiresAt: now.Add(dataKeyCacheTTL)}
+}
+
+// newWrappedDataKey generates a new data key and returns it wrapped by the key provider.
+func (m *Models) newWrappedDataKey(ctx context.Context) (string, error) {
+	dataKey, err := keys.NewDataKey()
+	if err != nil {
+		return "", ErrEncryptFailed
+	}
+
+	return m.keys.WrapKey(ctx, dataKey)
+}
+
+// unwrapDataKey returns the plaintext data key for a wrapped key.
+func (m *Models) unwrapDataKey(ctx context.Context, wrapped string) ([]byte, error) {
+	if dataKey, ok := m.dataKeys.get(wrapped); ok {
+		return dataKey, nil
+	}
+
+	dataKey, err := m.keys.UnwrapKey(ctx, wrapped)
+	if err != nil {
+		return nil, err
+	}
+
+	m.dataKeys.put(wrapped, dataKey)
+
+	return dataKey, nil
+}
+
+// accountDataKey returns the account's plaintext data key. Accounts created before envelope
+// encryption was introduced are given a data key the first time one is needed.
+func (m *Models) accountDataKey(ctx context.Context, accountID int64) ([]byte, error) {
+	account, err := m.store.AccountGetByID(ctx, accountID)
+	if err != nil {
+		return nil, err
+	}
+
+	return m.dataKeyForAccount(ctx, account)
+}
+
+// dataKeyForAccount is accountDataKey for callers that have already loaded the account.
+func (m *Models) dataKeyForAccount(ctx context.Context, account Account) ([]byte, error) {
+	if account.DataKey == "" {
+		wrapped, err := m.newWrappedDataKey(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		account.DataKey = wrapped
+		account, err = m.storeAccountKey(ctx, account, func(a Account) string { return a.DataKey },
+			func(ctx context.Context, a Account) error {
+				return m.store.AccountUpdateDataKey(ctx, a.ID, "", a.DataKey)
+			})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return m.unwrapDataKey(ctx, account.DataKey)
+}
+
+// storeAccountKey saves a key that was just created and set on the account, for accounts that
+// had none. key reads the key from an account, and update must only store it if the stored
+// account still has no key. Another request may have stored a key first, in which case the
+// account is reloaded so that its key is used instead.
+func (m *Models) storeAccountKey(ctx context.Context, account Account, key func(Account) string,
+	update func(ctx context.Context, account Account) error) (Account, error) {
+	if err := update(ctx, account); err == nil {
+		return account, nil
+	}
+
+	stored, err := m.store.AccountGetByID(ctx, account.ID)
+	if err != nil {
+		return Account{}, err
+	}
+
+	if key(stored) == "" {
+		return Account{}, ErrEncryptFailed
+	}
+
+	return stored, nil
+}
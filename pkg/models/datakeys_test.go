@@ -0,0 +1,95 @@
+package models
+
+import (
+	"bytes"
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/oalexander6/passman/config"
+	"github.com/oalexander6/passman/pkg/keys"
+)
+
+// accountKeyStore holds a single account in memory. While the account has no data key, callers
+// of AccountGetByID wait for each other, so they all find it without one.
+type accountKeyStore struct {
+	Store
+
+	mu      sync.Mutex
+	account Account
+	readers sync.WaitGroup
+	writes  int
+}
+
+func (s *accountKeyStore) AccountGetByID(ctx context.Context, id int64) (Account, error) {
+	s.mu.Lock()
+	account := s.account
+	s.mu.Unlock()
+
+	if account.DataKey == "" {
+		s.readers.Done()
+		s.readers.Wait()
+	}
+
+	return account, nil
+}
+
+func (s *accountKeyStore) AccountUpdateDataKey(ctx context.Context, id int64, currentDataKey string, newDataKey string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.account.DataKey != currentDataKey {
+		return ErrNotFound
+	}
+
+	s.account.DataKey = newDataKey
+	s.writes++
+
+	return nil
+}
+
+func TestAccountDataKeyConcurrentCreation(t *testing.T) {
+	const callers = 8
+
+	provider, err := keys.NewStaticProvider(testDataKey())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store := &accountKeyStore{account: Account{ID: 1}}
+	m := New(store, &config.Config{}, provider, nil, nil)
+
+	store.readers.Add(callers)
+
+	dataKeys := make([][]byte, callers)
+	errs := make([]error, callers)
+
+	var wg sync.WaitGroup
+	for i := range callers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			dataKeys[i], errs[i] = m.accountDataKey(context.Background(), 1)
+		}()
+	}
+	wg.Wait()
+
+	if store.writes != 1 {
+		t.Fatalf("expected the data key to be stored once, got %d", store.writes)
+	}
+
+	stored, err := provider.UnwrapKey(context.Background(), store.account.DataKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := range callers {
+		if errs[i] != nil {
+			t.Fatalf("caller %d: %v", i, errs[i])
+		}
+
+		if !bytes.Equal(dataKeys[i], stored) {
+			t.Fatalf("caller %d: expected the stored data key, got a different one", i)
+		}
+	}
+}
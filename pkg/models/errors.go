@@ -7,4 +7,12 @@ var (
 	ErrAlreadyExists = errors.New("entity already exists")
 	ErrEncryptFailed = errors.New("encryption failed")
 	ErrDecryptFailed = errors.New("decryption failed")
+	ErrInvalidInput  = errors.New("invalid input")
+	ErrWeakPassword  = errors.New("password does not meet the required strength")
+	ErrQuotaExceeded = errors.New("storage quota exceeded")
+	ErrReadOnlyShare = errors.New("the note is shared read-only")
+
+	ErrBreachCheckUnavailable = errors.New("breach checks are not configured")
+	ErrZeroKnowledgeVault     = errors.New("not available for zero-knowledge vaults")
+	ErrAttachmentsUnavailable = errors.New("attachments are not configured")
 )
@@ -0,0 +1,261 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+)
+
+// exportVersion is the version of the export format written by VaultExport.
+const exportVersion = 1
+
+// VaultExport is every note in a vault in the form they are created in, with hidden custom
+// fields revealed. Notes from zero-knowledge vaults hold their client-encrypted values, without
+// their search index. The folder IDs of notes and folders refer to the folders in the export.
+type VaultExport struct {
+	Version    int                 `json:"version" validate:"required,eq=1"`
+	ExportedAt time.Time           `json:"exported_at"`
+	VaultMode  string              `json:"vault_mode" validate:"omitempty,oneof=server zero_knowledge"`
+	Folders    []ExportFolder      `json:"folders,omitempty" validate:"max=10000,dive"`
+	Notes      []NoteCreateRequest `json:"notes" validate:"max=10000,dive"`
+}
+
+// ExportFolder is a folder in an export.
+type ExportFolder struct {
+	ID       int64  `json:"id" validate:"required,min=1"`
+	ParentID *int64 `json:"parent_id" validate:"omitempty,min=1"`
+	Name     string `json:"name" validate:"required,max=255"`
+}
+
+// VaultImportResponse reports how many notes an import created.
+type VaultImportResponse struct {
+	Imported int `json:"imported"`
+}
+
+// Defines the required interface to implement storage for imports.
+type importStore interface {
+	// VaultImport creates the folders in order and then the notes in one transaction, calling
+	// the seal at the same index for each saved note as NoteCreate does. The folders have
+	// negative placeholder IDs, and a negative parent ID or note folder ID refers to the folder
+	// created for that placeholder.
+	VaultImport(ctx context.Context, folders []Folder, notes []Note, seals []NoteSealFunc) error
+}
+
+// VaultExport returns every note owned by the account, decrypted.
+func (m *Models) VaultExport(ctx context.Context, accountID int64) (VaultExport, error) {
+	account, err := m.store.AccountGetByID(ctx, accountID)
+	if err != nil {
+		return VaultExport{}, err
+	}
+
+	notes, err := m.store.NoteGetByAccountID(ctx, accountID, NoteFilter{})
+	if err != nil {
+		return VaultExport{}, err
+	}
+
+	folders, err := m.store.FolderGetByAccountID(ctx, accountID)
+	if err != nil {
+		return VaultExport{}, err
+	}
+
+	dataKey, err := m.dataKeyForAccount(ctx, account)
+	if err != nil {
+		return VaultExport{}, err
+	}
+
+	export := VaultExport{
+		Version:    exportVersion,
+		ExportedAt: time.Now().UTC(),
+		VaultMode:  account.VaultMode,
+		Folders:    make([]ExportFolder, len(folders)),
+		Notes:      make([]NoteCreateRequest, len(notes)),
+	}
+
+	for i, folder := range folders {
+		export.Folders[i] = ExportFolder{ID: folder.ID, ParentID: folder.ParentID, Name: folder.Name}
+	}
+
+	for i, note := range notes {
+		plaintext, err := m.decryptNoteValue(dataKey, note, note.Value)
+		if err != nil {
+			return VaultExport{}, ErrDecryptFailed
+		}
+
+		export.Notes[i] = NoteCreateRequest{Name: note.Name, Type: note.Type, FolderID: note.FolderID, Tags: note.Tags}
+
+		if account.VaultMode == VaultModeZeroKnowledge {
+			export.Notes[i].Value = plaintext
+			continue
+		}
+
+		payload, err := decodeNotePayload(note, plaintext)
+		if err != nil {
+			return VaultExport{}, err
+		}
+
+		export.Notes[i].Type = payload.Type
+		export.Notes[i].NoteFields = payload.NoteFields
+		export.Notes[i].Fields = payload.Fields
+	}
+
+	return export, nil
+}
+
+// VaultImport creates a note for every note in the export. Every note is checked before any are
+// saved, and the folders and notes are saved in one transaction, so an import that fails adds
+// nothing. Exports from a vault with a different vault mode can't be imported, since one has
+// plaintext fields and the other client-encrypted values. Folders are merged into folders with
+// the same name and parent.
+func (m *Models) VaultImport(ctx context.Context, accountID int64, input VaultExport) (VaultImportResponse, error) {
+	account, err := m.store.AccountGetByID(ctx, accountID)
+	if err != nil {
+		return VaultImportResponse{}, err
+	}
+
+	if input.VaultMode != "" && input.VaultMode != account.VaultMode {
+		return VaultImportResponse{}, fmt.Errorf("%w: exports from %s vaults can't be imported into %s vaults", ErrInvalidInput, input.VaultMode, account.VaultMode)
+	}
+
+	order, err := exportFolderOrder(input.Folders)
+	if err != nil {
+		return VaultImportResponse{}, err
+	}
+
+	plaintexts := make([]string, len(input.Notes))
+	payloads := make([]notePayload, len(input.Notes))
+	tags := make([][]string, len(input.Notes))
+	for i, note := range input.Notes {
+		var payload notePayload
+		plaintexts[i], payload, err = notePlaintext(account, note)
+		if err != nil {
+			return VaultImportResponse{}, fmt.Errorf("note %d: %w", i+1, err)
+		}
+		payloads[i] = payload
+
+		if note.FolderID != nil {
+			if _, ok := order[*note.FolderID]; !ok {
+				return VaultImportResponse{}, fmt.Errorf("note %d: %w: folder %d not found", i+1, ErrInvalidInput, *note.FolderID)
+			}
+		}
+
+		tags[i], err = normalizeTags(note.Tags)
+		if err != nil {
+			return VaultImportResponse{}, fmt.Errorf("note %d: %w", i+1, err)
+		}
+	}
+
+	dataKey, err := m.dataKeyForAccount(ctx, account)
+	if err != nil {
+		return VaultImportResponse{}, err
+	}
+
+	indexKey, err := m.searchIndexKey(ctx, account)
+	if err != nil {
+		return VaultImportResponse{}, err
+	}
+
+	folderIDs, folders, err := m.importFolders(ctx, accountID, input.Folders, order)
+	if err != nil {
+		return VaultImportResponse{}, err
+	}
+
+	notes := make([]Note, len(input.Notes))
+	seals := make([]NoteSealFunc, len(input.Notes))
+	for i, note := range input.Notes {
+		search := noteSearchFields(account, indexKey, note, payloads[i])
+		notes[i] = Note{AccountID: accountID, Name: note.Name, Type: note.Type, Tags: tags[i], Search: &search}
+		if note.FolderID != nil {
+			folderID := folderIDs[*note.FolderID]
+			notes[i].FolderID = &folderID
+		}
+
+		seals[i] = noteSealer(dataKey, plaintexts[i])
+	}
+
+	if err := m.store.VaultImport(ctx, folders, notes, seals); err != nil {
+		return VaultImportResponse{}, err
+	}
+
+	return VaultImportResponse{Imported: len(input.Notes)}, nil
+}
+
+// exportFolderOrder checks that every parent of the exported folders is in the export and that
+// they don't form a cycle. Returns the depth of each folder by its ID, so parents can be created
+// before their subfolders.
+func exportFolderOrder(folders []ExportFolder) (map[int64]int, error) {
+	parents := make(map[int64]*int64, len(folders))
+	for _, folder := range folders {
+		if _, ok := parents[folder.ID]; ok {
+			return nil, fmt.Errorf("%w: folder %d is exported more than once", ErrInvalidInput, folder.ID)
+		}
+		parents[folder.ID] = folder.ParentID
+	}
+
+	depths := make(map[int64]int, len(folders))
+	for _, folder := range folders {
+		depth := 0
+		for parentID := folder.ParentID; parentID != nil; parentID = parents[*parentID] {
+			if _, ok := parents[*parentID]; !ok {
+				return nil, fmt.Errorf("%w: folder %d not found", ErrInvalidInput, *parentID)
+			}
+
+			depth++
+			if depth > len(folders) {
+				return nil, fmt.Errorf("%w: folder %d is inside itself", ErrInvalidInput, folder.ID)
+			}
+		}
+		depths[folder.ID] = depth
+	}
+
+	return depths, nil
+}
+
+// importFolders plans the folders to create for the exported folders, parents first, reusing
+// folders that already have the same name and parent. The folders to create are given negative
+// IDs for the store to replace. Returns the ID of the account's folder or of the folder to
+// create for each exported folder ID, along with the folders to create.
+func (m *Models) importFolders(ctx context.Context, accountID int64, folders []ExportFolder, depths map[int64]int) (map[int64]int64, []Folder, error) {
+	existing, err := m.store.FolderGetByAccountID(ctx, accountID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	sorted := slices.Clone(folders)
+	slices.SortStableFunc(sorted, func(a, b ExportFolder) int {
+		return depths[a.ID] - depths[b.ID]
+	})
+
+	ids := make(map[int64]int64, len(folders))
+	created := []Folder{}
+	for _, folder := range sorted {
+		var parentID *int64
+		if folder.ParentID != nil {
+			id := ids[*folder.ParentID]
+			parentID = &id
+		}
+
+		index := slices.IndexFunc(existing, func(f Folder) bool {
+			return f.Name == folder.Name && equalFolderIDs(f.ParentID, parentID)
+		})
+		if index >= 0 {
+			ids[folder.ID] = existing[index].ID
+			continue
+		}
+
+		planned := Folder{ID: -int64(len(created) + 1), AccountID: accountID, ParentID: parentID, Name: folder.Name}
+		created = append(created, planned)
+		existing = append(existing, planned)
+		ids[folder.ID] = planned.ID
+	}
+
+	return ids, created, nil
+}
+
+func equalFolderIDs(a *int64, b *int64) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+
+	return *a == *b
+}
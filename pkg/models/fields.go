@@ -0,0 +1,104 @@
+package models
+
+import (
+	"fmt"
+	"slices"
+)
+
+// Custom field types.
+const (
+	CustomFieldText    = "text"
+	CustomFieldHidden  = "hidden"
+	CustomFieldBoolean = "boolean"
+	CustomFieldLinked  = "linked"
+)
+
+// CustomField is extra metadata attached to a note, encrypted along with the note's other
+// fields. Boolean fields hold "true" or "false", and linked fields hold the name of a field of
+// the note's type, such as "username", which clients fill in the field's place. Hidden fields
+// are masked in responses unless they are asked for, and a masked field can be sent back with
+// Masked set to keep its stored value.
+type CustomField struct {
+	Name   string `json:"name" validate:"required,max=255"`
+	Type   string `json:"type" validate:"required,oneof=text hidden boolean linked"`
+	Value  string `json:"value" validate:"max=10000"`
+	Masked bool   `json:"masked,omitempty"`
+}
+
+// linkableFields are the fields of each note type that a linked custom field can refer to.
+var linkableFields = map[string][]string{
+	NoteTypeLogin:    {"username", "password", "totp"},
+	NoteTypeCard:     {"cardholder_name", "brand", "number", "exp_month", "exp_year", "code"},
+	NoteTypeSSHKey:   {"private_key", "passphrase", "public_key", "fingerprint"},
+	NoteTypeAPIToken: {"token", "url"},
+	NoteTypeIdentity: {"title", "first_name", "middle_name", "last_name", "username", "company", "email", "phone",
+		"address1", "address2", "address3", "city", "state", "postal_code", "country", "ssn", "passport_number",
+		"license_number"},
+}
+
+// checkCustomFields returns ErrInvalidInput if a custom field's value doesn't suit its type, or a
+// masked field is left that couldn't be matched to a stored value.
+func checkCustomFields(noteType string, fields []CustomField) error {
+	for _, field := range fields {
+		switch {
+		case field.Masked:
+			return fmt.Errorf("%w: field %q is masked, and only hidden fields of an existing note can be", ErrInvalidInput, field.Name)
+		case field.Type == CustomFieldBoolean && field.Value != "true" && field.Value != "false":
+			return fmt.Errorf("%w: boolean field %q must be \"true\" or \"false\"", ErrInvalidInput, field.Name)
+		case field.Type == CustomFieldLinked && len(linkableFields[noteType]) == 0:
+			return fmt.Errorf("%w: linked field %q can't be used, since %s notes have no fields to link to", ErrInvalidInput, field.Name, noteType)
+		case field.Type == CustomFieldLinked && !slices.Contains(linkableFields[noteType], field.Value):
+			return fmt.Errorf("%w: linked field %q must name one of the %s fields %v", ErrInvalidInput, field.Name, noteType, linkableFields[noteType])
+		}
+	}
+
+	return nil
+}
+
+// maskHiddenFields returns a copy of the fields with the values of hidden fields removed.
+func maskHiddenFields(fields []CustomField) []CustomField {
+	masked := slices.Clone(fields)
+	for i := range masked {
+		if masked[i].Type == CustomFieldHidden {
+			masked[i].Value = ""
+			masked[i].Masked = true
+		}
+	}
+
+	return masked
+}
+
+// hasMaskedFields reports whether any of the fields were sent masked.
+func hasMaskedFields(fields []CustomField) bool {
+	return slices.ContainsFunc(fields, func(field CustomField) bool { return field.Masked })
+}
+
+// restoreMaskedFields fills in masked hidden fields with the values stored for the note. The
+// nth masked field with a name takes the value of the nth stored hidden field with that name.
+func restoreMaskedFields(fields []CustomField, stored []CustomField) []CustomField {
+	restored := slices.Clone(fields)
+	used := map[string]int{}
+
+	for i, field := range restored {
+		if !field.Masked || field.Type != CustomFieldHidden {
+			continue
+		}
+
+		seen := 0
+		for _, storedField := range stored {
+			if storedField.Type != CustomFieldHidden || storedField.Name != field.Name {
+				continue
+			}
+
+			if seen == used[field.Name] {
+				restored[i].Value = storedField.Value
+				restored[i].Masked = false
+				break
+			}
+			seen++
+		}
+		used[field.Name]++
+	}
+
+	return restored
+}
@@ -0,0 +1,204 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+)
+
+const (
+	// FolderDeleteModeParent moves a deleted folder's notes and subfolders into its parent.
+	FolderDeleteModeParent = "parent"
+	// FolderDeleteModeTrash moves the notes in a deleted folder and all of its subfolders to the
+	// trash, and deletes the subfolders with it.
+	FolderDeleteModeTrash = "trash"
+)
+
+// Folder groups notes. Folders nest, and folders without a parent are at the top of the vault.
+type Folder struct {
+	ID        int64     `db:"id"`
+	AccountID int64     `db:"account_id"`
+	ParentID  *int64    `db:"parent_id"`
+	Name      string    `db:"name"`
+	CreatedAt time.Time `db:"created_at"`
+	UpdatedAt time.Time `db:"updated_at"`
+}
+
+// FolderRequest creates a folder, or renames and moves one. A nil ParentID puts the folder at
+// the top of the vault.
+type FolderRequest struct {
+	Name     string `json:"name" validate:"required,max=255"`
+	ParentID *int64 `json:"parent_id" validate:"omitempty,min=1"`
+}
+
+// FolderResponse describes a folder.
+type FolderResponse struct {
+	ID       int64  `json:"id"`
+	ParentID *int64 `json:"parent_id"`
+	Name     string `json:"name"`
+}
+
+// Defines the required interface to implement folder storage. Every method is scoped to the
+// owning account, and folder names must be unique among their siblings, which is reported as
+// ErrAlreadyExists.
+type folderStore interface {
+	FolderCreate(ctx context.Context, folder Folder) (Folder, error)
+	FolderGetByID(ctx context.Context, accountID int64, id int64) (Folder, error)
+	FolderGetByAccountID(ctx context.Context, accountID int64) ([]Folder, error)
+	// FolderUpdate replaces the name and parent of the folder.
+	FolderUpdate(ctx context.Context, folder Folder) (Folder, error)
+	// FolderDelete deletes the folder, moving its notes, including deleted notes, and its
+	// subfolders into its parent in the same transaction.
+	FolderDelete(ctx context.Context, accountID int64, id int64) error
+	// FolderDeleteTree deletes the folders with the provided IDs, which must be a folder and all
+	// of its subfolders. Their notes are moved to the trash and out of the folders in the same
+	// transaction.
+	FolderDeleteTree(ctx context.Context, accountID int64, ids []int64) error
+}
+
+// FolderGetByAccountID lists the account's folders.
+func (m *Models) FolderGetByAccountID(ctx context.Context, accountID int64) ([]FolderResponse, error) {
+	folders, err := m.store.FolderGetByAccountID(ctx, accountID)
+	if err != nil {
+		return []FolderResponse{}, err
+	}
+
+	responses := make([]FolderResponse, len(folders))
+	for i, folder := range folders {
+		responses[i] = folderResponse(folder)
+	}
+
+	return responses, nil
+}
+
+// FolderCreate creates a folder for the account. Returns ErrInvalidInput if the parent isn't
+// one of the account's folders, and ErrAlreadyExists if the parent already has a folder with the
+// same name.
+func (m *Models) FolderCreate(ctx context.Context, accountID int64, input FolderRequest) (FolderResponse, error) {
+	if err := m.checkFolder(ctx, accountID, input.ParentID); err != nil {
+		return FolderResponse{}, err
+	}
+
+	folder, err := m.store.FolderCreate(ctx, Folder{AccountID: accountID, ParentID: input.ParentID, Name: input.Name})
+	if err != nil {
+		return FolderResponse{}, err
+	}
+
+	return folderResponse(folder), nil
+}
+
+// FolderUpdate renames the account's folder with the provided ID and moves it to a new parent.
+// Returns ErrInvalidInput if the parent isn't one of the account's folders, or is the folder
+// itself or one of its subfolders.
+func (m *Models) FolderUpdate(ctx context.Context, accountID int64, folderID int64, input FolderRequest) (FolderResponse, error) {
+	folders, err := m.store.FolderGetByAccountID(ctx, accountID)
+	if err != nil {
+		return FolderResponse{}, err
+	}
+
+	folder, ok := findFolder(folders, folderID)
+	if !ok {
+		return FolderResponse{}, ErrNotFound
+	}
+
+	if input.ParentID != nil {
+		if _, ok := findFolder(folders, *input.ParentID); !ok {
+			return FolderResponse{}, fmt.Errorf("%w: folder %d not found", ErrInvalidInput, *input.ParentID)
+		}
+
+		if slices.Contains(folderTree(folders, folderID), *input.ParentID) {
+			return FolderResponse{}, fmt.Errorf("%w: a folder can't be moved into itself or its subfolders", ErrInvalidInput)
+		}
+	}
+
+	folder.Name = input.Name
+	folder.ParentID = input.ParentID
+
+	saved, err := m.store.FolderUpdate(ctx, folder)
+	if err != nil {
+		return FolderResponse{}, err
+	}
+
+	return folderResponse(saved), nil
+}
+
+// FolderDelete deletes the account's folder with the provided ID. In FolderDeleteModeParent its
+// notes and subfolders are moved into its parent, and in FolderDeleteModeTrash its notes and
+// those of all its subfolders are moved to the trash and the subfolders are deleted too.
+func (m *Models) FolderDelete(ctx context.Context, accountID int64, folderID int64, mode string) error {
+	switch mode {
+	case FolderDeleteModeParent:
+		err := m.store.FolderDelete(ctx, accountID, folderID)
+		if errors.Is(err, ErrAlreadyExists) {
+			return fmt.Errorf("%w: the parent already has a folder with the name of a subfolder", err)
+		}
+		return err
+
+	case FolderDeleteModeTrash:
+		folders, err := m.store.FolderGetByAccountID(ctx, accountID)
+		if err != nil {
+			return err
+		}
+
+		if _, ok := findFolder(folders, folderID); !ok {
+			return ErrNotFound
+		}
+
+		return m.store.FolderDeleteTree(ctx, accountID, folderTree(folders, folderID))
+
+	default:
+		return fmt.Errorf("%w: mode must be %s or %s", ErrInvalidInput, FolderDeleteModeParent, FolderDeleteModeTrash)
+	}
+}
+
+// checkFolder returns ErrInvalidInput unless the folder ID is nil or one of the account's
+// folders.
+func (m *Models) checkFolder(ctx context.Context, accountID int64, folderID *int64) error {
+	if folderID == nil {
+		return nil
+	}
+
+	_, err := m.store.FolderGetByID(ctx, accountID, *folderID)
+	if errors.Is(err, ErrNotFound) {
+		return fmt.Errorf("%w: folder %d not found", ErrInvalidInput, *folderID)
+	}
+
+	return err
+}
+
+// folderTree returns the ID of the folder followed by the IDs of all of its subfolders.
+func folderTree(folders []Folder, folderID int64) []int64 {
+	children := make(map[int64][]int64)
+	for _, folder := range folders {
+		if folder.ParentID != nil {
+			children[*folder.ParentID] = append(children[*folder.ParentID], folder.ID)
+		}
+	}
+
+	tree := []int64{folderID}
+	for i := 0; i < len(tree); i++ {
+		tree = append(tree, children[tree[i]]...)
+	}
+
+	return tree
+}
+
+func findFolder(folders []Folder, folderID int64) (Folder, bool) {
+	for _, folder := range folders {
+		if folder.ID == folderID {
+			return folder, true
+		}
+	}
+
+	return Folder{}, false
+}
+
+func folderResponse(folder Folder) FolderResponse {
+	return FolderResponse{
+		ID:       folder.ID,
+		ParentID: folder.ParentID,
+		Name:     folder.Name,
+	}
+}
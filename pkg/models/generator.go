@@ -0,0 +1,317 @@
+package models
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	_ "embed"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+const (
+	GenerateTypePassword   = "password"
+	GenerateTypePassphrase = "passphrase"
+
+	defaultPasswordLength  = 20
+	defaultPassphraseWords = 6
+	defaultWordSeparator   = "-"
+
+	lowercaseCharacters = "abcdefghijklmnopqrstuvwxyz"
+	uppercaseCharacters = "ABCDEFGHIJKLMNOPQRSTUVWXYZ"
+	digitCharacters     = "0123456789"
+	symbolCharacters    = "!@#$%^&*()-_=+[]{};:,.<>/?~"
+
+	// lookAlikeCharacters are easily confused with each other in many fonts.
+	lookAlikeCharacters = "0Oo1Il|"
+)
+
+// effLargeWordlist is the EFF large wordlist for diceware passphrases, one "<dice roll>\t<word>"
+// pair per line. See https://www.eff.org/dice.
+//
+//go:embed wordlists/eff_large_wordlist.txt
+var effLargeWordlist []byte
+
+var loadWordlist = sync.OnceValue(func() []string {
+	words := make([]string, 0, 7776)
+
+	scanner := bufio.NewScanner(bytes.NewReader(effLargeWordlist))
+	for scanner.Scan() {
+		_, word, ok := strings.Cut(scanner.Text(), "\t")
+		if ok && word != "" {
+			words = append(words, word)
+		}
+	}
+
+	return words
+})
+
+// PasswordGenerateRequest represents the options for generating a password or passphrase. Type
+// selects which of the option sets is used, and defaults to a password.
+type PasswordGenerateRequest struct {
+	Type       string            `json:"type" validate:"omitempty,oneof=password passphrase"`
+	Password   PasswordOptions   `json:"password"`
+	Passphrase PassphraseOptions `json:"passphrase"`
+}
+
+// PasswordOptions controls random character passwords. If no character classes are selected,
+// all of them are used. A minimum for a class also selects it. SymbolSet replaces the default
+// symbols when provided.
+type PasswordOptions struct {
+	Length            int    `json:"length" validate:"omitempty,min=4,max=256"`
+	Lowercase         bool   `json:"lowercase"`
+	Uppercase         bool   `json:"uppercase"`
+	Digits            bool   `json:"digits"`
+	Symbols           bool   `json:"symbols"`
+	MinLowercase      int    `json:"min_lowercase" validate:"min=0,max=256"`
+	MinUppercase      int    `json:"min_uppercase" validate:"min=0,max=256"`
+	MinDigits         int    `json:"min_digits" validate:"min=0,max=256"`
+	MinSymbols        int    `json:"min_symbols" validate:"min=0,max=256"`
+	ExcludeLookAlikes bool   `json:"exclude_look_alikes"`
+	SymbolSet         string `json:"symbol_set" validate:"omitempty,printascii,max=64"`
+}
+
+// PassphraseOptions controls diceware passphrases. Separator defaults to a hyphen when it is
+// missing, and an empty separator joins the words directly. Capitalize upper cases the first
+// letter of every word, and IncludeNumber appends a random digit to one random word.
+type PassphraseOptions struct {
+	Words         int     `json:"words" validate:"omitempty,min=3,max=20"`
+	Separator     *string `json:"separator" validate:"omitempty,max=8"`
+	Capitalize    bool    `json:"capitalize"`
+	IncludeNumber bool    `json:"include_number"`
+}
+
+// PasswordGenerateResponse holds a generated password and its entropy in bits.
+type PasswordGenerateResponse struct {
+	Value       string  `json:"value"`
+	EntropyBits float64 `json:"entropy_bits"`
+}
+
+// characterClass is a named set of characters a password may be required to include.
+type characterClass struct {
+	name       string
+	characters string
+	min        int
+}
+
+// GeneratePassword generates a random password or diceware passphrase with the provided
+// options. Returns ErrInvalidInput if the options can't be satisfied.
+func (m *Models) GeneratePassword(input PasswordGenerateRequest) (PasswordGenerateResponse, error) {
+	if input.Type == GenerateTypePassphrase {
+		return generatePassphrase(input.Passphrase)
+	}
+
+	return generatePassword(input.Password)
+}
+
+// generatePassword picks the minimum number of characters from each required class, fills the
+// rest from every selected class, then shuffles the result. The entropy reported is a lower
+// bound that ignores the shuffle.
+func generatePassword(opts PasswordOptions) (PasswordGenerateResponse, error) {
+	length := opts.Length
+	if length == 0 {
+		length = defaultPasswordLength
+	}
+
+	symbols := symbolCharacters
+	if opts.SymbolSet != "" {
+		symbols = opts.SymbolSet
+	}
+
+	selected := []struct {
+		enabled bool
+		class   characterClass
+	}{
+		{opts.Lowercase || opts.MinLowercase > 0, characterClass{"lowercase", lowercaseCharacters, opts.MinLowercase}},
+		{opts.Uppercase || opts.MinUppercase > 0, characterClass{"uppercase", uppercaseCharacters, opts.MinUppercase}},
+		{opts.Digits || opts.MinDigits > 0, characterClass{"digits", digitCharacters, opts.MinDigits}},
+		{opts.Symbols || opts.MinSymbols > 0, characterClass{"symbols", symbols, opts.MinSymbols}},
+	}
+
+	anySelected := false
+	for _, s := range selected {
+		anySelected = anySelected || s.enabled
+	}
+
+	var classes []characterClass
+	for _, s := range selected {
+		if !s.enabled && anySelected {
+			continue
+		}
+
+		s.class.characters = uniqueCharacters(s.class.characters, opts.ExcludeLookAlikes)
+		if s.class.characters == "" {
+			return PasswordGenerateResponse{}, fmt.Errorf("%w: no %s characters are left to choose from", ErrInvalidInput, s.class.name)
+		}
+
+		classes = append(classes, s.class)
+	}
+
+	required := 0
+	var pool strings.Builder
+	for _, class := range classes {
+		required += class.min
+		pool.WriteString(class.characters)
+	}
+
+	if required > length {
+		return PasswordGenerateResponse{}, fmt.Errorf("%w: the minimum character counts add up to more than the length", ErrInvalidInput)
+	}
+
+	result := make([]byte, 0, length)
+	entropy := 0.0
+
+	for _, class := range classes {
+		chars, err := generateRandomString(class.min, class.characters)
+		if err != nil {
+			return PasswordGenerateResponse{}, err
+		}
+
+		result = append(result, chars...)
+		entropy += float64(class.min) * math.Log2(float64(len(class.characters)))
+	}
+
+	poolCharacters := uniqueCharacters(pool.String(), false)
+
+	rest, err := generateRandomString(length-required, poolCharacters)
+	if err != nil {
+		return PasswordGenerateResponse{}, err
+	}
+
+	result = append(result, rest...)
+	entropy += float64(length-required) * math.Log2(float64(len(poolCharacters)))
+
+	if err := shuffle(len(result), func(i, j int) { result[i], result[j] = result[j], result[i] }); err != nil {
+		return PasswordGenerateResponse{}, err
+	}
+
+	return PasswordGenerateResponse{
+		Value:       string(result),
+		EntropyBits: roundEntropy(entropy),
+	}, nil
+}
+
+// generatePassphrase picks words uniformly from the EFF large wordlist. Capitalization adds no
+// entropy since it is applied to every word.
+func generatePassphrase(opts PassphraseOptions) (PasswordGenerateResponse, error) {
+	count := opts.Words
+	if count == 0 {
+		count = defaultPassphraseWords
+	}
+
+	separator := defaultWordSeparator
+	if opts.Separator != nil {
+		separator = *opts.Separator
+	}
+
+	wordlist := loadWordlist()
+	words := make([]string, count)
+
+	for i := range words {
+		index, err := randomInt(len(wordlist))
+		if err != nil {
+			return PasswordGenerateResponse{}, err
+		}
+
+		words[i] = wordlist[index]
+		if opts.Capitalize {
+			runes := []rune(words[i])
+			runes[0] = unicode.ToUpper(runes[0])
+			words[i] = string(runes)
+		}
+	}
+
+	entropy := float64(count) * math.Log2(float64(len(wordlist)))
+
+	if opts.IncludeNumber {
+		index, err := randomInt(count)
+		if err != nil {
+			return PasswordGenerateResponse{}, err
+		}
+
+		digit, err := generateRandomString(1, digitCharacters)
+		if err != nil {
+			return PasswordGenerateResponse{}, err
+		}
+
+		words[index] += digit
+		entropy += math.Log2(float64(count)) + math.Log2(float64(len(digitCharacters)))
+	}
+
+	return PasswordGenerateResponse{
+		Value:       strings.Join(words, separator),
+		EntropyBits: roundEntropy(entropy),
+	}, nil
+}
+
+// uniqueCharacters removes duplicate characters, and look-alikes if requested, from the set.
+func uniqueCharacters(characters string, excludeLookAlikes bool) string {
+	var result strings.Builder
+
+	for i := 0; i < len(characters); i++ {
+		c := characters[i]
+		if strings.IndexByte(characters[:i], c) >= 0 {
+			continue
+		}
+		if excludeLookAlikes && strings.IndexByte(lookAlikeCharacters, c) >= 0 {
+			continue
+		}
+
+		result.WriteByte(c)
+	}
+
+	return result.String()
+}
+
+func roundEntropy(bits float64) float64 {
+	return math.Round(bits*100) / 100
+}
+
+// generateRandomString returns a cryptographically secure random string of the provided length.
+func generateRandomString(length int, validCharacters string) (string, error) {
+	if len(validCharacters) == 0 {
+		return "", errors.New("must provide at least one valid character")
+	}
+
+	result := make([]byte, length)
+
+	for i := range result {
+		charIndex, err := randomInt(len(validCharacters))
+		if err != nil {
+			return "", err
+		}
+
+		result[i] = validCharacters[charIndex]
+	}
+
+	return string(result), nil
+}
+
+// randomInt returns a cryptographically secure random integer in [0, n).
+func randomInt(n int) (int, error) {
+	i, err := rand.Int(rand.Reader, big.NewInt(int64(n)))
+	if err != nil {
+		return 0, err
+	}
+
+	return int(i.Int64()), nil
+}
+
+// shuffle performs a Fisher-Yates shuffle using a cryptographically secure source.
+func shuffle(n int, swap func(i, j int)) error {
+	for i := n - 1; i > 0; i-- {
+		j, err := randomInt(i + 1)
+		if err != nil {
+			return err
+		}
+
+		swap(i, j)
+	}
+
+	return nil
+}
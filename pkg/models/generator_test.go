@@ -0,0 +1,286 @@
+package models
+
+import (
+	"errors"
+	"math"
+	"strings"
+	"testing"
+	"unicode"
+)
+
+// countCharacters counts the characters of value that are in characters.
+func countCharacters(value string, characters string) int {
+	count := 0
+	for _, c := range value {
+		if strings.ContainsRune(characters, c) {
+			count++
+		}
+	}
+
+	return count
+}
+
+func TestGeneratePasswordClasses(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    PasswordOptions
+		length  int
+		allowed string
+		// minimums holds the least number of characters expected from each set
+		minimums map[string]int
+	}{
+		{
+			name:    "defaults",
+			opts:    PasswordOptions{},
+			length:  defaultPasswordLength,
+			allowed: lowercaseCharacters + uppercaseCharacters + digitCharacters + symbolCharacters,
+		},
+		{
+			name:    "digits only",
+			opts:    PasswordOptions{Length: 12, Digits: true},
+			length:  12,
+			allowed: digitCharacters,
+		},
+		{
+			name:     "minimums select their classes",
+			opts:     PasswordOptions{Length: 8, MinUppercase: 3, MinDigits: 5},
+			length:   8,
+			allowed:  uppercaseCharacters + digitCharacters,
+			minimums: map[string]int{uppercaseCharacters: 3, digitCharacters: 5},
+		},
+		{
+			name:    "minimums within all classes",
+			opts:    PasswordOptions{Length: 16, Lowercase: true, Uppercase: true, Digits: true, Symbols: true, MinLowercase: 2, MinUppercase: 2, MinDigits: 4, MinSymbols: 4},
+			length:  16,
+			allowed: lowercaseCharacters + uppercaseCharacters + digitCharacters + symbolCharacters,
+			minimums: map[string]int{
+				lowercaseCharacters: 2, uppercaseCharacters: 2, digitCharacters: 4, symbolCharacters: 4,
+			},
+		},
+		{
+			name:     "symbol set",
+			opts:     PasswordOptions{Length: 10, Lowercase: true, MinSymbols: 4, SymbolSet: "#%"},
+			length:   10,
+			allowed:  lowercaseCharacters + "#%",
+			minimums: map[string]int{"#%": 4},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for range 50 {
+				generated, err := generatePassword(tt.opts)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if len(generated.Value) != tt.length {
+					t.Fatalf("expected %d characters, got %q", tt.length, generated.Value)
+				}
+
+				if countCharacters(generated.Value, tt.allowed) != tt.length {
+					t.Fatalf("expected only characters from %q, got %q", tt.allowed, generated.Value)
+				}
+
+				for characters, min := range tt.minimums {
+					if count := countCharacters(generated.Value, characters); count < min {
+						t.Fatalf("expected at least %d of %q, got %q", min, characters, generated.Value)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestGeneratePasswordExcludeLookAlikes(t *testing.T) {
+	for range 20 {
+		generated, err := generatePassword(PasswordOptions{Length: 256, ExcludeLookAlikes: true, SymbolSet: "|!"})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if count := countCharacters(generated.Value, lookAlikeCharacters); count != 0 {
+			t.Fatalf("expected none of %q, got %q", lookAlikeCharacters, generated.Value)
+		}
+	}
+
+	// the look-alikes are left out of the pool, and so of the entropy
+	generated, err := generatePassword(PasswordOptions{Length: 10, Digits: true, ExcludeLookAlikes: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if expected := roundEntropy(10 * math.Log2(8)); generated.EntropyBits != expected {
+		t.Fatalf("expected %v bits, got %v", expected, generated.EntropyBits)
+	}
+}
+
+func TestGeneratePasswordInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		opts PasswordOptions
+	}{
+		{"minimums over the length", PasswordOptions{Length: 8, MinLowercase: 4, MinDigits: 5}},
+		{"minimums over the default length", PasswordOptions{MinSymbols: defaultPasswordLength + 1}},
+		{"no symbols left", PasswordOptions{Symbols: true, SymbolSet: "|", ExcludeLookAlikes: true}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := generatePassword(tt.opts); !errors.Is(err, ErrInvalidInput) {
+				t.Fatalf("expected ErrInvalidInput, got %v", err)
+			}
+		})
+	}
+
+	// the minimums may add up to exactly the length
+	if _, err := generatePassword(PasswordOptions{Length: 9, MinLowercase: 4, MinDigits: 5}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestGeneratePassphrase(t *testing.T) {
+	wordlist := map[string]bool{}
+	for _, word := range loadWordlist() {
+		wordlist[word] = true
+	}
+
+	separator := func(s string) *string { return &s }
+
+	tests := []struct {
+		name      string
+		opts      PassphraseOptions
+		separator string
+		words     int
+	}{
+		{"defaults", PassphraseOptions{}, defaultWordSeparator, defaultPassphraseWords},
+		{"space", PassphraseOptions{Words: 3, Separator: separator(" ")}, " ", 3},
+		{"several characters", PassphraseOptions{Words: 20, Separator: separator("::")}, "::", 20},
+		{"no separator", PassphraseOptions{Words: 4, Separator: separator("")}, "", 4},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			generated, err := generatePassphrase(tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if tt.separator == "" {
+				if strings.ContainsAny(generated.Value, " :") || generated.Value != strings.ToLower(generated.Value) {
+					t.Fatalf("expected the words to be joined directly, got %q", generated.Value)
+				}
+				return
+			}
+
+			// some words hold hyphens, so only the count of other separators is exact
+			words := strings.Split(generated.Value, tt.separator)
+			if tt.separator == defaultWordSeparator {
+				if len(words) < tt.words {
+					t.Fatalf("expected at least %d parts, got %q", tt.words, generated.Value)
+				}
+				return
+			}
+
+			if len(words) != tt.words {
+				t.Fatalf("expected %d words, got %q", tt.words, generated.Value)
+			}
+
+			for _, word := range words {
+				if !wordlist[word] {
+					t.Fatalf("expected words from the wordlist, got %q", word)
+				}
+			}
+		})
+	}
+}
+
+func TestGeneratePassphraseOptions(t *testing.T) {
+	generated, err := generatePassphrase(PassphraseOptions{Words: 5, Separator: new(string), Capitalize: true, IncludeNumber: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	capitals, digits := 0, 0
+	for _, c := range generated.Value {
+		if unicode.IsUpper(c) {
+			capitals++
+		}
+		if unicode.IsDigit(c) {
+			digits++
+		}
+	}
+
+	if capitals != 5 || digits != 1 {
+		t.Fatalf("expected 5 capitalized words and 1 digit, got %q", generated.Value)
+	}
+
+	if !unicode.IsUpper([]rune(generated.Value)[0]) {
+		t.Fatalf("expected the first word to be capitalized, got %q", generated.Value)
+	}
+}
+
+func TestGeneratedEntropy(t *testing.T) {
+	words := float64(len(loadWordlist()))
+
+	tests := []struct {
+		name    string
+		request PasswordGenerateRequest
+		bits    float64
+	}{
+		{
+			name:    "digits",
+			request: PasswordGenerateRequest{Password: PasswordOptions{Length: 10, Digits: true}},
+			bits:    10 * math.Log2(10),
+		},
+		{
+			name:    "lowercase",
+			request: PasswordGenerateRequest{Password: PasswordOptions{Length: 8, Lowercase: true}},
+			bits:    8 * math.Log2(26),
+		},
+		{
+			name:    "minimums",
+			request: PasswordGenerateRequest{Password: PasswordOptions{Length: 10, Lowercase: true, MinDigits: 2}},
+			bits:    2*math.Log2(10) + 8*math.Log2(36),
+		},
+		{
+			name:    "duplicate symbols",
+			request: PasswordGenerateRequest{Password: PasswordOptions{Length: 4, Symbols: true, SymbolSet: "!!??"}},
+			bits:    4 * math.Log2(2),
+		},
+		{
+			name:    "passphrase",
+			request: PasswordGenerateRequest{Type: GenerateTypePassphrase},
+			bits:    defaultPassphraseWords * math.Log2(words),
+		},
+		{
+			name:    "passphrase with a number",
+			request: PasswordGenerateRequest{Type: GenerateTypePassphrase, Passphrase: PassphraseOptions{Words: 5, IncludeNumber: true}},
+			bits:    5*math.Log2(words) + math.Log2(5) + math.Log2(10),
+		},
+		{
+			name:    "capitalized passphrase",
+			request: PasswordGenerateRequest{Type: GenerateTypePassphrase, Passphrase: PassphraseOptions{Words: 4, Capitalize: true}},
+			bits:    4 * math.Log2(words),
+		},
+	}
+
+	m := &Models{}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			generated, err := m.GeneratePassword(tt.request)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if expected := roundEntropy(tt.bits); generated.EntropyBits != expected {
+				t.Fatalf("expected %v bits, got %v", expected, generated.EntropyBits)
+			}
+		})
+	}
+
+	if len(loadWordlist()) != 7776 {
+		t.Fatalf("expected the 7776 words of the EFF large wordlist, got %d", len(loadWordlist()))
+	}
+}
@@ -0,0 +1,261 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/oalexander6/passman/pkg/otp"
+	"github.com/oalexander6/passman/pkg/urlmatch"
+	"golang.org/x/crypto/ssh"
+)
+
+// Note types. Each type stores its own set of fields.
+const (
+	NoteTypeLogin      = "login"
+	NoteTypeCard       = "card"
+	NoteTypeIdentity   = "identity"
+	NoteTypeSSHKey     = "ssh_key"
+	NoteTypeAPIToken   = "api_token"
+	NoteTypeSecureNote = "secure_note"
+)
+
+// NoteFields holds the structured fields of a note. Only the member matching the note's type is
+// set. All of the fields are encrypted together as the note's value.
+type NoteFields struct {
+	Login      *LoginFields      `json:"login,omitempty"`
+	Card       *CardFields       `json:"card,omitempty"`
+	Identity   *IdentityFields   `json:"identity,omitempty"`
+	SSHKey     *SSHKeyFields     `json:"ssh_key,omitempty"`
+	APIToken   *APITokenFields   `json:"api_token,omitempty"`
+	SecureNote *SecureNoteFields `json:"secure_note,omitempty"`
+}
+
+// LoginFields are the fields of a website or app login. TOTP is the login's one-time password
+// seed, as an otpauth:// URI, a steam:// URI or a base32 secret.
+type LoginFields struct {
+	Username string     `json:"username" validate:"max=255"`
+	Password string     `json:"password" validate:"max=1024"`
+	TOTP     string     `json:"totp,omitempty" validate:"max=2048"`
+	URIs     []LoginURI `json:"uris" validate:"max=50,dive"`
+	Notes    string     `json:"notes" validate:"max=10000"`
+}
+
+// LoginURI is a website or app a login is used for. Match is how the URI is compared with the
+// URL of a page being filled, one of the urlmatch modes, and is base_domain when empty. Regex URIs
+// hold a regular expression rather than a URL.
+type LoginURI struct {
+	URI   string `json:"uri" validate:"required,max=2048"`
+	Match string `json:"match,omitempty" validate:"omitempty,oneof=base_domain host starts_with regex never"`
+}
+
+// CardFields are the fields of a payment card.
+type CardFields struct {
+	CardholderName string `json:"cardholder_name" validate:"max=255"`
+	Brand          string `json:"brand" validate:"omitempty,oneof=visa mastercard amex discover diners jcb unionpay other"`
+	Number         string `json:"number" validate:"required,credit_card"`
+	ExpMonth       int    `json:"exp_month" validate:"omitempty,min=1,max=12"`
+	ExpYear        int    `json:"exp_year" validate:"omitempty,min=1970,max=9999"`
+	Code           string `json:"code" validate:"omitempty,numeric,min=3,max=4"`
+	Notes          string `json:"notes" validate:"max=10000"`
+}
+
+// IdentityFields are the personal details used to fill in forms. Country is an ISO 3166-1
+// alpha-2 code.
+type IdentityFields struct {
+	Title          string `json:"title" validate:"max=64"`
+	FirstName      string `json:"first_name" validate:"max=255"`
+	MiddleName     string `json:"middle_name" validate:"max=255"`
+	LastName       string `json:"last_name" validate:"max=255"`
+	Username       string `json:"username" validate:"max=255"`
+	Company        string `json:"company" validate:"max=255"`
+	Email          string `json:"email" validate:"omitempty,email,max=255"`
+	Phone          string `json:"phone" validate:"max=64"`
+	Address1       string `json:"address1" validate:"max=255"`
+	Address2       string `json:"address2" validate:"max=255"`
+	Address3       string `json:"address3" validate:"max=255"`
+	City           string `json:"city" validate:"max=255"`
+	State          string `json:"state" validate:"max=255"`
+	PostalCode     string `json:"postal_code" validate:"max=32"`
+	Country        string `json:"country" validate:"omitempty,iso3166_1_alpha2"`
+	SSN            string `json:"ssn" validate:"max=64"`
+	PassportNumber string `json:"passport_number" validate:"max=64"`
+	LicenseNumber  string `json:"license_number" validate:"max=64"`
+	Notes          string `json:"notes" validate:"max=10000"`
+}
+
+// SSHKeyFields are the fields of an SSH key pair. PublicKey and Fingerprint are derived from the
+// private key when the note is saved.
+type SSHKeyFields struct {
+	PrivateKey  string `json:"private_key" validate:"required,max=16384"`
+	Passphrase  string `json:"passphrase" validate:"max=1024"`
+	PublicKey   string `json:"public_key" validate:"max=16384"`
+	Fingerprint string `json:"fingerprint"`
+	Notes       string `json:"notes" validate:"max=10000"`
+}
+
+// APITokenFields are the fields of an API token. URL is where the token is used.
+type APITokenFields struct {
+	Token     string     `json:"token" validate:"required,max=8192"`
+	URL       string     `json:"url" validate:"omitempty,url,max=2048"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	Notes     string     `json:"notes" validate:"max=10000"`
+}
+
+// SecureNoteFields are the fields of a free-form note.
+type SecureNoteFields struct {
+	Text string `json:"text" validate:"required,max=65536"`
+}
+
+// notePayload is the plaintext that is encrypted into a note's value. The type is repeated
+// inside it so a note can't be relabeled by changing its type column.
+type notePayload struct {
+	Type string `json:"type"`
+	NoteFields
+	Fields []CustomField `json:"fields"`
+}
+
+// check returns ErrInvalidInput unless the member of the fields matching noteType is the only
+// one set.
+func (f NoteFields) check(noteType string) error {
+	set := map[string]bool{
+		NoteTypeLogin:      f.Login != nil,
+		NoteTypeCard:       f.Card != nil,
+		NoteTypeIdentity:   f.Identity != nil,
+		NoteTypeSSHKey:     f.SSHKey != nil,
+		NoteTypeAPIToken:   f.APIToken != nil,
+		NoteTypeSecureNote: f.SecureNote != nil,
+	}
+
+	for t, isSet := range set {
+		if isSet != (t == noteType) {
+			return fmt.Errorf("%w: %s notes must set the %q fields and no others", ErrInvalidInput, noteType, noteType)
+		}
+	}
+
+	return nil
+}
+
+// password returns the password that strength and breach checks apply to, if the note has one.
+func (f NoteFields) password() string {
+	if f.Login != nil {
+		return f.Login.Password
+	}
+
+	return ""
+}
+
+// prepareFields checks the fields that need more than struct tags and fills in the fields the
+// server derives. URIs must be usable with their match mode and one-time password seeds must
+// parse. The public key and fingerprint of SSH keys come from the private key, and a provided
+// public key must match it.
+func prepareFields(fields NoteFields) (NoteFields, error) {
+	if fields.Login != nil {
+		login := *fields.Login
+		if login.URIs == nil {
+			login.URIs = []LoginURI{}
+		}
+
+		for i, uri := range login.URIs {
+			if err := urlmatch.Validate(uri.URI, uri.Match); err != nil {
+				return NoteFields{}, fmt.Errorf("%w: uri %d: %s", ErrInvalidInput, i+1, err)
+			}
+		}
+
+		if login.TOTP != "" {
+			if _, err := otp.Parse(login.TOTP); err != nil {
+				return NoteFields{}, fmt.Errorf("%w: %s", ErrInvalidInput, err)
+			}
+		}
+
+		fields.Login = &login
+	}
+
+	if fields.SSHKey == nil {
+		return fields, nil
+	}
+
+	sshKey := *fields.SSHKey
+
+	var (
+		key any
+		err error
+	)
+	if sshKey.Passphrase != "" {
+		key, err = ssh.ParseRawPrivateKeyWithPassphrase([]byte(sshKey.PrivateKey), []byte(sshKey.Passphrase))
+	} else {
+		key, err = ssh.ParseRawPrivateKey([]byte(sshKey.PrivateKey))
+	}
+	if err != nil {
+		return NoteFields{}, fmt.Errorf("%w: the private key could not be parsed", ErrInvalidInput)
+	}
+
+	signer, err := ssh.NewSignerFromKey(key)
+	if err != nil {
+		return NoteFields{}, fmt.Errorf("%w: the private key type is not supported", ErrInvalidInput)
+	}
+
+	publicKey := strings.TrimSpace(string(ssh.MarshalAuthorizedKey(signer.PublicKey())))
+
+	if sshKey.PublicKey != "" {
+		provided, _, _, _, err := ssh.ParseAuthorizedKey([]byte(sshKey.PublicKey))
+		if err != nil || ssh.FingerprintSHA256(provided) != ssh.FingerprintSHA256(signer.PublicKey()) {
+			return NoteFields{}, fmt.Errorf("%w: the public key does not match the private key", ErrInvalidInput)
+		}
+	} else {
+		sshKey.PublicKey = publicKey
+	}
+
+	sshKey.Fingerprint = ssh.FingerprintSHA256(signer.PublicKey())
+	fields.SSHKey = &sshKey
+
+	return fields, nil
+}
+
+// encodeNotePayload returns the plaintext to encrypt as the value of a note.
+func encodeNotePayload(payload notePayload) (string, error) {
+	plaintext, err := json.Marshal(payload)
+	if err != nil {
+		return "", err
+	}
+
+	return string(plaintext), nil
+}
+
+// decodeNotePayload parses the decrypted value of a note. Notes saved before types were
+// introduced have no type and a bare value, which is read as a login password.
+func decodeNotePayload(note Note, plaintext string) (notePayload, error) {
+	if note.Type == "" {
+		return notePayload{
+			Type:       NoteTypeLogin,
+			NoteFields: NoteFields{Login: &LoginFields{Password: plaintext, URIs: []LoginURI{}}},
+			Fields:     []CustomField{},
+		}, nil
+	}
+
+	var payload notePayload
+	if err := json.Unmarshal([]byte(plaintext), &payload); err != nil || payload.Type != note.Type {
+		return notePayload{}, ErrDecryptFailed
+	}
+
+	if err := payload.NoteFields.check(note.Type); err != nil {
+		return notePayload{}, ErrDecryptFailed
+	}
+
+	if payload.Fields == nil {
+		payload.Fields = []CustomField{}
+	}
+
+	return payload, nil
+}
+
+// storedNoteType returns the type a stored note is read as, for listings that don't decrypt it.
+// Notes saved before types were introduced have no type, and are read as logins.
+func storedNoteType(noteType string) string {
+	if noteType == "" {
+		return NoteTypeLogin
+	}
+
+	return noteType
+}
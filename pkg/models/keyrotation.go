@@ -0,0 +1,287 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/oalexander6/passman/pkg/keys"
+	"github.com/oalexander6/passman/pkg/logger"
+)
+
+const (
+	defaultRotationBatchSize = 100
+	defaultRotationWorkers   = 4
+)
+
+// KeyRotation records the progress of re-encrypting every account for a new primary master
+// key. Accounts are processed in ascending ID order and LastAccountID is saved after each
+// batch, so an interrupted rotation resumes from the last completed batch.
+type KeyRotation struct {
+	ID            int64      `db:"id"`
+	KeyID         string     `db:"key_id"`
+	LastAccountID int64      `db:"last_account_id"`
+	StartedAt     time.Time  `db:"started_at"`
+	UpdatedAt     time.Time  `db:"updated_at"`
+	CompletedAt   *time.Time `db:"completed_at"`
+}
+
+// KeyRotationOptions configures RotateKeys.
+type KeyRotationOptions struct {
+	// number of accounts processed between checkpoints
+	BatchSize int
+	// number of accounts processed concurrently
+	Workers int
+}
+
+// KeyRotationResult summarizes the work done by RotateKeys.
+type KeyRotationResult struct {
+	KeyID              string
+	Resumed            bool
+	Accounts           int
+	DataKeysRewrapped  int
+	IndexKeysRewrapped int
+	NotesReencrypted   int
+}
+
+// Defines the required interface to implement a key rotation checkpoint store.
+type keyRotationStore interface {
+	// KeyRotationGetIncomplete returns the unfinished rotation to the provided key, or
+	// ErrNotFound if there is none.
+	KeyRotationGetIncomplete(ctx context.Context, keyID string) (KeyRotation, error)
+	KeyRotationCreate(ctx context.Context, keyID string) (KeyRotation, error)
+	KeyRotationUpdate(ctx context.Context, rotation KeyRotation) error
+}
+
+// RotateKeys moves every account onto the key provider's primary master key while the
+// server keeps running. Data and index keys wrapped by an older master key are rewrapped, and
+// note values and versions still encrypted directly with ENCRYPTION_SECRET are re-encrypted with
+// their account's data key. Once it completes, older master keys and secrets can be removed.
+func (m *Models) RotateKeys(ctx context.Context, opts KeyRotationOptions) (KeyRotationResult, error) {
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = defaultRotationBatchSize
+	}
+	if opts.Workers <= 0 {
+		opts.Workers = defaultRotationWorkers
+	}
+
+	result := KeyRotationResult{KeyID: m.keys.PrimaryKeyID()}
+
+	rotation, err := m.store.KeyRotationGetIncomplete(ctx, result.KeyID)
+	if errors.Is(err, ErrNotFound) {
+		rotation, err = m.store.KeyRotationCreate(ctx, result.KeyID)
+	} else if err == nil {
+		result.Resumed = true
+		logger.Log.Info().Msgf("Resuming rotation to key %s after account %d", rotation.KeyID, rotation.LastAccountID)
+	}
+	if err != nil {
+		return result, err
+	}
+
+	for {
+		accountIDs, err := m.store.AccountListIDs(ctx, rotation.LastAccountID, opts.BatchSize)
+		if err != nil {
+			return result, err
+		}
+		if len(accountIDs) == 0 {
+			break
+		}
+
+		if err := m.rotateBatch(ctx, accountIDs, opts.Workers, &result); err != nil {
+			return result, err
+		}
+
+		rotation.LastAccountID = accountIDs[len(accountIDs)-1]
+		if err := m.store.KeyRotationUpdate(ctx, rotation); err != nil {
+			return result, err
+		}
+
+		logger.Log.Info().Msgf("Rotated %d accounts, through account %d", result.Accounts, rotation.LastAccountID)
+	}
+
+	completedAt := time.Now().UTC()
+	rotation.CompletedAt = &completedAt
+
+	return result, m.store.KeyRotationUpdate(ctx, rotation)
+}
+
+// rotateBatch rotates the provided accounts using a pool of workers and adds the work done
+// to result. Returns the first error encountered.
+func (m *Models) rotateBatch(ctx context.Context, accountIDs []int64, workers int, result *KeyRotationResult) error {
+	var (
+		mu       sync.Mutex
+		wg       sync.WaitGroup
+		firstErr error
+	)
+
+	jobs := make(chan int64)
+
+	for range min(workers, len(accountIDs)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for accountID := range jobs {
+				rewrapped, notes, err := m.rotateAccount(ctx, accountID)
+
+				mu.Lock()
+				if err != nil && firstErr == nil {
+					firstErr = err
+				}
+				result.Accounts++
+				result.NotesReencrypted += notes
+				if rewrapped.dataKey {
+					result.DataKeysRewrapped++
+				}
+				if rewrapped.indexKey {
+					result.IndexKeysRewrapped++
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+	for _, accountID := range accountIDs {
+		jobs <- accountID
+	}
+	close(jobs)
+
+	wg.Wait()
+
+	return firstErr
+}
+
+// rewrappedKeys records which of an account's keys were rewrapped by rotateAccount.
+type rewrappedKeys struct {
+	dataKey  bool
+	indexKey bool
+}
+
+// rotateAccount rewraps the account's data and index keys with the primary master key if
+// needed and re-encrypts any of its notes and note versions that are not encrypted with the data
+// key. Writes only succeed if the value has not changed since it was read, so concurrent edits
+// are never overwritten.
+func (m *Models) rotateAccount(ctx context.Context, accountID int64) (rewrappedKeys, int, error) {
+	rewrapped := rewrappedKeys{}
+
+	account, err := m.store.AccountGetByID(ctx, accountID)
+	if errors.Is(err, ErrNotFound) {
+		// deleted since the batch was listed
+		return rewrapped, 0, nil
+	}
+	if err != nil {
+		return rewrapped, 0, err
+	}
+
+	rewrapped.dataKey, err = m.rewrapKey(ctx, accountID, account.DataKey, m.store.AccountUpdateDataKey)
+	if err != nil {
+		return rewrapped, 0, err
+	}
+
+	rewrapped.indexKey, err = m.rewrapKey(ctx, accountID, account.IndexKey, m.store.AccountUpdateIndexKey)
+	if err != nil {
+		return rewrapped, 0, err
+	}
+
+	// creates a data key for accounts that have never needed one
+	dataKey, err := m.accountDataKey(ctx, accountID)
+	if err != nil {
+		return rewrapped, 0, err
+	}
+
+	notes, err := m.store.NoteGetAllByAccountID(ctx, accountID)
+	if err != nil {
+		return rewrapped, 0, err
+	}
+
+	reencrypted := 0
+
+	for _, note := range notes {
+		if isCurrentNoteCiphertext(note.Value) {
+			continue
+		}
+
+		plaintext, err := m.decryptNoteValue(dataKey, note, note.Value)
+		if err != nil {
+			return rewrapped, reencrypted, err
+		}
+
+		encVal, err := encryptNoteValue(dataKey, note, plaintext)
+		if err != nil {
+			return rewrapped, reencrypted, err
+		}
+
+		err = m.store.NoteUpdateValue(ctx, accountID, note.ID, note.Value, encVal)
+		if errors.Is(err, ErrNotFound) {
+			// updated concurrently, which already re-encrypted it
+			continue
+		}
+		if err != nil {
+			return rewrapped, reencrypted, err
+		}
+
+		reencrypted++
+	}
+
+	versions, err := m.store.NoteVersionGetAllByAccountID(ctx, accountID)
+	if err != nil {
+		return rewrapped, reencrypted, err
+	}
+
+	for _, version := range versions {
+		if isCurrentNoteCiphertext(version.Value) {
+			continue
+		}
+
+		plaintext, err := m.decryptNoteValue(dataKey, version.note(Note{}), version.Value)
+		if err != nil {
+			return rewrapped, reencrypted, err
+		}
+
+		encVal, err := encryptNoteValue(dataKey, version.note(Note{}), plaintext)
+		if err != nil {
+			return rewrapped, reencrypted, err
+		}
+
+		err = m.store.NoteVersionUpdateValue(ctx, accountID, version.ID, version.Value, encVal)
+		if errors.Is(err, ErrNotFound) {
+			// pruned concurrently
+			continue
+		}
+		if err != nil {
+			return rewrapped, reencrypted, err
+		}
+
+		reencrypted++
+	}
+
+	return rewrapped, reencrypted, nil
+}
+
+// rewrapKey wraps one of the account's keys with the primary master key using update, unless it
+// is already wrapped by it or the account has no such key yet. Returns whether it was rewrapped,
+// which it isn't if the key changed concurrently.
+func (m *Models) rewrapKey(ctx context.Context, accountID int64, wrapped string,
+	update func(ctx context.Context, id int64, currentKey string, newKey string) error) (bool, error) {
+	if wrapped == "" || keys.WrappedKeyID(wrapped) == m.keys.PrimaryKeyID() {
+		return false, nil
+	}
+
+	key, err := m.unwrapDataKey(ctx, wrapped)
+	if err != nil {
+		return false, err
+	}
+
+	rewrapped, err := m.keys.WrapKey(ctx, key)
+	if err != nil {
+		return false, err
+	}
+
+	err = update(ctx, accountID, wrapped, rewrapped)
+	if errors.Is(err, ErrNotFound) {
+		return false, nil
+	}
+
+	return err == nil, err
+}
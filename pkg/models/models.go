@@ -1,21 +1,53 @@
 package models
 
-import "github.com/oalexander6/passman/config"
+import (
+	"github.com/oalexander6/passman/config"
+	"github.com/oalexander6/passman/pkg/blob"
+	"github.com/oalexander6/passman/pkg/breach"
+	"github.com/oalexander6/passman/pkg/keys"
+	"github.com/oalexander6/passman/pkg/urlmatch"
+)
 
 type Store interface {
 	accountStore
 	noteStore
+	noteVersionStore
+	trashStore
+	keyRotationStore
+	attachmentStore
+	folderStore
+	tagStore
+	searchStore
+	shareStore
+	importStore
 	Close()
 }
 
 type Models struct {
-	config *config.Config
-	store  Store
+	config   *config.Config
+	store    Store
+	keys     keys.KeyProvider
+	dataKeys *dataKeyCache
+	breaches breach.Checker
+	blobs    blob.BlobStore
+	urls     *urlmatch.Matcher
 }
 
-func New(store Store, config *config.Config) *Models {
+// New creates the models. breaches may be nil, in which case breach checks are disabled, and
+// blobs may be nil, in which case attachments are disabled.
+func New(store Store, config *config.Config, keyProvider keys.KeyProvider, breaches breach.Checker, blobs blob.BlobStore) *Models {
+	equivalentDomains := config.URLMatch.EquivalentDomains
+	if equivalentDomains == nil {
+		equivalentDomains = urlmatch.DefaultEquivalentDomains()
+	}
+
 	return &Models{
-		config: config,
-		store:  store,
+		config:   config,
+		store:    store,
+		keys:     keyProvider,
+		dataKeys: newDataKeyCache(),
+		breaches: breaches,
+		blobs:    blobs,
+		urls:     urlmatch.New(equivalentDomains),
 	}
 }
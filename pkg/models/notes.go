@@ -1,71 +1,162 @@
 package models
 
 import (
-	"bytes"
 	"context"
-	"crypto/aes"
-	"crypto/cipher"
-	"crypto/rand"
-	"encoding/base64"
-	"errors"
-	"math/big"
+	"fmt"
+	"time"
+
+	"github.com/oalexander6/passman/pkg/strength"
 )
 
 // Note represents a note/password, which may be secure or not secure. Secure notes will
-// have their value encrypted upon storage.
+// have their value encrypted upon storage. The value of a typed note holds its fields.
 type Note struct {
-	ID    int64  `db:"id"`
-	Name  string `db:"name"`
-	Value string `db:"value"`
+	ID        int64  `db:"id"`
+	AccountID int64  `db:"account_id"`
+	Name      string `db:"name"`
+	Type      string `db:"type"`
+	Value     string `db:"value"`
+	UpdatedBy int64  `db:"updated_by"`
+	FolderID  *int64 `db:"folder_id"`
+	// ItemKey is the key the note's value is encrypted with once it has been shared, encrypted
+	// with the owner's data key. It is empty for notes that have never been shared.
+	ItemKey string `db:"item_key"`
 	Base
+	DeletedAt *time.Time `db:"deleted_at"`
+	// Tags are the names of the note's tags, sorted. They are stored apart from the note.
+	Tags []string `db:"-"`
+	// Search holds the blind indexes to save with the note. Nil leaves the saved indexes as
+	// they are.
+	Search *NoteSearchFields `db:"-"`
 }
 
-// NoteCreateRequest represents the data required to create a new note.
+// NoteCreateRequest represents the data required to create a new note. Notes in server vaults
+// set the fields for their type and any custom fields. Notes in zero-knowledge vaults set Value
+// to the ciphertext of their fields instead, since the server can't read them, and may send
+// SearchIndex for the note to be found by. A nil FolderID puts the note at the top of the
+// vault, and the folder, tags and search index are replaced on update.
 type NoteCreateRequest struct {
-	Name  string `json:"name" form:"name" validate:"required"`
-	Value string `json:"value" form:"value" validate:"required"`
+	Name        string       `json:"name" form:"name" validate:"required"`
+	Type        string       `json:"type" form:"type" validate:"required,oneof=login card identity ssh_key api_token secure_note"`
+	Value       string       `json:"value,omitempty" form:"value" validate:"max=131072"`
+	FolderID    *int64       `json:"folder_id,omitempty" validate:"omitempty,min=1"`
+	Tags        []string     `json:"tags,omitempty" validate:"max=50,dive,required,max=64"`
+	SearchIndex []BlindIndex `json:"search_index,omitempty" validate:"max=50,dive"`
+	NoteFields
+	Fields []CustomField `json:"fields,omitempty" validate:"max=100,dive"`
 }
 
+// NoteGetResponse is a decrypted note. Value is only set for zero-knowledge accounts, whose
+// fields the server can't read. Strength and Breach are only set when a note is created or
+// updated, since estimating strength is too slow for every note in a list, and are omitted for
+// zero-knowledge accounts and notes without a password. Hidden custom fields are masked unless
+// they were asked for.
 type NoteGetResponse struct {
-	Name  string `json:"name" form:"name"`
-	Value string `json:"value" form:"value"`
+	ID       int64            `json:"id"`
+	Name     string           `json:"name" form:"name"`
+	Type     string           `json:"type"`
+	Value    string           `json:"value,omitempty" form:"value"`
+	FolderID *int64           `json:"folder_id"`
+	Tags     []string         `json:"tags"`
+	Strength *strength.Result `json:"strength,omitempty"`
+	Breach   *BreachStatus    `json:"breach,omitempty"`
+	NoteFields
+	Fields []CustomField `json:"fields,omitempty"`
+}
+
+// NoteSealFunc encrypts the fields of a note. It is called by the store once the note's ID
+// has been assigned, since the ID is bound to the ciphertext.
+type NoteSealFunc func(note Note) (Note, error)
+
+// NoteFilter narrows a listing of notes. Notes must be in one of FolderIDs, where 0 is the top
+// of the vault, and have all of Tags. Empty fields don't filter.
+type NoteFilter struct {
+	FolderIDs []int64
+	Tags      []string
+}
+
+// NoteListRequest narrows a listing of notes to a folder, where 0 is the top of the vault, and
+// to notes with all of the provided tags. Recursive includes the notes in the folder's
+// subfolders.
+type NoteListRequest struct {
+	FolderID  *int64
+	Recursive bool
+	Tags      []string
 }
 
 // NoteStore defines the interface required to implement persistent storage functionality
-// for notes.
+// for notes. Every method is scoped to the owning account, and a note that belongs to a
+// different account must be reported as ErrNotFound.
 type noteStore interface {
-	NoteGetByID(ctx context.Context, id int64) (Note, error)
-	NoteGetByAccountID(ctx context.Context, userID int64) ([]Note, error)
-	NoteCreate(ctx context.Context, noteInput Note) (Note, error)
-	NoteUpdate(ctx context.Context, note Note) (Note, error)
-	NoteDeleteByID(ctx context.Context, id int64) error
+	NoteGetByID(ctx context.Context, accountID int64, id int64) (Note, error)
+	// NoteGetByAccountID returns the account's notes that match the filter.
+	NoteGetByAccountID(ctx context.Context, accountID int64, filter NoteFilter) ([]Note, error)
+	// NoteCreate inserts the note, calls seal with the saved note and stores the sealed
+	// value in the same transaction. Tags the account doesn't have yet are created.
+	NoteCreate(ctx context.Context, noteInput Note, seal NoteSealFunc) (Note, error)
+	// NoteUpdate replaces the note's name, type, value, folder and tags, and records who
+	// updated it, but only if its item key still matches note.ItemKey. Returns ErrNotFound
+	// otherwise. Tags the account doesn't have yet are created. In the same transaction the
+	// note as it was is saved as a version, and all but the newest keepVersions versions of the
+	// note are removed.
+	NoteUpdate(ctx context.Context, accountID int64, note Note, keepVersions int) (Note, error)
+	NoteDeleteByID(ctx context.Context, accountID int64, id int64) error
+	// NoteGetAllByAccountID returns every note owned by the account, including deleted notes.
+	NoteGetAllByAccountID(ctx context.Context, accountID int64) ([]Note, error)
+	// NoteUpdateValue replaces the encrypted value of a note without changing its updated_at
+	// time, but only if the value still matches currentValue. Returns ErrNotFound if the note
+	// does not exist or its value has changed.
+	NoteUpdateValue(ctx context.Context, accountID int64, id int64, currentValue string, newValue string) error
 }
 
-// NoteGetByID returns the note with the provided ID with the value of secure notes decrypted.
-// Returns an error if the note is not found.
-func (m *Models) NoteGetByID(ctx context.Context, noteID int64) (NoteGetResponse, error) {
-	note, err := m.store.NoteGetByID(ctx, noteID)
+// NoteGetByID returns the account's note with the provided ID with the value of secure notes
+// decrypted. Hidden custom fields are masked unless reveal is set. Returns an error if the note
+// is not found.
+func (m *Models) NoteGetByID(ctx context.Context, accountID int64, noteID int64, reveal bool) (NoteGetResponse, error) {
+	note, err := m.store.NoteGetByID(ctx, accountID, noteID)
 	if err != nil {
 		return NoteGetResponse{}, err
 	}
 
-	decryptedVal, err := m.Decyrpt([]byte(note.Value))
+	account, err := m.store.AccountGetByID(ctx, accountID)
 	if err != nil {
-		return NoteGetResponse{}, ErrDecryptFailed
+		return NoteGetResponse{}, err
 	}
 
-	note.Value = decryptedVal
+	dataKey, err := m.dataKeyForAccount(ctx, account)
+	if err != nil {
+		return NoteGetResponse{}, err
+	}
+
+	decryptedVal, err := m.decryptNoteValue(dataKey, note, note.Value)
+	if err != nil {
+		return NoteGetResponse{}, ErrDecryptFailed
+	}
 
-	return NoteGetResponse{
-		Name:  note.Name,
-		Value: note.Value,
-	}, nil
+	return noteResponse(account, note, decryptedVal, reveal)
 }
 
-// NoteGetByAccountID returns all stored notes with the value of secure notes decrypted.
-// Does NOT return an error if no notes are found.
-func (m *Models) NoteGetByAccountID(ctx context.Context, accountID int64) ([]NoteGetResponse, error) {
-	notes, err := m.store.NoteGetByAccountID(ctx, accountID)
+// NoteGetByAccountID returns the stored notes that match the request with the value of secure
+// notes decrypted. Hidden custom fields are masked unless reveal is set. Returns ErrInvalidInput
+// if the folder isn't one of the account's folders. Does NOT return an error if no notes are
+// found.
+func (m *Models) NoteGetByAccountID(ctx context.Context, accountID int64, input NoteListRequest, reveal bool) ([]NoteGetResponse, error) {
+	filter, err := m.noteFilter(ctx, accountID, input)
+	if err != nil {
+		return []NoteGetResponse{}, err
+	}
+
+	notes, err := m.store.NoteGetByAccountID(ctx, accountID, filter)
+	if err != nil {
+		return []NoteGetResponse{}, err
+	}
+
+	account, err := m.store.AccountGetByID(ctx, accountID)
+	if err != nil {
+		return []NoteGetResponse{}, err
+	}
+
+	dataKey, err := m.dataKeyForAccount(ctx, account)
 	if err != nil {
 		return []NoteGetResponse{}, err
 	}
@@ -73,160 +164,354 @@ func (m *Models) NoteGetByAccountID(ctx context.Context, accountID int64) ([]Not
 	unencryptedNotes := make([]NoteGetResponse, len(notes))
 
 	for i := range notes {
-		unencryptedNotes[i] = NoteGetResponse{
-			Name: notes[i].Name,
-		}
-
-		decryptedVal, err := m.Decyrpt([]byte(notes[i].Value))
+		decryptedVal, err := m.decryptNoteValue(dataKey, notes[i], notes[i].Value)
 		if err != nil {
 			return []NoteGetResponse{}, ErrDecryptFailed
 		}
 
-		unencryptedNotes[i].Value = decryptedVal
+		unencryptedNotes[i], err = noteResponse(account, notes[i], decryptedVal, reveal)
+		if err != nil {
+			return []NoteGetResponse{}, err
+		}
 	}
 
 	return unencryptedNotes, nil
 }
 
-// NoteCreate saves a new note. It will encrypt the value of the note if it is marked as secure.
-// Returns an error if the note fails to save.
-func (m *Models) NoteCreate(ctx context.Context, noteInput Note) (Note, error) {
-	unencryptedVal := noteInput.Value
+// NoteCreate saves a new note owned by the provided account. Its fields are encrypted together
+// as the note's value. Returns ErrInvalidInput if the fields don't match the note's type,
+// ErrWeakPassword if a login password doesn't meet the account's policy, or an error if the note
+// fails to save.
+func (m *Models) NoteCreate(ctx context.Context, accountID int64, input NoteCreateRequest) (NoteGetResponse, error) {
+	account, err := m.store.AccountGetByID(ctx, accountID)
+	if err != nil {
+		return NoteGetResponse{}, err
+	}
+
+	unencryptedVal, payload, err := notePlaintext(account, input)
+	if err != nil {
+		return NoteGetResponse{}, err
+	}
 
-	encVal, err := m.Encrypt([]byte(noteInput.Value))
+	tags, err := m.noteLocation(ctx, accountID, input)
 	if err != nil {
-		return Note{}, err
+		return NoteGetResponse{}, err
 	}
 
-	noteInput.Value = encVal
+	dataKey, err := m.dataKeyForAccount(ctx, account)
+	if err != nil {
+		return NoteGetResponse{}, err
+	}
 
-	savedNote, err := m.store.NoteCreate(ctx, noteInput)
+	indexKey, err := m.searchIndexKey(ctx, account)
 	if err != nil {
-		return Note{}, err
+		return NoteGetResponse{}, err
 	}
 
-	savedNote.Value = unencryptedVal
+	search := noteSearchFields(account, indexKey, input, payload)
+	noteInput := Note{AccountID: accountID, Name: input.Name, Type: input.Type, FolderID: input.FolderID, Tags: tags, Search: &search}
+
+	savedNote, err := m.createNote(ctx, dataKey, noteInput, unencryptedVal)
+	if err != nil {
+		return NoteGetResponse{}, err
+	}
 
-	return savedNote, nil
+	return m.savedNoteResponse(account, savedNote, unencryptedVal, payload), nil
 }
 
-// NoteUpdate updates the note that matches the provided note's ID. It will encrypt the provided
-// value if the note is marked as secure.
-// Returns an error if no note with the provided ID is found.
-func (m *Models) NoteUpdate(ctx context.Context, note Note) (Note, error) {
-	unencryptedVal := note.Value
+// NoteUpdate replaces the name, type and fields of the account's note with the provided ID. The
+// fields are encrypted with the current scheme, which also upgrades values written with older
+// schemes. Masked hidden custom fields keep their stored values, and the note as it was is kept
+// as a version, up to the account's MaxNoteVersions. Returns ErrInvalidInput if the fields don't
+// match the note's type, ErrWeakPassword if a login password doesn't meet the account's policy,
+// or an error if no note with the provided ID is found for the account.
+func (m *Models) NoteUpdate(ctx context.Context, accountID int64, noteID int64, input NoteCreateRequest) (NoteGetResponse, error) {
+	account, err := m.store.AccountGetByID(ctx, accountID)
+	if err != nil {
+		return NoteGetResponse{}, err
+	}
+
+	current, err := m.store.NoteGetByID(ctx, accountID, noteID)
+	if err != nil {
+		return NoteGetResponse{}, err
+	}
+
+	tags, err := m.noteLocation(ctx, accountID, input)
+	if err != nil {
+		return NoteGetResponse{}, err
+	}
+
+	return m.updateNote(ctx, account, current, accountID, input, tags)
+}
+
+// updateNote replaces the current note of the owner's vault with the input, in input.FolderID
+// and with tags, as written by updatedBy. The value is encrypted with the note's item key if it
+// has one, and the update fails with ErrNotFound if the item key changes before it is saved.
+func (m *Models) updateNote(ctx context.Context, owner Account, current Note, updatedBy int64, input NoteCreateRequest, tags []string) (NoteGetResponse, error) {
+	dataKey, err := m.dataKeyForAccount(ctx, owner)
+	if err != nil {
+		return NoteGetResponse{}, err
+	}
+
+	if hasMaskedFields(input.Fields) && owner.VaultMode != VaultModeZeroKnowledge {
+		decryptedVal, err := m.decryptNoteValue(dataKey, current, current.Value)
+		if err != nil {
+			return NoteGetResponse{}, ErrDecryptFailed
+		}
+
+		currentPayload, err := decodeNotePayload(current, decryptedVal)
+		if err != nil {
+			return NoteGetResponse{}, err
+		}
 
-	encVal, err := m.Encrypt([]byte(note.Value))
+		input.Fields = restoreMaskedFields(input.Fields, currentPayload.Fields)
+	}
+
+	unencryptedVal, payload, err := notePlaintext(owner, input)
 	if err != nil {
-		return Note{}, err
+		return NoteGetResponse{}, err
 	}
 
-	note.Value = encVal
-	savedNote, err := m.store.NoteUpdate(ctx, note)
+	indexKey, err := m.searchIndexKey(ctx, owner)
 	if err != nil {
-		return Note{}, err
+		return NoteGetResponse{}, err
 	}
 
-	savedNote.Value = unencryptedVal
+	search := noteSearchFields(owner, indexKey, input, payload)
+	note := Note{
+		ID:        current.ID,
+		AccountID: owner.ID,
+		Name:      input.Name,
+		Type:      input.Type,
+		UpdatedBy: updatedBy,
+		FolderID:  input.FolderID,
+		ItemKey:   current.ItemKey,
+		Tags:      tags,
+		Search:    &search,
+	}
+
+	note.Value, err = encryptNoteValue(dataKey, note, unencryptedVal)
+	if err != nil {
+		return NoteGetResponse{}, err
+	}
+
+	savedNote, err := m.store.NoteUpdate(ctx, owner.ID, note, owner.MaxNoteVersions)
+	if err != nil {
+		return NoteGetResponse{}, err
+	}
 
-	return savedNote, nil
+	return m.savedNoteResponse(owner, savedNote, unencryptedVal, payload), nil
 }
 
-// DeleteNoteByID will remove the note with the provided ID.
-// Returns an error if a note with that ID is not found.
-func (m *Models) NoteDeleteByID(ctx context.Context, noteID int64) error {
-	return m.store.NoteDeleteByID(ctx, noteID)
+// DeleteNoteByID will move the account's note with the provided ID to the trash.
+// Returns an error if a note with that ID is not found for the account.
+func (m *Models) NoteDeleteByID(ctx context.Context, accountID int64, noteID int64) error {
+	return m.store.NoteDeleteByID(ctx, accountID, noteID)
 }
 
-// generateRandomString returns a cryptographically secure random string of the provided length.
-func generateRandomString(length int, validCharacters string) (string, error) {
-	if len(validCharacters) == 0 {
-		return "", errors.New("must provide at least one valid character")
+// noteLocation checks the folder of a note being saved and returns its normalized tags. Returns
+// ErrInvalidInput if the folder isn't one of the account's folders or a tag is blank.
+func (m *Models) noteLocation(ctx context.Context, accountID int64, input NoteCreateRequest) ([]string, error) {
+	if err := m.checkFolder(ctx, accountID, input.FolderID); err != nil {
+		return nil, err
 	}
 
-	result := make([]byte, length)
+	return normalizeTags(input.Tags)
+}
+
+// noteFilter returns the store filter for a listing of the account's notes.
+func (m *Models) noteFilter(ctx context.Context, accountID int64, input NoteListRequest) (NoteFilter, error) {
+	tags, err := normalizeTags(input.Tags)
+	if err != nil {
+		return NoteFilter{}, err
+	}
+
+	filter := NoteFilter{Tags: tags}
+	if input.FolderID == nil {
+		return filter, nil
+	}
+
+	if *input.FolderID == 0 {
+		filter.FolderIDs = []int64{0}
+		return filter, nil
+	}
 
-	for i := range result {
-		charIndex, err := rand.Int(rand.Reader, big.NewInt(int64(len(validCharacters))))
+	if err := m.checkFolder(ctx, accountID, input.FolderID); err != nil {
+		return NoteFilter{}, err
+	}
+
+	filter.FolderIDs = []int64{*input.FolderID}
+	if input.Recursive {
+		folders, err := m.store.FolderGetByAccountID(ctx, accountID)
 		if err != nil {
-			return "", err
+			return NoteFilter{}, err
 		}
 
-		result[i] = validCharacters[charIndex.Int64()]
+		filter.FolderIDs = folderTree(folders, *input.FolderID)
 	}
 
-	return string(result), nil
+	return filter, nil
 }
 
-// Encrypt implements AES-256 encryption using PKCS7 padding.
-func (m *Models) Encrypt(plaintext []byte) (string, error) {
-	block, err := aes.NewCipher([]byte(m.config.Encryption.EncSecret))
-	if err != nil {
-		return "", err
+// createNote saves a new note, encrypting the plaintext as its value once its ID is assigned.
+func (m *Models) createNote(ctx context.Context, dataKey []byte, noteInput Note, unencryptedVal string) (Note, error) {
+	return m.store.NoteCreate(ctx, noteInput, noteSealer(dataKey, unencryptedVal))
+}
+
+// noteSealer returns a NoteSealFunc that sets the note's value to the encrypted plaintext.
+func noteSealer(dataKey []byte, unencryptedVal string) NoteSealFunc {
+	return func(note Note) (Note, error) {
+		encVal, err := encryptNoteValue(dataKey, note, unencryptedVal)
+		if err != nil {
+			return Note{}, err
+		}
+
+		note.Value = encVal
+		return note, nil
 	}
+}
 
-	paddedPlaintext := pkcs7Pad(plaintext, block.BlockSize())
+// notePlaintext returns the plaintext to encrypt as the value of a note, along with the payload
+// it encodes. Server vaults must send the fields for the note's type and no search index, and
+// zero-knowledge vaults must send only a client-encrypted value, which a search index may
+// accompany. Returns ErrWeakPassword if a login password doesn't meet the account's policy.
+func notePlaintext(account Account, input NoteCreateRequest) (string, notePayload, error) {
+	if account.VaultMode == VaultModeZeroKnowledge {
+		if input.Value == "" || input.NoteFields != (NoteFields{}) || len(input.Fields) > 0 {
+			return "", notePayload{}, fmt.Errorf("%w: zero-knowledge notes must set an encrypted value instead of fields", ErrInvalidInput)
+		}
 
-	ciphertext := make([]byte, len(paddedPlaintext))
+		return input.Value, notePayload{Type: input.Type}, nil
+	}
 
-	mode := cipher.NewCBCEncrypter(block, []byte(m.config.Encryption.EncIV))
-	mode.CryptBlocks(ciphertext, paddedPlaintext)
+	if input.Value != "" {
+		return "", notePayload{}, fmt.Errorf("%w: notes must set the fields for their type instead of a value", ErrInvalidInput)
+	}
 
-	return base64.StdEncoding.EncodeToString(ciphertext), nil
-}
+	if len(input.SearchIndex) > 0 {
+		return "", notePayload{}, fmt.Errorf("%w: only zero-knowledge notes can set a search index", ErrInvalidInput)
+	}
+
+	if err := input.NoteFields.check(input.Type); err != nil {
+		return "", notePayload{}, err
+	}
 
-// Decrypt implements AES-256 decryption using PKCS7 unpadding.
-func (m *Models) Decyrpt(encrypted []byte) (string, error) {
-	ciphertext, err := base64.StdEncoding.DecodeString(string(encrypted))
+	if err := checkCustomFields(input.Type, input.Fields); err != nil {
+		return "", notePayload{}, err
+	}
+
+	fields, err := prepareFields(input.NoteFields)
 	if err != nil {
-		return "", err
+		return "", notePayload{}, err
+	}
+
+	if err := checkPasswordPolicy(account, input.Name, fields.password()); err != nil {
+		return "", notePayload{}, err
+	}
+
+	payload := notePayload{Type: input.Type, NoteFields: fields, Fields: input.Fields}
+	if payload.Fields == nil {
+		payload.Fields = []CustomField{}
 	}
 
-	block, err := aes.NewCipher([]byte(m.config.Encryption.EncSecret))
+	plaintext, err := encodeNotePayload(payload)
 	if err != nil {
-		return "", err
+		return "", notePayload{}, err
 	}
 
-	mode := cipher.NewCBCDecrypter(block, []byte(m.config.Encryption.EncIV))
-	mode.CryptBlocks(ciphertext, ciphertext)
+	return plaintext, payload, nil
+}
+
+// noteResponse builds the response for a note from its decrypted value.
+func noteResponse(account Account, note Note, plaintext string, reveal bool) (NoteGetResponse, error) {
+	response := NoteGetResponse{
+		ID:       note.ID,
+		Name:     note.Name,
+		Type:     note.Type,
+		FolderID: note.FolderID,
+		Tags:     noteTags(note),
+	}
 
-	plaintext, err := pkcs7UnPad(ciphertext, block.BlockSize())
+	if account.VaultMode == VaultModeZeroKnowledge {
+		response.Value = plaintext
+		return response, nil
+	}
+
+	payload, err := decodeNotePayload(note, plaintext)
 	if err != nil {
-		return "", err
+		return NoteGetResponse{}, err
+	}
+
+	response.Type = payload.Type
+	response.NoteFields = payload.NoteFields
+	response.Fields = payload.Fields
+	if !reveal {
+		response.Fields = maskHiddenFields(payload.Fields)
 	}
 
-	return string(plaintext), nil
+	return response, nil
 }
 
-// pkcs7Pad implements PKCS7 padding by checking the length of the provided
-// buffer, and adding the number of bytes required to increase the length to
-// the next multiple of padToMultipleOf. It always pads with at least one byte.
-// The inserted bytes are all set to the number of bytes inserted.
-func pkcs7Pad(original []byte, padToMultipleOf int) []byte {
-	ogLength := len(original)
+// savedNoteResponse builds the response for a note that was just saved, including the strength
+// and breach status of its password. Hidden custom fields are masked.
+func (m *Models) savedNoteResponse(account Account, note Note, plaintext string, payload notePayload) NoteGetResponse {
+	response := NoteGetResponse{
+		ID:       note.ID,
+		Name:     note.Name,
+		Type:     note.Type,
+		FolderID: note.FolderID,
+		Tags:     noteTags(note),
+	}
+
+	if account.VaultMode == VaultModeZeroKnowledge {
+		response.Value = plaintext
+		return response
+	}
+
+	response.NoteFields = payload.NoteFields
+	response.Fields = maskHiddenFields(payload.Fields)
+	response.Strength = noteStrength(account, note.Name, payload.password())
+	response.Breach = m.noteBreachStatus(account, note.ID, payload.password())
+
+	return response
+}
 
-	bytesToAdd := padToMultipleOf - ogLength%padToMultipleOf
-	if bytesToAdd == 0 {
-		bytesToAdd = padToMultipleOf
+// noteTags returns the note's tags, or an empty list if it has none.
+func noteTags(note Note) []string {
+	if note.Tags == nil {
+		return []string{}
 	}
 
-	newBuf := make([]byte, ogLength+bytesToAdd)
+	return note.Tags
+}
 
-	copy(newBuf, original)
-	copy(newBuf[ogLength:], bytes.Repeat([]byte{uint8(bytesToAdd)}, bytesToAdd))
+// noteStrength estimates the strength of a note's password, treating the account's email and
+// name and the note's name as words an attacker would try. Returns nil if the note has no
+// password or the account is zero-knowledge.
+func noteStrength(account Account, name string, password string) *strength.Result {
+	if account.VaultMode == VaultModeZeroKnowledge || password == "" {
+		return nil
+	}
 
-	return newBuf
+	result := strength.Estimate(password, account.Email, account.Name, name)
+	return &result
 }
 
-// pkcs7UnPad implements removal of PKCS7 padding by checking the value of the
-// last byte and removing that many bytes from the end of the original buffer.
-func pkcs7UnPad(original []byte, blockSize int) ([]byte, error) {
-	ogLength := len(original)
-	if ogLength%blockSize != 0 {
-		return []byte{}, ErrDecryptFailed
+// checkPasswordPolicy returns ErrWeakPassword if the note's password scores below the account's
+// minimum password strength.
+func checkPasswordPolicy(account Account, name string, password string) error {
+	if account.MinPasswordStrength == 0 {
+		return nil
+	}
+
+	result := noteStrength(account, name, password)
+	if result == nil || result.Score >= account.MinPasswordStrength {
+		return nil
 	}
 
-	bytesToRemove := int(original[ogLength-1])
+	if result.Warning != "" {
+		return fmt.Errorf("%w: %s", ErrWeakPassword, result.Warning)
+	}
 
-	return original[:(ogLength - bytesToRemove)], nil
+	return ErrWeakPassword
 }
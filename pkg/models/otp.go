@@ -0,0 +1,145 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/oalexander6/passman/pkg/otp"
+)
+
+// otpCounterAttempts is how many times NoteOTP retries advancing an HOTP counter when the note
+// changes underneath it.
+const otpCounterAttempts = 3
+
+// OTPCodeResponse is the current one-time password for a login. SecondsLeft is set for TOTP
+// codes, and Counter is the counter an HOTP code was generated with.
+type OTPCodeResponse struct {
+	Code        string  `json:"code"`
+	Type        string  `json:"type"`
+	Period      int     `json:"period,omitempty"`
+	SecondsLeft int     `json:"seconds_left,omitempty"`
+	Counter     *uint64 `json:"counter,omitempty"`
+}
+
+// OTPScanResponse is the one-time password key read from a QR code. URI can be saved as a
+// login's TOTP field.
+type OTPScanResponse struct {
+	URI       string `json:"uri"`
+	Type      string `json:"type"`
+	Issuer    string `json:"issuer"`
+	Account   string `json:"account"`
+	Algorithm string `json:"algorithm"`
+	Digits    int    `json:"digits"`
+	Period    int    `json:"period,omitempty"`
+	Steam     bool   `json:"steam"`
+}
+
+// NoteOTP returns the current one-time password for the account's login with the provided ID.
+// Generating an HOTP code advances the counter stored with the login. Returns ErrNotFound if the
+// note doesn't exist or has no one-time password seed, and ErrZeroKnowledgeVault for
+// zero-knowledge accounts.
+func (m *Models) NoteOTP(ctx context.Context, accountID int64, noteID int64) (OTPCodeResponse, error) {
+	account, err := m.store.AccountGetByID(ctx, accountID)
+	if err != nil {
+		return OTPCodeResponse{}, err
+	}
+
+	if account.VaultMode == VaultModeZeroKnowledge {
+		return OTPCodeResponse{}, ErrZeroKnowledgeVault
+	}
+
+	dataKey, err := m.dataKeyForAccount(ctx, account)
+	if err != nil {
+		return OTPCodeResponse{}, err
+	}
+
+	for attempt := 1; ; attempt++ {
+		note, err := m.store.NoteGetByID(ctx, accountID, noteID)
+		if err != nil {
+			return OTPCodeResponse{}, err
+		}
+
+		plaintext, err := m.decryptNoteValue(dataKey, note, note.Value)
+		if err != nil {
+			return OTPCodeResponse{}, ErrDecryptFailed
+		}
+
+		payload, err := decodeNotePayload(note, plaintext)
+		if err != nil {
+			return OTPCodeResponse{}, err
+		}
+
+		if payload.Login == nil || payload.Login.TOTP == "" {
+			return OTPCodeResponse{}, fmt.Errorf("%w: the note has no one-time password seed", ErrNotFound)
+		}
+
+		key, err := otp.Parse(payload.Login.TOTP)
+		if err != nil {
+			return OTPCodeResponse{}, fmt.Errorf("%w: %s", ErrInvalidInput, err)
+		}
+
+		code := key.Generate(time.Now())
+
+		if key.Type == otp.TypeTOTP {
+			return OTPCodeResponse{Code: code.Code, Type: key.Type, Period: key.Period, SecondsLeft: code.SecondsLeft}, nil
+		}
+
+		// HOTP codes are used once, so the stored counter moves past the one just generated
+		counter := key.Counter
+		key.Counter++
+
+		login := *payload.Login
+		login.TOTP = key.URI()
+		payload.Login = &login
+
+		advanced, err := encodeNotePayload(payload)
+		if err != nil {
+			return OTPCodeResponse{}, err
+		}
+
+		encVal, err := encryptNoteValue(dataKey, note, advanced)
+		if err != nil {
+			return OTPCodeResponse{}, err
+		}
+
+		err = m.store.NoteUpdateValue(ctx, accountID, noteID, note.Value, encVal)
+		if errors.Is(err, ErrNotFound) && attempt < otpCounterAttempts {
+			continue
+		}
+		if err != nil {
+			return OTPCodeResponse{}, err
+		}
+
+		return OTPCodeResponse{Code: code.Code, Type: key.Type, Counter: &counter}, nil
+	}
+}
+
+// OTPScan reads the one-time password key from the QR code in a PNG image. Returns
+// ErrInvalidInput if the image has no QR code holding a key.
+func (m *Models) OTPScan(image io.Reader) (OTPScanResponse, error) {
+	key, text, err := otp.ScanPNG(image)
+	if errors.Is(err, otp.ErrNoQRCode) || errors.Is(err, otp.ErrInvalidKey) {
+		return OTPScanResponse{}, fmt.Errorf("%w: %s", ErrInvalidInput, err)
+	}
+	if err != nil {
+		return OTPScanResponse{}, err
+	}
+
+	response := OTPScanResponse{
+		URI:       text,
+		Type:      key.Type,
+		Issuer:    key.Issuer,
+		Account:   key.Account,
+		Algorithm: key.Algorithm,
+		Digits:    key.Digits,
+		Steam:     key.Steam,
+	}
+	if key.Type == otp.TypeTOTP {
+		response.Period = key.Period
+	}
+
+	return response, nil
+}
@@ -0,0 +1,205 @@
+package models
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/oalexander6/passman/pkg/strength"
+)
+
+const (
+	// weakPasswordScore is the highest strength score the health report treats as weak.
+	weakPasswordScore = 2
+
+	// Health score penalties for each problem with a note. A note's score can't go below 0.
+	breachedPenalty = 50
+	reusedPenalty   = 30
+	weakPenalty     = 30
+	oldPenalty      = 10
+
+	// reuseKeyInfo derives the key used to compare note values from the account's data key.
+	reuseKeyInfo = "passman:reuse"
+)
+
+// HealthReportRequest represents the options for the health report. Notes that haven't been
+// updated in MaxAgeDays are reported as old.
+type HealthReportRequest struct {
+	MaxAgeDays int `validate:"min=1,max=36500"`
+}
+
+// HealthReportNote identifies a note in the health report.
+type HealthReportNote struct {
+	ID   int64  `json:"id"`
+	Name string `json:"name"`
+}
+
+// HealthReportWeakNote is a note whose value scored weakPasswordScore or lower.
+type HealthReportWeakNote struct {
+	HealthReportNote
+	Score int `json:"score"`
+}
+
+// HealthReportOldNote is a note that hasn't been updated in the requested number of days.
+type HealthReportOldNote struct {
+	HealthReportNote
+	UpdatedAt time.Time `json:"updated_at"`
+	AgeDays   int       `json:"age_days"`
+}
+
+// HealthReportResponse summarizes the password hygiene of a vault. Score is 0-100, where a vault
+// with no problems scores 100. Reused lists groups of notes that share a password. Breached is nil
+// when no breach data is configured.
+type HealthReportResponse struct {
+	Score    int                    `json:"score"`
+	Total    int                    `json:"total"`
+	Reused   [][]HealthReportNote   `json:"reused"`
+	Weak     []HealthReportWeakNote `json:"weak"`
+	Old      []HealthReportOldNote  `json:"old"`
+	Breached []BreachReportNote     `json:"breached"`
+}
+
+// HealthReport reports the account's notes that share a password, are weak, are old, or appear in
+// the breached password data, along with an overall score. Values are compared by a hash keyed
+// with a secret derived from the account's data key, so the report never holds on to plaintext
+// to find duplicates. Returns ErrZeroKnowledgeVault for zero-knowledge accounts.
+func (m *Models) HealthReport(ctx context.Context, accountID int64, input HealthReportRequest) (HealthReportResponse, error) {
+	account, notes, err := m.reportNotes(ctx, accountID)
+	if err != nil {
+		return HealthReportResponse{}, err
+	}
+
+	dataKey, err := m.dataKeyForAccount(ctx, account)
+	if err != nil {
+		return HealthReportResponse{}, err
+	}
+
+	reuseKey := hmacSHA256(dataKey, []byte(reuseKeyInfo))
+	now := time.Now().UTC()
+	maxAge := time.Duration(input.MaxAgeDays) * 24 * time.Hour
+
+	report := HealthReportResponse{
+		Total:  len(notes),
+		Reused: [][]HealthReportNote{},
+		Weak:   []HealthReportWeakNote{},
+		Old:    []HealthReportOldNote{},
+	}
+	penalties := make(map[int64]int, len(notes))
+	groups := map[string][]HealthReportNote{}
+
+	for _, note := range notes {
+		ref := HealthReportNote{ID: note.ID, Name: note.Name}
+
+		valueHash := hex.EncodeToString(hmacSHA256(reuseKey, []byte(note.Value)))
+		groups[valueHash] = append(groups[valueHash], ref)
+
+		if result := strength.Estimate(note.Value, account.Email, account.Name, note.Name); result.Score <= weakPasswordScore {
+			report.Weak = append(report.Weak, HealthReportWeakNote{HealthReportNote: ref, Score: result.Score})
+			penalties[note.ID] += weakPenalty
+		}
+
+		if age := now.Sub(note.UpdatedAt); age > maxAge {
+			report.Old = append(report.Old, HealthReportOldNote{
+				HealthReportNote: ref,
+				UpdatedAt:        note.UpdatedAt,
+				AgeDays:          int(age.Hours() / 24),
+			})
+			penalties[note.ID] += oldPenalty
+		}
+	}
+
+	for _, group := range groups {
+		if len(group) < 2 {
+			continue
+		}
+
+		report.Reused = append(report.Reused, group)
+		for _, ref := range group {
+			penalties[ref.ID] += reusedPenalty
+		}
+	}
+	sort.Slice(report.Reused, func(i, j int) bool { return report.Reused[i][0].ID < report.Reused[j][0].ID })
+
+	if m.breaches != nil {
+		report.Breached, err = m.breachedNotes(notes)
+		if err != nil {
+			return HealthReportResponse{}, err
+		}
+
+		for _, breached := range report.Breached {
+			penalties[breached.ID] += breachedPenalty
+		}
+	}
+
+	report.Score = healthScore(len(notes), penalties)
+
+	return report, nil
+}
+
+// healthScore averages the score of every note, where each note starts at 100 and loses the
+// penalties for its problems.
+func healthScore(total int, penalties map[int64]int) int {
+	if total == 0 {
+		return 100
+	}
+
+	lost := 0
+	for _, penalty := range penalties {
+		lost += min(penalty, 100)
+	}
+
+	return int(math.Round(100 - float64(lost)/float64(total)))
+}
+
+// reportNotes returns the account along with every note it owns that has a password, with the
+// note's value replaced by the decrypted password. Reports need the plaintext passwords, so
+// zero-knowledge accounts get ErrZeroKnowledgeVault.
+func (m *Models) reportNotes(ctx context.Context, accountID int64) (Account, []Note, error) {
+	account, err := m.store.AccountGetByID(ctx, accountID)
+	if err != nil {
+		return Account{}, nil, err
+	}
+
+	if account.VaultMode == VaultModeZeroKnowledge {
+		return Account{}, nil, ErrZeroKnowledgeVault
+	}
+
+	notes, err := m.store.NoteGetByAccountID(ctx, accountID, NoteFilter{})
+	if err != nil {
+		return Account{}, nil, err
+	}
+
+	dataKey, err := m.dataKeyForAccount(ctx, account)
+	if err != nil {
+		return Account{}, nil, err
+	}
+
+	withPasswords := make([]Note, 0, len(notes))
+	for _, note := range notes {
+		plaintext, err := m.decryptNoteValue(dataKey, note, note.Value)
+		if err != nil {
+			return Account{}, nil, ErrDecryptFailed
+		}
+
+		payload, err := decodeNotePayload(note, plaintext)
+		if err != nil {
+			return Account{}, nil, err
+		}
+
+		if note.Value = payload.password(); note.Value != "" {
+			withPasswords = append(withPasswords, note)
+		}
+	}
+
+	return account, withPasswords, nil
+}
+
+func hmacSHA256(key []byte, data []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(data)
+	return mac.Sum(nil)
+}
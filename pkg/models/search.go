@@ -0,0 +1,368 @@
+package models
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/oalexander6/passman/pkg/logger"
+)
+
+const (
+	// DefaultSearchLimit is how many notes a page of search results has if no limit is given.
+	DefaultSearchLimit = 20
+	// MaxSearchLimit is the most notes a page of search results can have.
+	MaxSearchLimit = 100
+	// maxSearchTerms is the most terms a search can have.
+	maxSearchTerms = 10
+	// searchIndexBatchSize is how many notes are indexed between queries for more.
+	searchIndexBatchSize = 100
+)
+
+// NoteSearchFields are the blind indexes of a note's encrypted fields. Server vaults index the
+// username and URL hosts of a note, and zero-knowledge clients send the indexes of their notes
+// with them, since the server can't read them.
+type NoteSearchFields struct {
+	Indexes []BlindIndex
+}
+
+// NoteSearchRequest searches the account's notes. Every word of Query must start a word of a
+// note's name, tags or folder, or in server vaults be part of its username or one of its hosts.
+// Username and Host must equal a username or host of the note, and are only accepted from server
+// vaults. Blind terms are computed by zero-knowledge clients, which the server can't do for
+// them. Cursor continues from the NextCursor of a previous page of results.
+type NoteSearchRequest struct {
+	Query    string
+	Username string
+	Host     string
+	Blind    []BlindTerm
+	Fuzzy    bool
+	Limit    int
+	Cursor   string
+}
+
+// NoteSearchResponse is a page of notes matching a search, ordered by name. NextCursor is set
+// when there may be more results.
+type NoteSearchResponse struct {
+	Notes      []NoteGetResponse `json:"notes"`
+	NextCursor string            `json:"next_cursor,omitempty"`
+}
+
+// NoteSearchQuery is a search as run by the store, which matches the notes that match every one
+// of Terms. Results are ordered by name and then ID, and start after AfterName and AfterID when
+// AfterID is set.
+type NoteSearchQuery struct {
+	Terms     []NoteSearchTerm
+	Fuzzy     bool
+	AfterName string
+	AfterID   int64
+	Limit     int
+}
+
+// NoteSearchTerm matches the notes where Word is the start of a word in the note's name, tags or
+// folder, or that match any of Blind. With Fuzzy, Word may instead be close to a word, allowing
+// for typos. Word is empty for terms that only match blind indexes.
+type NoteSearchTerm struct {
+	Word  string
+	Blind []BlindTerm
+}
+
+// searchCursor is the position of the last note on a page of search results.
+type searchCursor struct {
+	Name string `json:"name"`
+	ID   int64  `json:"id"`
+}
+
+// Defines the required interface to implement note search. The index is kept up to date as
+// notes, tags and folders change, with every column stored as SearchText. The names of
+// zero-knowledge notes are encrypted, so they aren't indexed. The blind indexes of a note are set
+// by NoteCreate, NoteUpdate and NoteSearchIndex from Note.Search.
+type searchStore interface {
+	// NoteSearch returns up to query.Limit of the account's notes outside the trash that match
+	// the query.
+	NoteSearch(ctx context.Context, accountID int64, query NoteSearchQuery) ([]Note, error)
+	// NoteGetUnindexed returns up to limit notes of any account, including deleted notes, that
+	// are missing from the search index.
+	NoteGetUnindexed(ctx context.Context, limit int) ([]Note, error)
+	// NoteSearchIndex indexes the note with the provided fields, but only if its value still
+	// matches currentValue. Returns ErrNotFound if the note does not exist or its value has
+	// changed.
+	NoteSearchIndex(ctx context.Context, accountID int64, id int64, fields NoteSearchFields, currentValue string) error
+}
+
+// NoteSearch returns a page of the account's notes that match the search, with the value of
+// secure notes decrypted. Only the notes on the page are decrypted. Hidden custom fields are
+// masked unless reveal is set. Returns ErrInvalidInput if the search has no terms or too many,
+// blind terms are sent for a server vault, or the limit or cursor is invalid, and
+// ErrZeroKnowledgeVault if a zero-knowledge vault searches by username or host.
+func (m *Models) NoteSearch(ctx context.Context, accountID int64, input NoteSearchRequest, reveal bool) (NoteSearchResponse, error) {
+	if input.Limit < 1 || input.Limit > MaxSearchLimit {
+		return NoteSearchResponse{}, fmt.Errorf("%w: limit must be between 1 and %d", ErrInvalidInput, MaxSearchLimit)
+	}
+
+	account, err := m.store.AccountGetByID(ctx, accountID)
+	if err != nil {
+		return NoteSearchResponse{}, err
+	}
+
+	terms, err := m.searchTerms(ctx, account, input)
+	if err != nil {
+		return NoteSearchResponse{}, err
+	}
+
+	query := NoteSearchQuery{Terms: terms, Fuzzy: input.Fuzzy, Limit: input.Limit + 1}
+
+	if input.Cursor != "" {
+		cursor, err := decodeSearchCursor(input.Cursor)
+		if err != nil {
+			return NoteSearchResponse{}, err
+		}
+		query.AfterName = cursor.Name
+		query.AfterID = cursor.ID
+	}
+
+	notes, err := m.store.NoteSearch(ctx, accountID, query)
+	if err != nil {
+		return NoteSearchResponse{}, err
+	}
+
+	response := NoteSearchResponse{}
+	if len(notes) > input.Limit {
+		notes = notes[:input.Limit]
+		response.NextCursor = encodeSearchCursor(searchCursor{Name: notes[len(notes)-1].Name, ID: notes[len(notes)-1].ID})
+	}
+
+	dataKey, err := m.dataKeyForAccount(ctx, account)
+	if err != nil {
+		return NoteSearchResponse{}, err
+	}
+
+	response.Notes = make([]NoteGetResponse, len(notes))
+	for i := range notes {
+		decryptedVal, err := m.decryptNoteValue(dataKey, notes[i], notes[i].Value)
+		if err != nil {
+			return NoteSearchResponse{}, ErrDecryptFailed
+		}
+
+		response.Notes[i], err = noteResponse(account, notes[i], decryptedVal, reveal)
+		if err != nil {
+			return NoteSearchResponse{}, err
+		}
+	}
+
+	return response, nil
+}
+
+// searchTerms returns the terms of a search. In server vaults the words of the query also match
+// usernames and hosts, through blind indexes hashed with the account's index key.
+func (m *Models) searchTerms(ctx context.Context, account Account, input NoteSearchRequest) ([]NoteSearchTerm, error) {
+	if account.VaultMode == VaultModeZeroKnowledge && (input.Username != "" || input.Host != "") {
+		return nil, fmt.Errorf("%w: search usernames and hosts by blind index instead", ErrZeroKnowledgeVault)
+	}
+	if account.VaultMode != VaultModeZeroKnowledge && len(input.Blind) > 0 {
+		return nil, fmt.Errorf("%w: only zero-knowledge vaults can search by blind index", ErrInvalidInput)
+	}
+
+	words := searchWords(input.Query)
+
+	var indexKey []byte
+	if account.VaultMode != VaultModeZeroKnowledge && (len(words) > 0 || input.Username != "" || input.Host != "") {
+		var err error
+		indexKey, err = m.indexKeyForAccount(ctx, account)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	terms := []NoteSearchTerm{}
+	for _, word := range words {
+		term := NoteSearchTerm{Word: word}
+		if indexKey != nil {
+			term.Blind = blindTerms(indexKey, word)
+		}
+		terms = append(terms, term)
+	}
+
+	if input.Username != "" {
+		terms = append(terms, NoteSearchTerm{Blind: []BlindTerm{NewBlindExactTerm(indexKey, BlindFieldUsername, input.Username)}})
+	}
+	if input.Host != "" {
+		terms = append(terms, NoteSearchTerm{Blind: []BlindTerm{NewBlindExactTerm(indexKey, BlindFieldHost, input.Host)}})
+	}
+
+	for _, blind := range input.Blind {
+		// the store counts the n-grams a note has, so they must be distinct
+		blind.Ngrams = slices.Clone(blind.Ngrams)
+		slices.Sort(blind.Ngrams)
+		blind.Ngrams = slices.Compact(blind.Ngrams)
+		terms = append(terms, NoteSearchTerm{Blind: []BlindTerm{blind}})
+	}
+
+	if len(terms) == 0 {
+		return nil, fmt.Errorf("%w: the search must have a query with at least one letter or digit, or a blind index term", ErrInvalidInput)
+	}
+	if len(terms) > maxSearchTerms {
+		return nil, fmt.Errorf("%w: the search can have at most %d terms", ErrInvalidInput, maxSearchTerms)
+	}
+
+	return terms, nil
+}
+
+// IndexNotes adds every note that is missing from the search index, such as notes saved before
+// search was added. Notes that can't be decrypted are indexed without their blind indexes, and
+// zero-knowledge notes are indexed without them until their client saves them again.
+func (m *Models) IndexNotes(ctx context.Context) (int, error) {
+	indexed := 0
+	accounts := make(map[int64]Account)
+	indexKeys := make(map[int64][]byte)
+
+	for {
+		notes, err := m.store.NoteGetUnindexed(ctx, searchIndexBatchSize)
+		if err != nil {
+			return indexed, err
+		}
+		if len(notes) == 0 {
+			return indexed, nil
+		}
+
+		for _, note := range notes {
+			account, ok := accounts[note.AccountID]
+			if !ok {
+				account, err = m.store.AccountGetByID(ctx, note.AccountID)
+				if err != nil && !errors.Is(err, ErrNotFound) {
+					return indexed, err
+				}
+				accounts[note.AccountID] = account
+
+				if account.ID != 0 && account.VaultMode != VaultModeZeroKnowledge {
+					indexKeys[note.AccountID], err = m.indexKeyForAccount(ctx, account)
+					if err != nil {
+						return indexed, err
+					}
+				}
+			}
+
+			fields, err := m.storedNoteSearchFields(ctx, account, indexKeys[note.AccountID], note)
+			if err != nil {
+				return indexed, err
+			}
+
+			err = m.store.NoteSearchIndex(ctx, note.AccountID, note.ID, fields, note.Value)
+			if errors.Is(err, ErrNotFound) {
+				// the note was updated, which indexed it
+				continue
+			}
+			if err != nil {
+				return indexed, err
+			}
+			indexed++
+		}
+	}
+}
+
+// RunSearchIndex calls IndexNotes once, logging the outcome.
+func (m *Models) RunSearchIndex(ctx context.Context) {
+	indexed, err := m.IndexNotes(ctx)
+	if err != nil {
+		logger.Log.Error().Msgf("Failed to index notes for search: %s", err)
+	}
+	if indexed > 0 {
+		logger.Log.Info().Msgf("Indexed %d notes for search", indexed)
+	}
+}
+
+// noteSearchFields returns the fields to index a note being saved by: the blind indexes of its
+// payload hashed with indexKey in server vaults, or the ones its client sent in zero-knowledge
+// vaults.
+func noteSearchFields(account Account, indexKey []byte, input NoteCreateRequest, payload notePayload) NoteSearchFields {
+	if account.VaultMode == VaultModeZeroKnowledge {
+		return NoteSearchFields{Indexes: input.SearchIndex}
+	}
+
+	return NoteSearchFields{Indexes: payload.blindIndexes(indexKey)}
+}
+
+// storedNoteSearchFields decrypts a stored note to index it. Notes of deleted accounts,
+// zero-knowledge notes and notes that can't be decrypted have no blind indexes.
+func (m *Models) storedNoteSearchFields(ctx context.Context, account Account, indexKey []byte, note Note) (NoteSearchFields, error) {
+	if account.ID == 0 || account.VaultMode == VaultModeZeroKnowledge {
+		return NoteSearchFields{}, nil
+	}
+
+	dataKey, err := m.dataKeyForAccount(ctx, account)
+	if err != nil {
+		return NoteSearchFields{}, err
+	}
+
+	plaintext, err := m.decryptNoteValue(dataKey, note, note.Value)
+	if err != nil {
+		logger.Log.Warn().Msgf("Indexing note %d without its blind indexes, since it could not be decrypted", note.ID)
+		return NoteSearchFields{}, nil
+	}
+
+	return plaintextSearchFields(account, indexKey, note, plaintext), nil
+}
+
+// plaintextSearchFields returns the fields to index a note by from its decrypted value. The
+// server can't index zero-knowledge notes, so they have none.
+func plaintextSearchFields(account Account, indexKey []byte, note Note, plaintext string) NoteSearchFields {
+	if account.VaultMode == VaultModeZeroKnowledge {
+		return NoteSearchFields{}
+	}
+
+	payload, err := decodeNotePayload(note, plaintext)
+	if err != nil {
+		return NoteSearchFields{}
+	}
+
+	return NoteSearchFields{Indexes: payload.blindIndexes(indexKey)}
+}
+
+// searchIndexKey returns the key the account's notes are blind indexed with, or nil for
+// zero-knowledge accounts, whose clients index their notes with a key of their own.
+func (m *Models) searchIndexKey(ctx context.Context, account Account) ([]byte, error) {
+	if account.VaultMode == VaultModeZeroKnowledge {
+		return nil, nil
+	}
+
+	return m.indexKeyForAccount(ctx, account)
+}
+
+// SearchText returns the values as lowercase words separated by spaces, which is how every
+// column of the search index is stored.
+func SearchText(values ...string) string {
+	words := []string{}
+	for _, value := range values {
+		words = append(words, searchWords(value)...)
+	}
+
+	return strings.Join(words, " ")
+}
+
+// searchWords splits the value into lowercase words of letters and digits.
+func searchWords(value string) []string {
+	return strings.FieldsFunc(strings.ToLower(value), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+func encodeSearchCursor(cursor searchCursor) string {
+	data, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeSearchCursor(value string) (searchCursor, error) {
+	var cursor searchCursor
+
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil || json.Unmarshal(data, &cursor) != nil || cursor.ID <= 0 {
+		return searchCursor{}, fmt.Errorf("%w: invalid cursor", ErrInvalidInput)
+	}
+
+	return cursor, nil
+}
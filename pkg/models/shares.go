@@ -0,0 +1,603 @@
+package models
+
+import (
+	"context"
+	"crypto/ecdh"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/oalexander6/passman/pkg/keys"
+)
+
+// Share permissions. Recipients of read shares can only read the note, and recipients of write
+// shares can also update its name, type and fields.
+const (
+	SharePermissionRead  = "read"
+	SharePermissionWrite = "write"
+)
+
+// itemKeyAttempts is how many times sharing reads a note again when its value changes while it
+// is being given an item key.
+const itemKeyAttempts = 3
+
+// NoteShare gives the recipient access to one of the owner's notes. EncryptedKey is the note's
+// item key encrypted to the recipient's public key. OwnerEmail and RecipientEmail are read from
+// the accounts when shares are loaded.
+type NoteShare struct {
+	ID             int64     `db:"id"`
+	NoteID         int64     `db:"note_id"`
+	OwnerID        int64     `db:"owner_id"`
+	RecipientID    int64     `db:"recipient_id"`
+	Permission     string    `db:"permission"`
+	EncryptedKey   string    `db:"encrypted_key"`
+	OwnerEmail     string    `db:"owner_email"`
+	RecipientEmail string    `db:"recipient_email"`
+	CreatedAt      time.Time `db:"created_at"`
+	UpdatedAt      time.Time `db:"updated_at"`
+}
+
+// NoteShareRequest shares a note with the account with the provided email.
+type NoteShareRequest struct {
+	Email      string `json:"email" validate:"required,email"`
+	Permission string `json:"permission" validate:"required,oneof=read write"`
+}
+
+// NoteSharePermissionRequest changes the permission of a share.
+type NoteSharePermissionRequest struct {
+	Permission string `json:"permission" validate:"required,oneof=read write"`
+}
+
+// NoteShareResponse describes a share of one of the account's notes.
+type NoteShareResponse struct {
+	ID             int64     `json:"id"`
+	NoteID         int64     `json:"note_id"`
+	RecipientID    int64     `json:"recipient_id"`
+	RecipientEmail string    `json:"recipient_email"`
+	Permission     string    `json:"permission"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// NoteShareRevokeResponse is the result of revoking a share. Note is the note with its rotated
+// value when the revoke rotated it.
+type NoteShareRevokeResponse struct {
+	Rotated bool             `json:"rotated"`
+	Note    *NoteGetResponse `json:"note,omitempty"`
+}
+
+// SharedNoteResponse is a note shared with the account, decrypted. The note's folder and tags
+// belong to its owner, so they are left out.
+type SharedNoteResponse struct {
+	ShareID    int64           `json:"share_id"`
+	OwnerID    int64           `json:"owner_id"`
+	OwnerEmail string          `json:"owner_email"`
+	Permission string          `json:"permission"`
+	Note       NoteGetResponse `json:"note"`
+}
+
+// Defines the required interface to implement note sharing storage. Shares are looked up either
+// through the owner of the note or through the recipient.
+type shareStore interface {
+	// AccountSetKeyPair sets the account's key pair, but only if it doesn't have one yet.
+	// Returns ErrNotFound if the account does not exist or already has a key pair.
+	AccountSetKeyPair(ctx context.Context, id int64, publicKey string, privateKey string) error
+	// NoteSetItemKey gives a note without an item key one, replacing its value with newValue,
+	// but only if the value still matches currentValue. Returns ErrNotFound if the note does not
+	// exist, already has an item key or its value has changed.
+	NoteSetItemKey(ctx context.Context, accountID int64, id int64, currentValue string, newValue string, itemKey string) error
+	// NoteShareCreate saves the share, but only if the note isn't deleted and its item key still
+	// matches itemKey. Returns ErrNotFound otherwise, and ErrAlreadyExists if the note is already
+	// shared with the recipient.
+	NoteShareCreate(ctx context.Context, share NoteShare, itemKey string) (NoteShare, error)
+	// NoteShareGetByNoteID returns the shares of the owner's note, oldest first.
+	NoteShareGetByNoteID(ctx context.Context, ownerID int64, noteID int64) ([]NoteShare, error)
+	NoteShareGetByID(ctx context.Context, ownerID int64, noteID int64, id int64) (NoteShare, error)
+	NoteShareUpdatePermission(ctx context.Context, ownerID int64, noteID int64, id int64, permission string) error
+	NoteShareDelete(ctx context.Context, ownerID int64, noteID int64, id int64) error
+	// NoteShareGetByRecipientID returns the shares with the recipient of notes that aren't
+	// deleted, oldest first.
+	NoteShareGetByRecipientID(ctx context.Context, recipientID int64) ([]NoteShare, error)
+	// NoteShareGetForRecipient returns the recipient's share of the note, or ErrNotFound if it
+	// has none or the note is deleted.
+	NoteShareGetForRecipient(ctx context.Context, recipientID int64, noteID int64) (NoteShare, error)
+	// NoteShareRotate deletes a share of the owner's note and, in the same transaction, replaces
+	// the note's value and item key with those of note, as NoteUpdate does, but only if its value
+	// still matches currentValue. The remaining shares get the encrypted keys in shareKeys, by
+	// share ID. Returns ErrNotFound if the share doesn't exist, the value has changed, or
+	// shareKeys doesn't hold exactly the remaining shares.
+	NoteShareRotate(ctx context.Context, ownerID int64, id int64, note Note, currentValue string, keepVersions int,
+		shareKeys map[int64]string) (Note, error)
+}
+
+// NoteShareCreate shares the account's note with the account with the provided email. The first
+// time a note is shared its value is re-encrypted with an item key of its own, and the item key is
+// encrypted to the recipient's public key. Returns ErrInvalidInput when sharing with the owner,
+// ErrZeroKnowledgeVault if either account is a zero-knowledge vault, ErrNotFound if there is no
+// such note or recipient, and ErrAlreadyExists if the note is already shared with the recipient.
+func (m *Models) NoteShareCreate(ctx context.Context, accountID int64, noteID int64, input NoteShareRequest) (NoteShareResponse, error) {
+	owner, err := m.shareOwner(ctx, accountID)
+	if err != nil {
+		return NoteShareResponse{}, err
+	}
+
+	recipient, err := m.store.AccountGetByEmail(ctx, input.Email)
+	if err != nil {
+		return NoteShareResponse{}, err
+	}
+
+	if recipient.ID == owner.ID {
+		return NoteShareResponse{}, fmt.Errorf("%w: notes can't be shared with their owner", ErrInvalidInput)
+	}
+
+	if recipient.VaultMode == VaultModeZeroKnowledge {
+		return NoteShareResponse{}, fmt.Errorf("%w: notes can't be shared with zero-knowledge vaults", ErrZeroKnowledgeVault)
+	}
+
+	note, itemKey, err := m.noteItemKey(ctx, owner, noteID)
+	if err != nil {
+		return NoteShareResponse{}, err
+	}
+
+	encryptedKey, err := m.sealItemKeyTo(ctx, recipient, noteID, itemKey)
+	if err != nil {
+		return NoteShareResponse{}, err
+	}
+
+	share, err := m.store.NoteShareCreate(ctx, NoteShare{
+		NoteID:       noteID,
+		OwnerID:      owner.ID,
+		RecipientID:  recipient.ID,
+		Permission:   input.Permission,
+		EncryptedKey: encryptedKey,
+	}, note.ItemKey)
+	if err != nil {
+		return NoteShareResponse{}, err
+	}
+
+	return noteShareResponse(share), nil
+}
+
+// NoteShareGetByNoteID lists the shares of the account's note, oldest first.
+func (m *Models) NoteShareGetByNoteID(ctx context.Context, accountID int64, noteID int64) ([]NoteShareResponse, error) {
+	if _, err := m.store.NoteGetByID(ctx, accountID, noteID); err != nil {
+		return []NoteShareResponse{}, err
+	}
+
+	shares, err := m.store.NoteShareGetByNoteID(ctx, accountID, noteID)
+	if err != nil {
+		return []NoteShareResponse{}, err
+	}
+
+	responses := make([]NoteShareResponse, len(shares))
+	for i, share := range shares {
+		responses[i] = noteShareResponse(share)
+	}
+
+	return responses, nil
+}
+
+// NoteShareUpdate changes the permission of a share of the account's note.
+func (m *Models) NoteShareUpdate(ctx context.Context, accountID int64, noteID int64, shareID int64, input NoteSharePermissionRequest) (NoteShareResponse, error) {
+	if err := m.store.NoteShareUpdatePermission(ctx, accountID, noteID, shareID, input.Permission); err != nil {
+		return NoteShareResponse{}, err
+	}
+
+	share, err := m.store.NoteShareGetByID(ctx, accountID, noteID, shareID)
+	if err != nil {
+		return NoteShareResponse{}, err
+	}
+
+	return noteShareResponse(share), nil
+}
+
+// NoteShareRevoke removes a share of the account's note. The recipient may have kept the item key
+// and anything they read, so with rotate the note is also given a new item key, which is
+// encrypted again to the remaining recipients, and a login's password is replaced with a newly
+// generated one. The note as it was is kept as a version, and the new password must still be set
+// on the site it is for.
+func (m *Models) NoteShareRevoke(ctx context.Context, accountID int64, noteID int64, shareID int64, rotate bool) (NoteShareRevokeResponse, error) {
+	if !rotate {
+		return NoteShareRevokeResponse{}, m.store.NoteShareDelete(ctx, accountID, noteID, shareID)
+	}
+
+	owner, err := m.shareOwner(ctx, accountID)
+	if err != nil {
+		return NoteShareRevokeResponse{}, err
+	}
+
+	if _, err := m.store.NoteShareGetByID(ctx, accountID, noteID, shareID); err != nil {
+		return NoteShareRevokeResponse{}, err
+	}
+
+	current, err := m.store.NoteGetByID(ctx, accountID, noteID)
+	if err != nil {
+		return NoteShareRevokeResponse{}, err
+	}
+
+	dataKey, err := m.dataKeyForAccount(ctx, owner)
+	if err != nil {
+		return NoteShareRevokeResponse{}, err
+	}
+
+	plaintext, err := m.decryptNoteValue(dataKey, current, current.Value)
+	if err != nil {
+		return NoteShareRevokeResponse{}, ErrDecryptFailed
+	}
+
+	payload, err := decodeNotePayload(current, plaintext)
+	if err != nil {
+		return NoteShareRevokeResponse{}, err
+	}
+
+	if payload.Login != nil && payload.Login.Password != "" {
+		generated, err := generatePassword(PasswordOptions{})
+		if err != nil {
+			return NoteShareRevokeResponse{}, err
+		}
+
+		login := *payload.Login
+		login.Password = generated.Value
+		payload.Login = &login
+
+		if plaintext, err = encodeNotePayload(payload); err != nil {
+			return NoteShareRevokeResponse{}, err
+		}
+	}
+
+	itemKey, err := keys.NewDataKey()
+	if err != nil {
+		return NoteShareRevokeResponse{}, ErrEncryptFailed
+	}
+
+	rotated := current
+	rotated.UpdatedBy = accountID
+	rotated.ItemKey, err = sealItemKey(dataKey, noteID, accountID, itemKey)
+	if err != nil {
+		return NoteShareRevokeResponse{}, err
+	}
+
+	rotated.Value, err = encryptNoteValue(dataKey, rotated, plaintext)
+	if err != nil {
+		return NoteShareRevokeResponse{}, err
+	}
+
+	shares, err := m.store.NoteShareGetByNoteID(ctx, accountID, noteID)
+	if err != nil {
+		return NoteShareRevokeResponse{}, err
+	}
+
+	shareKeys := map[int64]string{}
+	for _, share := range shares {
+		if share.ID == shareID {
+			continue
+		}
+
+		recipient, err := m.store.AccountGetByID(ctx, share.RecipientID)
+		if err != nil {
+			return NoteShareRevokeResponse{}, err
+		}
+
+		if shareKeys[share.ID], err = m.sealItemKeyTo(ctx, recipient, noteID, itemKey); err != nil {
+			return NoteShareRevokeResponse{}, err
+		}
+	}
+
+	saved, err := m.store.NoteShareRotate(ctx, accountID, shareID, rotated, current.Value, owner.MaxNoteVersions, shareKeys)
+	if err != nil {
+		return NoteShareRevokeResponse{}, err
+	}
+
+	note := m.savedNoteResponse(owner, saved, plaintext, payload)
+
+	return NoteShareRevokeResponse{Rotated: true, Note: &note}, nil
+}
+
+// SharedNoteGetByRecipientID lists the notes shared with the account, decrypted with the item keys
+// encrypted to it. Hidden custom fields are masked unless reveal is set.
+func (m *Models) SharedNoteGetByRecipientID(ctx context.Context, accountID int64, reveal bool) ([]SharedNoteResponse, error) {
+	shares, err := m.store.NoteShareGetByRecipientID(ctx, accountID)
+	if err != nil {
+		return []SharedNoteResponse{}, err
+	}
+
+	if len(shares) == 0 {
+		return []SharedNoteResponse{}, nil
+	}
+
+	recipient, err := m.store.AccountGetByID(ctx, accountID)
+	if err != nil {
+		return []SharedNoteResponse{}, err
+	}
+
+	_, privateKey, err := m.keyPairForAccount(ctx, recipient)
+	if err != nil {
+		return []SharedNoteResponse{}, err
+	}
+
+	responses := make([]SharedNoteResponse, len(shares))
+	for i, share := range shares {
+		responses[i], err = m.openSharedNote(ctx, privateKey, share, reveal)
+		if err != nil {
+			return []SharedNoteResponse{}, err
+		}
+	}
+
+	return responses, nil
+}
+
+// SharedNoteGetByID returns a note shared with the account, decrypted with the item key encrypted
+// to it. Hidden custom fields are masked unless reveal is set.
+func (m *Models) SharedNoteGetByID(ctx context.Context, accountID int64, noteID int64, reveal bool) (SharedNoteResponse, error) {
+	share, err := m.store.NoteShareGetForRecipient(ctx, accountID, noteID)
+	if err != nil {
+		return SharedNoteResponse{}, err
+	}
+
+	recipient, err := m.store.AccountGetByID(ctx, accountID)
+	if err != nil {
+		return SharedNoteResponse{}, err
+	}
+
+	_, privateKey, err := m.keyPairForAccount(ctx, recipient)
+	if err != nil {
+		return SharedNoteResponse{}, err
+	}
+
+	return m.openSharedNote(ctx, privateKey, share, reveal)
+}
+
+// SharedNoteUpdate replaces the name, type and fields of a note shared with the account with write
+// permission, as NoteUpdate does for the owner. The owner's password policy applies, the note
+// keeps the owner's folder and tags, and the version saved is kept for the owner. Returns
+// ErrReadOnlyShare if the share is read-only.
+func (m *Models) SharedNoteUpdate(ctx context.Context, accountID int64, noteID int64, input NoteCreateRequest) (SharedNoteResponse, error) {
+	share, err := m.store.NoteShareGetForRecipient(ctx, accountID, noteID)
+	if err != nil {
+		return SharedNoteResponse{}, err
+	}
+
+	if share.Permission != SharePermissionWrite {
+		return SharedNoteResponse{}, ErrReadOnlyShare
+	}
+
+	if input.FolderID != nil || len(input.Tags) > 0 {
+		return SharedNoteResponse{}, fmt.Errorf("%w: the folder and tags of a shared note belong to its owner", ErrInvalidInput)
+	}
+
+	owner, err := m.store.AccountGetByID(ctx, share.OwnerID)
+	if err != nil {
+		return SharedNoteResponse{}, err
+	}
+
+	current, err := m.store.NoteGetByID(ctx, share.OwnerID, noteID)
+	if err != nil {
+		return SharedNoteResponse{}, err
+	}
+
+	input.FolderID = current.FolderID
+
+	note, err := m.updateNote(ctx, owner, current, accountID, input, current.Tags)
+	if err != nil {
+		return SharedNoteResponse{}, err
+	}
+
+	return sharedNoteResponse(share, note), nil
+}
+
+// shareOwner returns the account sharing a note, which must be a server vault since the server
+// can't read the notes of zero-knowledge vaults to encrypt them for anyone else.
+func (m *Models) shareOwner(ctx context.Context, accountID int64) (Account, error) {
+	account, err := m.store.AccountGetByID(ctx, accountID)
+	if err != nil {
+		return Account{}, err
+	}
+
+	if account.VaultMode == VaultModeZeroKnowledge {
+		return Account{}, ErrZeroKnowledgeVault
+	}
+
+	return account, nil
+}
+
+// noteItemKey returns the owner's note along with its plaintext item key. A note that has never
+// been shared is given an item key, and its value is encrypted again with it.
+func (m *Models) noteItemKey(ctx context.Context, owner Account, noteID int64) (Note, []byte, error) {
+	dataKey, err := m.dataKeyForAccount(ctx, owner)
+	if err != nil {
+		return Note{}, nil, err
+	}
+
+	for attempt := 1; ; attempt++ {
+		note, err := m.store.NoteGetByID(ctx, owner.ID, noteID)
+		if err != nil {
+			return Note{}, nil, err
+		}
+
+		if note.ItemKey != "" {
+			itemKey, err := openItemKey(dataKey, note.ID, note.AccountID, note.ItemKey)
+			if err != nil {
+				return Note{}, nil, ErrDecryptFailed
+			}
+
+			return note, itemKey, nil
+		}
+
+		plaintext, err := m.decryptNoteValue(dataKey, note, note.Value)
+		if err != nil {
+			return Note{}, nil, ErrDecryptFailed
+		}
+
+		itemKey, err := keys.NewDataKey()
+		if err != nil {
+			return Note{}, nil, ErrEncryptFailed
+		}
+
+		keyed := note
+		keyed.ItemKey, err = sealItemKey(dataKey, note.ID, note.AccountID, itemKey)
+		if err != nil {
+			return Note{}, nil, err
+		}
+
+		keyed.Value, err = encryptNoteValue(dataKey, keyed, plaintext)
+		if err != nil {
+			return Note{}, nil, err
+		}
+
+		// the note may have been updated or shared since it was read
+		err = m.store.NoteSetItemKey(ctx, owner.ID, noteID, note.Value, keyed.Value, keyed.ItemKey)
+		if errors.Is(err, ErrNotFound) && attempt < itemKeyAttempts {
+			continue
+		}
+		if err != nil {
+			return Note{}, nil, err
+		}
+
+		return keyed, itemKey, nil
+	}
+}
+
+// openSharedNote decrypts a note shared with the holder of privateKey.
+func (m *Models) openSharedNote(ctx context.Context, privateKey []byte, share NoteShare, reveal bool) (SharedNoteResponse, error) {
+	itemKey, err := openSharedItemKey(privateKey, share)
+	if err != nil {
+		return SharedNoteResponse{}, err
+	}
+
+	owner, err := m.store.AccountGetByID(ctx, share.OwnerID)
+	if err != nil {
+		return SharedNoteResponse{}, err
+	}
+
+	note, err := m.store.NoteGetByID(ctx, share.OwnerID, share.NoteID)
+	if err != nil {
+		return SharedNoteResponse{}, err
+	}
+
+	if !isCurrentNoteCiphertext(note.Value) {
+		return SharedNoteResponse{}, ErrDecryptFailed
+	}
+
+	plaintext, err := openNoteValue(itemKey, note, strings.TrimPrefix(note.Value, ciphertextPrefixDataKey))
+	if err != nil {
+		return SharedNoteResponse{}, ErrDecryptFailed
+	}
+
+	response, err := noteResponse(owner, note, plaintext, reveal)
+	if err != nil {
+		return SharedNoteResponse{}, err
+	}
+
+	return sharedNoteResponse(share, response), nil
+}
+
+// keyPairForAccount returns the account's X25519 public and private keys, creating them the first
+// time they are needed.
+func (m *Models) keyPairForAccount(ctx context.Context, account Account) ([]byte, []byte, error) {
+	dataKey, err := m.dataKeyForAccount(ctx, account)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if account.PublicKey == "" {
+		privateKey, err := ecdh.X25519().GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, nil, ErrEncryptFailed
+		}
+
+		sealed, err := sealGCM(dataKey, privateKey.Bytes(), privateKeyAssociatedData(account.ID))
+		if err != nil {
+			return nil, nil, err
+		}
+
+		account.PublicKey = base64.StdEncoding.EncodeToString(privateKey.PublicKey().Bytes())
+		account.PrivateKey = base64.StdEncoding.EncodeToString(sealed)
+		account, err = m.storeAccountKey(ctx, account, func(a Account) string { return a.PublicKey },
+			func(ctx context.Context, a Account) error {
+				return m.store.AccountSetKeyPair(ctx, a.ID, a.PublicKey, a.PrivateKey)
+			})
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	publicKey, err := base64.StdEncoding.DecodeString(account.PublicKey)
+	if err != nil {
+		return nil, nil, ErrDecryptFailed
+	}
+
+	sealed, err := base64.StdEncoding.DecodeString(account.PrivateKey)
+	if err != nil {
+		return nil, nil, ErrDecryptFailed
+	}
+
+	privateKey, err := openGCM(dataKey, sealed, privateKeyAssociatedData(account.ID))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return publicKey, privateKey, nil
+}
+
+// sealItemKeyTo encrypts a note's item key to the recipient's public key.
+func (m *Models) sealItemKeyTo(ctx context.Context, recipient Account, noteID int64, itemKey []byte) (string, error) {
+	publicKey, _, err := m.keyPairForAccount(ctx, recipient)
+	if err != nil {
+		return "", err
+	}
+
+	sealed, err := sealToPublicKey(publicKey, itemKey, shareAssociatedData(noteID, recipient.ID))
+	if err != nil {
+		return "", err
+	}
+
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// openSharedItemKey decrypts the item key of a share with the recipient's private key.
+func openSharedItemKey(privateKey []byte, share NoteShare) ([]byte, error) {
+	sealed, err := base64.StdEncoding.DecodeString(share.EncryptedKey)
+	if err != nil {
+		return nil, ErrDecryptFailed
+	}
+
+	return openWithPrivateKey(privateKey, sealed, shareAssociatedData(share.NoteID, share.RecipientID))
+}
+
+// privateKeyAssociatedData binds an encrypted private key to its account.
+func privateKeyAssociatedData(accountID int64) []byte {
+	return []byte(fmt.Sprintf("passman:private-key:account:%d", accountID))
+}
+
+// shareAssociatedData binds an item key encrypted to a recipient to the note and recipient.
+func shareAssociatedData(noteID int64, recipientID int64) []byte {
+	return []byte(fmt.Sprintf("passman:share:note:%d:recipient:%d", noteID, recipientID))
+}
+
+func noteShareResponse(share NoteShare) NoteShareResponse {
+	return NoteShareResponse{
+		ID:             share.ID,
+		NoteID:         share.NoteID,
+		RecipientID:    share.RecipientID,
+		RecipientEmail: share.RecipientEmail,
+		Permission:     share.Permission,
+		CreatedAt:      share.CreatedAt,
+	}
+}
+
+// sharedNoteResponse builds the recipient's view of a shared note, without the owner's folder and
+// tags.
+func sharedNoteResponse(share NoteShare, note NoteGetResponse) SharedNoteResponse {
+	note.FolderID = nil
+	note.Tags = []string{}
+
+	return SharedNoteResponse{
+		ShareID:    share.ID,
+		OwnerID:    share.OwnerID,
+		OwnerEmail: share.OwnerEmail,
+		Permission: share.Permission,
+		Note:       note,
+	}
+}
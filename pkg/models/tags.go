@@ -0,0 +1,135 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// maxTagLength is the longest tag name accepted, in bytes.
+const maxTagLength = 64
+
+// Tag is a free-form label on notes. Tags are created when they are first added to a note, and
+// Notes counts the notes outside the trash that have it.
+type Tag struct {
+	ID        int64     `db:"id"`
+	AccountID int64     `db:"account_id"`
+	Name      string    `db:"name"`
+	Notes     int       `db:"notes"`
+	CreatedAt time.Time `db:"created_at"`
+}
+
+// TagRequest creates or renames a tag.
+type TagRequest struct {
+	Name string `json:"name" validate:"required,max=64"`
+}
+
+// TagResponse describes a tag along with how many notes have it.
+type TagResponse struct {
+	ID    int64  `json:"id"`
+	Name  string `json:"name"`
+	Notes int    `json:"notes"`
+}
+
+// Defines the required interface to implement tag storage. Every method is scoped to the owning
+// account, and tag names must be unique, which is reported as ErrAlreadyExists. Tags are added
+// to notes by setting Note.Tags when notes are saved.
+type tagStore interface {
+	TagCreate(ctx context.Context, tag Tag) (Tag, error)
+	// TagGetByAccountID returns the account's tags ordered by name.
+	TagGetByAccountID(ctx context.Context, accountID int64) ([]Tag, error)
+	TagUpdate(ctx context.Context, accountID int64, id int64, name string) (Tag, error)
+	// TagDelete deletes the tag, removing it from every note in the same transaction.
+	TagDelete(ctx context.Context, accountID int64, id int64) error
+}
+
+// TagGetByAccountID lists the account's tags, ordered by name.
+func (m *Models) TagGetByAccountID(ctx context.Context, accountID int64) ([]TagResponse, error) {
+	tags, err := m.store.TagGetByAccountID(ctx, accountID)
+	if err != nil {
+		return []TagResponse{}, err
+	}
+
+	responses := make([]TagResponse, len(tags))
+	for i, tag := range tags {
+		responses[i] = tagResponse(tag)
+	}
+
+	return responses, nil
+}
+
+// TagCreate creates a tag for the account without adding it to any notes. Returns
+// ErrAlreadyExists if the account already has a tag with the name.
+func (m *Models) TagCreate(ctx context.Context, accountID int64, input TagRequest) (TagResponse, error) {
+	name, err := normalizeTag(input.Name)
+	if err != nil {
+		return TagResponse{}, err
+	}
+
+	tag, err := m.store.TagCreate(ctx, Tag{AccountID: accountID, Name: name})
+	if err != nil {
+		return TagResponse{}, err
+	}
+
+	return tagResponse(tag), nil
+}
+
+// TagUpdate renames the account's tag with the provided ID on every note that has it. Returns
+// ErrAlreadyExists if the account already has a tag with the new name.
+func (m *Models) TagUpdate(ctx context.Context, accountID int64, tagID int64, input TagRequest) (TagResponse, error) {
+	name, err := normalizeTag(input.Name)
+	if err != nil {
+		return TagResponse{}, err
+	}
+
+	tag, err := m.store.TagUpdate(ctx, accountID, tagID, name)
+	if err != nil {
+		return TagResponse{}, err
+	}
+
+	return tagResponse(tag), nil
+}
+
+// TagDelete deletes the account's tag with the provided ID and removes it from every note.
+func (m *Models) TagDelete(ctx context.Context, accountID int64, tagID int64) error {
+	return m.store.TagDelete(ctx, accountID, tagID)
+}
+
+// normalizeTags trims the tag names and removes duplicates, returning them sorted. Returns
+// ErrInvalidInput if a name is empty once trimmed.
+func normalizeTags(names []string) ([]string, error) {
+	tags := make([]string, 0, len(names))
+
+	for _, name := range names {
+		tag, err := normalizeTag(name)
+		if err != nil {
+			return nil, err
+		}
+
+		tags = append(tags, tag)
+	}
+
+	slices.Sort(tags)
+
+	return slices.Compact(tags), nil
+}
+
+// normalizeTag trims the tag name. Returns ErrInvalidInput if it is empty once trimmed.
+func normalizeTag(name string) (string, error) {
+	tag := strings.TrimSpace(name)
+	if tag == "" || len(tag) > maxTagLength {
+		return "", fmt.Errorf("%w: tags must have 1 to %d characters", ErrInvalidInput, maxTagLength)
+	}
+
+	return tag, nil
+}
+
+func tagResponse(tag Tag) TagResponse {
+	return TagResponse{
+		ID:    tag.ID,
+		Name:  tag.Name,
+		Notes: tag.Notes,
+	}
+}
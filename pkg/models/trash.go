@@ -0,0 +1,186 @@
+package models
+
+import (
+	"context"
+	"time"
+
+	"github.com/oalexander6/passman/pkg/logger"
+)
+
+// trashPurgeBatchSize is how many notes are purged between queries for more.
+const trashPurgeBatchSize = 100
+
+// TrashItemResponse describes a deleted note. PurgeAt is when it will be deleted permanently,
+// and is omitted when the trash is kept until it is emptied by hand.
+type TrashItemResponse struct {
+	ID        int64      `json:"id"`
+	Name      string     `json:"name"`
+	Type      string     `json:"type"`
+	DeletedAt time.Time  `json:"deleted_at"`
+	PurgeAt   *time.Time `json:"purge_at,omitempty"`
+}
+
+// TrashPurgeResponse reports how many notes were deleted permanently.
+type TrashPurgeResponse struct {
+	Purged int `json:"purged"`
+}
+
+// Defines the required interface to implement the trash. Every method but NoteGetDeletedBefore
+// is scoped to the owning account, and only acts on deleted notes.
+type trashStore interface {
+	// NoteGetDeletedByAccountID returns the account's deleted notes, most recently deleted first.
+	NoteGetDeletedByAccountID(ctx context.Context, accountID int64) ([]Note, error)
+	// NoteGetDeletedBefore returns up to limit notes of any account that were deleted before the
+	// provided time.
+	NoteGetDeletedBefore(ctx context.Context, before time.Time, limit int) ([]Note, error)
+	NoteRestore(ctx context.Context, accountID int64, id int64) error
+	// NotePurge removes a deleted note along with its versions and attachments. Their names,
+	// values and keys are overwritten before the rows are deleted, in the same transaction.
+	NotePurge(ctx context.Context, accountID int64, id int64) error
+}
+
+// TrashGetByAccountID lists the account's deleted notes, most recently deleted first.
+func (m *Models) TrashGetByAccountID(ctx context.Context, accountID int64) ([]TrashItemResponse, error) {
+	notes, err := m.store.NoteGetDeletedByAccountID(ctx, accountID)
+	if err != nil {
+		return []TrashItemResponse{}, err
+	}
+
+	items := make([]TrashItemResponse, len(notes))
+	for i, note := range notes {
+		items[i] = m.trashItemResponse(note)
+	}
+
+	return items, nil
+}
+
+// TrashRestore moves the account's deleted note with the provided ID out of the trash, along
+// with its versions and attachments.
+func (m *Models) TrashRestore(ctx context.Context, accountID int64, noteID int64) (NoteGetResponse, error) {
+	if err := m.store.NoteRestore(ctx, accountID, noteID); err != nil {
+		return NoteGetResponse{}, err
+	}
+
+	return m.NoteGetByID(ctx, accountID, noteID, false)
+}
+
+// TrashDelete permanently deletes the account's deleted note with the provided ID.
+func (m *Models) TrashDelete(ctx context.Context, accountID int64, noteID int64) error {
+	return m.purgeNote(ctx, accountID, noteID)
+}
+
+// TrashEmpty permanently deletes all of the account's deleted notes.
+func (m *Models) TrashEmpty(ctx context.Context, accountID int64) (TrashPurgeResponse, error) {
+	notes, err := m.store.NoteGetDeletedByAccountID(ctx, accountID)
+	if err != nil {
+		return TrashPurgeResponse{}, err
+	}
+
+	var response TrashPurgeResponse
+	for _, note := range notes {
+		if err := m.purgeNote(ctx, accountID, note.ID); err != nil {
+			return response, err
+		}
+		response.Purged++
+	}
+
+	return response, nil
+}
+
+// PurgeTrash permanently deletes every note that has been in the trash for longer than the
+// configured retention period. Does nothing if the retention period is 0.
+func (m *Models) PurgeTrash(ctx context.Context) (int, error) {
+	if m.config.Trash.RetentionDays == 0 {
+		return 0, nil
+	}
+
+	before := time.Now().UTC().AddDate(0, 0, -m.config.Trash.RetentionDays)
+	purged := 0
+
+	for {
+		notes, err := m.store.NoteGetDeletedBefore(ctx, before, trashPurgeBatchSize)
+		if err != nil {
+			return purged, err
+		}
+		if len(notes) == 0 {
+			return purged, nil
+		}
+
+		for _, note := range notes {
+			if err := m.purgeNote(ctx, note.AccountID, note.ID); err != nil {
+				return purged, err
+			}
+			purged++
+		}
+	}
+}
+
+// RunTrashPurge calls PurgeTrash at the configured interval until ctx is cancelled. Failures
+// are logged and retried at the next interval.
+func (m *Models) RunTrashPurge(ctx context.Context) {
+	if m.config.Trash.RetentionDays == 0 {
+		return
+	}
+
+	ticker := time.NewTicker(m.config.Trash.PurgeInterval)
+	defer ticker.Stop()
+
+	for {
+		purged, err := m.PurgeTrash(ctx)
+		if err != nil {
+			logger.Log.Error().Msgf("Failed to purge trash: %s", err)
+		}
+		if purged > 0 {
+			logger.Log.Info().Msgf("Purged %d notes from the trash", purged)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// purgeNote permanently deletes a note in the trash. Its attachments' keys are destroyed with
+// it, and their chunks are removed from the blob store afterwards.
+func (m *Models) purgeNote(ctx context.Context, accountID int64, noteID int64) error {
+	attachments, err := m.store.AttachmentGetByNoteID(ctx, accountID, noteID)
+	if err != nil {
+		return err
+	}
+
+	if err := m.store.NotePurge(ctx, accountID, noteID); err != nil {
+		return err
+	}
+
+	for _, attachment := range attachments {
+		if m.blobs == nil {
+			logger.Log.Warn().Msgf("Leaving chunks of attachment %d, since no blob store is configured", attachment.ID)
+			continue
+		}
+
+		m.deleteAttachmentChunks(ctx, attachment)
+	}
+
+	return nil
+}
+
+func (m *Models) trashItemResponse(note Note) TrashItemResponse {
+	item := TrashItemResponse{
+		ID:   note.ID,
+		Name: note.Name,
+		Type: storedNoteType(note.Type),
+	}
+
+	if note.DeletedAt != nil {
+		item.DeletedAt = *note.DeletedAt
+
+		if m.config.Trash.RetentionDays > 0 {
+			purgeAt := note.DeletedAt.AddDate(0, 0, m.config.Trash.RetentionDays)
+			item.PurgeAt = &purgeAt
+		}
+	}
+
+	return item
+}
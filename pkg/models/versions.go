@@ -0,0 +1,190 @@
+package models
+
+import (
+	"context"
+	"time"
+)
+
+// NoteVersion is a note as it was before one of its updates. Its value is still encrypted for
+// the note it was copied from, with the item key the note had then. UpdatedBy and UpdatedAt
+// record who wrote this version and when.
+type NoteVersion struct {
+	ID        int64     `db:"id"`
+	NoteID    int64     `db:"note_id"`
+	AccountID int64     `db:"account_id"`
+	Name      string    `db:"name"`
+	Type      string    `db:"type"`
+	Value     string    `db:"value"`
+	UpdatedBy int64     `db:"updated_by"`
+	UpdatedAt time.Time `db:"updated_at"`
+	ItemKey   string    `db:"item_key"`
+}
+
+// NoteVersionResponse describes a version without its fields.
+type NoteVersionResponse struct {
+	ID        int64     `json:"id"`
+	NoteID    int64     `json:"note_id"`
+	Name      string    `json:"name"`
+	Type      string    `json:"type"`
+	UpdatedBy int64     `json:"updated_by"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// NoteVersionGetResponse is a version along with the note as it was, decrypted.
+type NoteVersionGetResponse struct {
+	NoteVersionResponse
+	Note NoteGetResponse `json:"note"`
+}
+
+// Defines the required interface to implement note version storage. Every method is scoped to
+// the owning account.
+type noteVersionStore interface {
+	// NoteVersionGetByNoteID returns the versions of the note, newest first.
+	NoteVersionGetByNoteID(ctx context.Context, accountID int64, noteID int64) ([]NoteVersion, error)
+	NoteVersionGetByID(ctx context.Context, accountID int64, noteID int64, id int64) (NoteVersion, error)
+	// NoteVersionGetAllByAccountID returns every version of the account's notes, including
+	// versions of deleted notes.
+	NoteVersionGetAllByAccountID(ctx context.Context, accountID int64) ([]NoteVersion, error)
+	// NoteVersionUpdateValue replaces the encrypted value of a version, but only if the value
+	// still matches currentValue. Returns ErrNotFound if the version does not exist or its value
+	// has changed.
+	NoteVersionUpdateValue(ctx context.Context, accountID int64, id int64, currentValue string, newValue string) error
+	// NoteVersionPrune removes all but the newest keep versions of each of the account's notes.
+	NoteVersionPrune(ctx context.Context, accountID int64, keep int) error
+}
+
+// NoteVersionGetByNoteID lists the previous versions of the account's note, newest first.
+func (m *Models) NoteVersionGetByNoteID(ctx context.Context, accountID int64, noteID int64) ([]NoteVersionResponse, error) {
+	if _, err := m.store.NoteGetByID(ctx, accountID, noteID); err != nil {
+		return []NoteVersionResponse{}, err
+	}
+
+	versions, err := m.store.NoteVersionGetByNoteID(ctx, accountID, noteID)
+	if err != nil {
+		return []NoteVersionResponse{}, err
+	}
+
+	responses := make([]NoteVersionResponse, len(versions))
+	for i, version := range versions {
+		responses[i] = noteVersionResponse(version)
+	}
+
+	return responses, nil
+}
+
+// NoteVersionGetByID returns a previous version of the account's note with its value decrypted.
+// Hidden custom fields are masked unless reveal is set.
+func (m *Models) NoteVersionGetByID(ctx context.Context, accountID int64, noteID int64, versionID int64, reveal bool) (NoteVersionGetResponse, error) {
+	account, current, version, plaintext, err := m.openNoteVersion(ctx, accountID, noteID, versionID)
+	if err != nil {
+		return NoteVersionGetResponse{}, err
+	}
+
+	note, err := noteResponse(account, version.note(current), plaintext, reveal)
+	if err != nil {
+		return NoteVersionGetResponse{}, err
+	}
+
+	return NoteVersionGetResponse{NoteVersionResponse: noteVersionResponse(version), Note: note}, nil
+}
+
+// NoteVersionRestore replaces the account's note with one of its previous versions. The note as
+// it was before the restore is kept as a version, so a restore can itself be undone. The
+// restored fields are not checked against the account's policy, since they were accepted when
+// they were first saved. Versions don't record folders or tags, so the note keeps its current
+// ones. Zero-knowledge notes lose their search index, which only their client can rebuild.
+func (m *Models) NoteVersionRestore(ctx context.Context, accountID int64, noteID int64, versionID int64) (NoteGetResponse, error) {
+	account, current, version, plaintext, err := m.openNoteVersion(ctx, accountID, noteID, versionID)
+	if err != nil {
+		return NoteGetResponse{}, err
+	}
+
+	dataKey, err := m.dataKeyForAccount(ctx, account)
+	if err != nil {
+		return NoteGetResponse{}, err
+	}
+
+	indexKey, err := m.searchIndexKey(ctx, account)
+	if err != nil {
+		return NoteGetResponse{}, err
+	}
+
+	// the restored value is encrypted with the item key the note has now
+	note := version.note(current)
+	note.UpdatedBy = accountID
+	note.ItemKey = current.ItemKey
+
+	search := plaintextSearchFields(account, indexKey, note, plaintext)
+	note.Search = &search
+
+	note.Value, err = encryptNoteValue(dataKey, note, plaintext)
+	if err != nil {
+		return NoteGetResponse{}, err
+	}
+
+	savedNote, err := m.store.NoteUpdate(ctx, accountID, note, account.MaxNoteVersions)
+	if err != nil {
+		return NoteGetResponse{}, err
+	}
+
+	return noteResponse(account, savedNote, plaintext, false)
+}
+
+// openNoteVersion loads and decrypts a version of the account's note, which must not be
+// deleted, along with the note as it is now.
+func (m *Models) openNoteVersion(ctx context.Context, accountID int64, noteID int64, versionID int64) (Account, Note, NoteVersion, string, error) {
+	current, err := m.store.NoteGetByID(ctx, accountID, noteID)
+	if err != nil {
+		return Account{}, Note{}, NoteVersion{}, "", err
+	}
+
+	version, err := m.store.NoteVersionGetByID(ctx, accountID, noteID, versionID)
+	if err != nil {
+		return Account{}, Note{}, NoteVersion{}, "", err
+	}
+
+	account, err := m.store.AccountGetByID(ctx, accountID)
+	if err != nil {
+		return Account{}, Note{}, NoteVersion{}, "", err
+	}
+
+	dataKey, err := m.dataKeyForAccount(ctx, account)
+	if err != nil {
+		return Account{}, Note{}, NoteVersion{}, "", err
+	}
+
+	plaintext, err := m.decryptNoteValue(dataKey, version.note(current), version.Value)
+	if err != nil {
+		return Account{}, Note{}, NoteVersion{}, "", ErrDecryptFailed
+	}
+
+	return account, current, version, plaintext, nil
+}
+
+// note returns the note as it was in this version, in the current note's folder and with its
+// tags, since versions don't record them.
+func (v NoteVersion) note(current Note) Note {
+	return Note{
+		ID:        v.NoteID,
+		AccountID: v.AccountID,
+		Name:      v.Name,
+		Type:      v.Type,
+		Value:     v.Value,
+		UpdatedBy: v.UpdatedBy,
+		FolderID:  current.FolderID,
+		ItemKey:   v.ItemKey,
+		Tags:      current.Tags,
+		Base:      Base{UpdatedAt: v.UpdatedAt},
+	}
+}
+
+func noteVersionResponse(version NoteVersion) NoteVersionResponse {
+	return NoteVersionResponse{
+		ID:        version.ID,
+		NoteID:    version.NoteID,
+		Name:      version.Name,
+		Type:      storedNoteType(version.Type),
+		UpdatedBy: version.UpdatedBy,
+		UpdatedAt: version.UpdatedAt,
+	}
+}
@@ -0,0 +1,286 @@
+// Package otp generates one-time passwords from the seeds stored with logins: HOTP as defined in
+// RFC 4226, TOTP as defined in RFC 6238, and the Steam Guard variant of TOTP. Seeds are read from
+// otpauth:// URIs in the Key Uri Format used by authenticator apps, steam:// URIs, or bare base32
+// secrets, which are treated as TOTP with the default parameters.
+package otp
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base32"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Key types.
+const (
+	TypeTOTP = "totp"
+	TypeHOTP = "hotp"
+)
+
+// Hash algorithms.
+const (
+	AlgorithmSHA1   = "SHA1"
+	AlgorithmSHA256 = "SHA256"
+	AlgorithmSHA512 = "SHA512"
+)
+
+const (
+	defaultDigits = 6
+	defaultPeriod = 30
+	minDigits     = 6
+	maxDigits     = 10
+	maxPeriod     = 24 * 60 * 60
+
+	// encoderSteam marks keys that produce Steam Guard codes.
+	encoderSteam = "steam"
+	steamDigits  = 5
+
+	// steamAlphabet is the set of characters Steam Guard codes are made of.
+	steamAlphabet = "23456789BCDFGHJKMNPQRTVWXY"
+)
+
+var ErrInvalidKey = errors.New("invalid one-time password key")
+
+// Key is a parsed one-time password seed.
+type Key struct {
+	Type      string
+	Secret    []byte
+	Algorithm string
+	Digits    int
+	// Period is the number of seconds each TOTP code is valid for.
+	Period int
+	// Counter is the next HOTP counter value.
+	Counter uint64
+	Issuer  string
+	Account string
+	// Steam is set for keys that produce Steam Guard codes.
+	Steam bool
+}
+
+// Code is a generated one-time password.
+type Code struct {
+	Code string
+	// SecondsLeft is how long a TOTP code stays valid.
+	SecondsLeft int
+}
+
+// Parse reads an otpauth:// URI, a steam:// URI or a bare base32 secret.
+func Parse(s string) (Key, error) {
+	s = strings.TrimSpace(s)
+
+	switch {
+	case hasScheme(s, "otpauth"):
+		return parseOTPAuth(s)
+	case hasScheme(s, "steam"):
+		secret, err := decodeSecret(s[len("steam://"):])
+		if err != nil {
+			return Key{}, err
+		}
+		return Key{Type: TypeTOTP, Secret: secret, Algorithm: AlgorithmSHA1, Digits: steamDigits, Period: defaultPeriod, Steam: true}, nil
+	default:
+		secret, err := decodeSecret(s)
+		if err != nil {
+			return Key{}, err
+		}
+		return Key{Type: TypeTOTP, Secret: secret, Algorithm: AlgorithmSHA1, Digits: defaultDigits, Period: defaultPeriod}, nil
+	}
+}
+
+func hasScheme(s string, scheme string) bool {
+	return len(s) > len(scheme)+3 && strings.EqualFold(s[:len(scheme)+3], scheme+"://")
+}
+
+func parseOTPAuth(s string) (Key, error) {
+	u, err := url.Parse(s)
+	if err != nil {
+		return Key{}, fmt.Errorf("%w: %s", ErrInvalidKey, err)
+	}
+
+	key := Key{
+		Type:      strings.ToLower(u.Host),
+		Algorithm: AlgorithmSHA1,
+		Digits:    defaultDigits,
+		Period:    defaultPeriod,
+	}
+	if key.Type != TypeTOTP && key.Type != TypeHOTP {
+		return Key{}, fmt.Errorf("%w: type must be totp or hotp", ErrInvalidKey)
+	}
+
+	// the label is "issuer:account" or just "account"
+	label := strings.TrimPrefix(u.Path, "/")
+	if issuer, account, ok := strings.Cut(label, ":"); ok {
+		key.Issuer = strings.TrimSpace(issuer)
+		key.Account = strings.TrimSpace(account)
+	} else {
+		key.Account = strings.TrimSpace(label)
+	}
+
+	query := u.Query()
+
+	key.Secret, err = decodeSecret(query.Get("secret"))
+	if err != nil {
+		return Key{}, err
+	}
+
+	if issuer := query.Get("issuer"); issuer != "" {
+		key.Issuer = issuer
+	}
+
+	if algorithm := query.Get("algorithm"); algorithm != "" {
+		key.Algorithm = strings.ToUpper(algorithm)
+		if newHash(key.Algorithm) == nil {
+			return Key{}, fmt.Errorf("%w: algorithm must be SHA1, SHA256 or SHA512", ErrInvalidKey)
+		}
+	}
+
+	key.Steam = strings.EqualFold(query.Get("encoder"), encoderSteam)
+
+	// Steam Guard codes always have five characters, whatever digits says
+	if digits := query.Get("digits"); digits != "" && !key.Steam {
+		key.Digits, err = strconv.Atoi(digits)
+		if err != nil || key.Digits < minDigits || key.Digits > maxDigits {
+			return Key{}, fmt.Errorf("%w: digits must be between %d and %d", ErrInvalidKey, minDigits, maxDigits)
+		}
+	}
+
+	if period := query.Get("period"); period != "" {
+		key.Period, err = strconv.Atoi(period)
+		if err != nil || key.Period < 1 || key.Period > maxPeriod {
+			return Key{}, fmt.Errorf("%w: period must be between 1 and %d seconds", ErrInvalidKey, maxPeriod)
+		}
+	}
+
+	if key.Type == TypeHOTP {
+		counter := query.Get("counter")
+		if counter == "" {
+			return Key{}, fmt.Errorf("%w: hotp keys must have a counter", ErrInvalidKey)
+		}
+
+		key.Counter, err = strconv.ParseUint(counter, 10, 64)
+		if err != nil {
+			return Key{}, fmt.Errorf("%w: counter must be a non-negative integer", ErrInvalidKey)
+		}
+	}
+
+	if key.Steam {
+		if key.Type != TypeTOTP {
+			return Key{}, fmt.Errorf("%w: steam keys must be totp", ErrInvalidKey)
+		}
+		key.Digits = steamDigits
+	}
+
+	return key, nil
+}
+
+// decodeSecret decodes a base32 secret, ignoring case, spaces, dashes and padding.
+func decodeSecret(s string) ([]byte, error) {
+	s = strings.ToUpper(strings.NewReplacer(" ", "", "-", "", "=", "").Replace(s))
+	if s == "" {
+		return nil, fmt.Errorf("%w: the secret is missing", ErrInvalidKey)
+	}
+
+	secret, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(s)
+	if err != nil || len(secret) == 0 {
+		return nil, fmt.Errorf("%w: the secret must be base32", ErrInvalidKey)
+	}
+
+	return secret, nil
+}
+
+// URI returns the key as an otpauth:// URI.
+func (k Key) URI() string {
+	label := k.Account
+	if k.Issuer != "" {
+		label = k.Issuer + ":" + k.Account
+	}
+
+	query := url.Values{}
+	query.Set("secret", base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(k.Secret))
+	if k.Issuer != "" {
+		query.Set("issuer", k.Issuer)
+	}
+	query.Set("algorithm", k.Algorithm)
+	if k.Steam {
+		query.Set("encoder", encoderSteam)
+	} else {
+		query.Set("digits", strconv.Itoa(k.Digits))
+	}
+
+	if k.Type == TypeHOTP {
+		query.Set("counter", strconv.FormatUint(k.Counter, 10))
+	} else {
+		query.Set("period", strconv.Itoa(k.Period))
+	}
+
+	u := url.URL{Scheme: "otpauth", Host: k.Type, Path: "/" + label, RawQuery: query.Encode()}
+	return u.String()
+}
+
+// Generate returns the code for the key at time t. TOTP codes are derived from the time step t
+// falls in, and HOTP codes from the key's counter.
+func (k Key) Generate(t time.Time) Code {
+	if k.Type == TypeHOTP {
+		return Code{Code: k.code(k.Counter)}
+	}
+
+	unix := t.Unix()
+	period := int64(k.Period)
+
+	return Code{
+		Code:        k.code(uint64(unix / period)),
+		SecondsLeft: int(period - unix%period),
+	}
+}
+
+// code computes the HOTP value for the counter, formatted for the key.
+func (k Key) code(counter uint64) string {
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], counter)
+
+	mac := hmac.New(newHash(k.Algorithm), k.Secret)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+
+	// dynamic truncation from RFC 4226 section 5.3
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	if k.Steam {
+		code := make([]byte, steamDigits)
+		for i := range code {
+			code[i] = steamAlphabet[value%uint32(len(steamAlphabet))]
+			value /= uint32(len(steamAlphabet))
+		}
+		return string(code)
+	}
+
+	modulus := uint64(1)
+	for range k.Digits {
+		modulus *= 10
+	}
+
+	return fmt.Sprintf("%0*d", k.Digits, uint64(value)%modulus)
+}
+
+// newHash returns the constructor for the named algorithm, or nil if it isn't supported.
+func newHash(algorithm string) func() hash.Hash {
+	switch algorithm {
+	case AlgorithmSHA1:
+		return sha1.New
+	case AlgorithmSHA256:
+		return sha256.New
+	case AlgorithmSHA512:
+		return sha512.New
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,169 @@
+package otp
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// Seeds from the RFC 6238 reference implementation, in base32.
+const (
+	seedSHA1   = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+	seedSHA256 = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQGEZA"
+	seedSHA512 = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQGEZDGNA"
+)
+
+func mustParse(t *testing.T, s string) Key {
+	t.Helper()
+
+	key, err := Parse(s)
+	if err != nil {
+		t.Fatalf("parsing %q: %v", s, err)
+	}
+
+	return key
+}
+
+// TestTOTPVectors checks the test vectors from RFC 6238 Appendix B.
+func TestTOTPVectors(t *testing.T) {
+	seeds := map[string]string{
+		AlgorithmSHA1:   seedSHA1,
+		AlgorithmSHA256: seedSHA256,
+		AlgorithmSHA512: seedSHA512,
+	}
+
+	tests := []struct {
+		unix      int64
+		algorithm string
+		code      string
+	}{
+		{59, AlgorithmSHA1, "94287082"},
+		{59, AlgorithmSHA256, "46119246"},
+		{59, AlgorithmSHA512, "90693936"},
+		{1111111109, AlgorithmSHA1, "07081804"},
+		{1111111109, AlgorithmSHA256, "68084774"},
+		{1111111109, AlgorithmSHA512, "25091201"},
+		{1111111111, AlgorithmSHA1, "14050471"},
+		{1111111111, AlgorithmSHA256, "67062674"},
+		{1111111111, AlgorithmSHA512, "99943326"},
+		{1234567890, AlgorithmSHA1, "89005924"},
+		{1234567890, AlgorithmSHA256, "91819424"},
+		{1234567890, AlgorithmSHA512, "93441116"},
+		{2000000000, AlgorithmSHA1, "69279037"},
+		{2000000000, AlgorithmSHA256, "90698825"},
+		{2000000000, AlgorithmSHA512, "38618901"},
+		{20000000000, AlgorithmSHA1, "65353130"},
+		{20000000000, AlgorithmSHA256, "77737706"},
+		{20000000000, AlgorithmSHA512, "47863826"},
+	}
+
+	for _, tt := range tests {
+		uri := "otpauth://totp/Example:alice?secret=" + seeds[tt.algorithm] + "&algorithm=" + tt.algorithm + "&digits=8&period=30"
+		key := mustParse(t, uri)
+
+		code := key.Generate(time.Unix(tt.unix, 0))
+		if code.Code != tt.code {
+			t.Errorf("%s at %d: expected %s, got %s", tt.algorithm, tt.unix, tt.code, code.Code)
+		}
+	}
+}
+
+// TestHOTPVectors checks the test vectors from RFC 4226 Appendix D.
+func TestHOTPVectors(t *testing.T) {
+	codes := []string{"755224", "287082", "359152", "969429", "338314", "254676", "287922", "162583", "399871", "520489"}
+
+	for counter, expected := range codes {
+		key := mustParse(t, "otpauth://hotp/alice?secret="+seedSHA1+"&counter="+strconv.Itoa(counter))
+
+		if code := key.Generate(time.Now()); code.Code != expected {
+			t.Errorf("counter %d: expected %s, got %s", counter, expected, code.Code)
+		}
+	}
+}
+
+func TestTOTPSecondsLeft(t *testing.T) {
+	key := mustParse(t, seedSHA1)
+
+	if code := key.Generate(time.Unix(59, 0)); code.SecondsLeft != 1 {
+		t.Errorf("expected 1 second left, got %d", code.SecondsLeft)
+	}
+
+	if code := key.Generate(time.Unix(60, 0)); code.SecondsLeft != 30 {
+		t.Errorf("expected 30 seconds left, got %d", code.SecondsLeft)
+	}
+}
+
+// TestSteam checks Steam Guard codes against the RFC 4226 truncated values for counters 0 and 1,
+// written in the Steam alphabet least significant character first.
+func TestSteam(t *testing.T) {
+	for _, uri := range []string{"steam://" + seedSHA1, "otpauth://totp/Steam:alice?secret=" + seedSHA1 + "&encoder=steam&digits=8"} {
+		key := mustParse(t, uri)
+
+		if !key.Steam || key.Digits != steamDigits {
+			t.Fatalf("%s: expected a steam key with %d digits, got %+v", uri, steamDigits, key)
+		}
+
+		if code := key.Generate(time.Unix(0, 0)); code.Code != "GG5F5" {
+			t.Errorf("%s at 0: expected GG5F5, got %s", uri, code.Code)
+		}
+
+		if code := key.Generate(time.Unix(59, 0)); code.Code != "PV9M4" {
+			t.Errorf("%s at 59: expected PV9M4, got %s", uri, code.Code)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	key := mustParse(t, "otpauth://totp/ACME%20Co:alice@example.com?secret=jbsw-y3dp&issuer=ACME&algorithm=sha256&digits=7&period=60")
+
+	if key.Type != TypeTOTP || key.Issuer != "ACME" || key.Account != "alice@example.com" || key.Algorithm != AlgorithmSHA256 ||
+		key.Digits != 7 || key.Period != 60 || string(key.Secret) != "Hello" {
+		t.Fatalf("unexpected key %+v", key)
+	}
+
+	bare := mustParse(t, " jbsw y3dp ")
+	if bare.Type != TypeTOTP || bare.Digits != defaultDigits || bare.Period != defaultPeriod || bare.Algorithm != AlgorithmSHA1 {
+		t.Fatalf("unexpected key %+v", bare)
+	}
+
+	hotp := mustParse(t, "otpauth://hotp/alice?secret=JBSWY3DP&counter=42")
+	if hotp.Type != TypeHOTP || hotp.Counter != 42 {
+		t.Fatalf("unexpected key %+v", hotp)
+	}
+
+	if roundTrip := mustParse(t, hotp.URI()); roundTrip.Counter != 42 || string(roundTrip.Secret) != "Hello" {
+		t.Fatalf("unexpected key after a round trip %+v", roundTrip)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		uri  string
+	}{
+		{"missing secret", "otpauth://totp/alice"},
+		{"secret not base32", "otpauth://totp/alice?secret=not!base32"},
+		{"unknown type", "otpauth://motp/alice?secret=JBSWY3DP"},
+		{"unknown algorithm", "otpauth://totp/alice?secret=JBSWY3DP&algorithm=MD5"},
+		{"too few digits", "otpauth://totp/alice?secret=JBSWY3DP&digits=5"},
+		{"too many digits", "otpauth://totp/alice?secret=JBSWY3DP&digits=11"},
+		{"digits not a number", "otpauth://totp/alice?secret=JBSWY3DP&digits=six"},
+		{"zero period", "otpauth://totp/alice?secret=JBSWY3DP&period=0"},
+		{"period too long", "otpauth://totp/alice?secret=JBSWY3DP&period=86401"},
+		{"period not a number", "otpauth://totp/alice?secret=JBSWY3DP&period=30s"},
+		{"hotp missing counter", "otpauth://hotp/alice?secret=JBSWY3DP"},
+		{"negative counter", "otpauth://hotp/alice?secret=JBSWY3DP&counter=-1"},
+		{"steam hotp", "otpauth://hotp/alice?secret=JBSWY3DP&counter=1&encoder=steam"},
+		{"empty steam secret", "steam://"},
+		{"empty", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Parse(tt.uri); !errors.Is(err, ErrInvalidKey) {
+				t.Fatalf("expected ErrInvalidKey, got %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,70 @@
+package otp
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+
+	"github.com/makiuchi-d/gozxing"
+	"github.com/makiuchi-d/gozxing/qrcode"
+)
+
+var ErrNoQRCode = errors.New("no QR code found in the image")
+
+// maxImagePixels bounds the size of images ScanPNG will decode, so a small compressed file can't
+// claim huge dimensions.
+const maxImagePixels = 4096 * 4096
+
+// ScanPNG decodes the QR code in a PNG image and returns the key it holds, along with the text
+// of the code. Returns ErrNoQRCode if the image has no readable QR code, or ErrInvalidKey if the
+// code doesn't hold a one-time password key.
+func ScanPNG(r io.Reader) (Key, string, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return Key{}, "", err
+	}
+
+	config, err := png.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return Key{}, "", fmt.Errorf("%w: %s", ErrNoQRCode, err)
+	}
+	if config.Width*config.Height > maxImagePixels {
+		return Key{}, "", fmt.Errorf("%w: the image is larger than %d pixels", ErrNoQRCode, maxImagePixels)
+	}
+
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		return Key{}, "", fmt.Errorf("%w: %s", ErrNoQRCode, err)
+	}
+
+	text, err := decodeQR(img)
+	if err != nil {
+		return Key{}, "", err
+	}
+
+	key, err := Parse(text)
+	if err != nil {
+		return Key{}, "", err
+	}
+
+	return key, text, nil
+}
+
+func decodeQR(img image.Image) (string, error) {
+	bitmap, err := gozxing.NewBinaryBitmapFromImage(img)
+	if err != nil {
+		return "", fmt.Errorf("%w: %s", ErrNoQRCode, err)
+	}
+
+	result, err := qrcode.NewQRCodeReader().Decode(bitmap, map[gozxing.DecodeHintType]interface{}{
+		gozxing.DecodeHintType_TRY_HARDER: true,
+	})
+	if err != nil {
+		return "", ErrNoQRCode
+	}
+
+	return result.GetText(), nil
+}
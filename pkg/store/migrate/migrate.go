@@ -0,0 +1,294 @@
+// Package migrate implements versioned schema migrations shared by every store backend.
+// Each backend embeds its own ordered set of up/down SQL files and provides a Driver that
+// knows how to lock the database and record applied versions in a schema_migrations table.
+package migrate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+)
+
+var (
+	ErrSchemaMismatch  = errors.New("database schema version does not match the application")
+	ErrNoMigrations    = errors.New("no migrations to apply")
+	ErrInvalidFileName = errors.New("invalid migration file name")
+)
+
+// fileNamePattern matches migration files such as 0001_create_accounts.up.sql.
+var fileNamePattern = regexp.MustCompile(`^(\d+)_([a-z0-9_]+)\.(up|down)\.sql$`)
+
+// Migration is a single schema change with the SQL required to apply and revert it.
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+}
+
+// AppliedMigration is a row from the schema_migrations table.
+type AppliedMigration struct {
+	Version   int64
+	AppliedAt time.Time
+}
+
+// MigrationStatus describes whether a known migration has been applied to the database.
+type MigrationStatus struct {
+	Version   int64
+	Name      string
+	Applied   bool
+	AppliedAt time.Time
+}
+
+// Driver defines the backend specific operations required to run migrations. All calls
+// other than Lock are made while the lock is held.
+type Driver interface {
+	// Lock blocks until this process holds an exclusive migration lock on the database and
+	// ensures the schema_migrations table exists.
+	Lock(ctx context.Context) error
+	// Unlock releases the lock acquired by Lock.
+	Unlock(ctx context.Context) error
+	// Applied returns the applied migrations ordered by version.
+	Applied(ctx context.Context) ([]AppliedMigration, error)
+	// Apply atomically runs the migration SQL and records or removes its version.
+	Apply(ctx context.Context, migration Migration, up bool) error
+}
+
+type Migrator struct {
+	driver     Driver
+	migrations []Migration
+}
+
+// New creates a migrator for the provided driver and migrations, which must be ordered
+// by version as returned by Load.
+func New(driver Driver, migrations []Migration) *Migrator {
+	return &Migrator{
+		driver:     driver,
+		migrations: migrations,
+	}
+}
+
+// Load reads every *.up.sql and *.down.sql file in the provided directory and returns the
+// migrations ordered by version. Every migration must have both an up and a down file.
+func Load(fsys fs.FS, dir string) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int64]*Migration)
+
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+
+		match := fileNamePattern.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidFileName, entry.Name())
+		}
+
+		version, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidFileName, entry.Name())
+		}
+
+		contents, err := fs.ReadFile(fsys, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		}
+		if m.Name != match[2] {
+			return nil, fmt.Errorf("%w: version %d has conflicting names %s and %s", ErrInvalidFileName, version, m.Name, match[2])
+		}
+
+		if match[3] == "up" {
+			m.Up = string(contents)
+		} else {
+			m.Down = string(contents)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" || m.Down == "" {
+			return nil, fmt.Errorf("%w: version %d must have both up and down files", ErrInvalidFileName, m.Version)
+		}
+		migrations = append(migrations, *m)
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	return migrations, nil
+}
+
+// LatestVersion returns the version the application expects the database to be at.
+func (m *Migrator) LatestVersion() int64 {
+	if len(m.migrations) == 0 {
+		return 0
+	}
+
+	return m.migrations[len(m.migrations)-1].Version
+}
+
+// Up applies every pending migration in order and returns the ones that were applied.
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	var applied []Migration
+
+	err := m.withLock(ctx, func() error {
+		appliedVersions, err := m.appliedSet(ctx)
+		if err != nil {
+			return err
+		}
+
+		for _, migration := range m.migrations {
+			if _, ok := appliedVersions[migration.Version]; ok {
+				continue
+			}
+
+			if err := m.driver.Apply(ctx, migration, true); err != nil {
+				return fmt.Errorf("migration %d_%s failed: %w", migration.Version, migration.Name, err)
+			}
+
+			applied = append(applied, migration)
+		}
+
+		return nil
+	})
+
+	return applied, err
+}
+
+// Down reverts the most recently applied migrations, up to the provided number of steps,
+// and returns the ones that were reverted.
+func (m *Migrator) Down(ctx context.Context, steps int) ([]Migration, error) {
+	var reverted []Migration
+
+	err := m.withLock(ctx, func() error {
+		appliedVersions, err := m.appliedSet(ctx)
+		if err != nil {
+			return err
+		}
+
+		for i := len(m.migrations) - 1; i >= 0 && len(reverted) < steps; i-- {
+			migration := m.migrations[i]
+			if _, ok := appliedVersions[migration.Version]; !ok {
+				continue
+			}
+
+			if err := m.driver.Apply(ctx, migration, false); err != nil {
+				return fmt.Errorf("reverting migration %d_%s failed: %w", migration.Version, migration.Name, err)
+			}
+
+			reverted = append(reverted, migration)
+		}
+
+		if len(reverted) == 0 {
+			return ErrNoMigrations
+		}
+
+		return nil
+	})
+
+	return reverted, err
+}
+
+// Status returns every known migration along with whether it has been applied.
+func (m *Migrator) Status(ctx context.Context) ([]MigrationStatus, error) {
+	var statuses []MigrationStatus
+
+	err := m.withLock(ctx, func() error {
+		appliedVersions, err := m.appliedSet(ctx)
+		if err != nil {
+			return err
+		}
+
+		for _, migration := range m.migrations {
+			appliedAt, ok := appliedVersions[migration.Version]
+			statuses = append(statuses, MigrationStatus{
+				Version:   migration.Version,
+				Name:      migration.Name,
+				Applied:   ok,
+				AppliedAt: appliedAt,
+			})
+		}
+
+		return nil
+	})
+
+	return statuses, err
+}
+
+// Check returns ErrSchemaMismatch unless exactly the known migrations have been applied.
+func (m *Migrator) Check(ctx context.Context) error {
+	return m.withLock(ctx, func() error {
+		applied, err := m.driver.Applied(ctx)
+		if err != nil {
+			return err
+		}
+
+		known := make(map[int64]struct{}, len(m.migrations))
+		for _, migration := range m.migrations {
+			known[migration.Version] = struct{}{}
+		}
+
+		var current int64
+		for _, a := range applied {
+			if _, ok := known[a.Version]; !ok {
+				return fmt.Errorf("%w: database has migration %d which this build does not know about", ErrSchemaMismatch, a.Version)
+			}
+			current = max(current, a.Version)
+		}
+
+		if len(applied) != len(m.migrations) {
+			return fmt.Errorf("%w: database is at version %d with %d of %d migrations applied, expected version %d",
+				ErrSchemaMismatch, current, len(applied), len(m.migrations), m.LatestVersion())
+		}
+
+		return nil
+	})
+}
+
+// appliedSet returns the applied versions mapped to the time they were applied.
+func (m *Migrator) appliedSet(ctx context.Context) (map[int64]time.Time, error) {
+	applied, err := m.driver.Applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	versions := make(map[int64]time.Time, len(applied))
+	for _, a := range applied {
+		versions[a.Version] = a.AppliedAt
+	}
+
+	return versions, nil
+}
+
+// withLock runs fn while holding the driver's migration lock.
+func (m *Migrator) withLock(ctx context.Context, fn func() error) (err error) {
+	if err := m.driver.Lock(ctx); err != nil {
+		return fmt.Errorf("failed to acquire migration lock: %w", err)
+	}
+
+	defer func() {
+		// the lock must be released even if ctx was cancelled while running fn
+		if unlockErr := m.driver.Unlock(context.WithoutCancel(ctx)); unlockErr != nil && err == nil {
+			err = fmt.Errorf("failed to release migration lock: %w", unlockErr)
+		}
+	}()
+
+	return fn()
+}
@@ -0,0 +1,150 @@
+package postgres
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/oalexander6/passman/pkg/models"
+)
+
+const attachmentColumns = `id, account_id, note_id, name, content_type, size, chunks, file_key, complete, created_at`
+
+// AttachmentCreate implements models.Store.
+func (s PostgresStore) AttachmentCreate(ctx context.Context, attachment models.Attachment) (models.Attachment, error) {
+	query := `INSERT INTO attachments (account_id, note_id, name, content_type, size, chunks, file_key, complete, created_at)
+		VALUES (@account_id, @note_id, @name, @content_type, 0, 0, '', false, @created_at)
+		RETURNING ` + attachmentColumns + `;`
+
+	args := pgx.NamedArgs{
+		"account_id":   attachment.AccountID,
+		"note_id":      attachment.NoteID,
+		"name":         attachment.Name,
+		"content_type": attachment.ContentType,
+		"created_at":   time.Now().UTC(),
+	}
+
+	rows, err := s.dbpool.Query(ctx, query, args)
+	if err != nil {
+		return models.Attachment{}, err
+	}
+
+	saved, err := pgx.CollectOneRow(rows, pgx.RowToStructByName[models.Attachment])
+	if err != nil {
+		return models.Attachment{}, mapError(err)
+	}
+
+	return saved, nil
+}
+
+// AttachmentComplete implements models.Store. The account's row is locked while the quota is
+// checked, so concurrent uploads can't both fit into the same remaining space.
+func (s PostgresStore) AttachmentComplete(ctx context.Context, attachment models.Attachment, quota int64) error {
+	tx, err := s.dbpool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `SELECT 1 FROM accounts WHERE id=$1 FOR UPDATE;`, attachment.AccountID); err != nil {
+		return err
+	}
+
+	if quota > 0 {
+		var used int64
+		if err := tx.QueryRow(ctx, `SELECT COALESCE(SUM(size), 0) FROM attachments
+			WHERE account_id=$1 AND complete=true;`, attachment.AccountID).Scan(&used); err != nil {
+			return err
+		}
+
+		if used+attachment.Size > quota {
+			return models.ErrQuotaExceeded
+		}
+	}
+
+	query := `UPDATE attachments SET size=@size, chunks=@chunks, file_key=@file_key, complete=true
+		WHERE id=@id AND account_id=@account_id AND complete=false;`
+
+	args := pgx.NamedArgs{
+		"id":         attachment.ID,
+		"account_id": attachment.AccountID,
+		"size":       attachment.Size,
+		"chunks":     attachment.Chunks,
+		"file_key":   attachment.FileKey,
+	}
+
+	result, err := tx.Exec(ctx, query, args)
+	if err != nil {
+		return err
+	}
+
+	if result.RowsAffected() != 1 {
+		return models.ErrNotFound
+	}
+
+	return tx.Commit(ctx)
+}
+
+// AttachmentGetByID implements models.Store.
+func (s PostgresStore) AttachmentGetByID(ctx context.Context, accountID int64, noteID int64, id int64) (models.Attachment, error) {
+	query := `SELECT ` + attachmentColumns + ` FROM attachments
+		WHERE id=$1 AND account_id=$2 AND note_id=$3 AND complete=true;`
+
+	rows, err := s.dbpool.Query(ctx, query, id, accountID, noteID)
+	if err != nil {
+		return models.Attachment{}, err
+	}
+
+	attachment, err := pgx.CollectOneRow(rows, pgx.RowToStructByName[models.Attachment])
+	if err != nil {
+		return models.Attachment{}, mapError(err)
+	}
+
+	return attachment, nil
+}
+
+// AttachmentGetByNoteID implements models.Store.
+func (s PostgresStore) AttachmentGetByNoteID(ctx context.Context, accountID int64, noteID int64) ([]models.Attachment, error) {
+	query := `SELECT ` + attachmentColumns + ` FROM attachments
+		WHERE account_id=$1 AND note_id=$2 AND complete=true ORDER BY id;`
+
+	rows, err := s.dbpool.Query(ctx, query, accountID, noteID)
+	if err != nil {
+		return []models.Attachment{}, err
+	}
+
+	attachments, err := pgx.CollectRows(rows, pgx.RowToStructByName[models.Attachment])
+	if err != nil {
+		return []models.Attachment{}, err
+	}
+
+	return attachments, nil
+}
+
+// AttachmentDelete implements models.Store.
+func (s PostgresStore) AttachmentDelete(ctx context.Context, accountID int64, id int64) error {
+	query := `DELETE FROM attachments WHERE id=$1 AND account_id=$2;`
+
+	result, err := s.dbpool.Exec(ctx, query, id, accountID)
+	if err != nil {
+		return err
+	}
+
+	if result.RowsAffected() != 1 {
+		return models.ErrNotFound
+	}
+
+	return nil
+}
+
+// AttachmentUsage implements models.Store.
+func (s PostgresStore) AttachmentUsage(ctx context.Context, accountID int64) (int64, error) {
+	query := `SELECT COALESCE(SUM(size), 0) FROM attachments WHERE account_id=$1 AND complete=true;`
+
+	var used int64
+	if err := s.dbpool.QueryRow(ctx, query, accountID).Scan(&used); err != nil {
+		return 0, err
+	}
+
+	return used, nil
+}
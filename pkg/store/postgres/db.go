@@ -2,30 +2,30 @@ package postgres
 
 import (
 	"context"
+	"errors"
+	"slices"
 	"time"
 
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/oalexander6/passman/config"
 	"github.com/oalexander6/passman/pkg/logger"
 	"github.com/oalexander6/passman/pkg/models"
 )
 
+// pgUniqueViolation is the Postgres error code raised when a unique constraint fails.
+const pgUniqueViolation = "23505"
+
 type PostgresStore struct {
 	dbpool *pgxpool.Pool
 }
 
-var accountsSchema = `
-CREATE TABLE IF NOT EXISTS accounts (
-	id         BIGSERIAL PRIMARY KEY,
-	email      TEXT NOT NULL,
-	password   TEXT NOT NULL,
-	name       TEXT NOT NULL,
-	created_at TIMESTAMPTZ NOT NULL,
-	updated_at TIMESTAMPTZ NOT NULL,
-	deleted    BOOLEAN NOT NULL
-);
-`
+const (
+	accountColumns = `id, email, password, name, data_key, index_key, public_key, private_key, vault_mode, kdf_salt, kdf_memory,
+		kdf_iterations, kdf_parallelism, min_password_strength, max_note_versions, session_epoch, created_at, updated_at, deleted`
+	noteColumns = `id, account_id, name, type, value, updated_by, folder_id, item_key, created_at, updated_at, deleted, deleted_at`
+)
 
 func New(opts config.PostgresConfig) *PostgresStore {
 	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
@@ -37,15 +37,13 @@ func New(opts config.PostgresConfig) *PostgresStore {
 	}
 
 	var greeting string
-	err = conn.QueryRow(context.Background(), "SELECT 'Hello, world!'").Scan(&greeting)
+	err = conn.QueryRow(ctx, "SELECT 'Hello, world!'").Scan(&greeting)
 	if err != nil {
 		logger.Log.Fatal().Msgf("Failed to get greeting: %s", err)
 	}
 
 	logger.Log.Debug().Msgf("Got greeting: %s", greeting)
 
-	conn.Exec(context.Background(), accountsSchema)
-
 	return &PostgresStore{
 		dbpool: conn,
 	}
@@ -55,11 +53,96 @@ func (s PostgresStore) Close() {
 	s.dbpool.Close()
 }
 
-// AccountDelete implements models.Store.
-func (s PostgresStore) AccountDelete(ctx context.Context, id int64) error {
-	query := `UPDATE accounts SET deleted=true WHERE id=$1;`
+// mapError converts driver errors into the errors defined by the models package.
+func mapError(err error) error {
+	if errors.Is(err, pgx.ErrNoRows) {
+		return models.ErrNotFound
+	}
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == pgUniqueViolation {
+		return models.ErrAlreadyExists
+	}
+
+	return err
+}
+
+// AccountCreate implements models.Store.
+func (s PostgresStore) AccountCreate(ctx context.Context, account models.Account) (models.Account, error) {
+	query := `INSERT INTO accounts (email, password, name, data_key, vault_mode, kdf_salt, kdf_memory,
+			kdf_iterations, kdf_parallelism, created_at, updated_at, deleted)
+		VALUES (@email, @password, @name, @data_key, @vault_mode, @kdf_salt, @kdf_memory,
+			@kdf_iterations, @kdf_parallelism, @created_at, @updated_at, @deleted)
+		RETURNING ` + accountColumns + `;`
+
+	now := time.Now().UTC()
+	args := pgx.NamedArgs{
+		"email":           account.Email,
+		"password":        account.Password,
+		"name":            account.Name,
+		"data_key":        account.DataKey,
+		"vault_mode":      account.VaultMode,
+		"kdf_salt":        account.Salt,
+		"kdf_memory":      account.Memory,
+		"kdf_iterations":  account.Iterations,
+		"kdf_parallelism": account.Parallelism,
+		"created_at":      now,
+		"updated_at":      now,
+		"deleted":         false,
+	}
+
+	rows, err := s.dbpool.Query(ctx, query, args)
+	if err != nil {
+		return models.Account{}, mapError(err)
+	}
+
+	savedAccount, err := pgx.CollectOneRow(rows, pgx.RowToStructByName[models.Account])
+	if err != nil {
+		return models.Account{}, mapError(err)
+	}
+
+	return savedAccount, nil
+}
+
+// AccountGetByID implements models.Store.
+func (s PostgresStore) AccountGetByID(ctx context.Context, id int64) (models.Account, error) {
+	query := `SELECT ` + accountColumns + ` FROM accounts WHERE id=$1 AND deleted=false;`
+
+	rows, err := s.dbpool.Query(ctx, query, id)
+	if err != nil {
+		return models.Account{}, err
+	}
+
+	account, err := pgx.CollectOneRow(rows, pgx.RowToStructByName[models.Account])
+	if err != nil {
+		return models.Account{}, mapError(err)
+	}
+
+	return account, nil
+}
+
+// AccountGetByEmail implements models.Store.
+func (s PostgresStore) AccountGetByEmail(ctx context.Context, email string) (models.Account, error) {
+	query := `SELECT ` + accountColumns + ` FROM accounts WHERE email=$1 AND deleted=false;`
+
+	rows, err := s.dbpool.Query(ctx, query, email)
+	if err != nil {
+		return models.Account{}, err
+	}
+
+	account, err := pgx.CollectOneRow(rows, pgx.RowToStructByName[models.Account])
+	if err != nil {
+		return models.Account{}, mapError(err)
+	}
+
+	return account, nil
+}
+
+// AccountUpdateDataKey implements models.Store.
+func (s PostgresStore) AccountUpdateDataKey(ctx context.Context, id int64, currentDataKey string, newDataKey string) error {
+	query := `UPDATE accounts SET data_key=$3 WHERE id=$1 AND data_key=$2 AND deleted=false;`
 
-	result, err := s.dbpool.Exec(ctx, query, id)
+	result, err := s.dbpool.Exec(ctx, query, id, currentDataKey, newDataKey)
 	if err != nil {
 		return err
 	}
@@ -71,21 +154,43 @@ func (s PostgresStore) AccountDelete(ctx context.Context, id int64) error {
 	return nil
 }
 
-// AccountGetByEmail implements models.Store.
-func (s PostgresStore) AccountGetByEmail(ctx context.Context, email string) (models.Account, error) {
-	panic("unimplemented")
+// AccountUpdateIndexKey implements models.Store.
+func (s PostgresStore) AccountUpdateIndexKey(ctx context.Context, id int64, currentIndexKey string, newIndexKey string) error {
+	query := `UPDATE accounts SET index_key=$3 WHERE id=$1 AND index_key=$2 AND deleted=false;`
+
+	result, err := s.dbpool.Exec(ctx, query, id, currentIndexKey, newIndexKey)
+	if err != nil {
+		return err
+	}
+
+	if result.RowsAffected() != 1 {
+		return models.ErrNotFound
+	}
+
+	return nil
 }
 
-// NoteCreate implements models.Store.
-func (s PostgresStore) NoteCreate(ctx context.Context, noteInput models.Note) (models.Note, error) {
-	panic("unimplemented")
+// AccountUpdatePolicy implements models.Store.
+func (s PostgresStore) AccountUpdatePolicy(ctx context.Context, id int64, policy models.AccountPolicy) error {
+	query := `UPDATE accounts SET min_password_strength=$2, max_note_versions=$3, updated_at=$4 WHERE id=$1 AND deleted=false;`
+
+	result, err := s.dbpool.Exec(ctx, query, id, policy.MinPasswordStrength, policy.MaxNoteVersions, time.Now().UTC())
+	if err != nil {
+		return err
+	}
+
+	if result.RowsAffected() != 1 {
+		return models.ErrNotFound
+	}
+
+	return nil
 }
 
-// NoteDeleteByID implements models.Store.
-func (s PostgresStore) NoteDeleteByID(ctx context.Context, id int64) error {
-	query := `UPDATE notes SET deleted=true WHERE id=$1;`
+// AccountRevokeSessions implements models.Store.
+func (s PostgresStore) AccountRevokeSessions(ctx context.Context, id int64) error {
+	query := `UPDATE accounts SET session_epoch=session_epoch+1, updated_at=$2 WHERE id=$1 AND deleted=false;`
 
-	result, err := s.dbpool.Exec(ctx, query, id)
+	result, err := s.dbpool.Exec(ctx, query, id, time.Now().UTC())
 	if err != nil {
 		return err
 	}
@@ -97,66 +202,266 @@ func (s PostgresStore) NoteDeleteByID(ctx context.Context, id int64) error {
 	return nil
 }
 
-// NoteGetByAccountID implements models.Store.
-func (s PostgresStore) NoteGetByAccountID(ctx context.Context, userID int64) ([]models.Note, error) {
-	panic("unimplemented")
+// AccountListIDs implements models.Store.
+func (s PostgresStore) AccountListIDs(ctx context.Context, afterID int64, limit int) ([]int64, error) {
+	query := `SELECT id FROM accounts WHERE id>$1 AND deleted=false ORDER BY id LIMIT $2;`
+
+	rows, err := s.dbpool.Query(ctx, query, afterID, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	return pgx.CollectRows(rows, pgx.RowTo[int64])
+}
+
+// AccountDelete implements models.Store.
+func (s PostgresStore) AccountDelete(ctx context.Context, id int64) error {
+	query := `UPDATE accounts SET deleted=true, updated_at=$2 WHERE id=$1 AND deleted=false;`
+
+	result, err := s.dbpool.Exec(ctx, query, id, time.Now().UTC())
+	if err != nil {
+		return err
+	}
+
+	if result.RowsAffected() != 1 {
+		return models.ErrNotFound
+	}
+
+	return nil
+}
+
+// NoteCreate implements models.Store.
+func (s PostgresStore) NoteCreate(ctx context.Context, noteInput models.Note, seal models.NoteSealFunc) (models.Note, error) {
+	tx, err := s.dbpool.Begin(ctx)
+	if err != nil {
+		return models.Note{}, err
+	}
+	defer tx.Rollback(ctx)
+
+	sealedNote, err := createNote(ctx, tx, noteInput, seal)
+	if err != nil {
+		return models.Note{}, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return models.Note{}, err
+	}
+
+	return sealedNote, nil
+}
+
+// createNote inserts the note with its tags and search index, and stores the value seal sets.
+func createNote(ctx context.Context, tx pgx.Tx, noteInput models.Note, seal models.NoteSealFunc) (models.Note, error) {
+	query := `INSERT INTO notes (account_id, name, type, value, updated_by, folder_id, created_at, updated_at, deleted)
+		VALUES (@account_id, @name, @type, @value, @account_id, @folder_id, @created_at, @updated_at, @deleted)
+		RETURNING ` + noteColumns + `;`
+
+	now := time.Now().UTC()
+	args := pgx.NamedArgs{
+		"account_id": noteInput.AccountID,
+		"name":       noteInput.Name,
+		"type":       noteInput.Type,
+		"value":      "",
+		"folder_id":  noteInput.FolderID,
+		"created_at": now,
+		"updated_at": now,
+		"deleted":    false,
+	}
+
+	rows, err := tx.Query(ctx, query, args)
+	if err != nil {
+		return models.Note{}, mapError(err)
+	}
+
+	savedNote, err := pgx.CollectOneRow(rows, pgx.RowToStructByName[models.Note])
+	if err != nil {
+		return models.Note{}, mapError(err)
+	}
+
+	if err := setNoteTags(ctx, tx, savedNote.AccountID, savedNote.ID, noteInput.Tags); err != nil {
+		return models.Note{}, err
+	}
+	savedNote.Tags = noteInput.Tags
+
+	if err := indexNote(ctx, tx, savedNote.ID, noteInput.Search); err != nil {
+		return models.Note{}, err
+	}
+
+	sealedNote, err := seal(savedNote)
+	if err != nil {
+		return models.Note{}, err
+	}
+
+	if _, err := tx.Exec(ctx, `UPDATE notes SET value=$1 WHERE id=$2;`, sealedNote.Value, savedNote.ID); err != nil {
+		return models.Note{}, err
+	}
+
+	return sealedNote, nil
 }
 
 // NoteGetByID implements models.Store.
-func (s PostgresStore) NoteGetByID(ctx context.Context, id int64) (models.Note, error) {
-	query := `SELECT * FROM notes WHERE id=$1 AND deleted=false;`
+func (s PostgresStore) NoteGetByID(ctx context.Context, accountID int64, id int64) (models.Note, error) {
+	query := `SELECT ` + noteColumns + ` FROM notes WHERE id=$1 AND account_id=$2 AND deleted=false;`
 
-	row, err := s.dbpool.Query(ctx, query, id)
+	rows, err := s.dbpool.Query(ctx, query, id, accountID)
 	if err != nil {
 		return models.Note{}, err
 	}
 
-	pgx.RowTo[models.Account](row)
-	account, err := pgx.CollectOneRow(row, pgx.RowToStructByName[models.Account])
+	note, err := pgx.CollectOneRow(rows, pgx.RowToStructByName[models.Note])
 	if err != nil {
-		return models.Account{}, models.ErrNotFound
+		return models.Note{}, mapError(err)
 	}
 
-	return account, nil
+	notes := []models.Note{note}
+	if err := s.loadNoteTags(ctx, accountID, notes); err != nil {
+		return models.Note{}, err
+	}
+
+	return notes[0], nil
 }
 
-// NoteUpdate implements models.Store.
-func (s PostgresStore) NoteUpdate(ctx context.Context, note models.Note) (models.Note, error) {
-	panic("unimplemented")
+// NoteGetByAccountID implements models.Store.
+func (s PostgresStore) NoteGetByAccountID(ctx context.Context, accountID int64, filter models.NoteFilter) ([]models.Note, error) {
+	query := `SELECT ` + noteColumns + ` FROM notes WHERE account_id=@account_id AND deleted=false`
+	args := pgx.NamedArgs{"account_id": accountID}
+
+	if len(filter.FolderIDs) > 0 {
+		query += ` AND (folder_id = ANY(@folder_ids) OR (@unfiled AND folder_id IS NULL))`
+		args["folder_ids"] = filter.FolderIDs
+		args["unfiled"] = slices.Contains(filter.FolderIDs, 0)
+	}
+
+	if len(filter.Tags) > 0 {
+		query += ` AND id IN (SELECT nt.note_id FROM note_tags nt JOIN tags t ON t.id=nt.tag_id
+			WHERE t.account_id=@account_id AND t.name = ANY(@tags)
+			GROUP BY nt.note_id HAVING COUNT(*)=@tag_count)`
+		args["tags"] = filter.Tags
+		args["tag_count"] = len(filter.Tags)
+	}
+
+	rows, err := s.dbpool.Query(ctx, query+` ORDER BY id;`, args)
+	if err != nil {
+		return []models.Note{}, err
+	}
+
+	notes, err := pgx.CollectRows(rows, pgx.RowToStructByName[models.Note])
+	if err != nil {
+		return []models.Note{}, err
+	}
+
+	if err := s.loadNoteTags(ctx, accountID, notes); err != nil {
+		return []models.Note{}, err
+	}
+
+	return notes, nil
 }
 
-// AccountCreate implements models.Store.
-func (s PostgresStore) AccountCreate(ctx context.Context, account models.Account) (models.Account, error) {
-	query := `INSERT INTO accounts (email, password, name, created_at, updated_at, delete) VALUES (@email, @password, @name, @created_at, @updated_at, @delete);`
+// NoteUpdate implements models.Store.
+func (s PostgresStore) NoteUpdate(ctx context.Context, accountID int64, note models.Note, keepVersions int) (models.Note, error) {
+	query := `UPDATE notes SET name=@name, type=@type, value=@value, updated_by=@updated_by, folder_id=@folder_id,
+			updated_at=@updated_at
+		WHERE id=@id AND account_id=@account_id AND deleted=false AND item_key=@item_key
+		RETURNING ` + noteColumns + `;`
 
 	args := pgx.NamedArgs{
-		"email":      account.Email,
-		"password":   account.Password,
-		"name":       account.Name,
-		"created_at": time.Now().UTC().Format(time.RFC3339),
-		"updated_at": time.Now().UTC().Format(time.RFC3339),
-		"deleted":    false,
+		"id":         note.ID,
+		"account_id": accountID,
+		"name":       note.Name,
+		"type":       note.Type,
+		"value":      note.Value,
+		"updated_by": note.UpdatedBy,
+		"folder_id":  note.FolderID,
+		"item_key":   note.ItemKey,
+		"updated_at": time.Now().UTC(),
+	}
+
+	tx, err := s.dbpool.Begin(ctx)
+	if err != nil {
+		return models.Note{}, err
+	}
+	defer tx.Rollback(ctx)
+
+	if keepVersions > 0 {
+		// the row lock taken here keeps concurrent updates from saving the same version twice
+		if _, err := tx.Exec(ctx, `INSERT INTO note_versions (note_id, account_id, name, type, value, updated_by, updated_at, item_key)
+			SELECT id, account_id, name, type, value, updated_by, updated_at, item_key FROM notes
+			WHERE id=$1 AND account_id=$2 AND deleted=false FOR UPDATE;`, note.ID, accountID); err != nil {
+			return models.Note{}, err
+		}
+	}
+
+	rows, err := tx.Query(ctx, query, args)
+	if err != nil {
+		return models.Note{}, err
+	}
+
+	savedNote, err := pgx.CollectOneRow(rows, pgx.RowToStructByName[models.Note])
+	if err != nil {
+		return models.Note{}, mapError(err)
 	}
 
-	s.dbpool.Exec(ctx, query, args)
+	if err := setNoteTags(ctx, tx, accountID, note.ID, note.Tags); err != nil {
+		return models.Note{}, err
+	}
+	savedNote.Tags = note.Tags
 
-	return models.Account{}, nil
+	if err := indexNote(ctx, tx, note.ID, note.Search); err != nil {
+		return models.Note{}, err
+	}
+
+	if _, err := tx.Exec(ctx, `DELETE FROM note_versions WHERE note_id=$1 AND account_id=$2 AND id NOT IN (
+		SELECT id FROM note_versions WHERE note_id=$1 ORDER BY id DESC LIMIT $3);`, note.ID, accountID, keepVersions); err != nil {
+		return models.Note{}, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return models.Note{}, err
+	}
+
+	return savedNote, nil
 }
 
-// AccountGetByID implements models.Store.
-func (s PostgresStore) AccountGetByID(ctx context.Context, id int64) (models.Account, error) {
-	query := `SELECT * FROM accounts WHERE id=$1 AND deleted=false;`
+// NoteDeleteByID implements models.Store.
+func (s PostgresStore) NoteDeleteByID(ctx context.Context, accountID int64, id int64) error {
+	query := `UPDATE notes SET deleted=true, updated_at=$3, deleted_at=$3 WHERE id=$1 AND account_id=$2 AND deleted=false;`
 
-	row, err := s.dbpool.Query(ctx, query, id)
+	result, err := s.dbpool.Exec(ctx, query, id, accountID, time.Now().UTC())
 	if err != nil {
-		return models.Account{}, err
+		return err
 	}
 
-	pgx.RowTo[models.Account](row)
-	account, err := pgx.CollectOneRow(row, pgx.RowToStructByName[models.Account])
+	if result.RowsAffected() != 1 {
+		return models.ErrNotFound
+	}
+
+	return nil
+}
+
+// NoteGetAllByAccountID implements models.Store.
+func (s PostgresStore) NoteGetAllByAccountID(ctx context.Context, accountID int64) ([]models.Note, error) {
+	query := `SELECT ` + noteColumns + ` FROM notes WHERE account_id=$1 ORDER BY id;`
+
+	rows, err := s.dbpool.Query(ctx, query, accountID)
 	if err != nil {
-		return models.Account{}, models.ErrNotFound
+		return []models.Note{}, err
 	}
 
-	return account, nil
+	return pgx.CollectRows(rows, pgx.RowToStructByName[models.Note])
+}
+
+// NoteUpdateValue implements models.Store.
+func (s PostgresStore) NoteUpdateValue(ctx context.Context, accountID int64, id int64, currentValue string, newValue string) error {
+	query := `UPDATE notes SET value=$4 WHERE id=$1 AND account_id=$2 AND value=$3;`
+
+	result, err := s.dbpool.Exec(ctx, query, id, accountID, currentValue, newValue)
+	if err != nil {
+		return err
+	}
+
+	if result.RowsAffected() != 1 {
+		return models.ErrNotFound
+	}
+
+	return nil
 }
@@ -0,0 +1,200 @@
+package postgres
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/oalexander6/passman/pkg/models"
+)
+
+const folderColumns = `id, account_id, parent_id, name, created_at, updated_at`
+
+const folderInsertQuery = `INSERT INTO folders (account_id, parent_id, name, created_at, updated_at)
+	VALUES ($1, $2, $3, $4, $4)
+	RETURNING ` + folderColumns + `;`
+
+// FolderCreate implements models.Store.
+func (s PostgresStore) FolderCreate(ctx context.Context, folder models.Folder) (models.Folder, error) {
+	rows, err := s.dbpool.Query(ctx, folderInsertQuery, folder.AccountID, folder.ParentID, folder.Name, time.Now().UTC())
+	if err != nil {
+		return models.Folder{}, mapError(err)
+	}
+
+	savedFolder, err := pgx.CollectOneRow(rows, pgx.RowToStructByName[models.Folder])
+	if err != nil {
+		return models.Folder{}, mapError(err)
+	}
+
+	return savedFolder, nil
+}
+
+// FolderGetByID implements models.Store.
+func (s PostgresStore) FolderGetByID(ctx context.Context, accountID int64, id int64) (models.Folder, error) {
+	query := `SELECT ` + folderColumns + ` FROM folders WHERE id=$1 AND account_id=$2;`
+
+	rows, err := s.dbpool.Query(ctx, query, id, accountID)
+	if err != nil {
+		return models.Folder{}, err
+	}
+
+	folder, err := pgx.CollectOneRow(rows, pgx.RowToStructByName[models.Folder])
+	if err != nil {
+		return models.Folder{}, mapError(err)
+	}
+
+	return folder, nil
+}
+
+// FolderGetByAccountID implements models.Store.
+func (s PostgresStore) FolderGetByAccountID(ctx context.Context, accountID int64) ([]models.Folder, error) {
+	query := `SELECT ` + folderColumns + ` FROM folders WHERE account_id=$1 ORDER BY name, id;`
+
+	rows, err := s.dbpool.Query(ctx, query, accountID)
+	if err != nil {
+		return []models.Folder{}, err
+	}
+
+	folders, err := pgx.CollectRows(rows, pgx.RowToStructByName[models.Folder])
+	if err != nil {
+		return []models.Folder{}, err
+	}
+
+	return folders, nil
+}
+
+// FolderUpdate implements models.Store.
+func (s PostgresStore) FolderUpdate(ctx context.Context, folder models.Folder) (models.Folder, error) {
+	query := `UPDATE folders SET parent_id=$3, name=$4, updated_at=$5 WHERE id=$1 AND account_id=$2
+		RETURNING ` + folderColumns + `;`
+
+	tx, err := s.dbpool.Begin(ctx)
+	if err != nil {
+		return models.Folder{}, err
+	}
+	defer tx.Rollback(ctx)
+
+	rows, err := tx.Query(ctx, query, folder.ID, folder.AccountID, folder.ParentID, folder.Name, time.Now().UTC())
+	if err != nil {
+		return models.Folder{}, mapError(err)
+	}
+
+	savedFolder, err := pgx.CollectOneRow(rows, pgx.RowToStructByName[models.Folder])
+	if err != nil {
+		return models.Folder{}, mapError(err)
+	}
+
+	noteIDs, err := folderNoteIDs(ctx, tx, folder.AccountID, []int64{folder.ID})
+	if err != nil {
+		return models.Folder{}, err
+	}
+
+	if err := reindexNotes(ctx, tx, noteIDs); err != nil {
+		return models.Folder{}, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return models.Folder{}, err
+	}
+
+	return savedFolder, nil
+}
+
+// FolderDelete implements models.Store.
+func (s PostgresStore) FolderDelete(ctx context.Context, accountID int64, id int64) error {
+	tx, err := s.dbpool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	rows, err := tx.Query(ctx, `SELECT `+folderColumns+` FROM folders WHERE id=$1 AND account_id=$2 FOR UPDATE;`, id, accountID)
+	if err != nil {
+		return err
+	}
+
+	folder, err := pgx.CollectOneRow(rows, pgx.RowToStructByName[models.Folder])
+	if err != nil {
+		return mapError(err)
+	}
+
+	noteIDs, err := folderNoteIDs(ctx, tx, accountID, []int64{id})
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(ctx, `UPDATE notes SET folder_id=$3 WHERE folder_id=$1 AND account_id=$2;`,
+		id, accountID, folder.ParentID); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(ctx, `UPDATE folders SET parent_id=$3, updated_at=$4 WHERE parent_id=$1 AND account_id=$2;`,
+		id, accountID, folder.ParentID, time.Now().UTC()); err != nil {
+		return mapError(err)
+	}
+
+	if _, err := tx.Exec(ctx, `DELETE FROM folders WHERE id=$1 AND account_id=$2;`, id, accountID); err != nil {
+		return err
+	}
+
+	if err := reindexNotes(ctx, tx, noteIDs); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// FolderDeleteTree implements models.Store.
+func (s PostgresStore) FolderDeleteTree(ctx context.Context, accountID int64, ids []int64) error {
+	tx, err := s.dbpool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	noteIDs, err := folderNoteIDs(ctx, tx, accountID, ids)
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(ctx, `UPDATE notes SET deleted=true, updated_at=$3, deleted_at=$3
+		WHERE account_id=$1 AND folder_id = ANY($2) AND deleted=false;`, accountID, ids, time.Now().UTC()); err != nil {
+		return err
+	}
+
+	statements := []string{
+		`UPDATE notes SET folder_id=NULL WHERE account_id=$1 AND folder_id = ANY($2);`,
+		`UPDATE folders SET parent_id=NULL WHERE account_id=$1 AND id = ANY($2);`,
+	}
+
+	for _, statement := range statements {
+		if _, err := tx.Exec(ctx, statement, accountID, ids); err != nil {
+			return err
+		}
+	}
+
+	result, err := tx.Exec(ctx, `DELETE FROM folders WHERE account_id=$1 AND id = ANY($2);`, accountID, ids)
+	if err != nil {
+		return err
+	}
+
+	if result.RowsAffected() != int64(len(ids)) {
+		return models.ErrNotFound
+	}
+
+	if err := reindexNotes(ctx, tx, noteIDs); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// folderNoteIDs returns the IDs of the account's notes in the folders, including deleted notes.
+func folderNoteIDs(ctx context.Context, tx pgx.Tx, accountID int64, folderIDs []int64) ([]int64, error) {
+	rows, err := tx.Query(ctx, `SELECT id FROM notes WHERE account_id=$1 AND folder_id = ANY($2);`, accountID, folderIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	return pgx.CollectRows(rows, pgx.RowTo[int64])
+}
@@ -0,0 +1,64 @@
+package postgres
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/oalexander6/passman/pkg/models"
+)
+
+const keyRotationColumns = `id, key_id, last_account_id, started_at, updated_at, completed_at`
+
+// KeyRotationGetIncomplete implements models.Store.
+func (s PostgresStore) KeyRotationGetIncomplete(ctx context.Context, keyID string) (models.KeyRotation, error) {
+	query := `SELECT ` + keyRotationColumns + ` FROM key_rotations
+		WHERE key_id=$1 AND completed_at IS NULL ORDER BY id DESC LIMIT 1;`
+
+	rows, err := s.dbpool.Query(ctx, query, keyID)
+	if err != nil {
+		return models.KeyRotation{}, err
+	}
+
+	rotation, err := pgx.CollectOneRow(rows, pgx.RowToStructByName[models.KeyRotation])
+	if err != nil {
+		return models.KeyRotation{}, mapError(err)
+	}
+
+	return rotation, nil
+}
+
+// KeyRotationCreate implements models.Store.
+func (s PostgresStore) KeyRotationCreate(ctx context.Context, keyID string) (models.KeyRotation, error) {
+	query := `INSERT INTO key_rotations (key_id, last_account_id, started_at, updated_at)
+		VALUES ($1, 0, $2, $2)
+		RETURNING ` + keyRotationColumns + `;`
+
+	rows, err := s.dbpool.Query(ctx, query, keyID, time.Now().UTC())
+	if err != nil {
+		return models.KeyRotation{}, err
+	}
+
+	rotation, err := pgx.CollectOneRow(rows, pgx.RowToStructByName[models.KeyRotation])
+	if err != nil {
+		return models.KeyRotation{}, mapError(err)
+	}
+
+	return rotation, nil
+}
+
+// KeyRotationUpdate implements models.Store.
+func (s PostgresStore) KeyRotationUpdate(ctx context.Context, rotation models.KeyRotation) error {
+	query := `UPDATE key_rotations SET last_account_id=$2, updated_at=$3, completed_at=$4 WHERE id=$1;`
+
+	result, err := s.dbpool.Exec(ctx, query, rotation.ID, rotation.LastAccountID, time.Now().UTC(), rotation.CompletedAt)
+	if err != nil {
+		return err
+	}
+
+	if result.RowsAffected() != 1 {
+		return models.ErrNotFound
+	}
+
+	return nil
+}
@@ -0,0 +1,134 @@
+package postgres
+
+import (
+	"context"
+	"embed"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/oalexander6/passman/pkg/store/migrate"
+)
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// migrationLockID is the pg_advisory_lock key held while migrations run, so that several
+// instances starting at once apply each migration exactly once.
+const migrationLockID int64 = 0x706173736d616e // "passman"
+
+var errNotLocked = errors.New("migration lock is not held")
+
+var migrationsTableSchema = `
+CREATE TABLE IF NOT EXISTS schema_migrations (
+	version    BIGINT PRIMARY KEY,
+	name       TEXT NOT NULL,
+	applied_at TIMESTAMPTZ NOT NULL
+);
+`
+
+// Migrator returns a migrator for the embedded Postgres migrations.
+func (s PostgresStore) Migrator() (*migrate.Migrator, error) {
+	migrations, err := migrate.Load(migrationFiles, "migrations")
+	if err != nil {
+		return nil, err
+	}
+
+	return migrate.New(&migrationDriver{pool: s.dbpool}, migrations), nil
+}
+
+// migrationDriver implements migrate.Driver using a session level advisory lock held on a
+// single pooled connection.
+type migrationDriver struct {
+	pool *pgxpool.Pool
+	conn *pgxpool.Conn
+}
+
+// Lock implements migrate.Driver.
+func (d *migrationDriver) Lock(ctx context.Context) error {
+	conn, err := d.pool.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+
+	if _, err := conn.Exec(ctx, `SELECT pg_advisory_lock($1);`, migrationLockID); err != nil {
+		conn.Release()
+		return err
+	}
+
+	d.conn = conn
+
+	if _, err := conn.Exec(ctx, migrationsTableSchema); err != nil {
+		d.Unlock(ctx)
+		return err
+	}
+
+	return nil
+}
+
+// Unlock implements migrate.Driver.
+func (d *migrationDriver) Unlock(ctx context.Context) error {
+	if d.conn == nil {
+		return errNotLocked
+	}
+
+	defer func() {
+		d.conn.Release()
+		d.conn = nil
+	}()
+
+	_, err := d.conn.Exec(ctx, `SELECT pg_advisory_unlock($1);`, migrationLockID)
+	return err
+}
+
+// Applied implements migrate.Driver.
+func (d *migrationDriver) Applied(ctx context.Context) ([]migrate.AppliedMigration, error) {
+	if d.conn == nil {
+		return nil, errNotLocked
+	}
+
+	rows, err := d.conn.Query(ctx, `SELECT version, applied_at FROM schema_migrations ORDER BY version;`)
+	if err != nil {
+		return nil, err
+	}
+
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (migrate.AppliedMigration, error) {
+		var applied migrate.AppliedMigration
+		err := row.Scan(&applied.Version, &applied.AppliedAt)
+		return applied, err
+	})
+}
+
+// Apply implements migrate.Driver.
+func (d *migrationDriver) Apply(ctx context.Context, migration migrate.Migration, up bool) error {
+	if d.conn == nil {
+		return errNotLocked
+	}
+
+	tx, err := d.conn.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if up {
+		if _, err := tx.Exec(ctx, migration.Up); err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(ctx, `INSERT INTO schema_migrations (version, name, applied_at) VALUES ($1, $2, $3);`,
+			migration.Version, migration.Name, time.Now().UTC())
+	} else {
+		if _, err := tx.Exec(ctx, migration.Down); err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(ctx, `DELETE FROM schema_migrations WHERE version=$1;`, migration.Version)
+	}
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
@@ -0,0 +1,23 @@
+CREATE TABLE IF NOT EXISTS accounts (
+	id         BIGSERIAL PRIMARY KEY,
+	email      TEXT NOT NULL,
+	password   TEXT NOT NULL,
+	name       TEXT NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL,
+	updated_at TIMESTAMPTZ NOT NULL,
+	deleted    BOOLEAN NOT NULL
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS accounts_email_idx ON accounts (email);
+
+CREATE TABLE IF NOT EXISTS notes (
+	id         BIGSERIAL PRIMARY KEY,
+	account_id BIGINT NOT NULL REFERENCES accounts (id),
+	name       TEXT NOT NULL,
+	value      TEXT NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL,
+	updated_at TIMESTAMPTZ NOT NULL,
+	deleted    BOOLEAN NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS notes_account_id_idx ON notes (account_id);
@@ -0,0 +1,8 @@
+CREATE TABLE key_rotations (
+	id              BIGSERIAL PRIMARY KEY,
+	key_id          TEXT NOT NULL,
+	last_account_id BIGINT NOT NULL,
+	started_at      TIMESTAMPTZ NOT NULL,
+	updated_at      TIMESTAMPTZ NOT NULL,
+	completed_at    TIMESTAMPTZ
+);
@@ -0,0 +1,6 @@
+-- Zero-knowledge accounts can no longer log in once this is reverted.
+ALTER TABLE accounts DROP COLUMN kdf_parallelism;
+ALTER TABLE accounts DROP COLUMN kdf_iterations;
+ALTER TABLE accounts DROP COLUMN kdf_memory;
+ALTER TABLE accounts DROP COLUMN kdf_salt;
+ALTER TABLE accounts DROP COLUMN vault_mode;
@@ -0,0 +1,5 @@
+ALTER TABLE accounts ADD COLUMN vault_mode TEXT NOT NULL DEFAULT 'server';
+ALTER TABLE accounts ADD COLUMN kdf_salt TEXT NOT NULL DEFAULT '';
+ALTER TABLE accounts ADD COLUMN kdf_memory INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE accounts ADD COLUMN kdf_iterations INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE accounts ADD COLUMN kdf_parallelism INTEGER NOT NULL DEFAULT 0;
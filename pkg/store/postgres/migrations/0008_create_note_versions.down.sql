@@ -0,0 +1,4 @@
+-- Previous versions of notes are lost once this is reverted.
+DROP TABLE note_versions;
+ALTER TABLE accounts DROP COLUMN max_note_versions;
+ALTER TABLE notes DROP COLUMN updated_by;
@@ -0,0 +1,6 @@
+-- Notes deleted before the trash was introduced have not been updated since, so they were
+-- deleted when they were last updated.
+ALTER TABLE notes ADD COLUMN deleted_at TIMESTAMPTZ;
+UPDATE notes SET deleted_at=updated_at WHERE deleted=true;
+
+CREATE INDEX notes_deleted_at_idx ON notes (deleted_at);
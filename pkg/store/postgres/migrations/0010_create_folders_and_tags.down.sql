@@ -0,0 +1,5 @@
+DROP TABLE note_tags;
+DROP TABLE tags;
+DROP INDEX notes_folder_id_idx;
+ALTER TABLE notes DROP COLUMN folder_id;
+DROP TABLE folders;
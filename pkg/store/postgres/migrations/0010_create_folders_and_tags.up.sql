@@ -0,0 +1,32 @@
+-- Folders nest through parent_id, and folders without a parent are at the top of the vault.
+CREATE TABLE folders (
+	id         BIGSERIAL PRIMARY KEY,
+	account_id BIGINT NOT NULL REFERENCES accounts (id),
+	parent_id  BIGINT REFERENCES folders (id),
+	name       TEXT NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL,
+	updated_at TIMESTAMPTZ NOT NULL
+);
+
+-- Folder names are unique among their siblings, including top level folders.
+CREATE UNIQUE INDEX folders_parent_name_idx ON folders (account_id, COALESCE(parent_id, 0), name);
+
+ALTER TABLE notes ADD COLUMN folder_id BIGINT REFERENCES folders (id);
+CREATE INDEX notes_folder_id_idx ON notes (folder_id);
+
+CREATE TABLE tags (
+	id         BIGSERIAL PRIMARY KEY,
+	account_id BIGINT NOT NULL REFERENCES accounts (id),
+	name       TEXT NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE UNIQUE INDEX tags_account_name_idx ON tags (account_id, name);
+
+CREATE TABLE note_tags (
+	note_id BIGINT NOT NULL REFERENCES notes (id),
+	tag_id  BIGINT NOT NULL REFERENCES tags (id),
+	PRIMARY KEY (note_id, tag_id)
+);
+
+CREATE INDEX note_tags_tag_id_idx ON note_tags (tag_id);
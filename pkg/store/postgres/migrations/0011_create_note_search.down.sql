@@ -0,0 +1,2 @@
+-- pg_trgm is left installed, since other database objects may have come to rely on it.
+DROP TABLE note_search;
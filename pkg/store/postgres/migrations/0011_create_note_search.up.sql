@@ -0,0 +1,21 @@
+-- pg_trgm matches misspelled words in fuzzy searches. It is a trusted extension, so the owner of
+-- the database can create it.
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+-- The search index holds one row per note. Every column is stored as lowercase words separated
+-- by spaces. Only names, tags and folders are indexed, which are stored in plaintext anyway;
+-- usernames and URLs are encrypted, so they are never copied here. Notes without a row are
+-- indexed by the server in the background.
+CREATE TABLE note_search (
+	note_id    BIGINT PRIMARY KEY REFERENCES notes (id),
+	account_id BIGINT NOT NULL REFERENCES accounts (id),
+	name       TEXT NOT NULL,
+	tags       TEXT NOT NULL,
+	folder     TEXT NOT NULL,
+	content    TEXT GENERATED ALWAYS AS (name || ' ' || tags || ' ' || folder) STORED,
+	document   TSVECTOR GENERATED ALWAYS AS (to_tsvector('simple', name || ' ' || tags || ' ' || folder)) STORED
+);
+
+CREATE INDEX note_search_account_id_idx ON note_search (account_id);
+CREATE INDEX note_search_document_idx ON note_search USING GIN (document);
+CREATE INDEX note_search_content_idx ON note_search USING GIN (content gin_trgm_ops);
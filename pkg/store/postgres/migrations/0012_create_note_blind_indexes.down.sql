@@ -0,0 +1,2 @@
+DROP TABLE note_blind_indexes;
+ALTER TABLE accounts DROP COLUMN index_key;
@@ -0,0 +1,20 @@
+-- Blind indexes are keyed with a key of their own, wrapped by the master key like data_key.
+ALTER TABLE accounts ADD COLUMN index_key TEXT NOT NULL DEFAULT '';
+
+-- A blind index holds keyed hashes of one value of a note's field, so that it can be matched
+-- without being stored. position numbers the values of a note, so that the n-grams of a
+-- substring search must all come from the same value.
+CREATE TABLE note_blind_indexes (
+	note_id    BIGINT NOT NULL REFERENCES notes (id),
+	account_id BIGINT NOT NULL REFERENCES accounts (id),
+	field      TEXT NOT NULL,
+	position   INTEGER NOT NULL,
+	kind       TEXT NOT NULL,
+	token      TEXT NOT NULL,
+	PRIMARY KEY (note_id, position, kind, token)
+);
+
+CREATE INDEX note_blind_indexes_token_idx ON note_blind_indexes (account_id, field, token);
+
+-- Notes are indexed again by the server in the background, which adds their blind indexes.
+DELETE FROM note_search;
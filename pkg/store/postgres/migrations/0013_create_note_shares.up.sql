@@ -0,0 +1,25 @@
+-- Each account has an X25519 key pair for receiving shared notes. The private key is encrypted
+-- with the account's data key.
+ALTER TABLE accounts ADD COLUMN public_key TEXT NOT NULL DEFAULT '';
+ALTER TABLE accounts ADD COLUMN private_key TEXT NOT NULL DEFAULT '';
+
+-- A shared note's value is encrypted with an item key of its own, which is encrypted with the
+-- owner's data key. Versions keep the item key their value was encrypted with. Notes that have
+-- never been shared have none, and are encrypted with the data key.
+ALTER TABLE notes ADD COLUMN item_key TEXT NOT NULL DEFAULT '';
+ALTER TABLE note_versions ADD COLUMN item_key TEXT NOT NULL DEFAULT '';
+
+-- A share gives the recipient the note's item key, encrypted to their public key.
+CREATE TABLE note_shares (
+	id            BIGSERIAL PRIMARY KEY,
+	note_id       BIGINT NOT NULL REFERENCES notes (id),
+	owner_id      BIGINT NOT NULL REFERENCES accounts (id),
+	recipient_id  BIGINT NOT NULL REFERENCES accounts (id),
+	permission    TEXT NOT NULL,
+	encrypted_key TEXT NOT NULL,
+	created_at    TIMESTAMPTZ NOT NULL,
+	updated_at    TIMESTAMPTZ NOT NULL,
+	UNIQUE (note_id, recipient_id)
+);
+
+CREATE INDEX note_shares_recipient_id_idx ON note_shares (recipient_id);
@@ -0,0 +1,3 @@
+-- Every session cookie records the account's session epoch when it was issued, and is only
+-- accepted while the epoch is unchanged. Logging out increments it, which ends every session.
+ALTER TABLE accounts ADD COLUMN session_epoch BIGINT NOT NULL DEFAULT 0;
@@ -0,0 +1,200 @@
+package postgres
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/oalexander6/passman/pkg/models"
+)
+
+// NoteSearch implements models.Store. A word of a term must be the start of a word, and with
+// fuzzy matching it may instead be close enough to a word by pg_trgm's word similarity.
+func (s PostgresStore) NoteSearch(ctx context.Context, accountID int64, query models.NoteSearchQuery) ([]models.Note, error) {
+	q := `SELECT ` + noteColumns + ` FROM notes WHERE account_id=@account_id AND deleted=false`
+	args := pgx.NamedArgs{"account_id": accountID, "limit": query.Limit}
+
+	for i, term := range query.Terms {
+		q += ` AND ` + searchTerm(strconv.Itoa(i), term, query.Fuzzy, args)
+	}
+
+	if query.AfterID > 0 {
+		q += ` AND (name>@after_name OR (name=@after_name AND id>@after_id))`
+		args["after_name"] = query.AfterName
+		args["after_id"] = query.AfterID
+	}
+
+	rows, err := s.dbpool.Query(ctx, q+` ORDER BY name, id LIMIT @limit;`, args)
+	if err != nil {
+		return []models.Note{}, err
+	}
+
+	notes, err := pgx.CollectRows(rows, pgx.RowToStructByName[models.Note])
+	if err != nil {
+		return []models.Note{}, err
+	}
+
+	if err := s.loadNoteTags(ctx, accountID, notes); err != nil {
+		return []models.Note{}, err
+	}
+
+	return notes, nil
+}
+
+// NoteGetUnindexed implements models.Store.
+func (s PostgresStore) NoteGetUnindexed(ctx context.Context, limit int) ([]models.Note, error) {
+	query := `SELECT ` + noteColumns + ` FROM notes n
+		WHERE NOT EXISTS (SELECT 1 FROM note_search s WHERE s.note_id=n.id) ORDER BY id LIMIT $1;`
+
+	rows, err := s.dbpool.Query(ctx, query, limit)
+	if err != nil {
+		return []models.Note{}, err
+	}
+
+	return pgx.CollectRows(rows, pgx.RowToStructByName[models.Note])
+}
+
+// NoteSearchIndex implements models.Store.
+func (s PostgresStore) NoteSearchIndex(ctx context.Context, accountID int64, id int64, fields models.NoteSearchFields, currentValue string) error {
+	tx, err := s.dbpool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	// the row lock keeps the value from changing before the note is indexed
+	result, err := tx.Exec(ctx, `SELECT 1 FROM notes WHERE id=$1 AND account_id=$2 AND value=$3 FOR UPDATE;`,
+		id, accountID, currentValue)
+	if err != nil {
+		return err
+	}
+
+	if result.RowsAffected() != 1 {
+		return models.ErrNotFound
+	}
+
+	if err := indexNote(ctx, tx, id, &fields); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// searchTerm returns the condition for notes matching the term, adding its arguments to args
+// with names ending in suffix.
+func searchTerm(suffix string, term models.NoteSearchTerm, fuzzy bool, args pgx.NamedArgs) string {
+	alternatives := []string{}
+
+	if term.Word != "" {
+		match := `document @@ to_tsquery('simple', @prefix` + suffix + `)`
+		args["prefix"+suffix] = term.Word + ":*"
+
+		if fuzzy {
+			match += ` OR content %> @word` + suffix
+			args["word"+suffix] = term.Word
+		}
+
+		alternatives = append(alternatives, `id IN (SELECT note_id FROM note_search WHERE account_id=@account_id AND (`+match+`))`)
+	}
+
+	for i, blind := range term.Blind {
+		name := suffix + "_" + strconv.Itoa(i)
+		args["field"+name] = blind.Field
+
+		if blind.Exact != "" {
+			alternatives = append(alternatives, `id IN (SELECT note_id FROM note_blind_indexes
+				WHERE account_id=@account_id AND field=@field`+name+` AND kind='exact' AND token=@exact`+name+`)`)
+			args["exact"+name] = blind.Exact
+			continue
+		}
+
+		// every n-gram must come from the same value of the field
+		alternatives = append(alternatives, `id IN (SELECT note_id FROM note_blind_indexes
+			WHERE account_id=@account_id AND field=@field`+name+` AND kind='ngram' AND token=ANY(@ngrams`+name+`)
+			GROUP BY note_id, position HAVING COUNT(DISTINCT token)=@count`+name+`)`)
+		args["ngrams"+name] = blind.Ngrams
+		args["count"+name] = len(blind.Ngrams)
+	}
+
+	return `(` + strings.Join(alternatives, ` OR `) + `)`
+}
+
+// indexNote replaces the note's row in the search index, and its blind indexes unless fields
+// is nil. Nil fields also leaves a note that isn't indexed yet for the background indexer.
+func indexNote(ctx context.Context, tx pgx.Tx, noteID int64, fields *models.NoteSearchFields) error {
+	if fields == nil {
+		var indexed bool
+		if err := tx.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM note_search WHERE note_id=$1);`, noteID).Scan(&indexed); err != nil {
+			return err
+		}
+		if !indexed {
+			return nil
+		}
+	}
+
+	// the names of zero-knowledge notes are encrypted, so they aren't worth indexing
+	var (
+		accountID    int64
+		name, folder string
+	)
+	if err := tx.QueryRow(ctx, `SELECT n.account_id, CASE WHEN a.vault_mode=$2 THEN '' ELSE n.name END, COALESCE(f.name, '')
+		FROM notes n JOIN accounts a ON a.id=n.account_id LEFT JOIN folders f ON f.id=n.folder_id WHERE n.id=$1;`,
+		noteID, models.VaultModeZeroKnowledge).Scan(&accountID, &name, &folder); err != nil {
+		return mapError(err)
+	}
+
+	rows, err := tx.Query(ctx, `SELECT t.name FROM note_tags nt JOIN tags t ON t.id=nt.tag_id WHERE nt.note_id=$1;`, noteID)
+	if err != nil {
+		return err
+	}
+
+	tags, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(ctx, `INSERT INTO note_search (note_id, account_id, name, tags, folder) VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (note_id) DO UPDATE SET name=EXCLUDED.name, tags=EXCLUDED.tags, folder=EXCLUDED.folder;`,
+		noteID, accountID, models.SearchText(name), models.SearchText(tags...), models.SearchText(folder)); err != nil {
+		return err
+	}
+
+	if fields == nil {
+		return nil
+	}
+
+	return setBlindIndexes(ctx, tx, noteID, accountID, fields.Indexes)
+}
+
+// setBlindIndexes replaces the blind indexes of the note.
+func setBlindIndexes(ctx context.Context, tx pgx.Tx, noteID int64, accountID int64, indexes []models.BlindIndex) error {
+	if _, err := tx.Exec(ctx, `DELETE FROM note_blind_indexes WHERE note_id=$1;`, noteID); err != nil {
+		return err
+	}
+
+	for position, index := range indexes {
+		// clients may send an n-gram token more than once
+		_, err := tx.Exec(ctx, `INSERT INTO note_blind_indexes (note_id, account_id, field, position, kind, token)
+			SELECT $1::BIGINT, $2::BIGINT, $3::TEXT, $4::INTEGER, 'exact', $5::TEXT
+			UNION ALL SELECT $1, $2, $3, $4, 'ngram', UNNEST($6::TEXT[])
+			ON CONFLICT DO NOTHING;`, noteID, accountID, index.Field, position, index.Exact, index.Ngrams)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// reindexNotes refreshes the search index rows of the notes, such as after the name of their
+// folder or one of their tags has changed.
+func reindexNotes(ctx context.Context, tx pgx.Tx, noteIDs []int64) error {
+	for _, noteID := range noteIDs {
+		if err := indexNote(ctx, tx, noteID, nil); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
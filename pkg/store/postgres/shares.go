@@ -0,0 +1,245 @@
+package postgres
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/oalexander6/passman/pkg/models"
+)
+
+// shareQuery selects shares along with the emails of their owner and recipient.
+const shareQuery = `SELECT s.id, s.note_id, s.owner_id, s.recipient_id, s.permission, s.encrypted_key,
+		o.email AS owner_email, r.email AS recipient_email, s.created_at, s.updated_at
+	FROM note_shares s JOIN accounts o ON o.id=s.owner_id JOIN accounts r ON r.id=s.recipient_id`
+
+// AccountSetKeyPair implements models.Store.
+func (s PostgresStore) AccountSetKeyPair(ctx context.Context, id int64, publicKey string, privateKey string) error {
+	query := `UPDATE accounts SET public_key=$2, private_key=$3 WHERE id=$1 AND public_key='' AND deleted=false;`
+
+	result, err := s.dbpool.Exec(ctx, query, id, publicKey, privateKey)
+	if err != nil {
+		return err
+	}
+
+	if result.RowsAffected() != 1 {
+		return models.ErrNotFound
+	}
+
+	return nil
+}
+
+// NoteSetItemKey implements models.Store.
+func (s PostgresStore) NoteSetItemKey(ctx context.Context, accountID int64, id int64, currentValue string, newValue string, itemKey string) error {
+	query := `UPDATE notes SET value=$4, item_key=$5 WHERE id=$1 AND account_id=$2 AND item_key='' AND value=$3;`
+
+	result, err := s.dbpool.Exec(ctx, query, id, accountID, currentValue, newValue, itemKey)
+	if err != nil {
+		return err
+	}
+
+	if result.RowsAffected() != 1 {
+		return models.ErrNotFound
+	}
+
+	return nil
+}
+
+// NoteShareCreate implements models.Store.
+func (s PostgresStore) NoteShareCreate(ctx context.Context, share models.NoteShare, itemKey string) (models.NoteShare, error) {
+	query := `INSERT INTO note_shares (note_id, owner_id, recipient_id, permission, encrypted_key, created_at, updated_at)
+		SELECT @note_id::BIGINT, @owner_id::BIGINT, @recipient_id::BIGINT, @permission::TEXT, @encrypted_key::TEXT,
+			@created_at::TIMESTAMPTZ, @created_at::TIMESTAMPTZ
+		WHERE EXISTS (SELECT 1 FROM notes WHERE id=@note_id AND account_id=@owner_id AND deleted=false AND item_key=@item_key
+			FOR SHARE)
+		RETURNING id;`
+
+	args := pgx.NamedArgs{
+		"note_id":       share.NoteID,
+		"owner_id":      share.OwnerID,
+		"recipient_id":  share.RecipientID,
+		"permission":    share.Permission,
+		"encrypted_key": share.EncryptedKey,
+		"created_at":    time.Now().UTC(),
+		"item_key":      itemKey,
+	}
+
+	// the lock waits for a rotation of the note's item key to finish, so the key is checked
+	// against the rotated one
+	var id int64
+	if err := s.dbpool.QueryRow(ctx, query, args).Scan(&id); err != nil {
+		return models.NoteShare{}, mapError(err)
+	}
+
+	return s.NoteShareGetByID(ctx, share.OwnerID, share.NoteID, id)
+}
+
+// NoteShareGetByNoteID implements models.Store.
+func (s PostgresStore) NoteShareGetByNoteID(ctx context.Context, ownerID int64, noteID int64) ([]models.NoteShare, error) {
+	query := shareQuery + ` WHERE s.note_id=$1 AND s.owner_id=$2 ORDER BY s.id;`
+
+	rows, err := s.dbpool.Query(ctx, query, noteID, ownerID)
+	if err != nil {
+		return []models.NoteShare{}, err
+	}
+
+	return pgx.CollectRows(rows, pgx.RowToStructByName[models.NoteShare])
+}
+
+// NoteShareGetByID implements models.Store.
+func (s PostgresStore) NoteShareGetByID(ctx context.Context, ownerID int64, noteID int64, id int64) (models.NoteShare, error) {
+	query := shareQuery + ` WHERE s.id=$1 AND s.note_id=$2 AND s.owner_id=$3;`
+
+	rows, err := s.dbpool.Query(ctx, query, id, noteID, ownerID)
+	if err != nil {
+		return models.NoteShare{}, err
+	}
+
+	share, err := pgx.CollectOneRow(rows, pgx.RowToStructByName[models.NoteShare])
+	if err != nil {
+		return models.NoteShare{}, mapError(err)
+	}
+
+	return share, nil
+}
+
+// NoteShareUpdatePermission implements models.Store.
+func (s PostgresStore) NoteShareUpdatePermission(ctx context.Context, ownerID int64, noteID int64, id int64, permission string) error {
+	query := `UPDATE note_shares SET permission=$4, updated_at=$5 WHERE id=$1 AND note_id=$2 AND owner_id=$3;`
+
+	result, err := s.dbpool.Exec(ctx, query, id, noteID, ownerID, permission, time.Now().UTC())
+	if err != nil {
+		return err
+	}
+
+	if result.RowsAffected() != 1 {
+		return models.ErrNotFound
+	}
+
+	return nil
+}
+
+// NoteShareDelete implements models.Store.
+func (s PostgresStore) NoteShareDelete(ctx context.Context, ownerID int64, noteID int64, id int64) error {
+	result, err := s.dbpool.Exec(ctx, `DELETE FROM note_shares WHERE id=$1 AND note_id=$2 AND owner_id=$3;`, id, noteID, ownerID)
+	if err != nil {
+		return err
+	}
+
+	if result.RowsAffected() != 1 {
+		return models.ErrNotFound
+	}
+
+	return nil
+}
+
+// NoteShareGetByRecipientID implements models.Store.
+func (s PostgresStore) NoteShareGetByRecipientID(ctx context.Context, recipientID int64) ([]models.NoteShare, error) {
+	query := shareQuery + ` JOIN notes n ON n.id=s.note_id
+		WHERE s.recipient_id=$1 AND n.deleted=false AND o.deleted=false ORDER BY s.id;`
+
+	rows, err := s.dbpool.Query(ctx, query, recipientID)
+	if err != nil {
+		return []models.NoteShare{}, err
+	}
+
+	return pgx.CollectRows(rows, pgx.RowToStructByName[models.NoteShare])
+}
+
+// NoteShareGetForRecipient implements models.Store.
+func (s PostgresStore) NoteShareGetForRecipient(ctx context.Context, recipientID int64, noteID int64) (models.NoteShare, error) {
+	query := shareQuery + ` JOIN notes n ON n.id=s.note_id
+		WHERE s.recipient_id=$1 AND s.note_id=$2 AND n.deleted=false AND o.deleted=false;`
+
+	rows, err := s.dbpool.Query(ctx, query, recipientID, noteID)
+	if err != nil {
+		return models.NoteShare{}, err
+	}
+
+	share, err := pgx.CollectOneRow(rows, pgx.RowToStructByName[models.NoteShare])
+	if err != nil {
+		return models.NoteShare{}, mapError(err)
+	}
+
+	return share, nil
+}
+
+// NoteShareRotate implements models.Store.
+func (s PostgresStore) NoteShareRotate(ctx context.Context, ownerID int64, id int64, note models.Note, currentValue string, keepVersions int,
+	shareKeys map[int64]string) (models.Note, error) {
+	tx, err := s.dbpool.Begin(ctx)
+	if err != nil {
+		return models.Note{}, err
+	}
+	defer tx.Rollback(ctx)
+
+	result, err := tx.Exec(ctx, `DELETE FROM note_shares WHERE id=$1 AND note_id=$2 AND owner_id=$3;`, id, note.ID, ownerID)
+	if err != nil {
+		return models.Note{}, err
+	}
+
+	if result.RowsAffected() != 1 {
+		return models.Note{}, models.ErrNotFound
+	}
+
+	if keepVersions > 0 {
+		// the row lock taken here keeps concurrent updates from saving the same version twice
+		if _, err := tx.Exec(ctx, `INSERT INTO note_versions (note_id, account_id, name, type, value, updated_by, updated_at, item_key)
+			SELECT id, account_id, name, type, value, updated_by, updated_at, item_key FROM notes
+			WHERE id=$1 AND account_id=$2 AND deleted=false FOR UPDATE;`, note.ID, ownerID); err != nil {
+			return models.Note{}, err
+		}
+	}
+
+	now := time.Now().UTC()
+
+	rows, err := tx.Query(ctx, `UPDATE notes SET value=$4, item_key=$5, updated_by=$6, updated_at=$7
+		WHERE id=$1 AND account_id=$2 AND deleted=false AND value=$3
+		RETURNING `+noteColumns+`;`, note.ID, ownerID, currentValue, note.Value, note.ItemKey, note.UpdatedBy, now)
+	if err != nil {
+		return models.Note{}, err
+	}
+
+	savedNote, err := pgx.CollectOneRow(rows, pgx.RowToStructByName[models.Note])
+	if err != nil {
+		return models.Note{}, mapError(err)
+	}
+	savedNote.Tags = note.Tags
+
+	// a share created since the keys were encrypted would be left with the old item key
+	rows, err = tx.Query(ctx, `SELECT id FROM note_shares WHERE note_id=$1 AND owner_id=$2 FOR UPDATE;`, note.ID, ownerID)
+	if err != nil {
+		return models.Note{}, err
+	}
+
+	remaining, err := pgx.CollectRows(rows, pgx.RowTo[int64])
+	if err != nil {
+		return models.Note{}, err
+	}
+
+	if len(remaining) != len(shareKeys) {
+		return models.Note{}, models.ErrNotFound
+	}
+
+	for _, shareID := range remaining {
+		encryptedKey, ok := shareKeys[shareID]
+		if !ok {
+			return models.Note{}, models.ErrNotFound
+		}
+
+		if _, err := tx.Exec(ctx, `UPDATE note_shares SET encrypted_key=$2, updated_at=$3 WHERE id=$1;`, shareID, encryptedKey, now); err != nil {
+			return models.Note{}, err
+		}
+	}
+
+	if _, err := tx.Exec(ctx, `DELETE FROM note_versions WHERE note_id=$1 AND account_id=$2 AND id NOT IN (
+		SELECT id FROM note_versions WHERE note_id=$1 ORDER BY id DESC LIMIT $3);`, note.ID, ownerID, keepVersions); err != nil {
+		return models.Note{}, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return models.Note{}, err
+	}
+
+	return savedNote, nil
+}
@@ -0,0 +1,196 @@
+package postgres
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/oalexander6/passman/pkg/models"
+)
+
+// tagQuery selects tags along with how many notes outside the trash have them.
+const tagQuery = `SELECT t.id, t.account_id, t.name, t.created_at, COUNT(n.id) AS notes
+	FROM tags t
+	LEFT JOIN note_tags nt ON nt.tag_id=t.id
+	LEFT JOIN notes n ON n.id=nt.note_id AND n.deleted=false`
+
+// TagCreate implements models.Store.
+func (s PostgresStore) TagCreate(ctx context.Context, tag models.Tag) (models.Tag, error) {
+	query := `INSERT INTO tags (account_id, name, created_at) VALUES ($1, $2, $3)
+		RETURNING id, account_id, name, 0 AS notes, created_at;`
+
+	rows, err := s.dbpool.Query(ctx, query, tag.AccountID, tag.Name, time.Now().UTC())
+	if err != nil {
+		return models.Tag{}, mapError(err)
+	}
+
+	savedTag, err := pgx.CollectOneRow(rows, pgx.RowToStructByName[models.Tag])
+	if err != nil {
+		return models.Tag{}, mapError(err)
+	}
+
+	return savedTag, nil
+}
+
+// TagGetByAccountID implements models.Store.
+func (s PostgresStore) TagGetByAccountID(ctx context.Context, accountID int64) ([]models.Tag, error) {
+	query := tagQuery + ` WHERE t.account_id=$1 GROUP BY t.id ORDER BY t.name;`
+
+	rows, err := s.dbpool.Query(ctx, query, accountID)
+	if err != nil {
+		return []models.Tag{}, err
+	}
+
+	tags, err := pgx.CollectRows(rows, pgx.RowToStructByName[models.Tag])
+	if err != nil {
+		return []models.Tag{}, err
+	}
+
+	return tags, nil
+}
+
+// TagUpdate implements models.Store.
+func (s PostgresStore) TagUpdate(ctx context.Context, accountID int64, id int64, name string) (models.Tag, error) {
+	tx, err := s.dbpool.Begin(ctx)
+	if err != nil {
+		return models.Tag{}, err
+	}
+	defer tx.Rollback(ctx)
+
+	result, err := tx.Exec(ctx, `UPDATE tags SET name=$3 WHERE id=$1 AND account_id=$2;`, id, accountID, name)
+	if err != nil {
+		return models.Tag{}, mapError(err)
+	}
+
+	if result.RowsAffected() != 1 {
+		return models.Tag{}, models.ErrNotFound
+	}
+
+	noteIDs, err := tagNoteIDs(ctx, tx, accountID, id)
+	if err != nil {
+		return models.Tag{}, err
+	}
+
+	if err := reindexNotes(ctx, tx, noteIDs); err != nil {
+		return models.Tag{}, err
+	}
+
+	rows, err := tx.Query(ctx, tagQuery+` WHERE t.id=$1 AND t.account_id=$2 GROUP BY t.id;`, id, accountID)
+	if err != nil {
+		return models.Tag{}, err
+	}
+
+	tag, err := pgx.CollectOneRow(rows, pgx.RowToStructByName[models.Tag])
+	if err != nil {
+		return models.Tag{}, mapError(err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return models.Tag{}, err
+	}
+
+	return tag, nil
+}
+
+// TagDelete implements models.Store.
+func (s PostgresStore) TagDelete(ctx context.Context, accountID int64, id int64) error {
+	tx, err := s.dbpool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	noteIDs, err := tagNoteIDs(ctx, tx, accountID, id)
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(ctx, `DELETE FROM note_tags WHERE tag_id IN (SELECT id FROM tags WHERE id=$1 AND account_id=$2);`,
+		id, accountID); err != nil {
+		return err
+	}
+
+	result, err := tx.Exec(ctx, `DELETE FROM tags WHERE id=$1 AND account_id=$2;`, id, accountID)
+	if err != nil {
+		return err
+	}
+
+	if result.RowsAffected() != 1 {
+		return models.ErrNotFound
+	}
+
+	if err := reindexNotes(ctx, tx, noteIDs); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// tagNoteIDs returns the IDs of the notes with the account's tag, including deleted notes.
+func tagNoteIDs(ctx context.Context, tx pgx.Tx, accountID int64, tagID int64) ([]int64, error) {
+	rows, err := tx.Query(ctx, `SELECT note_id FROM note_tags WHERE tag_id IN (
+		SELECT id FROM tags WHERE id=$1 AND account_id=$2);`, tagID, accountID)
+	if err != nil {
+		return nil, err
+	}
+
+	return pgx.CollectRows(rows, pgx.RowTo[int64])
+}
+
+// setNoteTags replaces the tags of the note, creating the tags the account doesn't have yet.
+func setNoteTags(ctx context.Context, tx pgx.Tx, accountID int64, noteID int64, tags []string) error {
+	if _, err := tx.Exec(ctx, `DELETE FROM note_tags WHERE note_id=$1;`, noteID); err != nil {
+		return err
+	}
+
+	if len(tags) == 0 {
+		return nil
+	}
+
+	if _, err := tx.Exec(ctx, `INSERT INTO tags (account_id, name, created_at) SELECT $1, unnest($2::text[]), $3
+		ON CONFLICT (account_id, name) DO NOTHING;`, accountID, tags, time.Now().UTC()); err != nil {
+		return err
+	}
+
+	_, err := tx.Exec(ctx, `INSERT INTO note_tags (note_id, tag_id)
+		SELECT $1, id FROM tags WHERE account_id=$2 AND name = ANY($3);`, noteID, accountID, tags)
+
+	return err
+}
+
+// loadNoteTags sets the tags of the account's notes, sorted by name.
+func (s PostgresStore) loadNoteTags(ctx context.Context, accountID int64, notes []models.Note) error {
+	if len(notes) == 0 {
+		return nil
+	}
+
+	ids := make([]int64, len(notes))
+	for i, note := range notes {
+		ids[i] = note.ID
+	}
+
+	rows, err := s.dbpool.Query(ctx, `SELECT nt.note_id, t.name FROM note_tags nt JOIN tags t ON t.id=nt.tag_id
+		WHERE t.account_id=$1 AND nt.note_id = ANY($2) ORDER BY t.name;`, accountID, ids)
+	if err != nil {
+		return err
+	}
+
+	tags := make(map[int64][]string)
+	var (
+		noteID int64
+		name   string
+	)
+	_, err = pgx.ForEachRow(rows, []any{&noteID, &name}, func() error {
+		tags[noteID] = append(tags[noteID], name)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for i := range notes {
+		notes[i].Tags = tags[notes[i].ID]
+	}
+
+	return nil
+}
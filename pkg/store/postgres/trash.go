@@ -0,0 +1,99 @@
+package postgres
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/oalexander6/passman/pkg/models"
+)
+
+// zeroed replaces a text column with as many zeros as it has characters.
+func zeroed(column string) string {
+	return `repeat('0', length(` + column + `))`
+}
+
+// NoteGetDeletedByAccountID implements models.Store.
+func (s PostgresStore) NoteGetDeletedByAccountID(ctx context.Context, accountID int64) ([]models.Note, error) {
+	query := `SELECT ` + noteColumns + ` FROM notes WHERE account_id=$1 AND deleted=true ORDER BY deleted_at DESC, id DESC;`
+
+	rows, err := s.dbpool.Query(ctx, query, accountID)
+	if err != nil {
+		return []models.Note{}, err
+	}
+
+	notes, err := pgx.CollectRows(rows, pgx.RowToStructByName[models.Note])
+	if err != nil {
+		return []models.Note{}, err
+	}
+
+	return notes, nil
+}
+
+// NoteGetDeletedBefore implements models.Store.
+func (s PostgresStore) NoteGetDeletedBefore(ctx context.Context, before time.Time, limit int) ([]models.Note, error) {
+	query := `SELECT ` + noteColumns + ` FROM notes WHERE deleted=true AND deleted_at<$1 ORDER BY deleted_at LIMIT $2;`
+
+	rows, err := s.dbpool.Query(ctx, query, before, limit)
+	if err != nil {
+		return []models.Note{}, err
+	}
+
+	return pgx.CollectRows(rows, pgx.RowToStructByName[models.Note])
+}
+
+// NoteRestore implements models.Store.
+func (s PostgresStore) NoteRestore(ctx context.Context, accountID int64, id int64) error {
+	query := `UPDATE notes SET deleted=false, deleted_at=NULL, updated_at=$3 WHERE id=$1 AND account_id=$2 AND deleted=true;`
+
+	result, err := s.dbpool.Exec(ctx, query, id, accountID, time.Now().UTC())
+	if err != nil {
+		return err
+	}
+
+	if result.RowsAffected() != 1 {
+		return models.ErrNotFound
+	}
+
+	return nil
+}
+
+// NotePurge implements models.Store. Postgres keeps the overwritten row versions until they are
+// vacuumed.
+func (s PostgresStore) NotePurge(ctx context.Context, accountID int64, id int64) error {
+	tx, err := s.dbpool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	result, err := tx.Exec(ctx, `UPDATE notes SET name=`+zeroed("name")+`, value=`+zeroed("value")+`
+		WHERE id=$1 AND account_id=$2 AND deleted=true;`, id, accountID)
+	if err != nil {
+		return err
+	}
+
+	if result.RowsAffected() != 1 {
+		return models.ErrNotFound
+	}
+
+	statements := []string{
+		`UPDATE note_versions SET name=` + zeroed("name") + `, value=` + zeroed("value") + ` WHERE note_id=$1 AND account_id=$2;`,
+		`UPDATE attachments SET name=` + zeroed("name") + `, file_key=` + zeroed("file_key") + ` WHERE note_id=$1 AND account_id=$2;`,
+		`DELETE FROM note_versions WHERE note_id=$1 AND account_id=$2;`,
+		`DELETE FROM attachments WHERE note_id=$1 AND account_id=$2;`,
+		`DELETE FROM note_tags WHERE note_id IN (SELECT id FROM notes WHERE id=$1 AND account_id=$2);`,
+		`DELETE FROM note_search WHERE note_id IN (SELECT id FROM notes WHERE id=$1 AND account_id=$2);`,
+		`DELETE FROM note_blind_indexes WHERE note_id=$1 AND account_id=$2;`,
+		`DELETE FROM note_shares WHERE note_id=$1 AND owner_id=$2;`,
+		`DELETE FROM notes WHERE id=$1 AND account_id=$2;`,
+	}
+
+	for _, statement := range statements {
+		if _, err := tx.Exec(ctx, statement, id, accountID); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit(ctx)
+}
@@ -0,0 +1,85 @@
+package postgres
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/oalexander6/passman/pkg/models"
+)
+
+const noteVersionColumns = `id, note_id, account_id, name, type, value, updated_by, updated_at, item_key`
+
+// NoteVersionGetByNoteID implements models.Store.
+func (s PostgresStore) NoteVersionGetByNoteID(ctx context.Context, accountID int64, noteID int64) ([]models.NoteVersion, error) {
+	query := `SELECT ` + noteVersionColumns + ` FROM note_versions WHERE note_id=$1 AND account_id=$2 ORDER BY id DESC;`
+
+	rows, err := s.dbpool.Query(ctx, query, noteID, accountID)
+	if err != nil {
+		return []models.NoteVersion{}, err
+	}
+
+	versions, err := pgx.CollectRows(rows, pgx.RowToStructByName[models.NoteVersion])
+	if err != nil {
+		return []models.NoteVersion{}, err
+	}
+
+	return versions, nil
+}
+
+// NoteVersionGetByID implements models.Store.
+func (s PostgresStore) NoteVersionGetByID(ctx context.Context, accountID int64, noteID int64, id int64) (models.NoteVersion, error) {
+	query := `SELECT ` + noteVersionColumns + ` FROM note_versions WHERE id=$1 AND note_id=$2 AND account_id=$3;`
+
+	rows, err := s.dbpool.Query(ctx, query, id, noteID, accountID)
+	if err != nil {
+		return models.NoteVersion{}, err
+	}
+
+	version, err := pgx.CollectOneRow(rows, pgx.RowToStructByName[models.NoteVersion])
+	if err != nil {
+		return models.NoteVersion{}, mapError(err)
+	}
+
+	return version, nil
+}
+
+// NoteVersionGetAllByAccountID implements models.Store.
+func (s PostgresStore) NoteVersionGetAllByAccountID(ctx context.Context, accountID int64) ([]models.NoteVersion, error) {
+	query := `SELECT ` + noteVersionColumns + ` FROM note_versions WHERE account_id=$1 ORDER BY id;`
+
+	rows, err := s.dbpool.Query(ctx, query, accountID)
+	if err != nil {
+		return []models.NoteVersion{}, err
+	}
+
+	return pgx.CollectRows(rows, pgx.RowToStructByName[models.NoteVersion])
+}
+
+// NoteVersionUpdateValue implements models.Store.
+func (s PostgresStore) NoteVersionUpdateValue(ctx context.Context, accountID int64, id int64, currentValue string, newValue string) error {
+	query := `UPDATE note_versions SET value=$4 WHERE id=$1 AND account_id=$2 AND value=$3;`
+
+	result, err := s.dbpool.Exec(ctx, query, id, accountID, currentValue, newValue)
+	if err != nil {
+		return err
+	}
+
+	if result.RowsAffected() != 1 {
+		return models.ErrNotFound
+	}
+
+	return nil
+}
+
+// NoteVersionPrune implements models.Store.
+func (s PostgresStore) NoteVersionPrune(ctx context.Context, accountID int64, keep int) error {
+	query := `DELETE FROM note_versions WHERE id IN (
+		SELECT id FROM (
+			SELECT id, ROW_NUMBER() OVER (PARTITION BY note_id ORDER BY id DESC) AS n
+			FROM note_versions WHERE account_id=$1
+		) AS ranked WHERE n > $2
+	);`
+
+	_, err := s.dbpool.Exec(ctx, query, accountID, keep)
+	return err
+}
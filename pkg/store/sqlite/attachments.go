@@ -0,0 +1,106 @@
+package sqlite
+
+import (
+	"context"
+	"time"
+
+	"github.com/oalexander6/passman/pkg/models"
+)
+
+const attachmentColumns = `id, account_id, note_id, name, content_type, size, chunks, file_key, complete, created_at`
+
+// AttachmentCreate implements models.Store.
+func (s SqliteStore) AttachmentCreate(ctx context.Context, attachment models.Attachment) (models.Attachment, error) {
+	query := `INSERT INTO attachments (account_id, note_id, name, content_type, size, chunks, file_key, complete, created_at)
+		VALUES (?, ?, ?, ?, 0, 0, '', false, ?)
+		RETURNING ` + attachmentColumns + `;`
+
+	var saved models.Attachment
+	if err := s.db.GetContext(ctx, &saved, query, attachment.AccountID, attachment.NoteID, attachment.Name,
+		attachment.ContentType, time.Now().UTC()); err != nil {
+		return models.Attachment{}, mapError(err)
+	}
+
+	return saved, nil
+}
+
+// AttachmentComplete implements models.Store. The quota is checked within the update itself, so
+// concurrent uploads can't both fit into the same remaining space.
+func (s SqliteStore) AttachmentComplete(ctx context.Context, attachment models.Attachment, quota int64) error {
+	query := `UPDATE attachments SET size=?, chunks=?, file_key=?, complete=true
+		WHERE id=? AND account_id=? AND complete=false
+		AND (? = 0 OR ? + (SELECT COALESCE(SUM(size), 0) FROM attachments WHERE account_id=? AND complete=true) <= ?);`
+
+	result, err := s.db.ExecContext(ctx, query, attachment.Size, attachment.Chunks, attachment.FileKey,
+		attachment.ID, attachment.AccountID, quota, attachment.Size, attachment.AccountID, quota)
+	if err != nil {
+		return err
+	}
+
+	if err := requireOneRow(result); err != nil {
+		// tell a missing upload apart from one that no longer fits
+		var pending bool
+		if err := s.db.GetContext(ctx, &pending, `SELECT EXISTS (SELECT 1 FROM attachments
+			WHERE id=? AND account_id=? AND complete=false);`, attachment.ID, attachment.AccountID); err != nil {
+			return err
+		}
+
+		if pending {
+			return models.ErrQuotaExceeded
+		}
+
+		return err
+	}
+
+	return nil
+}
+
+// AttachmentGetByID implements models.Store.
+func (s SqliteStore) AttachmentGetByID(ctx context.Context, accountID int64, noteID int64, id int64) (models.Attachment, error) {
+	query := `SELECT ` + attachmentColumns + ` FROM attachments
+		WHERE id=? AND account_id=? AND note_id=? AND complete=true;`
+
+	var attachment models.Attachment
+	if err := s.db.GetContext(ctx, &attachment, query, id, accountID, noteID); err != nil {
+		return models.Attachment{}, mapError(err)
+	}
+
+	return attachment, nil
+}
+
+// AttachmentGetByNoteID implements models.Store.
+func (s SqliteStore) AttachmentGetByNoteID(ctx context.Context, accountID int64, noteID int64) ([]models.Attachment, error) {
+	query := `SELECT ` + attachmentColumns + ` FROM attachments
+		WHERE account_id=? AND note_id=? AND complete=true ORDER BY id;`
+
+	attachments := []models.Attachment{}
+	if err := s.db.SelectContext(ctx, &attachments, query, accountID, noteID); err != nil {
+		return []models.Attachment{}, err
+	}
+
+	return attachments, nil
+}
+
+// AttachmentDelete implements models.Store.
+func (s SqliteStore) AttachmentDelete(ctx context.Context, accountID int64, id int64) error {
+	query := `DELETE FROM attachments WHERE id=? AND account_id=?;`
+
+	result, err := s.db.ExecContext(ctx, query, id, accountID)
+	if err != nil {
+		return err
+	}
+
+	return requireOneRow(result)
+}
+
+// AttachmentUsage implements models.Store.
+func (s SqliteStore) AttachmentUsage(ctx context.Context, accountID int64) (int64, error) {
+	query := `SELECT COALESCE(SUM(size), 0) FROM attachments WHERE account_id=? AND complete=true;`
+
+	var used int64
+	if err := s.db.GetContext(ctx, &used, query, accountID); err != nil {
+		return 0, err
+	}
+
+	return used, nil
+}
@@ -0,0 +1,414 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/mattn/go-sqlite3"
+	"github.com/oalexander6/passman/config"
+	"github.com/oalexander6/passman/pkg/logger"
+	"github.com/oalexander6/passman/pkg/models"
+)
+
+type SqliteStore struct {
+	db *sqlx.DB
+}
+
+const (
+	accountColumns = `id, email, password, name, data_key, index_key, public_key, private_key, vault_mode, kdf_salt, kdf_memory,
+		kdf_iterations, kdf_parallelism, min_password_strength, max_note_versions, session_epoch, created_at, updated_at, deleted`
+	noteColumns = `id, account_id, name, type, value, updated_by, folder_id, item_key, created_at, updated_at, deleted, deleted_at`
+)
+
+func New(opts config.SqliteConfig) *SqliteStore {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	// foreign keys are off by default in SQLite, and the busy timeout lets concurrent
+	// writers wait for the file lock instead of failing immediately. Secure delete zeroes
+	// deleted content, so purged notes don't linger in free pages.
+	dsn := "file:" + opts.Path + "?_foreign_keys=on&_busy_timeout=5000&_journal_mode=WAL&_secure_delete=on"
+
+	db, err := sqlx.ConnectContext(ctx, "sqlite3", dsn)
+	if err != nil {
+		logger.Log.Fatal().Msgf("Unable to open sqlite database %s: %s", opts.Path, err)
+	}
+
+	// the search index is an FTS5 table, which go-sqlite3 only compiles in with the sqlite_fts5
+	// build tag
+	var fts5 bool
+	if err := db.GetContext(ctx, &fts5, `SELECT sqlite_compileoption_used('ENABLE_FTS5');`); err != nil || !fts5 {
+		logger.Log.Fatal().Msg("SQLite was built without FTS5, which search needs. Build passman with -tags sqlite_fts5")
+	}
+
+	return &SqliteStore{
+		db: db,
+	}
+}
+
+func (s SqliteStore) Close() {
+	s.db.Close()
+}
+
+// mapError converts driver errors into the errors defined by the models package.
+func mapError(err error) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.ErrNotFound
+	}
+
+	var sqliteErr sqlite3.Error
+	if errors.As(err, &sqliteErr) && sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique {
+		return models.ErrAlreadyExists
+	}
+
+	return err
+}
+
+// AccountCreate implements models.Store.
+func (s SqliteStore) AccountCreate(ctx context.Context, account models.Account) (models.Account, error) {
+	query := `INSERT INTO accounts (email, password, name, data_key, vault_mode, kdf_salt, kdf_memory,
+			kdf_iterations, kdf_parallelism, created_at, updated_at, deleted)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		RETURNING ` + accountColumns + `;`
+
+	now := time.Now().UTC()
+
+	var savedAccount models.Account
+	err := s.db.GetContext(ctx, &savedAccount, query, account.Email, account.Password, account.Name, account.DataKey,
+		account.VaultMode, account.Salt, account.Memory, account.Iterations, account.Parallelism, now, now, false)
+	if err != nil {
+		return models.Account{}, mapError(err)
+	}
+
+	return savedAccount, nil
+}
+
+// AccountGetByID implements models.Store.
+func (s SqliteStore) AccountGetByID(ctx context.Context, id int64) (models.Account, error) {
+	query := `SELECT ` + accountColumns + ` FROM accounts WHERE id=? AND deleted=false;`
+
+	var account models.Account
+	if err := s.db.GetContext(ctx, &account, query, id); err != nil {
+		return models.Account{}, mapError(err)
+	}
+
+	return account, nil
+}
+
+// AccountGetByEmail implements models.Store.
+func (s SqliteStore) AccountGetByEmail(ctx context.Context, email string) (models.Account, error) {
+	query := `SELECT ` + accountColumns + ` FROM accounts WHERE email=? AND deleted=false;`
+
+	var account models.Account
+	if err := s.db.GetContext(ctx, &account, query, email); err != nil {
+		return models.Account{}, mapError(err)
+	}
+
+	return account, nil
+}
+
+// AccountUpdateDataKey implements models.Store.
+func (s SqliteStore) AccountUpdateDataKey(ctx context.Context, id int64, currentDataKey string, newDataKey string) error {
+	query := `UPDATE accounts SET data_key=? WHERE id=? AND data_key=? AND deleted=false;`
+
+	result, err := s.db.ExecContext(ctx, query, newDataKey, id, currentDataKey)
+	if err != nil {
+		return err
+	}
+
+	return requireOneRow(result)
+}
+
+// AccountUpdateIndexKey implements models.Store.
+func (s SqliteStore) AccountUpdateIndexKey(ctx context.Context, id int64, currentIndexKey string, newIndexKey string) error {
+	query := `UPDATE accounts SET index_key=? WHERE id=? AND index_key=? AND deleted=false;`
+
+	result, err := s.db.ExecContext(ctx, query, newIndexKey, id, currentIndexKey)
+	if err != nil {
+		return err
+	}
+
+	return requireOneRow(result)
+}
+
+// AccountUpdatePolicy implements models.Store.
+func (s SqliteStore) AccountUpdatePolicy(ctx context.Context, id int64, policy models.AccountPolicy) error {
+	query := `UPDATE accounts SET min_password_strength=?, max_note_versions=?, updated_at=? WHERE id=? AND deleted=false;`
+
+	result, err := s.db.ExecContext(ctx, query, policy.MinPasswordStrength, policy.MaxNoteVersions, time.Now().UTC(), id)
+	if err != nil {
+		return err
+	}
+
+	return requireOneRow(result)
+}
+
+// AccountRevokeSessions implements models.Store.
+func (s SqliteStore) AccountRevokeSessions(ctx context.Context, id int64) error {
+	query := `UPDATE accounts SET session_epoch=session_epoch+1, updated_at=? WHERE id=? AND deleted=false;`
+
+	result, err := s.db.ExecContext(ctx, query, time.Now().UTC(), id)
+	if err != nil {
+		return err
+	}
+
+	return requireOneRow(result)
+}
+
+// AccountListIDs implements models.Store.
+func (s SqliteStore) AccountListIDs(ctx context.Context, afterID int64, limit int) ([]int64, error) {
+	query := `SELECT id FROM accounts WHERE id>? AND deleted=false ORDER BY id LIMIT ?;`
+
+	ids := []int64{}
+	if err := s.db.SelectContext(ctx, &ids, query, afterID, limit); err != nil {
+		return nil, err
+	}
+
+	return ids, nil
+}
+
+// AccountDelete implements models.Store.
+func (s SqliteStore) AccountDelete(ctx context.Context, id int64) error {
+	query := `UPDATE accounts SET deleted=true, updated_at=? WHERE id=? AND deleted=false;`
+
+	result, err := s.db.ExecContext(ctx, query, time.Now().UTC(), id)
+	if err != nil {
+		return err
+	}
+
+	return requireOneRow(result)
+}
+
+// NoteCreate implements models.Store.
+func (s SqliteStore) NoteCreate(ctx context.Context, noteInput models.Note, seal models.NoteSealFunc) (models.Note, error) {
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return models.Note{}, err
+	}
+	defer tx.Rollback()
+
+	sealedNote, err := createNote(ctx, tx, noteInput, seal)
+	if err != nil {
+		return models.Note{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return models.Note{}, err
+	}
+
+	return sealedNote, nil
+}
+
+// createNote inserts the note with its tags and search index, and stores the value seal sets.
+func createNote(ctx context.Context, tx *sqlx.Tx, noteInput models.Note, seal models.NoteSealFunc) (models.Note, error) {
+	query := `INSERT INTO notes (account_id, name, type, value, updated_by, folder_id, created_at, updated_at, deleted)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+		RETURNING ` + noteColumns + `;`
+
+	now := time.Now().UTC()
+
+	var savedNote models.Note
+	err := tx.GetContext(ctx, &savedNote, query, noteInput.AccountID, noteInput.Name, noteInput.Type, "", noteInput.AccountID,
+		noteInput.FolderID, now, now, false)
+	if err != nil {
+		return models.Note{}, mapError(err)
+	}
+
+	if err := setNoteTags(ctx, tx, savedNote.AccountID, savedNote.ID, noteInput.Tags); err != nil {
+		return models.Note{}, err
+	}
+	savedNote.Tags = noteInput.Tags
+
+	if err := indexNote(ctx, tx, savedNote.ID, noteInput.Search); err != nil {
+		return models.Note{}, err
+	}
+
+	sealedNote, err := seal(savedNote)
+	if err != nil {
+		return models.Note{}, err
+	}
+
+	if _, err := tx.ExecContext(ctx, `UPDATE notes SET value=? WHERE id=?;`, sealedNote.Value, savedNote.ID); err != nil {
+		return models.Note{}, err
+	}
+
+	return sealedNote, nil
+}
+
+// NoteGetByID implements models.Store.
+func (s SqliteStore) NoteGetByID(ctx context.Context, accountID int64, id int64) (models.Note, error) {
+	query := `SELECT ` + noteColumns + ` FROM notes WHERE id=? AND account_id=? AND deleted=false;`
+
+	var note models.Note
+	if err := s.db.GetContext(ctx, &note, query, id, accountID); err != nil {
+		return models.Note{}, mapError(err)
+	}
+
+	notes := []models.Note{note}
+	if err := s.loadNoteTags(ctx, accountID, notes); err != nil {
+		return models.Note{}, err
+	}
+
+	return notes[0], nil
+}
+
+// NoteGetByAccountID implements models.Store.
+func (s SqliteStore) NoteGetByAccountID(ctx context.Context, accountID int64, filter models.NoteFilter) ([]models.Note, error) {
+	query := `SELECT ` + noteColumns + ` FROM notes WHERE account_id=? AND deleted=false`
+	args := []any{accountID}
+
+	if len(filter.FolderIDs) > 0 {
+		ids := []any{}
+		unfiled := false
+		for _, id := range filter.FolderIDs {
+			if id == 0 {
+				unfiled = true
+				continue
+			}
+			ids = append(ids, id)
+		}
+
+		condition := `folder_id IN (` + placeholders(len(ids)) + `)`
+		if unfiled {
+			condition += ` OR folder_id IS NULL`
+		}
+
+		query += ` AND (` + condition + `)`
+		args = append(args, ids...)
+	}
+
+	if len(filter.Tags) > 0 {
+		query += ` AND id IN (SELECT nt.note_id FROM note_tags nt JOIN tags t ON t.id=nt.tag_id
+			WHERE t.account_id=? AND t.name IN (` + placeholders(len(filter.Tags)) + `)
+			GROUP BY nt.note_id HAVING COUNT(*)=?)`
+		args = append(args, accountID)
+		for _, tag := range filter.Tags {
+			args = append(args, tag)
+		}
+		args = append(args, len(filter.Tags))
+	}
+
+	notes := []models.Note{}
+	if err := s.db.SelectContext(ctx, &notes, query+` ORDER BY id;`, args...); err != nil {
+		return []models.Note{}, err
+	}
+
+	if err := s.loadNoteTags(ctx, accountID, notes); err != nil {
+		return []models.Note{}, err
+	}
+
+	return notes, nil
+}
+
+// NoteUpdate implements models.Store.
+func (s SqliteStore) NoteUpdate(ctx context.Context, accountID int64, note models.Note, keepVersions int) (models.Note, error) {
+	query := `UPDATE notes SET name=?, type=?, value=?, updated_by=?, folder_id=?, updated_at=?
+		WHERE id=? AND account_id=? AND deleted=false AND item_key=?
+		RETURNING ` + noteColumns + `;`
+
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return models.Note{}, err
+	}
+	defer tx.Rollback()
+
+	if keepVersions > 0 {
+		if _, err := tx.ExecContext(ctx, `INSERT INTO note_versions (note_id, account_id, name, type, value, updated_by, updated_at, item_key)
+			SELECT id, account_id, name, type, value, updated_by, updated_at, item_key FROM notes
+			WHERE id=? AND account_id=? AND deleted=false;`, note.ID, accountID); err != nil {
+			return models.Note{}, err
+		}
+	}
+
+	var savedNote models.Note
+	err = tx.GetContext(ctx, &savedNote, query, note.Name, note.Type, note.Value, note.UpdatedBy, note.FolderID, time.Now().UTC(),
+		note.ID, accountID, note.ItemKey)
+	if err != nil {
+		return models.Note{}, mapError(err)
+	}
+
+	if err := setNoteTags(ctx, tx, accountID, note.ID, note.Tags); err != nil {
+		return models.Note{}, err
+	}
+	savedNote.Tags = note.Tags
+
+	if err := indexNote(ctx, tx, note.ID, note.Search); err != nil {
+		return models.Note{}, err
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM note_versions WHERE note_id=? AND account_id=? AND id NOT IN (
+		SELECT id FROM note_versions WHERE note_id=? ORDER BY id DESC LIMIT ?);`, note.ID, accountID, note.ID, keepVersions); err != nil {
+		return models.Note{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return models.Note{}, err
+	}
+
+	return savedNote, nil
+}
+
+// NoteDeleteByID implements models.Store.
+func (s SqliteStore) NoteDeleteByID(ctx context.Context, accountID int64, id int64) error {
+	query := `UPDATE notes SET deleted=true, updated_at=?, deleted_at=? WHERE id=? AND account_id=? AND deleted=false;`
+
+	now := time.Now().UTC()
+
+	result, err := s.db.ExecContext(ctx, query, now, now, id, accountID)
+	if err != nil {
+		return err
+	}
+
+	return requireOneRow(result)
+}
+
+// placeholders returns a comma separated list of n placeholders, for IN lists.
+func placeholders(n int) string {
+	if n == 0 {
+		return "NULL"
+	}
+
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
+
+// requireOneRow returns models.ErrNotFound unless the statement changed exactly one row.
+func requireOneRow(result sql.Result) error {
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affected != 1 {
+		return models.ErrNotFound
+	}
+
+	return nil
+}
+
+// NoteGetAllByAccountID implements models.Store.
+func (s SqliteStore) NoteGetAllByAccountID(ctx context.Context, accountID int64) ([]models.Note, error) {
+	query := `SELECT ` + noteColumns + ` FROM notes WHERE account_id=? ORDER BY id;`
+
+	notes := []models.Note{}
+	if err := s.db.SelectContext(ctx, &notes, query, accountID); err != nil {
+		return []models.Note{}, err
+	}
+
+	return notes, nil
+}
+
+// NoteUpdateValue implements models.Store.
+func (s SqliteStore) NoteUpdateValue(ctx context.Context, accountID int64, id int64, currentValue string, newValue string) error {
+	query := `UPDATE notes SET value=? WHERE id=? AND account_id=? AND value=?;`
+
+	result, err := s.db.ExecContext(ctx, query, newValue, id, accountID, currentValue)
+	if err != nil {
+		return err
+	}
+
+	return requireOneRow(result)
+}
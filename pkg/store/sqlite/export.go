@@ -0,0 +1,50 @@
+package sqlite
+
+import (
+	"context"
+	"time"
+
+	"github.com/oalexander6/passman/pkg/models"
+)
+
+// VaultImport implements models.Store.
+func (s SqliteStore) VaultImport(ctx context.Context, folders []models.Folder, notes []models.Note, seals []models.NoteSealFunc) error {
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// the IDs of the created folders by their placeholder IDs
+	folderIDs := make(map[int64]int64, len(folders))
+	folderID := func(id *int64) *int64 {
+		if id == nil || *id > 0 {
+			return id
+		}
+
+		created := folderIDs[*id]
+		return &created
+	}
+
+	now := time.Now().UTC()
+
+	for _, folder := range folders {
+		var savedFolder models.Folder
+		if err := tx.GetContext(ctx, &savedFolder, folderInsertQuery, folder.AccountID, folderID(folder.ParentID), folder.Name,
+			now, now); err != nil {
+			return mapError(err)
+		}
+
+		folderIDs[folder.ID] = savedFolder.ID
+	}
+
+	for i, note := range notes {
+		note.FolderID = folderID(note.FolderID)
+
+		if _, err := createNote(ctx, tx, note, seals[i]); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
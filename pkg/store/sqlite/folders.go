@@ -0,0 +1,197 @@
+package sqlite
+
+import (
+	"context"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/oalexander6/passman/pkg/models"
+)
+
+const folderColumns = `id, account_id, parent_id, name, created_at, updated_at`
+
+const folderInsertQuery = `INSERT INTO folders (account_id, parent_id, name, created_at, updated_at)
+	VALUES (?, ?, ?, ?, ?)
+	RETURNING ` + folderColumns + `;`
+
+// FolderCreate implements models.Store.
+func (s SqliteStore) FolderCreate(ctx context.Context, folder models.Folder) (models.Folder, error) {
+	now := time.Now().UTC()
+
+	var savedFolder models.Folder
+	if err := s.db.GetContext(ctx, &savedFolder, folderInsertQuery, folder.AccountID, folder.ParentID, folder.Name, now, now); err != nil {
+		return models.Folder{}, mapError(err)
+	}
+
+	return savedFolder, nil
+}
+
+// FolderGetByID implements models.Store.
+func (s SqliteStore) FolderGetByID(ctx context.Context, accountID int64, id int64) (models.Folder, error) {
+	query := `SELECT ` + folderColumns + ` FROM folders WHERE id=? AND account_id=?;`
+
+	var folder models.Folder
+	if err := s.db.GetContext(ctx, &folder, query, id, accountID); err != nil {
+		return models.Folder{}, mapError(err)
+	}
+
+	return folder, nil
+}
+
+// FolderGetByAccountID implements models.Store.
+func (s SqliteStore) FolderGetByAccountID(ctx context.Context, accountID int64) ([]models.Folder, error) {
+	query := `SELECT ` + folderColumns + ` FROM folders WHERE account_id=? ORDER BY name, id;`
+
+	folders := []models.Folder{}
+	if err := s.db.SelectContext(ctx, &folders, query, accountID); err != nil {
+		return []models.Folder{}, err
+	}
+
+	return folders, nil
+}
+
+// FolderUpdate implements models.Store.
+func (s SqliteStore) FolderUpdate(ctx context.Context, folder models.Folder) (models.Folder, error) {
+	query := `UPDATE folders SET parent_id=?, name=?, updated_at=? WHERE id=? AND account_id=?
+		RETURNING ` + folderColumns + `;`
+
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return models.Folder{}, err
+	}
+	defer tx.Rollback()
+
+	var savedFolder models.Folder
+	err = tx.GetContext(ctx, &savedFolder, query, folder.ParentID, folder.Name, time.Now().UTC(), folder.ID, folder.AccountID)
+	if err != nil {
+		return models.Folder{}, mapError(err)
+	}
+
+	noteIDs, err := folderNoteIDs(ctx, tx, folder.AccountID, []int64{folder.ID})
+	if err != nil {
+		return models.Folder{}, err
+	}
+
+	if err := reindexNotes(ctx, tx, noteIDs); err != nil {
+		return models.Folder{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return models.Folder{}, err
+	}
+
+	return savedFolder, nil
+}
+
+// FolderDelete implements models.Store.
+func (s SqliteStore) FolderDelete(ctx context.Context, accountID int64, id int64) error {
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var folder models.Folder
+	if err := tx.GetContext(ctx, &folder, `SELECT `+folderColumns+` FROM folders WHERE id=? AND account_id=?;`, id, accountID); err != nil {
+		return mapError(err)
+	}
+
+	noteIDs, err := folderNoteIDs(ctx, tx, accountID, []int64{id})
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, `UPDATE notes SET folder_id=? WHERE folder_id=? AND account_id=?;`,
+		folder.ParentID, id, accountID); err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, `UPDATE folders SET parent_id=?, updated_at=? WHERE parent_id=? AND account_id=?;`,
+		folder.ParentID, time.Now().UTC(), id, accountID); err != nil {
+		return mapError(err)
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM folders WHERE id=? AND account_id=?;`, id, accountID); err != nil {
+		return err
+	}
+
+	if err := reindexNotes(ctx, tx, noteIDs); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// FolderDeleteTree implements models.Store.
+func (s SqliteStore) FolderDeleteTree(ctx context.Context, accountID int64, ids []int64) error {
+	in := placeholders(len(ids))
+	args := []any{accountID}
+	for _, id := range ids {
+		args = append(args, id)
+	}
+
+	now := time.Now().UTC()
+
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	noteIDs, err := folderNoteIDs(ctx, tx, accountID, ids)
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, `UPDATE notes SET deleted=true, updated_at=?, deleted_at=?
+		WHERE account_id=? AND folder_id IN (`+in+`) AND deleted=false;`, append([]any{now, now}, args...)...); err != nil {
+		return err
+	}
+
+	statements := []string{
+		`UPDATE notes SET folder_id=NULL WHERE account_id=? AND folder_id IN (` + in + `);`,
+		`UPDATE folders SET parent_id=NULL WHERE account_id=? AND id IN (` + in + `);`,
+	}
+
+	for _, statement := range statements {
+		if _, err := tx.ExecContext(ctx, statement, args...); err != nil {
+			return err
+		}
+	}
+
+	result, err := tx.ExecContext(ctx, `DELETE FROM folders WHERE account_id=? AND id IN (`+in+`);`, args...)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affected != int64(len(ids)) {
+		return models.ErrNotFound
+	}
+
+	if err := reindexNotes(ctx, tx, noteIDs); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// folderNoteIDs returns the IDs of the account's notes in the folders, including deleted notes.
+func folderNoteIDs(ctx context.Context, tx *sqlx.Tx, accountID int64, folderIDs []int64) ([]int64, error) {
+	args := []any{accountID}
+	for _, id := range folderIDs {
+		args = append(args, id)
+	}
+
+	noteIDs := []int64{}
+	if err := tx.SelectContext(ctx, &noteIDs, `SELECT id FROM notes WHERE account_id=? AND folder_id IN (`+
+		placeholders(len(folderIDs))+`);`, args...); err != nil {
+		return nil, err
+	}
+
+	return noteIDs, nil
+}
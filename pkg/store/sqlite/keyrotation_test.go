@@ -0,0 +1,389 @@
+package sqlite
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/oalexander6/passman/config"
+	"github.com/oalexander6/passman/pkg/keys"
+	"github.com/oalexander6/passman/pkg/models"
+)
+
+const legacySecret = "0123456789abcdef0123456789abcdef"
+
+var errInterrupted = errors.New("interrupted")
+
+func newTestModels(t *testing.T, store models.Store, encSecret string, masterKeys ...[]byte) *models.Models {
+	t.Helper()
+
+	provider, err := keys.NewStaticProvider(masterKeys...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &config.Config{Encryption: config.EncryptionConfig{EncSecret: encSecret, EncIV: "1234567890abcdef"}}
+	return models.New(store, cfg, provider, nil, nil)
+}
+
+func newMasterKey(t *testing.T) []byte {
+	t.Helper()
+
+	key, err := keys.NewDataKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return key
+}
+
+// setLegacyValue overwrites a note with a bare password encrypted the way notes were written
+// before types and envelope encryption, with AES-GCM directly under ENCRYPTION_SECRET.
+func setLegacyValue(t *testing.T, s *SqliteStore, note models.NoteGetResponse, accountID int64, password string) {
+	t.Helper()
+
+	block, err := aes.NewCipher([]byte(legacySecret))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		t.Fatal(err)
+	}
+
+	sealed := aead.Seal(nonce, nonce, []byte(password), []byte(fmt.Sprintf("passman:note:%d:account:%d", note.ID, accountID)))
+	value := "v2:" + base64.StdEncoding.EncodeToString(sealed)
+
+	if _, err := s.db.Exec(`UPDATE notes SET type='', value=? WHERE id=?;`, value, note.ID); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func loginRequest(name string, password string) models.NoteCreateRequest {
+	return models.NoteCreateRequest{
+		Name:       name,
+		Type:       models.NoteTypeLogin,
+		NoteFields: models.NoteFields{Login: &models.LoginFields{Username: "alice", Password: password}},
+	}
+}
+
+// rotationAccount is an account seeded with a note encrypted with its data key, a note still
+// encrypted with ENCRYPTION_SECRET and a note with a version still encrypted with it.
+type rotationAccount struct {
+	id        int64
+	current   int64
+	legacy    int64
+	versioned int64
+}
+
+func seedRotationAccount(t *testing.T, s *SqliteStore, m *models.Models, email string) rotationAccount {
+	t.Helper()
+	ctx := context.Background()
+
+	account := rotationAccount{id: createTestAccount(t, s, email).ID}
+
+	current, err := m.NoteCreate(ctx, account.id, loginRequest("current", "current-password"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	account.current = current.ID
+
+	legacy, err := m.NoteCreate(ctx, account.id, loginRequest("legacy", "placeholder"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	setLegacyValue(t, s, legacy, account.id, "legacy-password")
+	account.legacy = legacy.ID
+
+	versioned, err := m.NoteCreate(ctx, account.id, loginRequest("versioned", "placeholder"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	setLegacyValue(t, s, versioned, account.id, "legacy-version-password")
+	account.versioned = versioned.ID
+
+	// the legacy value is kept as a version
+	if _, err := m.NoteUpdate(ctx, account.id, versioned.ID, loginRequest("versioned", "updated-password")); err != nil {
+		t.Fatal(err)
+	}
+
+	return account
+}
+
+// checkRotatedAccount checks that every note and version of the account decrypts with the
+// models, and that they are all encrypted with the account's data key.
+func checkRotatedAccount(t *testing.T, s *SqliteStore, m *models.Models, account rotationAccount) {
+	t.Helper()
+	ctx := context.Background()
+
+	expected := map[int64]string{
+		account.current:   "current-password",
+		account.legacy:    "legacy-password",
+		account.versioned: "updated-password",
+	}
+
+	for noteID, password := range expected {
+		note, err := m.NoteGetByID(ctx, account.id, noteID, true)
+		if err != nil {
+			t.Fatalf("note %d: %v", noteID, err)
+		}
+
+		if note.Login == nil || note.Login.Password != password {
+			t.Fatalf("note %d: expected password %s, got %+v", noteID, password, note.Login)
+		}
+	}
+
+	versions, err := m.NoteVersionGetByNoteID(ctx, account.id, account.versioned)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(versions) != 1 {
+		t.Fatalf("expected 1 version, got %d", len(versions))
+	}
+
+	version, err := m.NoteVersionGetByID(ctx, account.id, account.versioned, versions[0].ID, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if version.Note.Login == nil || version.Note.Login.Password != "legacy-version-password" {
+		t.Fatalf("expected the version's password to be legacy-version-password, got %+v", version.Note.Login)
+	}
+
+	values := []string{}
+	if err := s.db.Select(&values, `SELECT value FROM notes WHERE account_id=?
+		UNION ALL SELECT value FROM note_versions WHERE account_id=?;`, account.id, account.id); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, value := range values {
+		if !strings.HasPrefix(value, "v3:") {
+			t.Fatalf("expected every value to be encrypted with the data key, got %q", value)
+		}
+	}
+}
+
+// checkWrappedBy checks that the account's data and index keys are wrapped by the master key.
+func checkWrappedBy(t *testing.T, s *SqliteStore, accountID int64, keyID string) {
+	t.Helper()
+
+	account, err := s.AccountGetByID(context.Background(), accountID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if keys.WrappedKeyID(account.DataKey) != keyID || keys.WrappedKeyID(account.IndexKey) != keyID {
+		t.Fatalf("account %d: expected its keys to be wrapped by %s, got %s and %s", accountID, keyID,
+			keys.WrappedKeyID(account.DataKey), keys.WrappedKeyID(account.IndexKey))
+	}
+}
+
+// interruptedStore fails to list accounts after the first batch, as if the server had stopped
+// part way through a rotation.
+type interruptedStore struct {
+	SqliteStore
+	batches int
+}
+
+func (s *interruptedStore) AccountListIDs(ctx context.Context, afterID int64, limit int) ([]int64, error) {
+	if s.batches == 1 {
+		return nil, errInterrupted
+	}
+	s.batches++
+
+	return s.SqliteStore.AccountListIDs(ctx, afterID, limit)
+}
+
+func TestRotateKeysResumes(t *testing.T) {
+	ctx := context.Background()
+	s := newMigratedStore(t)
+
+	oldKey, newKey := newMasterKey(t), newMasterKey(t)
+	oldProvider, _ := keys.NewStaticProvider(oldKey)
+	newProvider, _ := keys.NewStaticProvider(newKey, oldKey)
+
+	before := newTestModels(t, s, legacySecret, oldKey)
+	accounts := []rotationAccount{}
+	for i := range 3 {
+		accounts = append(accounts, seedRotationAccount(t, s, before, fmt.Sprintf("user%d@example.com", i)))
+	}
+
+	for _, account := range accounts {
+		checkWrappedBy(t, s, account.id, oldProvider.PrimaryKeyID())
+	}
+
+	// after `keys add`, keys wrapped by the old master key still open
+	added := newTestModels(t, s, legacySecret, newKey, oldKey)
+	for _, account := range accounts {
+		note, err := added.NoteGetByID(ctx, account.id, account.current, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if note.Login.Password != "current-password" {
+			t.Fatalf("expected current-password, got %s", note.Login.Password)
+		}
+	}
+
+	interrupted := &interruptedStore{SqliteStore: *s}
+	result, err := newTestModels(t, interrupted, legacySecret, newKey, oldKey).RotateKeys(ctx, models.KeyRotationOptions{BatchSize: 1})
+	if !errors.Is(err, errInterrupted) {
+		t.Fatalf("expected the rotation to be interrupted, got %v", err)
+	}
+
+	if result.Resumed || result.Accounts != 1 || result.DataKeysRewrapped != 1 || result.IndexKeysRewrapped != 1 ||
+		result.NotesReencrypted != 2 {
+		t.Fatalf("unexpected result of the interrupted rotation %+v", result)
+	}
+
+	checkpoint, err := s.KeyRotationGetIncomplete(ctx, newProvider.PrimaryKeyID())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if checkpoint.LastAccountID != accounts[0].id || checkpoint.CompletedAt != nil {
+		t.Fatalf("expected a checkpoint after account %d, got %+v", accounts[0].id, checkpoint)
+	}
+
+	checkWrappedBy(t, s, accounts[0].id, newProvider.PrimaryKeyID())
+	checkWrappedBy(t, s, accounts[1].id, oldProvider.PrimaryKeyID())
+
+	result, err = added.RotateKeys(ctx, models.KeyRotationOptions{BatchSize: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !result.Resumed || result.Accounts != 2 || result.DataKeysRewrapped != 2 || result.IndexKeysRewrapped != 2 ||
+		result.NotesReencrypted != 4 {
+		t.Fatalf("unexpected result of the resumed rotation %+v", result)
+	}
+
+	if _, err := s.KeyRotationGetIncomplete(ctx, newProvider.PrimaryKeyID()); !errors.Is(err, models.ErrNotFound) {
+		t.Fatalf("expected the rotation to be complete, got %v", err)
+	}
+
+	// with the old master key and ENCRYPTION_SECRET removed, everything still decrypts
+	after := newTestModels(t, s, "fedcba9876543210fedcba9876543210", newKey)
+	for _, account := range accounts {
+		checkWrappedBy(t, s, account.id, newProvider.PrimaryKeyID())
+		checkRotatedAccount(t, s, after, account)
+	}
+
+	// rotating again has nothing left to do
+	result, err = after.RotateKeys(ctx, models.KeyRotationOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result.Resumed || result.Accounts != 3 || result.DataKeysRewrapped != 0 || result.NotesReencrypted != 0 {
+		t.Fatalf("unexpected result of a repeated rotation %+v", result)
+	}
+}
+
+// editingStore runs edit once, after the rotation has read an account's notes and before it
+// writes them back, as a concurrent update would.
+type editingStore struct {
+	SqliteStore
+	edit func()
+}
+
+func (s *editingStore) NoteGetAllByAccountID(ctx context.Context, accountID int64) ([]models.Note, error) {
+	notes, err := s.SqliteStore.NoteGetAllByAccountID(ctx, accountID)
+	if s.edit != nil {
+		s.edit()
+		s.edit = nil
+	}
+
+	return notes, err
+}
+
+func TestRotateKeysKeepsConcurrentEdits(t *testing.T) {
+	ctx := context.Background()
+	s := newMigratedStore(t)
+
+	masterKey := newMasterKey(t)
+	m := newTestModels(t, s, legacySecret, masterKey)
+	account := seedRotationAccount(t, s, m, "alice@example.com")
+
+	store := &editingStore{SqliteStore: *s}
+	store.edit = func() {
+		if _, err := m.NoteUpdate(ctx, account.id, account.legacy, loginRequest("legacy", "edited-password")); err != nil {
+			t.Error(err)
+		}
+	}
+
+	result, err := newTestModels(t, store, legacySecret, masterKey).RotateKeys(ctx, models.KeyRotationOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the edit re-encrypted the note itself, but kept its legacy value as a second version
+	if result.NotesReencrypted != 2 {
+		t.Fatalf("expected 2 versions to be re-encrypted, got %d", result.NotesReencrypted)
+	}
+
+	note, err := m.NoteGetByID(ctx, account.id, account.legacy, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if note.Login.Password != "edited-password" {
+		t.Fatalf("expected the concurrent edit to be kept, got %s", note.Login.Password)
+	}
+}
+
+func TestKeyUpdatesCompareAndSwap(t *testing.T) {
+	ctx := context.Background()
+	s := newMigratedStore(t)
+
+	m := newTestModels(t, s, legacySecret, newMasterKey(t))
+	account := seedRotationAccount(t, s, m, "alice@example.com")
+
+	stored, err := s.AccountGetByID(ctx, account.id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.AccountUpdateDataKey(ctx, account.id, "stale", "new"); !errors.Is(err, models.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound for a stale data key, got %v", err)
+	}
+
+	if err := s.AccountUpdateIndexKey(ctx, account.id, "stale", "new"); !errors.Is(err, models.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound for a stale index key, got %v", err)
+	}
+
+	if err := s.NoteUpdateValue(ctx, account.id, account.current, "stale", "new"); !errors.Is(err, models.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound for a stale note value, got %v", err)
+	}
+
+	versions, err := s.NoteVersionGetAllByAccountID(ctx, account.id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.NoteVersionUpdateValue(ctx, account.id, versions[0].ID, "stale", "new"); !errors.Is(err, models.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound for a stale version value, got %v", err)
+	}
+
+	unchanged, err := s.AccountGetByID(ctx, account.id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if unchanged.DataKey != stored.DataKey || unchanged.IndexKey != stored.IndexKey {
+		t.Fatal("expected the account's keys to be unchanged")
+	}
+}
@@ -0,0 +1,51 @@
+package sqlite
+
+import (
+	"context"
+	"time"
+
+	"github.com/oalexander6/passman/pkg/models"
+)
+
+const keyRotationColumns = `id, key_id, last_account_id, started_at, updated_at, completed_at`
+
+// KeyRotationGetIncomplete implements models.Store.
+func (s SqliteStore) KeyRotationGetIncomplete(ctx context.Context, keyID string) (models.KeyRotation, error) {
+	query := `SELECT ` + keyRotationColumns + ` FROM key_rotations
+		WHERE key_id=? AND completed_at IS NULL ORDER BY id DESC LIMIT 1;`
+
+	var rotation models.KeyRotation
+	if err := s.db.GetContext(ctx, &rotation, query, keyID); err != nil {
+		return models.KeyRotation{}, mapError(err)
+	}
+
+	return rotation, nil
+}
+
+// KeyRotationCreate implements models.Store.
+func (s SqliteStore) KeyRotationCreate(ctx context.Context, keyID string) (models.KeyRotation, error) {
+	query := `INSERT INTO key_rotations (key_id, last_account_id, started_at, updated_at)
+		VALUES (?, 0, ?, ?)
+		RETURNING ` + keyRotationColumns + `;`
+
+	now := time.Now().UTC()
+
+	var rotation models.KeyRotation
+	if err := s.db.GetContext(ctx, &rotation, query, keyID, now, now); err != nil {
+		return models.KeyRotation{}, mapError(err)
+	}
+
+	return rotation, nil
+}
+
+// KeyRotationUpdate implements models.Store.
+func (s SqliteStore) KeyRotationUpdate(ctx context.Context, rotation models.KeyRotation) error {
+	query := `UPDATE key_rotations SET last_account_id=?, updated_at=?, completed_at=? WHERE id=?;`
+
+	result, err := s.db.ExecContext(ctx, query, rotation.LastAccountID, time.Now().UTC(), rotation.CompletedAt, rotation.ID)
+	if err != nil {
+		return err
+	}
+
+	return requireOneRow(result)
+}
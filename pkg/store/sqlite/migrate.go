@@ -0,0 +1,157 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+	"github.com/oalexander6/passman/pkg/store/migrate"
+)
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// lockRetryInterval is how long to wait before retrying when another process holds the
+// database write lock.
+const lockRetryInterval = 250 * time.Millisecond
+
+var errNotLocked = errors.New("migration lock is not held")
+
+var migrationsTableSchema = `
+CREATE TABLE IF NOT EXISTS schema_migrations (
+	version    INTEGER PRIMARY KEY,
+	name       TEXT NOT NULL,
+	applied_at TIMESTAMP NOT NULL
+);
+`
+
+// Migrator returns a migrator for the embedded SQLite migrations.
+func (s SqliteStore) Migrator() (*migrate.Migrator, error) {
+	migrations, err := migrate.Load(migrationFiles, "migrations")
+	if err != nil {
+		return nil, err
+	}
+
+	return migrate.New(&migrationDriver{db: s.db.DB}, migrations), nil
+}
+
+// migrationDriver implements migrate.Driver. SQLite has no advisory locks, so the lock is
+// an IMMEDIATE transaction that holds the database write lock for the whole run. Each
+// migration is applied inside a savepoint so a failure only reverts that migration.
+type migrationDriver struct {
+	db   *sql.DB
+	conn *sql.Conn
+}
+
+// Lock implements migrate.Driver.
+func (d *migrationDriver) Lock(ctx context.Context) error {
+	conn, err := d.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+
+	_, err = conn.ExecContext(ctx, `BEGIN IMMEDIATE;`)
+	for isBusy(err) {
+		select {
+		case <-ctx.Done():
+			conn.Close()
+			return ctx.Err()
+		case <-time.After(lockRetryInterval):
+		}
+
+		_, err = conn.ExecContext(ctx, `BEGIN IMMEDIATE;`)
+	}
+	if err != nil {
+		conn.Close()
+		return err
+	}
+
+	d.conn = conn
+
+	if _, err := conn.ExecContext(ctx, migrationsTableSchema); err != nil {
+		d.Unlock(ctx)
+		return err
+	}
+
+	return nil
+}
+
+// Unlock implements migrate.Driver.
+func (d *migrationDriver) Unlock(ctx context.Context) error {
+	if d.conn == nil {
+		return errNotLocked
+	}
+
+	defer func() {
+		d.conn.Close()
+		d.conn = nil
+	}()
+
+	_, err := d.conn.ExecContext(ctx, `COMMIT;`)
+	return err
+}
+
+// Applied implements migrate.Driver.
+func (d *migrationDriver) Applied(ctx context.Context) ([]migrate.AppliedMigration, error) {
+	if d.conn == nil {
+		return nil, errNotLocked
+	}
+
+	rows, err := d.conn.QueryContext(ctx, `SELECT version, applied_at FROM schema_migrations ORDER BY version;`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var applied []migrate.AppliedMigration
+	for rows.Next() {
+		var a migrate.AppliedMigration
+		if err := rows.Scan(&a.Version, &a.AppliedAt); err != nil {
+			return nil, err
+		}
+		applied = append(applied, a)
+	}
+
+	return applied, rows.Err()
+}
+
+// Apply implements migrate.Driver.
+func (d *migrationDriver) Apply(ctx context.Context, migration migrate.Migration, up bool) error {
+	if d.conn == nil {
+		return errNotLocked
+	}
+
+	if _, err := d.conn.ExecContext(ctx, `SAVEPOINT migration;`); err != nil {
+		return err
+	}
+
+	var err error
+	if up {
+		if _, err = d.conn.ExecContext(ctx, migration.Up); err == nil {
+			_, err = d.conn.ExecContext(ctx, `INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?);`,
+				migration.Version, migration.Name, time.Now().UTC())
+		}
+	} else {
+		if _, err = d.conn.ExecContext(ctx, migration.Down); err == nil {
+			_, err = d.conn.ExecContext(ctx, `DELETE FROM schema_migrations WHERE version=?;`, migration.Version)
+		}
+	}
+
+	if err != nil {
+		d.conn.ExecContext(ctx, `ROLLBACK TO migration;`)
+		d.conn.ExecContext(ctx, `RELEASE migration;`)
+		return err
+	}
+
+	_, err = d.conn.ExecContext(ctx, `RELEASE migration;`)
+	return err
+}
+
+// isBusy reports whether err is caused by another connection holding the write lock.
+func isBusy(err error) bool {
+	var sqliteErr sqlite3.Error
+	return errors.As(err, &sqliteErr) && sqliteErr.Code == sqlite3.ErrBusy
+}
@@ -0,0 +1,142 @@
+package sqlite
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/oalexander6/passman/pkg/store/migrate"
+)
+
+// newTestStore opens an empty database in a temporary directory.
+func newTestStore(t *testing.T) *SqliteStore {
+	t.Helper()
+
+	db, err := sqlx.Connect("sqlite3", "file:"+filepath.Join(t.TempDir(), "passman.db")+"?_foreign_keys=on")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	var fts5 bool
+	if err := db.Get(&fts5, `SELECT sqlite_compileoption_used('ENABLE_FTS5');`); err != nil || !fts5 {
+		t.Skip("SQLite was built without FTS5, run the tests with -tags sqlite_fts5")
+	}
+
+	return &SqliteStore{db: db}
+}
+
+// userTables returns the tables the migrations created, leaving out schema_migrations and
+// SQLite's own tables.
+func userTables(t *testing.T, s *SqliteStore) []string {
+	t.Helper()
+
+	tables := []string{}
+	if err := s.db.Select(&tables, `SELECT name FROM sqlite_master WHERE type='table'
+		AND name<>'schema_migrations' AND name NOT LIKE 'sqlite_%' ORDER BY name;`); err != nil {
+		t.Fatal(err)
+	}
+
+	return tables
+}
+
+func TestMigrations(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStore(t)
+
+	migrator, err := s.Migrator()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	migrations, err := migrate.Load(migrationFiles, "migrations")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := migrator.Check(ctx); !errors.Is(err, migrate.ErrSchemaMismatch) {
+		t.Fatalf("expected ErrSchemaMismatch before migrating, got %v", err)
+	}
+
+	applied, err := migrator.Up(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(applied) != len(migrations) {
+		t.Fatalf("expected %d migrations to be applied, got %d", len(migrations), len(applied))
+	}
+
+	if err := migrator.Check(ctx); err != nil {
+		t.Fatalf("expected the schema to match after migrating, got %v", err)
+	}
+
+	if applied, err := migrator.Up(ctx); err != nil || len(applied) != 0 {
+		t.Fatalf("expected nothing to apply, got %d migrations and %v", len(applied), err)
+	}
+
+	reverted, err := migrator.Down(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(reverted) != 1 || reverted[0].Version != migrator.LatestVersion() {
+		t.Fatalf("expected the latest migration to be reverted, got %+v", reverted)
+	}
+
+	if err := migrator.Check(ctx); !errors.Is(err, migrate.ErrSchemaMismatch) {
+		t.Fatalf("expected ErrSchemaMismatch with a migration reverted, got %v", err)
+	}
+
+	if applied, err := migrator.Up(ctx); err != nil || len(applied) != 1 {
+		t.Fatalf("expected the reverted migration to be applied again, got %d migrations and %v", len(applied), err)
+	}
+
+	reverted, err = migrator.Down(ctx, len(migrations))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(reverted) != len(migrations) {
+		t.Fatalf("expected %d migrations to be reverted, got %d", len(migrations), len(reverted))
+	}
+
+	if tables := userTables(t, s); len(tables) != 0 {
+		t.Fatalf("expected every table to be dropped, found %v", tables)
+	}
+
+	if _, err := migrator.Down(ctx, 1); !errors.Is(err, migrate.ErrNoMigrations) {
+		t.Fatalf("expected ErrNoMigrations, got %v", err)
+	}
+
+	// migrating again from scratch checks the down migrations left nothing behind
+	if _, err := migrator.Up(ctx); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCheckUnknownMigration(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStore(t)
+
+	migrator, err := s.Migrator()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := migrator.Up(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// a database migrated by a newer build
+	if _, err := s.db.Exec(`INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, 'from_the_future', CURRENT_TIMESTAMP);`,
+		migrator.LatestVersion()+1); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := migrator.Check(ctx); !errors.Is(err, migrate.ErrSchemaMismatch) {
+		t.Fatalf("expected ErrSchemaMismatch, got %v", err)
+	}
+}
@@ -0,0 +1,23 @@
+CREATE TABLE IF NOT EXISTS accounts (
+	id         INTEGER PRIMARY KEY AUTOINCREMENT,
+	email      TEXT NOT NULL,
+	password   TEXT NOT NULL,
+	name       TEXT NOT NULL,
+	created_at TIMESTAMP NOT NULL,
+	updated_at TIMESTAMP NOT NULL,
+	deleted    BOOLEAN NOT NULL
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS accounts_email_idx ON accounts (email);
+
+CREATE TABLE IF NOT EXISTS notes (
+	id         INTEGER PRIMARY KEY AUTOINCREMENT,
+	account_id INTEGER NOT NULL REFERENCES accounts (id),
+	name       TEXT NOT NULL,
+	value      TEXT NOT NULL,
+	created_at TIMESTAMP NOT NULL,
+	updated_at TIMESTAMP NOT NULL,
+	deleted    BOOLEAN NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS notes_account_id_idx ON notes (account_id);
@@ -0,0 +1,2 @@
+-- Notes encrypted with an account data key can no longer be decrypted once this is reverted.
+ALTER TABLE accounts DROP COLUMN data_key;
@@ -0,0 +1 @@
+ALTER TABLE accounts ADD COLUMN data_key TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,8 @@
+CREATE TABLE key_rotations (
+	id              INTEGER PRIMARY KEY AUTOINCREMENT,
+	key_id          TEXT NOT NULL,
+	last_account_id INTEGER NOT NULL,
+	started_at      TIMESTAMP NOT NULL,
+	updated_at      TIMESTAMP NOT NULL,
+	completed_at    TIMESTAMP
+);
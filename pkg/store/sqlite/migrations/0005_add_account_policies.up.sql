@@ -0,0 +1 @@
+ALTER TABLE accounts ADD COLUMN min_password_strength INTEGER NOT NULL DEFAULT 0;
@@ -0,0 +1,2 @@
+-- Typed notes can no longer be read once this is reverted.
+ALTER TABLE notes DROP COLUMN type;
@@ -0,0 +1,2 @@
+-- Notes saved before types were introduced keep an empty type, and are read as logins.
+ALTER TABLE notes ADD COLUMN type TEXT NOT NULL DEFAULT '';
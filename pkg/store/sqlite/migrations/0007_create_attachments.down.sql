@@ -0,0 +1,2 @@
+-- Attachment chunks are left in the blob store once this is reverted.
+DROP TABLE attachments;
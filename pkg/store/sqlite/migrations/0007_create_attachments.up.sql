@@ -0,0 +1,17 @@
+-- Attachments are written in chunks before they are marked complete. Incomplete rows belong to
+-- uploads in progress, and hold no key.
+CREATE TABLE attachments (
+	id           INTEGER PRIMARY KEY AUTOINCREMENT,
+	account_id   INTEGER NOT NULL REFERENCES accounts (id),
+	note_id      INTEGER NOT NULL REFERENCES notes (id),
+	name         TEXT NOT NULL,
+	content_type TEXT NOT NULL,
+	size         BIGINT NOT NULL,
+	chunks       BIGINT NOT NULL,
+	file_key     TEXT NOT NULL,
+	complete     BOOLEAN NOT NULL,
+	created_at   TIMESTAMP NOT NULL
+);
+
+CREATE INDEX attachments_account_id_idx ON attachments (account_id);
+CREATE INDEX attachments_note_id_idx ON attachments (note_id);
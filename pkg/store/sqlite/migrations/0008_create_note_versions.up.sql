@@ -0,0 +1,21 @@
+-- Notes written before versions were introduced are attributed to their owner.
+ALTER TABLE notes ADD COLUMN updated_by INTEGER NOT NULL DEFAULT 0;
+UPDATE notes SET updated_by=account_id;
+
+ALTER TABLE accounts ADD COLUMN max_note_versions INTEGER NOT NULL DEFAULT 10;
+
+-- Each version is a copy of a note as it was before an update, with its value still encrypted
+-- for the note it was copied from.
+CREATE TABLE note_versions (
+	id         INTEGER PRIMARY KEY AUTOINCREMENT,
+	note_id    INTEGER NOT NULL REFERENCES notes (id),
+	account_id INTEGER NOT NULL REFERENCES accounts (id),
+	name       TEXT NOT NULL,
+	type       TEXT NOT NULL,
+	value      TEXT NOT NULL,
+	updated_by INTEGER NOT NULL,
+	updated_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX note_versions_note_id_idx ON note_versions (note_id);
+CREATE INDEX note_versions_account_id_idx ON note_versions (account_id);
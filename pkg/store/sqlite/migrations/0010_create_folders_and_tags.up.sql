@@ -0,0 +1,34 @@
+-- Folders nest through parent_id, and folders without a parent are at the top of the vault.
+CREATE TABLE folders (
+	id         INTEGER PRIMARY KEY AUTOINCREMENT,
+	account_id INTEGER NOT NULL REFERENCES accounts (id),
+	parent_id  INTEGER REFERENCES folders (id),
+	name       TEXT NOT NULL,
+	created_at TIMESTAMP NOT NULL,
+	updated_at TIMESTAMP NOT NULL
+);
+
+-- Folder names are unique among their siblings, including top level folders.
+CREATE UNIQUE INDEX folders_parent_name_idx ON folders (account_id, COALESCE(parent_id, 0), name);
+
+-- SQLite can't drop a column that references another table, so folder_id has no foreign key and
+-- is kept consistent by the store instead.
+ALTER TABLE notes ADD COLUMN folder_id INTEGER;
+CREATE INDEX notes_folder_id_idx ON notes (folder_id);
+
+CREATE TABLE tags (
+	id         INTEGER PRIMARY KEY AUTOINCREMENT,
+	account_id INTEGER NOT NULL REFERENCES accounts (id),
+	name       TEXT NOT NULL,
+	created_at TIMESTAMP NOT NULL
+);
+
+CREATE UNIQUE INDEX tags_account_name_idx ON tags (account_id, name);
+
+CREATE TABLE note_tags (
+	note_id INTEGER NOT NULL REFERENCES notes (id),
+	tag_id  INTEGER NOT NULL REFERENCES tags (id),
+	PRIMARY KEY (note_id, tag_id)
+);
+
+CREATE INDEX note_tags_tag_id_idx ON note_tags (tag_id);
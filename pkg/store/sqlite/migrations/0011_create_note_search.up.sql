@@ -0,0 +1,13 @@
+-- The search index holds one row per note, keyed by the note's ID as its rowid. Every column is
+-- stored as lowercase words separated by spaces, so the tokenizer only splits on spaces. Only
+-- names, tags and folders are indexed, which are stored in plaintext anyway; usernames and URLs
+-- are encrypted, so they are never copied here. Notes without a row are indexed by the server in
+-- the background. FTS5 is only available when built with -tags sqlite_fts5.
+CREATE VIRTUAL TABLE note_search USING fts5 (
+	name,
+	tags,
+	folder,
+	account_id UNINDEXED,
+	tokenize = 'unicode61 remove_diacritics 2',
+	prefix = '2 3'
+);
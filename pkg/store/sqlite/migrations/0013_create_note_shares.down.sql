@@ -0,0 +1,7 @@
+-- Shares are lost once this is reverted, and so are item keys, which leaves every note that was
+-- ever shared unreadable. Only revert before any note has been shared.
+DROP TABLE note_shares;
+ALTER TABLE note_versions DROP COLUMN item_key;
+ALTER TABLE notes DROP COLUMN item_key;
+ALTER TABLE accounts DROP COLUMN private_key;
+ALTER TABLE accounts DROP COLUMN public_key;
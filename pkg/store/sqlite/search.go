@@ -0,0 +1,330 @@
+package sqlite
+
+import (
+	"context"
+	"slices"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/oalexander6/passman/pkg/models"
+)
+
+// maxFuzzyWords is the most misspellings of a term that a fuzzy search matches.
+const maxFuzzyWords = 20
+
+// NoteSearch implements models.Store.
+func (s SqliteStore) NoteSearch(ctx context.Context, accountID int64, query models.NoteSearchQuery) ([]models.Note, error) {
+	q := `SELECT ` + noteColumns + ` FROM notes WHERE account_id=? AND deleted=false`
+	args := []any{accountID}
+
+	for _, term := range query.Terms {
+		clause, termArgs, err := s.searchTerm(ctx, accountID, term, query.Fuzzy)
+		if err != nil {
+			return []models.Note{}, err
+		}
+
+		q += ` AND ` + clause
+		args = append(args, termArgs...)
+	}
+
+	if query.AfterID > 0 {
+		q += ` AND (name>? OR (name=? AND id>?))`
+		args = append(args, query.AfterName, query.AfterName, query.AfterID)
+	}
+
+	notes := []models.Note{}
+	if err := s.db.SelectContext(ctx, &notes, q+` ORDER BY name, id LIMIT ?;`, append(args, query.Limit)...); err != nil {
+		return []models.Note{}, err
+	}
+
+	if err := s.loadNoteTags(ctx, accountID, notes); err != nil {
+		return []models.Note{}, err
+	}
+
+	return notes, nil
+}
+
+// NoteGetUnindexed implements models.Store.
+func (s SqliteStore) NoteGetUnindexed(ctx context.Context, limit int) ([]models.Note, error) {
+	query := `SELECT ` + noteColumns + ` FROM notes WHERE id NOT IN (SELECT rowid FROM note_search) ORDER BY id LIMIT ?;`
+
+	notes := []models.Note{}
+	if err := s.db.SelectContext(ctx, &notes, query, limit); err != nil {
+		return []models.Note{}, err
+	}
+
+	return notes, nil
+}
+
+// NoteSearchIndex implements models.Store.
+func (s SqliteStore) NoteSearchIndex(ctx context.Context, accountID int64, id int64, fields models.NoteSearchFields, currentValue string) error {
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// the update takes the write lock, so the value can't change before the note is indexed
+	result, err := tx.ExecContext(ctx, `UPDATE notes SET value=value WHERE id=? AND account_id=? AND value=?;`, id, accountID, currentValue)
+	if err != nil {
+		return err
+	}
+
+	if err := requireOneRow(result); err != nil {
+		return err
+	}
+
+	if err := indexNote(ctx, tx, id, &fields); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// searchTerm returns the condition for notes matching the term, along with its arguments.
+func (s SqliteStore) searchTerm(ctx context.Context, accountID int64, term models.NoteSearchTerm, fuzzy bool) (string, []any, error) {
+	alternatives := []string{}
+	args := []any{}
+
+	if term.Word != "" {
+		match, err := s.searchMatch(ctx, accountID, term.Word, fuzzy)
+		if err != nil {
+			return "", nil, err
+		}
+
+		alternatives = append(alternatives, `id IN (SELECT rowid FROM note_search WHERE note_search MATCH ? AND account_id=?)`)
+		args = append(args, match, accountID)
+	}
+
+	for _, blind := range term.Blind {
+		if blind.Exact != "" {
+			alternatives = append(alternatives, `id IN (SELECT note_id FROM note_blind_indexes
+				WHERE account_id=? AND field=? AND kind='exact' AND token=?)`)
+			args = append(args, accountID, blind.Field, blind.Exact)
+			continue
+		}
+
+		// every n-gram must come from the same value of the field
+		alternatives = append(alternatives, `id IN (SELECT note_id FROM note_blind_indexes
+			WHERE account_id=? AND field=? AND kind='ngram' AND token IN (`+placeholders(len(blind.Ngrams))+`)
+			GROUP BY note_id, position HAVING COUNT(DISTINCT token)=?)`)
+		args = append(args, accountID, blind.Field)
+		for _, ngram := range blind.Ngrams {
+			args = append(args, ngram)
+		}
+		args = append(args, len(blind.Ngrams))
+	}
+
+	return `(` + strings.Join(alternatives, ` OR `) + `)`, args, nil
+}
+
+// searchMatch returns the FTS5 query for a word of a search. The word must be the start of a
+// word, and with fuzzy matching it may instead be a word indexed for the account that is a few
+// edits away from it.
+func (s SqliteStore) searchMatch(ctx context.Context, accountID int64, word string, fuzzy bool) (string, error) {
+	alternatives := []string{quoteFTS(word) + `*`}
+
+	if fuzzy {
+		words, err := s.fuzzyWords(ctx, accountID, word)
+		if err != nil {
+			return "", err
+		}
+
+		for _, w := range words {
+			alternatives = append(alternatives, quoteFTS(w))
+		}
+	}
+
+	return strings.Join(alternatives, ` OR `), nil
+}
+
+// fuzzyWords returns the words indexed for the account's notes that are within maxEdits of the
+// term, closest first. Only the account's own words are candidates, so that the words of other
+// accounts can neither crowd out its matches nor be learned from its results.
+func (s SqliteStore) fuzzyWords(ctx context.Context, accountID int64, term string) ([]string, error) {
+	edits := maxEdits(term)
+	if edits == 0 {
+		return nil, nil
+	}
+
+	length := len([]rune(term))
+
+	// the indexed columns hold lowercase words separated by spaces
+	rows := []string{}
+	if err := s.db.SelectContext(ctx, &rows, `SELECT name || ' ' || tags || ' ' || folder FROM note_search
+		WHERE rowid IN (SELECT id FROM notes WHERE account_id=? AND deleted=false);`, accountID); err != nil {
+		return nil, err
+	}
+
+	type match struct {
+		word  string
+		edits int
+	}
+
+	seen := map[string]bool{}
+	matches := []match{}
+	for _, row := range rows {
+		for _, candidate := range strings.Fields(row) {
+			if seen[candidate] {
+				continue
+			}
+			seen[candidate] = true
+
+			if l := len([]rune(candidate)); l < length-edits || l > length+edits {
+				continue
+			}
+
+			if d := editDistance(term, candidate); d > 0 && d <= edits {
+				matches = append(matches, match{candidate, d})
+			}
+		}
+	}
+
+	slices.SortFunc(matches, func(a, b match) int {
+		if a.edits != b.edits {
+			return a.edits - b.edits
+		}
+		return strings.Compare(a.word, b.word)
+	})
+
+	words := []string{}
+	for i := 0; i < len(matches) && i < maxFuzzyWords; i++ {
+		words = append(words, matches[i].word)
+	}
+
+	return words, nil
+}
+
+// maxEdits is how many typos a fuzzy search allows in a term. Short terms must be spelled
+// exactly, since almost every short word is a couple of edits from any other.
+func maxEdits(term string) int {
+	switch length := len([]rune(term)); {
+	case length < 4:
+		return 0
+	case length < 8:
+		return 1
+	default:
+		return 2
+	}
+}
+
+// editDistance returns the optimal string alignment distance between two words, which counts
+// insertions, deletions, substitutions and swaps of adjacent letters as one edit each.
+func editDistance(a string, b string) int {
+	ra, rb := []rune(a), []rune(b)
+
+	d := make([][]int, len(ra)+1)
+	for i := range d {
+		d[i] = make([]int, len(rb)+1)
+		d[i][0] = i
+	}
+	for j := range d[0] {
+		d[0][j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+
+			d[i][j] = min(d[i-1][j]+1, d[i][j-1]+1, d[i-1][j-1]+cost)
+			if i > 1 && j > 1 && ra[i-1] == rb[j-2] && ra[i-2] == rb[j-1] {
+				d[i][j] = min(d[i][j], d[i-2][j-2]+1)
+			}
+		}
+	}
+
+	return d[len(ra)][len(rb)]
+}
+
+// quoteFTS quotes a word as an FTS5 string, so it is never read as a query operator.
+func quoteFTS(word string) string {
+	return `"` + strings.ReplaceAll(word, `"`, `""`) + `"`
+}
+
+// indexNote replaces the note's row in the search index, and its blind indexes unless fields
+// is nil. Nil fields also leaves a note that isn't indexed yet for the background indexer.
+func indexNote(ctx context.Context, tx *sqlx.Tx, noteID int64, fields *models.NoteSearchFields) error {
+	if fields == nil {
+		var indexed bool
+		if err := tx.GetContext(ctx, &indexed, `SELECT EXISTS (SELECT 1 FROM note_search WHERE rowid=?);`, noteID); err != nil {
+			return err
+		}
+		if !indexed {
+			return nil
+		}
+	}
+
+	// the names of zero-knowledge notes are encrypted, so they aren't worth indexing
+	var note struct {
+		AccountID int64  `db:"account_id"`
+		Name      string `db:"name"`
+		Folder    string `db:"folder"`
+	}
+	if err := tx.GetContext(ctx, &note, `SELECT n.account_id, CASE WHEN a.vault_mode=? THEN '' ELSE n.name END AS name,
+			COALESCE(f.name, '') AS folder
+		FROM notes n JOIN accounts a ON a.id=n.account_id LEFT JOIN folders f ON f.id=n.folder_id WHERE n.id=?;`,
+		models.VaultModeZeroKnowledge, noteID); err != nil {
+		return mapError(err)
+	}
+
+	tags := []string{}
+	if err := tx.SelectContext(ctx, &tags, `SELECT t.name FROM note_tags nt JOIN tags t ON t.id=nt.tag_id
+		WHERE nt.note_id=?;`, noteID); err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM note_search WHERE rowid=?;`, noteID); err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, `INSERT INTO note_search (rowid, name, tags, folder, account_id) VALUES (?, ?, ?, ?, ?);`,
+		noteID, models.SearchText(note.Name), models.SearchText(tags...), models.SearchText(note.Folder), note.AccountID); err != nil {
+		return err
+	}
+
+	if fields == nil {
+		return nil
+	}
+
+	return setBlindIndexes(ctx, tx, noteID, note.AccountID, fields.Indexes)
+}
+
+// setBlindIndexes replaces the blind indexes of the note.
+func setBlindIndexes(ctx context.Context, tx *sqlx.Tx, noteID int64, accountID int64, indexes []models.BlindIndex) error {
+	if _, err := tx.ExecContext(ctx, `DELETE FROM note_blind_indexes WHERE note_id=?;`, noteID); err != nil {
+		return err
+	}
+
+	for position, index := range indexes {
+		rows := []string{`(?, ?, ?, ?, 'exact', ?)`}
+		args := []any{noteID, accountID, index.Field, position, index.Exact}
+
+		for _, ngram := range index.Ngrams {
+			rows = append(rows, `(?, ?, ?, ?, 'ngram', ?)`)
+			args = append(args, noteID, accountID, index.Field, position, ngram)
+		}
+
+		// clients may send an n-gram token more than once
+		if _, err := tx.ExecContext(ctx, `INSERT OR IGNORE INTO note_blind_indexes (note_id, account_id, field, position, kind, token)
+			VALUES `+strings.Join(rows, `, `)+`;`, args...); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// reindexNotes refreshes the search index rows of the notes, such as after the name of their
+// folder or one of their tags has changed.
+func reindexNotes(ctx context.Context, tx *sqlx.Tx, noteIDs []int64) error {
+	for _, noteID := range noteIDs {
+		if err := indexNote(ctx, tx, noteID, nil); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,98 @@
+package sqlite
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"testing"
+
+	"github.com/oalexander6/passman/pkg/models"
+)
+
+// newMigratedStore opens an empty database with every migration applied.
+func newMigratedStore(t *testing.T) *SqliteStore {
+	t.Helper()
+
+	s := newTestStore(t)
+
+	migrator, err := s.Migrator()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := migrator.Up(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	return s
+}
+
+func createTestAccount(t *testing.T, s *SqliteStore, email string) models.Account {
+	t.Helper()
+
+	account, err := s.AccountCreate(context.Background(), models.Account{Email: email, VaultMode: models.VaultModeServer})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return account
+}
+
+func createTestNote(t *testing.T, s *SqliteStore, accountID int64, name string) models.Note {
+	t.Helper()
+
+	search := models.NoteSearchFields{}
+	note, err := s.NoteCreate(context.Background(), models.Note{AccountID: accountID, Name: name, Type: "login", Search: &search},
+		func(note models.Note) (models.Note, error) { return note, nil })
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return note
+}
+
+func TestFuzzyWordsScopedToAccount(t *testing.T) {
+	ctx := context.Background()
+	s := newMigratedStore(t)
+
+	alice := createTestAccount(t, s, "alice@example.com")
+	mallory := createTestAccount(t, s, "mallory@example.com")
+
+	mailbox := createTestNote(t, s, alice.ID, "Mailbox")
+
+	// more misspellings of the term than a fuzzy search matches, all sorting before alice's word
+	for r := 'a'; r <= 'z'; r++ {
+		createTestNote(t, s, mallory.ID, fmt.Sprintf("mailbi%c", r))
+	}
+
+	words, err := s.fuzzyWords(ctx, alice.ID, "mailbix")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !slices.Equal(words, []string{"mailbox"}) {
+		t.Fatalf("expected only alice's word, got %v", words)
+	}
+
+	notes, err := s.NoteSearch(ctx, alice.ID, models.NoteSearchQuery{
+		Terms: []models.NoteSearchTerm{{Word: "mailbix"}},
+		Fuzzy: true,
+		Limit: 10,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(notes) != 1 || notes[0].ID != mailbox.ID {
+		t.Fatalf("expected alice's note, got %+v", notes)
+	}
+
+	words, err = s.fuzzyWords(ctx, mallory.ID, "mailbix")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(words) != maxFuzzyWords || slices.Contains(words, "mailbox") {
+		t.Fatalf("expected %d of mallory's words without alice's, got %v", maxFuzzyWords, words)
+	}
+}
@@ -0,0 +1,196 @@
+package sqlite
+
+import (
+	"context"
+	"time"
+
+	"github.com/oalexander6/passman/pkg/models"
+)
+
+// shareQuery selects shares along with the emails of their owner and recipient.
+const shareQuery = `SELECT s.id, s.note_id, s.owner_id, s.recipient_id, s.permission, s.encrypted_key,
+		o.email AS owner_email, r.email AS recipient_email, s.created_at, s.updated_at
+	FROM note_shares s JOIN accounts o ON o.id=s.owner_id JOIN accounts r ON r.id=s.recipient_id`
+
+// AccountSetKeyPair implements models.Store.
+func (s SqliteStore) AccountSetKeyPair(ctx context.Context, id int64, publicKey string, privateKey string) error {
+	query := `UPDATE accounts SET public_key=?, private_key=? WHERE id=? AND public_key='' AND deleted=false;`
+
+	result, err := s.db.ExecContext(ctx, query, publicKey, privateKey, id)
+	if err != nil {
+		return err
+	}
+
+	return requireOneRow(result)
+}
+
+// NoteSetItemKey implements models.Store.
+func (s SqliteStore) NoteSetItemKey(ctx context.Context, accountID int64, id int64, currentValue string, newValue string, itemKey string) error {
+	query := `UPDATE notes SET value=?, item_key=? WHERE id=? AND account_id=? AND item_key='' AND value=?;`
+
+	result, err := s.db.ExecContext(ctx, query, newValue, itemKey, id, accountID, currentValue)
+	if err != nil {
+		return err
+	}
+
+	return requireOneRow(result)
+}
+
+// NoteShareCreate implements models.Store.
+func (s SqliteStore) NoteShareCreate(ctx context.Context, share models.NoteShare, itemKey string) (models.NoteShare, error) {
+	query := `INSERT INTO note_shares (note_id, owner_id, recipient_id, permission, encrypted_key, created_at, updated_at)
+		SELECT ?, ?, ?, ?, ?, ?, ?
+		WHERE EXISTS (SELECT 1 FROM notes WHERE id=? AND account_id=? AND deleted=false AND item_key=?)
+		RETURNING id;`
+
+	now := time.Now().UTC()
+
+	var id int64
+	if err := s.db.GetContext(ctx, &id, query, share.NoteID, share.OwnerID, share.RecipientID, share.Permission, share.EncryptedKey,
+		now, now, share.NoteID, share.OwnerID, itemKey); err != nil {
+		return models.NoteShare{}, mapError(err)
+	}
+
+	return s.NoteShareGetByID(ctx, share.OwnerID, share.NoteID, id)
+}
+
+// NoteShareGetByNoteID implements models.Store.
+func (s SqliteStore) NoteShareGetByNoteID(ctx context.Context, ownerID int64, noteID int64) ([]models.NoteShare, error) {
+	query := shareQuery + ` WHERE s.note_id=? AND s.owner_id=? ORDER BY s.id;`
+
+	shares := []models.NoteShare{}
+	if err := s.db.SelectContext(ctx, &shares, query, noteID, ownerID); err != nil {
+		return []models.NoteShare{}, err
+	}
+
+	return shares, nil
+}
+
+// NoteShareGetByID implements models.Store.
+func (s SqliteStore) NoteShareGetByID(ctx context.Context, ownerID int64, noteID int64, id int64) (models.NoteShare, error) {
+	query := shareQuery + ` WHERE s.id=? AND s.note_id=? AND s.owner_id=?;`
+
+	var share models.NoteShare
+	if err := s.db.GetContext(ctx, &share, query, id, noteID, ownerID); err != nil {
+		return models.NoteShare{}, mapError(err)
+	}
+
+	return share, nil
+}
+
+// NoteShareUpdatePermission implements models.Store.
+func (s SqliteStore) NoteShareUpdatePermission(ctx context.Context, ownerID int64, noteID int64, id int64, permission string) error {
+	query := `UPDATE note_shares SET permission=?, updated_at=? WHERE id=? AND note_id=? AND owner_id=?;`
+
+	result, err := s.db.ExecContext(ctx, query, permission, time.Now().UTC(), id, noteID, ownerID)
+	if err != nil {
+		return err
+	}
+
+	return requireOneRow(result)
+}
+
+// NoteShareDelete implements models.Store.
+func (s SqliteStore) NoteShareDelete(ctx context.Context, ownerID int64, noteID int64, id int64) error {
+	result, err := s.db.ExecContext(ctx, `DELETE FROM note_shares WHERE id=? AND note_id=? AND owner_id=?;`, id, noteID, ownerID)
+	if err != nil {
+		return err
+	}
+
+	return requireOneRow(result)
+}
+
+// NoteShareGetByRecipientID implements models.Store.
+func (s SqliteStore) NoteShareGetByRecipientID(ctx context.Context, recipientID int64) ([]models.NoteShare, error) {
+	query := shareQuery + ` JOIN notes n ON n.id=s.note_id
+		WHERE s.recipient_id=? AND n.deleted=false AND o.deleted=false ORDER BY s.id;`
+
+	shares := []models.NoteShare{}
+	if err := s.db.SelectContext(ctx, &shares, query, recipientID); err != nil {
+		return []models.NoteShare{}, err
+	}
+
+	return shares, nil
+}
+
+// NoteShareGetForRecipient implements models.Store.
+func (s SqliteStore) NoteShareGetForRecipient(ctx context.Context, recipientID int64, noteID int64) (models.NoteShare, error) {
+	query := shareQuery + ` JOIN notes n ON n.id=s.note_id
+		WHERE s.recipient_id=? AND s.note_id=? AND n.deleted=false AND o.deleted=false;`
+
+	var share models.NoteShare
+	if err := s.db.GetContext(ctx, &share, query, recipientID, noteID); err != nil {
+		return models.NoteShare{}, mapError(err)
+	}
+
+	return share, nil
+}
+
+// NoteShareRotate implements models.Store.
+func (s SqliteStore) NoteShareRotate(ctx context.Context, ownerID int64, id int64, note models.Note, currentValue string, keepVersions int,
+	shareKeys map[int64]string) (models.Note, error) {
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return models.Note{}, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, `DELETE FROM note_shares WHERE id=? AND note_id=? AND owner_id=?;`, id, note.ID, ownerID)
+	if err != nil {
+		return models.Note{}, err
+	}
+
+	if err := requireOneRow(result); err != nil {
+		return models.Note{}, err
+	}
+
+	if keepVersions > 0 {
+		if _, err := tx.ExecContext(ctx, `INSERT INTO note_versions (note_id, account_id, name, type, value, updated_by, updated_at, item_key)
+			SELECT id, account_id, name, type, value, updated_by, updated_at, item_key FROM notes
+			WHERE id=? AND account_id=? AND deleted=false;`, note.ID, ownerID); err != nil {
+			return models.Note{}, err
+		}
+	}
+
+	now := time.Now().UTC()
+
+	var savedNote models.Note
+	if err := tx.GetContext(ctx, &savedNote, `UPDATE notes SET value=?, item_key=?, updated_by=?, updated_at=?
+		WHERE id=? AND account_id=? AND deleted=false AND value=?
+		RETURNING `+noteColumns+`;`, note.Value, note.ItemKey, note.UpdatedBy, now, note.ID, ownerID, currentValue); err != nil {
+		return models.Note{}, mapError(err)
+	}
+	savedNote.Tags = note.Tags
+
+	// a share created since the keys were encrypted would be left with the old item key
+	remaining := []int64{}
+	if err := tx.SelectContext(ctx, &remaining, `SELECT id FROM note_shares WHERE note_id=? AND owner_id=?;`, note.ID, ownerID); err != nil {
+		return models.Note{}, err
+	}
+
+	if len(remaining) != len(shareKeys) {
+		return models.Note{}, models.ErrNotFound
+	}
+
+	for _, shareID := range remaining {
+		encryptedKey, ok := shareKeys[shareID]
+		if !ok {
+			return models.Note{}, models.ErrNotFound
+		}
+
+		if _, err := tx.ExecContext(ctx, `UPDATE note_shares SET encrypted_key=?, updated_at=? WHERE id=?;`, encryptedKey, now, shareID); err != nil {
+			return models.Note{}, err
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM note_versions WHERE note_id=? AND account_id=? AND id NOT IN (
+		SELECT id FROM note_versions WHERE note_id=? ORDER BY id DESC LIMIT ?);`, note.ID, ownerID, note.ID, keepVersions); err != nil {
+		return models.Note{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return models.Note{}, err
+	}
+
+	return savedNote, nil
+}
@@ -0,0 +1,183 @@
+package sqlite
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/oalexander6/passman/pkg/models"
+)
+
+// shareNote shares the owner's note with the recipient and returns the share's ID.
+func shareNote(t *testing.T, m *models.Models, ownerID int64, noteID int64, email string, permission string) int64 {
+	t.Helper()
+
+	share, err := m.NoteShareCreate(context.Background(), ownerID, noteID, models.NoteShareRequest{Email: email, Permission: permission})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return share.ID
+}
+
+// checkSharedPassword checks that the recipient reads the password of the shared note.
+func checkSharedPassword(t *testing.T, m *models.Models, recipientID int64, noteID int64, password string) {
+	t.Helper()
+
+	shared, err := m.SharedNoteGetByID(context.Background(), recipientID, noteID, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if shared.Note.Login == nil || shared.Note.Login.Password != password {
+		t.Fatalf("expected the recipient to read password %s, got %+v", password, shared.Note.Login)
+	}
+}
+
+func TestSharePermissions(t *testing.T) {
+	ctx := context.Background()
+	s := newMigratedStore(t)
+	m := newTestModels(t, s, legacySecret, newMasterKey(t))
+
+	owner := createTestAccount(t, s, "owner@example.com")
+	reader := createTestAccount(t, s, "reader@example.com")
+	writer := createTestAccount(t, s, "writer@example.com")
+
+	note, err := m.NoteCreate(ctx, owner.ID, loginRequest("shared", "owner-password"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	shareNote(t, m, owner.ID, note.ID, "reader@example.com", models.SharePermissionRead)
+	shareNote(t, m, owner.ID, note.ID, "writer@example.com", models.SharePermissionWrite)
+
+	checkSharedPassword(t, m, reader.ID, note.ID, "owner-password")
+
+	if _, err := m.SharedNoteUpdate(ctx, reader.ID, note.ID, loginRequest("shared", "reader-password")); !errors.Is(err, models.ErrReadOnlyShare) {
+		t.Fatalf("expected ErrReadOnlyShare for a read-only recipient, got %v", err)
+	}
+
+	checkSharedPassword(t, m, writer.ID, note.ID, "owner-password")
+
+	if _, err := m.SharedNoteUpdate(ctx, writer.ID, note.ID, loginRequest("shared", "writer-password")); err != nil {
+		t.Fatal(err)
+	}
+
+	// the owner and every recipient read the writer's update
+	updated, err := m.NoteGetByID(ctx, owner.ID, note.ID, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if updated.Login.Password != "writer-password" {
+		t.Fatalf("expected the owner to read the writer's update, got %s", updated.Login.Password)
+	}
+
+	checkSharedPassword(t, m, reader.ID, note.ID, "writer-password")
+
+	// an account the note isn't shared with can't read it
+	if _, err := m.SharedNoteGetByID(ctx, createTestAccount(t, s, "other@example.com").ID, note.ID, true); !errors.Is(err, models.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound without a share, got %v", err)
+	}
+}
+
+func TestShareRevoke(t *testing.T) {
+	ctx := context.Background()
+	s := newMigratedStore(t)
+	m := newTestModels(t, s, legacySecret, newMasterKey(t))
+
+	owner := createTestAccount(t, s, "owner@example.com")
+	kept := createTestAccount(t, s, "kept@example.com")
+	revoked := createTestAccount(t, s, "revoked@example.com")
+	rotated := createTestAccount(t, s, "rotated@example.com")
+
+	note, err := m.NoteCreate(ctx, owner.ID, loginRequest("shared", "owner-password"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	shareNote(t, m, owner.ID, note.ID, "kept@example.com", models.SharePermissionRead)
+	revokedShare := shareNote(t, m, owner.ID, note.ID, "revoked@example.com", models.SharePermissionWrite)
+	rotatedShare := shareNote(t, m, owner.ID, note.ID, "rotated@example.com", models.SharePermissionRead)
+
+	// revoking without rotation only removes the share
+	result, err := m.NoteShareRevoke(ctx, owner.ID, note.ID, revokedShare, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result.Rotated {
+		t.Fatal("expected the note not to be rotated")
+	}
+
+	if _, err := m.SharedNoteGetByID(ctx, revoked.ID, note.ID, true); !errors.Is(err, models.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound for a revoked recipient, got %v", err)
+	}
+
+	if _, err := m.SharedNoteUpdate(ctx, revoked.ID, note.ID, loginRequest("shared", "revoked-password")); !errors.Is(err, models.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound for a revoked recipient's update, got %v", err)
+	}
+
+	if shared, err := m.SharedNoteGetByRecipientID(ctx, revoked.ID, true); err != nil || len(shared) != 0 {
+		t.Fatalf("expected no notes shared with a revoked recipient, got %d and %v", len(shared), err)
+	}
+
+	checkSharedPassword(t, m, kept.ID, note.ID, "owner-password")
+
+	// revoking with rotation replaces the password and the item key
+	var old struct {
+		ItemKey      string `db:"item_key"`
+		EncryptedKey string `db:"encrypted_key"`
+	}
+	if err := s.db.Get(&old, `SELECT notes.item_key, note_shares.encrypted_key FROM notes
+		JOIN note_shares ON note_shares.note_id=notes.id WHERE note_shares.id=?;`, rotatedShare); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err = m.NoteShareRevoke(ctx, owner.ID, note.ID, rotatedShare, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !result.Rotated || result.Note == nil || result.Note.Login.Password == "owner-password" {
+		t.Fatalf("expected the password to be rotated, got %+v", result)
+	}
+
+	password := result.Note.Login.Password
+
+	stored, err := s.NoteGetByID(ctx, owner.ID, note.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if stored.ItemKey == "" || stored.ItemKey == old.ItemKey {
+		t.Fatal("expected the note to be given a new item key")
+	}
+
+	// the remaining recipient reads the new password, and the owner keeps the old one as a version
+	checkSharedPassword(t, m, kept.ID, note.ID, password)
+
+	versions, err := m.NoteVersionGetByNoteID(ctx, owner.ID, note.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(versions) != 1 {
+		t.Fatalf("expected the rotated note to be kept as a version, got %d versions", len(versions))
+	}
+
+	if _, err := m.SharedNoteGetByID(ctx, rotated.ID, note.ID, true); !errors.Is(err, models.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound for a revoked recipient, got %v", err)
+	}
+
+	// an item key the revoked recipient kept no longer opens the note
+	if _, err := s.db.Exec(`INSERT INTO note_shares (note_id, owner_id, recipient_id, permission, encrypted_key,
+		created_at, updated_at) VALUES (?, ?, ?, ?, ?, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP);`,
+		note.ID, owner.ID, rotated.ID, models.SharePermissionRead, old.EncryptedKey); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := m.SharedNoteGetByID(ctx, rotated.ID, note.ID, true); !errors.Is(err, models.ErrDecryptFailed) {
+		t.Fatalf("expected ErrDecryptFailed with the old item key, got %v", err)
+	}
+}
@@ -0,0 +1,174 @@
+package sqlite
+
+import (
+	"context"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/oalexander6/passman/pkg/models"
+)
+
+// tagQuery selects tags along with how many notes outside the trash have them.
+const tagQuery = `SELECT t.id, t.account_id, t.name, t.created_at, COUNT(n.id) AS notes
+	FROM tags t
+	LEFT JOIN note_tags nt ON nt.tag_id=t.id
+	LEFT JOIN notes n ON n.id=nt.note_id AND n.deleted=false`
+
+// TagCreate implements models.Store.
+func (s SqliteStore) TagCreate(ctx context.Context, tag models.Tag) (models.Tag, error) {
+	query := `INSERT INTO tags (account_id, name, created_at) VALUES (?, ?, ?)
+		RETURNING id, account_id, name, 0 AS notes, created_at;`
+
+	var savedTag models.Tag
+	if err := s.db.GetContext(ctx, &savedTag, query, tag.AccountID, tag.Name, time.Now().UTC()); err != nil {
+		return models.Tag{}, mapError(err)
+	}
+
+	return savedTag, nil
+}
+
+// TagGetByAccountID implements models.Store.
+func (s SqliteStore) TagGetByAccountID(ctx context.Context, accountID int64) ([]models.Tag, error) {
+	query := tagQuery + ` WHERE t.account_id=? GROUP BY t.id ORDER BY t.name;`
+
+	tags := []models.Tag{}
+	if err := s.db.SelectContext(ctx, &tags, query, accountID); err != nil {
+		return []models.Tag{}, err
+	}
+
+	return tags, nil
+}
+
+// TagUpdate implements models.Store.
+func (s SqliteStore) TagUpdate(ctx context.Context, accountID int64, id int64, name string) (models.Tag, error) {
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return models.Tag{}, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, `UPDATE tags SET name=? WHERE id=? AND account_id=?;`, name, id, accountID)
+	if err != nil {
+		return models.Tag{}, mapError(err)
+	}
+
+	if err := requireOneRow(result); err != nil {
+		return models.Tag{}, err
+	}
+
+	if err := reindexTagNotes(ctx, tx, id); err != nil {
+		return models.Tag{}, err
+	}
+
+	var tag models.Tag
+	if err := tx.GetContext(ctx, &tag, tagQuery+` WHERE t.id=? AND t.account_id=? GROUP BY t.id;`, id, accountID); err != nil {
+		return models.Tag{}, mapError(err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return models.Tag{}, err
+	}
+
+	return tag, nil
+}
+
+// TagDelete implements models.Store.
+func (s SqliteStore) TagDelete(ctx context.Context, accountID int64, id int64) error {
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	noteIDs := []int64{}
+	if err := tx.SelectContext(ctx, &noteIDs, `SELECT note_id FROM note_tags WHERE tag_id IN (
+		SELECT id FROM tags WHERE id=? AND account_id=?);`, id, accountID); err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM note_tags WHERE tag_id IN (SELECT id FROM tags WHERE id=? AND account_id=?);`,
+		id, accountID); err != nil {
+		return err
+	}
+
+	result, err := tx.ExecContext(ctx, `DELETE FROM tags WHERE id=? AND account_id=?;`, id, accountID)
+	if err != nil {
+		return err
+	}
+
+	if err := requireOneRow(result); err != nil {
+		return err
+	}
+
+	if err := reindexNotes(ctx, tx, noteIDs); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// setNoteTags replaces the tags of the note, creating the tags the account doesn't have yet.
+func setNoteTags(ctx context.Context, tx *sqlx.Tx, accountID int64, noteID int64, tags []string) error {
+	if _, err := tx.ExecContext(ctx, `DELETE FROM note_tags WHERE note_id=?;`, noteID); err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+
+	for _, tag := range tags {
+		if _, err := tx.ExecContext(ctx, `INSERT INTO tags (account_id, name, created_at) VALUES (?, ?, ?)
+			ON CONFLICT (account_id, name) DO NOTHING;`, accountID, tag, now); err != nil {
+			return err
+		}
+
+		if _, err := tx.ExecContext(ctx, `INSERT INTO note_tags (note_id, tag_id)
+			SELECT ?, id FROM tags WHERE account_id=? AND name=?;`, noteID, accountID, tag); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// reindexTagNotes refreshes the search index rows of the notes with the tag.
+func reindexTagNotes(ctx context.Context, tx *sqlx.Tx, tagID int64) error {
+	noteIDs := []int64{}
+	if err := tx.SelectContext(ctx, &noteIDs, `SELECT note_id FROM note_tags WHERE tag_id=?;`, tagID); err != nil {
+		return err
+	}
+
+	return reindexNotes(ctx, tx, noteIDs)
+}
+
+// loadNoteTags sets the tags of the account's notes, sorted by name.
+func (s SqliteStore) loadNoteTags(ctx context.Context, accountID int64, notes []models.Note) error {
+	if len(notes) == 0 {
+		return nil
+	}
+
+	query := `SELECT nt.note_id, t.name FROM note_tags nt JOIN tags t ON t.id=nt.tag_id WHERE t.account_id=?`
+	args := []any{accountID}
+	if len(notes) == 1 {
+		query += ` AND nt.note_id=?`
+		args = append(args, notes[0].ID)
+	}
+
+	rows := []struct {
+		NoteID int64  `db:"note_id"`
+		Name   string `db:"name"`
+	}{}
+	if err := s.db.SelectContext(ctx, &rows, query+` ORDER BY t.name;`, args...); err != nil {
+		return err
+	}
+
+	tags := make(map[int64][]string)
+	for _, row := range rows {
+		tags[row.NoteID] = append(tags[row.NoteID], row.Name)
+	}
+
+	for i := range notes {
+		notes[i].Tags = tags[notes[i].ID]
+	}
+
+	return nil
+}
@@ -0,0 +1,89 @@
+package sqlite
+
+import (
+	"context"
+	"time"
+
+	"github.com/oalexander6/passman/pkg/models"
+)
+
+// zeroed replaces a text column with as many zeros as it has characters.
+func zeroed(column string) string {
+	return `substr(hex(zeroblob(length(` + column + `))), 1, length(` + column + `))`
+}
+
+// NoteGetDeletedByAccountID implements models.Store.
+func (s SqliteStore) NoteGetDeletedByAccountID(ctx context.Context, accountID int64) ([]models.Note, error) {
+	query := `SELECT ` + noteColumns + ` FROM notes WHERE account_id=? AND deleted=true ORDER BY deleted_at DESC, id DESC;`
+
+	notes := []models.Note{}
+	if err := s.db.SelectContext(ctx, &notes, query, accountID); err != nil {
+		return []models.Note{}, err
+	}
+
+	return notes, nil
+}
+
+// NoteGetDeletedBefore implements models.Store.
+func (s SqliteStore) NoteGetDeletedBefore(ctx context.Context, before time.Time, limit int) ([]models.Note, error) {
+	query := `SELECT ` + noteColumns + ` FROM notes WHERE deleted=true AND deleted_at<? ORDER BY deleted_at LIMIT ?;`
+
+	notes := []models.Note{}
+	if err := s.db.SelectContext(ctx, &notes, query, before, limit); err != nil {
+		return []models.Note{}, err
+	}
+
+	return notes, nil
+}
+
+// NoteRestore implements models.Store.
+func (s SqliteStore) NoteRestore(ctx context.Context, accountID int64, id int64) error {
+	query := `UPDATE notes SET deleted=false, deleted_at=NULL, updated_at=? WHERE id=? AND account_id=? AND deleted=true;`
+
+	result, err := s.db.ExecContext(ctx, query, time.Now().UTC(), id, accountID)
+	if err != nil {
+		return err
+	}
+
+	return requireOneRow(result)
+}
+
+// NotePurge implements models.Store. Secure delete is enabled on the connection, so SQLite
+// also zeroes the freed pages.
+func (s SqliteStore) NotePurge(ctx context.Context, accountID int64, id int64) error {
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, `UPDATE notes SET name=`+zeroed("name")+`, value=`+zeroed("value")+`
+		WHERE id=? AND account_id=? AND deleted=true;`, id, accountID)
+	if err != nil {
+		return err
+	}
+
+	if err := requireOneRow(result); err != nil {
+		return err
+	}
+
+	statements := []string{
+		`UPDATE note_versions SET name=` + zeroed("name") + `, value=` + zeroed("value") + ` WHERE note_id=? AND account_id=?;`,
+		`UPDATE attachments SET name=` + zeroed("name") + `, file_key=` + zeroed("file_key") + ` WHERE note_id=? AND account_id=?;`,
+		`DELETE FROM note_versions WHERE note_id=? AND account_id=?;`,
+		`DELETE FROM attachments WHERE note_id=? AND account_id=?;`,
+		`DELETE FROM note_tags WHERE note_id IN (SELECT id FROM notes WHERE id=? AND account_id=?);`,
+		`DELETE FROM note_search WHERE rowid IN (SELECT id FROM notes WHERE id=? AND account_id=?);`,
+		`DELETE FROM note_blind_indexes WHERE note_id=? AND account_id=?;`,
+		`DELETE FROM note_shares WHERE note_id=? AND owner_id=?;`,
+		`DELETE FROM notes WHERE id=? AND account_id=?;`,
+	}
+
+	for _, statement := range statements {
+		if _, err := tx.ExecContext(ctx, statement, id, accountID); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
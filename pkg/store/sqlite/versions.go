@@ -0,0 +1,70 @@
+package sqlite
+
+import (
+	"context"
+
+	"github.com/oalexander6/passman/pkg/models"
+)
+
+const noteVersionColumns = `id, note_id, account_id, name, type, value, updated_by, updated_at, item_key`
+
+// NoteVersionGetByNoteID implements models.Store.
+func (s SqliteStore) NoteVersionGetByNoteID(ctx context.Context, accountID int64, noteID int64) ([]models.NoteVersion, error) {
+	query := `SELECT ` + noteVersionColumns + ` FROM note_versions WHERE note_id=? AND account_id=? ORDER BY id DESC;`
+
+	versions := []models.NoteVersion{}
+	if err := s.db.SelectContext(ctx, &versions, query, noteID, accountID); err != nil {
+		return []models.NoteVersion{}, err
+	}
+
+	return versions, nil
+}
+
+// NoteVersionGetByID implements models.Store.
+func (s SqliteStore) NoteVersionGetByID(ctx context.Context, accountID int64, noteID int64, id int64) (models.NoteVersion, error) {
+	query := `SELECT ` + noteVersionColumns + ` FROM note_versions WHERE id=? AND note_id=? AND account_id=?;`
+
+	var version models.NoteVersion
+	if err := s.db.GetContext(ctx, &version, query, id, noteID, accountID); err != nil {
+		return models.NoteVersion{}, mapError(err)
+	}
+
+	return version, nil
+}
+
+// NoteVersionGetAllByAccountID implements models.Store.
+func (s SqliteStore) NoteVersionGetAllByAccountID(ctx context.Context, accountID int64) ([]models.NoteVersion, error) {
+	query := `SELECT ` + noteVersionColumns + ` FROM note_versions WHERE account_id=? ORDER BY id;`
+
+	versions := []models.NoteVersion{}
+	if err := s.db.SelectContext(ctx, &versions, query, accountID); err != nil {
+		return []models.NoteVersion{}, err
+	}
+
+	return versions, nil
+}
+
+// NoteVersionUpdateValue implements models.Store.
+func (s SqliteStore) NoteVersionUpdateValue(ctx context.Context, accountID int64, id int64, currentValue string, newValue string) error {
+	query := `UPDATE note_versions SET value=? WHERE id=? AND account_id=? AND value=?;`
+
+	result, err := s.db.ExecContext(ctx, query, newValue, id, accountID, currentValue)
+	if err != nil {
+		return err
+	}
+
+	return requireOneRow(result)
+}
+
+// NoteVersionPrune implements models.Store.
+func (s SqliteStore) NoteVersionPrune(ctx context.Context, accountID int64, keep int) error {
+	query := `DELETE FROM note_versions WHERE id IN (
+		SELECT id FROM (
+			SELECT id, ROW_NUMBER() OVER (PARTITION BY note_id ORDER BY id DESC) AS n
+			FROM note_versions WHERE account_id=?
+		) WHERE n > ?
+	);`
+
+	_, err := s.db.ExecContext(ctx, query, accountID, keep)
+	return err
+}
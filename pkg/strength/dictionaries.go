@@ -0,0 +1,75 @@
+package strength
+
+import (
+	"bufio"
+	"bytes"
+	"embed"
+	"sync"
+	"unicode/utf8"
+)
+
+const (
+	dictionaryPasswords   = "passwords"
+	dictionaryEnglish     = "english"
+	dictionarySurnames    = "surnames"
+	dictionaryMaleNames   = "male_names"
+	dictionaryFemaleNames = "female_names"
+	dictionaryUserInputs  = "user_inputs"
+)
+
+// data holds the frequency lists, one lower case word per line from most to least common. They
+// come from the zxcvbn project: leaked passwords, English words by frequency in film and TV
+// subtitles, and US census names.
+//
+//go:embed data/*.txt
+var data embed.FS
+
+var rankedDictionaries = sync.OnceValue(func() map[string]map[string]int {
+	dictionaries := map[string]map[string]int{}
+
+	for _, name := range []string{dictionaryPasswords, dictionaryEnglish, dictionarySurnames, dictionaryMaleNames, dictionaryFemaleNames} {
+		contents, err := data.ReadFile("data/" + name + ".txt")
+		if err != nil {
+			panic(err)
+		}
+
+		ranked := map[string]int{}
+		scanner := bufio.NewScanner(bytes.NewReader(contents))
+		for scanner.Scan() {
+			if word := scanner.Text(); word != "" {
+				ranked[word] = len(ranked) + 1
+			}
+		}
+
+		dictionaries[name] = ranked
+	}
+
+	return dictionaries
+})
+
+var rankedMaxWordLength = sync.OnceValue(func() int {
+	maxWordLength := 0
+	for _, dictionary := range rankedDictionaries() {
+		for word := range dictionary {
+			maxWordLength = max(maxWordLength, utf8.RuneCountInString(word))
+		}
+	}
+
+	return maxWordLength
+})
+
+// l33tTable maps letters to the characters commonly substituted for them.
+var l33tTable = map[rune][]rune{
+	'a': {'4', '@'},
+	'b': {'8'},
+	'c': {'(', '{', '[', '<'},
+	'e': {'3'},
+	'g': {'6', '9'},
+	'i': {'1', '!', '|'},
+	'l': {'1', '|', '7'},
+	'o': {'0'},
+	's': {'$', '5'},
+	't': {'+', '7'},
+	'x': {'%'},
+	'z': {'2'},
+}
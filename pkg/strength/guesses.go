@@ -0,0 +1,246 @@
+package strength
+
+import (
+	"math"
+	"unicode"
+)
+
+const minYearSpace = 20
+
+// estimateGuesses returns the number of guesses an attacker needs to find the match's token,
+// given that they are searching for that kind of pattern. Matches that are only part of a
+// longer password are never counted as trivially cheap.
+func estimateGuesses(m match, passwordLength int) float64 {
+	if m.guesses > 0 {
+		return m.guesses
+	}
+
+	tokenLength := len([]rune(m.token))
+	minGuesses := 1.0
+	if tokenLength < passwordLength {
+		minGuesses = minSubmatchGuessesMultiChar
+		if tokenLength == 1 {
+			minGuesses = minSubmatchGuessesSingleChar
+		}
+	}
+
+	var guesses float64
+	switch m.pattern {
+	case patternBruteforce:
+		guesses = bruteforceGuesses(tokenLength)
+	case patternDictionary:
+		guesses = dictionaryGuesses(m)
+	case patternSpatial:
+		guesses = spatialGuesses(m)
+	case patternRepeat:
+		guesses = m.baseGuesses * float64(m.repeatCount)
+	case patternSequence:
+		guesses = sequenceGuesses(m)
+	case patternYear:
+		guesses = yearSpace(m.year)
+	case patternDate:
+		guesses = yearSpace(m.year) * 365
+		if m.separator != "" {
+			guesses *= 4
+		}
+	}
+
+	return math.Max(guesses, minGuesses)
+}
+
+func bruteforceGuesses(length int) float64 {
+	guesses := math.Pow(bruteforceCardinality, float64(length))
+	if math.IsInf(guesses, 1) {
+		guesses = math.MaxFloat64
+	}
+
+	minGuesses := float64(minSubmatchGuessesMultiChar + 1)
+	if length == 1 {
+		minGuesses = minSubmatchGuessesSingleChar + 1
+	}
+
+	return math.Max(guesses, minGuesses)
+}
+
+// dictionaryGuesses starts from the word's rank in its frequency list and multiplies by the ways
+// it could have been capitalized, substituted or reversed.
+func dictionaryGuesses(m match) float64 {
+	guesses := float64(m.rank) * uppercaseVariations(m.token) * l33tVariations(m)
+	if m.reversed {
+		guesses *= 2
+	}
+
+	return guesses
+}
+
+// uppercaseVariations counts the capitalizations an attacker tries before reaching the token's.
+// Capitalizing the first or last letter, or every letter, is common enough to count as one bit.
+func uppercaseVariations(token string) float64 {
+	runes := []rune(token)
+	upper, lower := 0, 0
+	for _, r := range runes {
+		if unicode.IsUpper(r) {
+			upper++
+		} else if unicode.IsLower(r) {
+			lower++
+		}
+	}
+
+	switch {
+	case upper == 0:
+		return 1
+	case lower == 0 || isStartUpper(runes) || isEndUpper(runes):
+		return 2
+	}
+
+	variations := 0.0
+	for i := 1; i <= min(upper, lower); i++ {
+		variations += binomial(upper+lower, i)
+	}
+
+	return variations
+}
+
+// isStartUpper reports whether only the first letter is upper case.
+func isStartUpper(runes []rune) bool {
+	if len(runes) < 2 || !unicode.IsUpper(runes[0]) {
+		return false
+	}
+
+	for _, r := range runes[1:] {
+		if unicode.IsUpper(r) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// isEndUpper reports whether only the last letter is upper case.
+func isEndUpper(runes []rune) bool {
+	if len(runes) < 2 || !unicode.IsUpper(runes[len(runes)-1]) {
+		return false
+	}
+
+	for _, r := range runes[:len(runes)-1] {
+		if unicode.IsUpper(r) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// l33tVariations counts the ways the substituted letters could have been chosen. Substituting
+// every occurrence of a letter, or none, counts as one bit.
+func l33tVariations(m match) float64 {
+	if !m.l33t {
+		return 1
+	}
+
+	variations := 1.0
+	for subbed, unsubbed := range m.substitutions {
+		s, u := 0, 0
+		for _, r := range m.token {
+			switch unicode.ToLower(r) {
+			case subbed:
+				s++
+			case unsubbed:
+				u++
+			}
+		}
+
+		if s == 0 || u == 0 {
+			variations *= 2
+			continue
+		}
+
+		possibilities := 0.0
+		for i := 1; i <= min(u, s); i++ {
+			possibilities += binomial(u+s, i)
+		}
+		variations *= possibilities
+	}
+
+	return variations
+}
+
+// spatialGuesses counts the keyboard walks up to the token's length with at most as many turns,
+// then multiplies by the ways shift could have been used.
+func spatialGuesses(m match) float64 {
+	graph := adjacencyGraphs()[m.graph]
+	starts := graph.startingPositions()
+	degree := graph.averageDegree()
+
+	length := len([]rune(m.token))
+	guesses := 0.0
+	for i := 2; i <= length; i++ {
+		possibleTurns := min(m.turns, i-1)
+		for j := 1; j <= possibleTurns; j++ {
+			guesses += binomial(i-1, j-1) * starts * math.Pow(degree, float64(j))
+		}
+	}
+
+	if m.shiftedCount > 0 {
+		shifted, unshifted := m.shiftedCount, length-m.shiftedCount
+		if shifted == 0 || unshifted == 0 {
+			guesses *= 2
+		} else {
+			variations := 0.0
+			for i := 1; i <= min(shifted, unshifted); i++ {
+				variations += binomial(shifted+unshifted, i)
+			}
+			guesses *= variations
+		}
+	}
+
+	return guesses
+}
+
+// sequenceGuesses assumes attackers try sequences starting at the obvious characters first.
+func sequenceGuesses(m match) float64 {
+	first := []rune(m.token)[0]
+
+	var base float64
+	switch {
+	case first == 'a' || first == 'A' || first == 'z' || first == 'Z' || first == '0' || first == '1' || first == '9':
+		base = 4
+	case isASCIIDigit(first):
+		base = 10
+	default:
+		base = 26
+	}
+
+	if !m.ascending {
+		base *= 2
+	}
+
+	return base * float64(len([]rune(m.token)))
+}
+
+func yearSpace(year int) float64 {
+	return float64(max(abs(year-referenceYear()), minYearSpace))
+}
+
+func binomial(n, k int) float64 {
+	if k > n {
+		return 0
+	}
+
+	result := 1.0
+	for i := 1; i <= k; i++ {
+		result *= float64(n - k + i)
+		result /= float64(i)
+	}
+
+	return result
+}
+
+func factorial(n int) float64 {
+	result := 1.0
+	for i := 2; i <= n; i++ {
+		result *= float64(i)
+	}
+
+	return result
+}
@@ -0,0 +1,111 @@
+package strength
+
+import (
+	"strings"
+	"sync"
+)
+
+const (
+	graphQwerty = "qwerty"
+	graphDvorak = "dvorak"
+	graphKeypad = "keypad"
+)
+
+// Keyboard layouts, one row per line with each key's unshifted and shifted characters. Each row
+// of a keyboard is indented one more space than the last to reflect the slant of real keys.
+const (
+	qwertyLayout = `
+` + "`~" + ` 1! 2@ 3# 4$ 5% 6^ 7& 8* 9( 0) -_ =+
+    qQ wW eE rR tT yY uU iI oO pP [{ ]} \|
+     aA sS dD fF gG hH jJ kK lL ;: '"
+      zZ xX cC vV bB nN mM ,< .> /?
+`
+
+	dvorakLayout = `
+` + "`~" + ` 1! 2@ 3# 4$ 5% 6^ 7& 8* 9( 0) [{ ]}
+    '" ,< .> pP yY fF gG cC rR lL /? =+ \|
+     aA oO eE uU iI dD hH tT nN sS -_
+      ;: qQ jJ kK xX bB mM wW vV zZ
+`
+
+	keypadLayout = `
+  / * -
+7 8 9 +
+4 5 6
+1 2 3
+  0 .
+`
+)
+
+// adjacencyGraph maps each character to the keys around it, in a fixed order of directions.
+// Missing neighbours are empty strings so the direction of each entry is preserved.
+type adjacencyGraph map[rune][]string
+
+var adjacencyGraphs = sync.OnceValue(func() map[string]adjacencyGraph {
+	return map[string]adjacencyGraph{
+		graphQwerty: buildAdjacencyGraph(qwertyLayout, true),
+		graphDvorak: buildAdjacencyGraph(dvorakLayout, true),
+		graphKeypad: buildAdjacencyGraph(keypadLayout, false),
+	}
+})
+
+// buildAdjacencyGraph works out the position of every key from its column in the layout. Slanted
+// keyboards have six neighbours per key, and aligned keypads have eight.
+func buildAdjacencyGraph(layout string, slanted bool) adjacencyGraph {
+	type position struct{ x, y int }
+	positions := map[position]string{}
+
+	tokenSize := len(strings.Fields(layout)[0])
+	xUnit := tokenSize + 1
+
+	for y, line := range strings.Split(layout, "\n") {
+		slant := 0
+		if slanted {
+			slant = y - 1
+		}
+
+		offset := 0
+		for _, token := range strings.Fields(line) {
+			index := strings.Index(line[offset:], token) + offset
+			offset = index + len(token)
+			positions[position{(index - slant) / xUnit, y}] = token
+		}
+	}
+
+	neighbours := func(x, y int) []position {
+		if slanted {
+			return []position{{x - 1, y}, {x, y - 1}, {x + 1, y - 1}, {x + 1, y}, {x, y + 1}, {x - 1, y + 1}}
+		}
+		return []position{{x - 1, y}, {x - 1, y - 1}, {x, y - 1}, {x + 1, y - 1}, {x + 1, y}, {x + 1, y + 1}, {x, y + 1}, {x - 1, y + 1}}
+	}
+
+	graph := adjacencyGraph{}
+	for p, token := range positions {
+		for _, r := range token {
+			for _, n := range neighbours(p.x, p.y) {
+				graph[r] = append(graph[r], positions[n])
+			}
+		}
+	}
+
+	return graph
+}
+
+// startingPositions and averageDegree describe how many ways a keyboard walk can begin and
+// continue, which is what an attacker enumerating walks has to search.
+func (g adjacencyGraph) startingPositions() float64 {
+	return float64(len(g))
+}
+
+func (g adjacencyGraph) averageDegree() float64 {
+	total := 0
+	for _, adjacent := range g {
+		for _, key := range adjacent {
+			if key != "" {
+				total++
+			}
+		}
+	}
+
+	return float64(total) / float64(len(g))
+}
@@ -0,0 +1,670 @@
+package strength
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	patternBruteforce = "bruteforce"
+	patternDictionary = "dictionary"
+	patternSpatial    = "spatial"
+	patternRepeat     = "repeat"
+	patternSequence   = "sequence"
+	patternYear       = "year"
+	patternDate       = "date"
+
+	maxSequenceDelta = 5
+
+	dateMinYear = 1000
+	dateMaxYear = 2050
+)
+
+// match is a part of the password, from rune i to rune j inclusive, that fits a pattern.
+// Only the fields relevant to the pattern are set.
+type match struct {
+	pattern string
+	i, j    int
+	token   string
+	guesses float64
+
+	// dictionary
+	dictionary  string
+	matchedWord string
+	rank        int
+	reversed    bool
+	l33t        bool
+	// substitutions maps each l33t character in the token to the letter it replaced.
+	substitutions map[rune]rune
+
+	// spatial
+	graph        string
+	turns        int
+	shiftedCount int
+
+	// repeat
+	baseToken   string
+	baseGuesses float64
+	repeatCount int
+
+	// sequence
+	sequenceSpace int
+	ascending     bool
+
+	// date and year
+	year      int
+	separator string
+}
+
+// matcher holds the ranked dictionaries to search, including one built from the user inputs.
+type matcher struct {
+	dictionaries map[string]map[string]int
+	// maxWordLength is the length of the longest word in any dictionary, which bounds the
+	// substrings worth looking up.
+	maxWordLength int
+}
+
+func newMatcher(userInputs []string) matcher {
+	dictionaries := make(map[string]map[string]int, len(rankedDictionaries())+1)
+	for name, dictionary := range rankedDictionaries() {
+		dictionaries[name] = dictionary
+	}
+
+	userDictionary := map[string]int{}
+	for _, input := range userInputs {
+		// emails and full names are matched both whole and by their parts
+		parts := append([]string{input}, strings.FieldsFunc(input, func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+		})...)
+
+		for _, part := range parts {
+			part = strings.ToLower(part)
+			if part != "" {
+				if _, ok := userDictionary[part]; !ok {
+					userDictionary[part] = len(userDictionary) + 1
+				}
+			}
+		}
+	}
+	dictionaries[dictionaryUserInputs] = userDictionary
+
+	maxWordLength := rankedMaxWordLength()
+	for word := range userDictionary {
+		maxWordLength = max(maxWordLength, utf8.RuneCountInString(word))
+	}
+
+	return matcher{dictionaries: dictionaries, maxWordLength: maxWordLength}
+}
+
+// omnimatch returns every pattern match found in the password, sorted by position.
+func omnimatch(password []rune, m matcher) []match {
+	var matches []match
+	matches = append(matches, m.dictionaryMatch(password)...)
+	matches = append(matches, m.reverseDictionaryMatch(password)...)
+	matches = append(matches, m.l33tMatch(password)...)
+	matches = append(matches, spatialMatch(password)...)
+	matches = append(matches, repeatMatch(password, m)...)
+	matches = append(matches, sequenceMatch(password)...)
+	matches = append(matches, yearMatch(password)...)
+	matches = append(matches, dateMatch(password)...)
+
+	sortMatches(matches)
+	return matches
+}
+
+func sortMatches(matches []match) {
+	sort.SliceStable(matches, func(a, b int) bool {
+		if matches[a].i != matches[b].i {
+			return matches[a].i < matches[b].i
+		}
+		return matches[a].j < matches[b].j
+	})
+}
+
+// dictionaryMatch finds every substring that is a word in one of the dictionaries.
+func (m matcher) dictionaryMatch(password []rune) []match {
+	lower := make([]rune, len(password))
+	for k, r := range password {
+		lower[k] = unicode.ToLower(r)
+	}
+
+	var matches []match
+	for name, dictionary := range m.dictionaries {
+		for i := range lower {
+			for j := i; j < len(lower) && j-i < m.maxWordLength; j++ {
+				word := string(lower[i : j+1])
+				if rank, ok := dictionary[word]; ok {
+					matches = append(matches, match{
+						pattern:     patternDictionary,
+						i:           i,
+						j:           j,
+						token:       string(password[i : j+1]),
+						dictionary:  name,
+						matchedWord: word,
+						rank:        rank,
+					})
+				}
+			}
+		}
+	}
+
+	return matches
+}
+
+// reverseDictionaryMatch finds dictionary words written backwards.
+func (m matcher) reverseDictionaryMatch(password []rune) []match {
+	reversed := reverseRunes(password)
+
+	matches := m.dictionaryMatch(reversed)
+	for k := range matches {
+		matches[k].token = string(reverseRunes([]rune(matches[k].token)))
+		matches[k].reversed = true
+		matches[k].i, matches[k].j = len(password)-1-matches[k].j, len(password)-1-matches[k].i
+	}
+
+	return matches
+}
+
+// l33tMatch finds dictionary words with letters replaced by look-alike digits and symbols. A
+// character such as '1' can stand for more than one letter, so every combination of the
+// substitutions present in the password is tried.
+func (m matcher) l33tMatch(password []rune) []match {
+	var matches []match
+
+	for _, substitutions := range l33tSubstitutionCombinations(password) {
+		if len(substitutions) == 0 {
+			continue
+		}
+
+		subbed := make([]rune, len(password))
+		for k, r := range password {
+			if letter, ok := substitutions[r]; ok {
+				subbed[k] = letter
+			} else {
+				subbed[k] = r
+			}
+		}
+
+		for _, dm := range m.dictionaryMatch(subbed) {
+			token := password[dm.i : dm.j+1]
+
+			// only keep the substitutions that appear in this token
+			used := map[rune]rune{}
+			for _, r := range token {
+				if letter, ok := substitutions[r]; ok {
+					used[r] = letter
+				}
+			}
+
+			// single character l33t matches are too noisy to be useful
+			if len(used) == 0 || len(token) <= 1 {
+				continue
+			}
+
+			dm.token = string(token)
+			dm.l33t = true
+			dm.substitutions = used
+			matches = append(matches, dm)
+		}
+	}
+
+	return dedupeMatches(matches)
+}
+
+// l33tSubstitutionCombinations returns every way to map the l33t characters in the password back
+// to a single letter each.
+func l33tSubstitutionCombinations(password []rune) []map[rune]rune {
+	present := map[rune][]rune{}
+	for letter, subs := range l33tTable {
+		for _, sub := range subs {
+			if containsRune(password, sub) {
+				present[sub] = append(present[sub], letter)
+			}
+		}
+	}
+
+	subs := make([]rune, 0, len(present))
+	for sub := range present {
+		subs = append(subs, sub)
+		sort.Slice(present[sub], func(a, b int) bool { return present[sub][a] < present[sub][b] })
+	}
+	sort.Slice(subs, func(a, b int) bool { return subs[a] < subs[b] })
+
+	combinations := []map[rune]rune{{}}
+	for _, sub := range subs {
+		var next []map[rune]rune
+		for _, combination := range combinations {
+			for _, letter := range present[sub] {
+				extended := make(map[rune]rune, len(combination)+1)
+				for k, v := range combination {
+					extended[k] = v
+				}
+				extended[sub] = letter
+				next = append(next, extended)
+			}
+		}
+		combinations = next
+	}
+
+	return combinations
+}
+
+// dedupeMatches removes matches that cover the same span of the same dictionary word, which the
+// l33t combinations produce when a substitution is not used by the word.
+func dedupeMatches(matches []match) []match {
+	seen := map[string]bool{}
+	result := matches[:0]
+
+	for _, m := range matches {
+		key := strconv.Itoa(m.i) + ":" + strconv.Itoa(m.j) + ":" + m.dictionary + ":" + m.matchedWord
+		if !seen[key] {
+			seen[key] = true
+			result = append(result, m)
+		}
+	}
+
+	return result
+}
+
+// spatialMatch finds runs of three or more characters that are next to each other on a
+// keyboard or keypad.
+func spatialMatch(password []rune) []match {
+	var matches []match
+	for name, graph := range adjacencyGraphs() {
+		matches = append(matches, spatialMatchGraph(password, name, graph)...)
+	}
+
+	return matches
+}
+
+func spatialMatchGraph(password []rune, graphName string, graph adjacencyGraph) []match {
+	var matches []match
+	keyboard := graphName == graphQwerty || graphName == graphDvorak
+
+	for i := 0; i < len(password)-1; {
+		j := i + 1
+		lastDirection := -1
+		turns := 0
+		shiftedCount := 0
+		if keyboard && isShifted(password[i]) {
+			shiftedCount = 1
+		}
+
+		for {
+			found := false
+			if j < len(password) {
+				for direction, adjacent := range graph[password[j-1]] {
+					if index := strings.IndexRune(adjacent, password[j]); adjacent != "" && index >= 0 {
+						found = true
+						// the second character of each key is the shifted one
+						if index > 0 {
+							shiftedCount++
+						}
+						if lastDirection != direction {
+							turns++
+							lastDirection = direction
+						}
+						break
+					}
+				}
+			}
+
+			if found {
+				j++
+				continue
+			}
+
+			if j-i > 2 {
+				matches = append(matches, match{
+					pattern:      patternSpatial,
+					i:            i,
+					j:            j - 1,
+					token:        string(password[i:j]),
+					graph:        graphName,
+					turns:        turns,
+					shiftedCount: shiftedCount,
+				})
+			}
+			i = j
+			break
+		}
+	}
+
+	return matches
+}
+
+func isShifted(r rune) bool {
+	return strings.ContainsRune(`~!@#$%^&*()_+{}|:"<>?`, r) || unicode.IsUpper(r)
+}
+
+// repeatMatch finds a substring repeated two or more times in a row. At each position the
+// repeat covering the most characters wins, and its base is the shortest substring that
+// repeats to produce it.
+func repeatMatch(password []rune, m matcher) []match {
+	var matches []match
+
+	for i := 0; i < len(password)-1; {
+		bestLength, bestBase := 0, 0
+		for base := 1; i+2*base <= len(password); base++ {
+			count := 1
+			for i+(count+1)*base <= len(password) &&
+				string(password[i+count*base:i+(count+1)*base]) == string(password[i:i+base]) {
+				count++
+			}
+
+			if count >= 2 && count*base > bestLength {
+				bestLength, bestBase = count*base, base
+			}
+		}
+
+		if bestLength == 0 {
+			i++
+			continue
+		}
+
+		baseRunes := password[i : i+bestBase]
+		baseGuesses, _ := mostGuessableMatchSequence(baseRunes, omnimatch(baseRunes, m))
+
+		matches = append(matches, match{
+			pattern:     patternRepeat,
+			i:           i,
+			j:           i + bestLength - 1,
+			token:       string(password[i : i+bestLength]),
+			baseToken:   string(baseRunes),
+			baseGuesses: baseGuesses,
+			repeatCount: bestLength / bestBase,
+		})
+		i += bestLength
+	}
+
+	return matches
+}
+
+// sequenceMatch finds runs where each character is a constant step from the last, such as
+// "abcd", "9753" or "zyx".
+func sequenceMatch(password []rune) []match {
+	if len(password) < 2 {
+		return nil
+	}
+
+	var matches []match
+	update := func(i, j, delta int) {
+		if j-i > 1 || abs(delta) == 1 {
+			if abs(delta) > 0 && abs(delta) <= maxSequenceDelta {
+				token := string(password[i : j+1])
+
+				space := 26
+				if isAll(token, unicode.IsDigit) {
+					space = 10
+				}
+
+				matches = append(matches, match{
+					pattern:       patternSequence,
+					i:             i,
+					j:             j,
+					token:         token,
+					sequenceSpace: space,
+					ascending:     delta > 0,
+				})
+			}
+		}
+	}
+
+	i := 0
+	lastDelta := int(password[1] - password[0])
+	for k := 1; k < len(password); k++ {
+		delta := int(password[k] - password[k-1])
+		if delta == lastDelta {
+			continue
+		}
+
+		update(i, k-1, lastDelta)
+		i = k - 1
+		lastDelta = delta
+	}
+	update(i, len(password)-1, lastDelta)
+
+	return matches
+}
+
+// yearMatch finds four digit years from 1900 to 2029.
+func yearMatch(password []rune) []match {
+	var matches []match
+
+	for i := 0; i+4 <= len(password); i++ {
+		token := string(password[i : i+4])
+		if !isAll(token, isASCIIDigit) {
+			continue
+		}
+
+		year, _ := strconv.Atoi(token)
+		if year >= 1900 && year <= 2029 {
+			matches = append(matches, match{pattern: patternYear, i: i, j: i + 3, token: token, year: year})
+		}
+	}
+
+	return matches
+}
+
+// dateSplits lists where a date of each length without separators may be split into its three
+// parts, for example 6: {2, 4} splits "110991" into 11 09 91.
+var dateSplits = map[int][][2]int{
+	4: {{1, 2}, {2, 3}},
+	5: {{1, 3}, {2, 3}},
+	6: {{1, 2}, {2, 4}, {4, 5}},
+	7: {{1, 3}, {2, 3}, {4, 5}, {4, 6}},
+	8: {{2, 4}, {4, 6}},
+}
+
+// dateMatch finds dates in day, month and year order in any arrangement, with two or four digit
+// years, either run together ("13121991") or with a consistent separator ("13.12.91").
+func dateMatch(password []rune) []match {
+	var matches []match
+
+	for i := 0; i+4 <= len(password); i++ {
+		for j := i + 3; j <= i+7 && j < len(password); j++ {
+			token := string(password[i : j+1])
+			if !isAll(token, isASCIIDigit) {
+				continue
+			}
+
+			bestYear, found := 0, false
+			for _, split := range dateSplits[len(token)] {
+				parts := [3]int{}
+				parts[0], _ = strconv.Atoi(token[:split[0]])
+				parts[1], _ = strconv.Atoi(token[split[0]:split[1]])
+				parts[2], _ = strconv.Atoi(token[split[1]:])
+
+				if year, ok := mapIntsToYear(parts); ok {
+					if !found || abs(year-referenceYear()) < abs(bestYear-referenceYear()) {
+						bestYear, found = year, true
+					}
+				}
+			}
+
+			if found {
+				matches = append(matches, match{pattern: patternDate, i: i, j: j, token: token, year: bestYear})
+			}
+		}
+	}
+
+	for i := 0; i+6 <= len(password); i++ {
+		for j := i + 5; j <= i+9 && j < len(password); j++ {
+			token := string(password[i : j+1])
+			parts, separator, ok := splitDateWithSeparator(token)
+			if !ok {
+				continue
+			}
+
+			if year, ok := mapIntsToYear(parts); ok {
+				matches = append(matches, match{
+					pattern:   patternDate,
+					i:         i,
+					j:         j,
+					token:     token,
+					year:      year,
+					separator: separator,
+				})
+			}
+		}
+	}
+
+	// drop dates contained in a longer date, such as "1991" within "13121991"
+	var result []match
+	for a, m := range matches {
+		contained := false
+		for b, other := range matches {
+			if a != b && other.i <= m.i && other.j >= m.j && (other.i != m.i || other.j != m.j) {
+				contained = true
+				break
+			}
+		}
+		if !contained {
+			result = append(result, m)
+		}
+	}
+
+	return result
+}
+
+// splitDateWithSeparator splits a token of the form 1-4 digits, separator, 1-2 digits, the same
+// separator, 1-4 digits.
+func splitDateWithSeparator(token string) ([3]int, string, bool) {
+	for _, separator := range []string{" ", "/", `\`, "_", ".", "-"} {
+		parts := strings.Split(token, separator)
+		if len(parts) != 3 {
+			continue
+		}
+
+		if len(parts[0]) < 1 || len(parts[0]) > 4 || len(parts[1]) < 1 || len(parts[1]) > 2 ||
+			len(parts[2]) < 1 || len(parts[2]) > 4 {
+			return [3]int{}, "", false
+		}
+
+		var ints [3]int
+		for k, part := range parts {
+			if !isAll(part, isASCIIDigit) {
+				return [3]int{}, "", false
+			}
+			ints[k], _ = strconv.Atoi(part)
+		}
+
+		return ints, separator, true
+	}
+
+	return [3]int{}, "", false
+}
+
+// mapIntsToYear checks that the three numbers form a valid day, month and year in some order
+// and returns the four digit year.
+func mapIntsToYear(ints [3]int) (int, bool) {
+	if ints[1] > 31 || ints[1] <= 0 {
+		return 0, false
+	}
+
+	over12, over31, under1 := 0, 0, 0
+	for _, n := range ints {
+		if (n > 99 && n < dateMinYear) || n > dateMaxYear {
+			return 0, false
+		}
+		if n > 31 {
+			over31++
+		}
+		if n > 12 {
+			over12++
+		}
+		if n <= 0 {
+			under1++
+		}
+	}
+	if over31 >= 2 || over12 == 3 || under1 >= 2 {
+		return 0, false
+	}
+
+	yearSplits := [2]struct {
+		year int
+		rest [2]int
+	}{
+		{ints[2], [2]int{ints[0], ints[1]}},
+		{ints[0], [2]int{ints[1], ints[2]}},
+	}
+
+	for _, split := range yearSplits {
+		if split.year >= dateMinYear && split.year <= dateMaxYear {
+			return split.year, isDayMonth(split.rest)
+		}
+	}
+
+	for _, split := range yearSplits {
+		if isDayMonth(split.rest) {
+			return twoToFourDigitYear(split.year), true
+		}
+	}
+
+	return 0, false
+}
+
+func isDayMonth(ints [2]int) bool {
+	for _, dm := range [2][2]int{{ints[0], ints[1]}, {ints[1], ints[0]}} {
+		if dm[0] >= 1 && dm[0] <= 31 && dm[1] >= 1 && dm[1] <= 12 {
+			return true
+		}
+	}
+
+	return false
+}
+
+func twoToFourDigitYear(year int) int {
+	switch {
+	case year > 99:
+		return year
+	case year > 50:
+		return year + 1900
+	default:
+		return year + 2000
+	}
+}
+
+func reverseRunes(runes []rune) []rune {
+	reversed := make([]rune, len(runes))
+	for k, r := range runes {
+		reversed[len(runes)-1-k] = r
+	}
+
+	return reversed
+}
+
+func containsRune(runes []rune, r rune) bool {
+	for _, c := range runes {
+		if c == r {
+			return true
+		}
+	}
+
+	return false
+}
+
+func isAll(s string, f func(rune) bool) bool {
+	for _, r := range s {
+		if !f(r) {
+			return false
+		}
+	}
+
+	return s != ""
+}
+
+func isASCIIDigit(r rune) bool {
+	return r >= '0' && r <= '9'
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+
+	return n
+}
@@ -0,0 +1,169 @@
+// Package strength estimates how hard a password is to guess without calling any external
+// service. It follows the approach of Dropbox's zxcvbn: the password is searched for patterns an
+// attacker would try first (common passwords, dictionary words and names, l33t substitutions,
+// keyboard walks, repeats, sequences and dates), the number of guesses needed for each pattern is
+// estimated, and the cheapest way to cover the whole password with patterns and brute force
+// determines the result.
+package strength
+
+import (
+	"math"
+	"time"
+)
+
+const (
+	// maxPasswordLength bounds the work done for very long values. Anything after it is ignored,
+	// which only makes the estimate more conservative.
+	maxPasswordLength = 100
+
+	bruteforceCardinality = 10
+
+	minSubmatchGuessesSingleChar = 10
+	minSubmatchGuessesMultiChar  = 50
+
+	// minGuessesBeforeGrowingSequence penalizes splitting a password into many short patterns,
+	// since an attacker has to guess how the patterns were combined too.
+	minGuessesBeforeGrowingSequence = 10000
+
+	// scoreDelta keeps values that land exactly on a threshold in the lower score.
+	scoreDelta = 5
+)
+
+// Result is the outcome of estimating a password's strength.
+type Result struct {
+	// Score is 0 (too guessable) to 4 (very unguessable).
+	Score        int      `json:"score"`
+	GuessesLog10 float64  `json:"guesses_log10"`
+	Warning      string   `json:"warning,omitempty"`
+	Suggestions  []string `json:"suggestions"`
+}
+
+// Estimate returns the strength of password. userInputs are words the password should not be
+// based on, such as the account's email and name.
+func Estimate(password string, userInputs ...string) Result {
+	runes := []rune(password)
+	if len(runes) > maxPasswordLength {
+		runes = runes[:maxPasswordLength]
+	}
+
+	matches := omnimatch(runes, newMatcher(userInputs))
+	guesses, sequence := mostGuessableMatchSequence(runes, matches)
+	score := guessesToScore(guesses)
+	warning, suggestions := feedback(score, sequence)
+
+	return Result{
+		Score:        score,
+		GuessesLog10: math.Round(math.Log10(guesses)*100) / 100,
+		Warning:      warning,
+		Suggestions:  suggestions,
+	}
+}
+
+func guessesToScore(guesses float64) int {
+	switch {
+	case guesses < 1e3+scoreDelta:
+		return 0
+	case guesses < 1e6+scoreDelta:
+		return 1
+	case guesses < 1e8+scoreDelta:
+		return 2
+	case guesses < 1e10+scoreDelta:
+		return 3
+	default:
+		return 4
+	}
+}
+
+// mostGuessableMatchSequence finds the sequence of non-overlapping matches, with brute force
+// filling the gaps, that covers the password with the fewest total guesses. optimal[k][l] holds
+// the best sequence of length l that ends at position k.
+func mostGuessableMatchSequence(password []rune, matches []match) (float64, []match) {
+	n := len(password)
+	if n == 0 {
+		return 1, nil
+	}
+
+	matchesByEnd := make([][]match, n)
+	for _, m := range matches {
+		matchesByEnd[m.j] = append(matchesByEnd[m.j], m)
+	}
+
+	type candidate struct {
+		m  match
+		pi float64
+		g  float64
+	}
+	optimal := make([]map[int]candidate, n)
+	for k := range optimal {
+		optimal[k] = map[int]candidate{}
+	}
+
+	update := func(m match, l int) {
+		k := m.j
+		m.guesses = estimateGuesses(m, n)
+
+		pi := m.guesses
+		if l > 1 {
+			pi *= optimal[m.i-1][l-1].pi
+		}
+
+		g := factorial(l)*pi + math.Pow(minGuessesBeforeGrowingSequence, float64(l-1))
+
+		// a shorter or equal length sequence that is at least as good makes this one pointless
+		for competingL, competing := range optimal[k] {
+			if competingL <= l && competing.g <= g {
+				return
+			}
+		}
+
+		optimal[k][l] = candidate{m: m, pi: pi, g: g}
+	}
+
+	for k := 0; k < n; k++ {
+		for _, m := range matchesByEnd[k] {
+			if m.i > 0 {
+				for l := range optimal[m.i-1] {
+					update(m, l+1)
+				}
+			} else {
+				update(m, 1)
+			}
+		}
+
+		update(bruteforceMatch(password, 0, k), 1)
+		for i := 1; i <= k; i++ {
+			bf := bruteforceMatch(password, i, k)
+			for l, last := range optimal[i-1] {
+				// consecutive brute force matches are always worse than one longer one
+				if last.m.pattern != patternBruteforce {
+					update(bf, l+1)
+				}
+			}
+		}
+	}
+
+	bestL, bestG := 0, math.Inf(1)
+	for l, c := range optimal[n-1] {
+		if c.g < bestG || (c.g == bestG && l < bestL) {
+			bestL, bestG = l, c.g
+		}
+	}
+
+	sequence := make([]match, bestL)
+	for k, l := n-1, bestL; k >= 0; l-- {
+		m := optimal[k][l].m
+		sequence[l-1] = m
+		k = m.i - 1
+	}
+
+	return bestG, sequence
+}
+
+func bruteforceMatch(password []rune, i, j int) match {
+	return match{pattern: patternBruteforce, i: i, j: j, token: string(password[i : j+1])}
+}
+
+// referenceYear is the year recent years and dates are measured against.
+func referenceYear() int {
+	return time.Now().Year()
+}
@@ -0,0 +1,149 @@
+package strength
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+// bestSequence returns the patterns that Estimate settles on for password.
+func bestSequence(password string, userInputs ...string) []match {
+	runes := []rune(password)
+	_, sequence := mostGuessableMatchSequence(runes, omnimatch(runes, newMatcher(userInputs)))
+
+	return sequence
+}
+
+func TestEstimateScores(t *testing.T) {
+	tests := []struct {
+		password   string
+		userInputs []string
+		score      int
+		warning    bool
+	}{
+		{password: "", score: 0},
+		{password: "password", score: 0, warning: true},
+		{password: "123456", score: 0, warning: true},
+		{password: "P@ssw0rd", score: 0, warning: true},
+		{password: "abcdefgh", score: 0, warning: true},
+		{password: "12/25/1992", score: 1, warning: true},
+		{password: "alice1990", userInputs: []string{"alice@example.com", "alice"}, score: 1, warning: true},
+		{password: "correcthorse", score: 2},
+		{password: "correct horse battery staple", score: 4},
+		{password: "Kx9#mQ2$vL7!pW4z", score: 4},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.password, func(t *testing.T) {
+			result := Estimate(tt.password, tt.userInputs...)
+			if result.Score != tt.score {
+				t.Fatalf("expected score %d, got %d (%v guesses log10)", tt.score, result.Score, result.GuessesLog10)
+			}
+
+			if (result.Warning != "") != tt.warning {
+				t.Fatalf("expected a warning %t, got %q", tt.warning, result.Warning)
+			}
+
+			if result.Score < 3 && len(result.Suggestions) == 0 {
+				t.Fatal("expected suggestions for a weak password")
+			}
+		})
+	}
+}
+
+func TestEstimatePatterns(t *testing.T) {
+	tests := []struct {
+		name       string
+		password   string
+		userInputs []string
+		pattern    string
+		dictionary string
+		l33t       bool
+		reversed   bool
+	}{
+		{name: "common password", password: "dragon", pattern: patternDictionary, dictionary: dictionaryPasswords},
+		{name: "english word", password: "battery", pattern: patternDictionary, dictionary: dictionaryEnglish},
+		{name: "user input", password: "alice1990", userInputs: []string{"alice"}, pattern: patternDictionary, dictionary: dictionaryUserInputs},
+		{name: "l33t", password: "p4ssw0rd", pattern: patternDictionary, dictionary: dictionaryPasswords, l33t: true},
+		{name: "reversed", password: "drowssap", pattern: patternDictionary, dictionary: dictionaryPasswords, reversed: true},
+		{name: "keyboard row", password: "asdfghjkl;", pattern: patternSpatial},
+		{name: "repeated character", password: "aaaaaaaa", pattern: patternRepeat},
+		{name: "repeated word", password: "abcabcabc", pattern: patternRepeat},
+		{name: "ascending sequence", password: "abcdefgh", pattern: patternSequence},
+		{name: "descending sequence", password: "zyxwvu", pattern: patternSequence},
+		{name: "sequence with a step", password: "13579", pattern: patternSequence},
+		{name: "year", password: "1992", pattern: patternYear},
+		{name: "date with separators", password: "12/25/1992", pattern: patternDate},
+		{name: "date without separators", password: "19920525", pattern: patternDate},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sequence := bestSequence(tt.password, tt.userInputs...)
+
+			found := false
+			for _, m := range sequence {
+				if m.pattern == tt.pattern && m.dictionary == tt.dictionary && m.l33t == tt.l33t && m.reversed == tt.reversed {
+					found = true
+				}
+			}
+
+			if !found {
+				t.Fatalf("expected a %s match, got %+v", tt.pattern, sequence)
+			}
+
+			if score := Estimate(tt.password, tt.userInputs...).Score; score > 1 {
+				t.Fatalf("expected a weak score, got %d", score)
+			}
+		})
+	}
+}
+
+func TestEstimateLength(t *testing.T) {
+	// only the first maxPasswordLength runes are considered
+	long := strings.Repeat("Kx9#mQ2$vL7!pW4z", 100)
+	truncated := string([]rune(long)[:maxPasswordLength])
+
+	if Estimate(long).GuessesLog10 != Estimate(truncated).GuessesLog10 {
+		t.Fatal("expected a long password to be estimated by its first runes")
+	}
+
+	// a longer random password is never weaker
+	if Estimate("Kx9#mQ2$").GuessesLog10 >= Estimate("Kx9#mQ2$vL7!pW4z").GuessesLog10 {
+		t.Fatal("expected a longer password to need more guesses")
+	}
+}
+
+func TestEstimateUnicode(t *testing.T) {
+	passwords := []string{
+		"пароль",
+		"ñandú2024",
+		"straße",
+		"İstanbulİİİ",
+		"ǅǅǅ",
+		"ﬃﬃ",
+		"éé",
+		"שלום123",
+		"🔑🔑🔑🔑",
+		"\x00\x00",
+		"\xff\xfe\xfd",
+		strings.Repeat("🔑", maxPasswordLength*2),
+		strings.Repeat("İ", maxPasswordLength+1),
+		"99/99/9999",
+		"1/1/1/1/1",
+	}
+
+	for _, password := range passwords {
+		t.Run(password, func(t *testing.T) {
+			result := Estimate(password, "İstanbul", "", "🔑")
+
+			if result.Score < 0 || result.Score > 4 {
+				t.Fatalf("expected a score from 0 to 4, got %d", result.Score)
+			}
+
+			if math.IsNaN(result.GuessesLog10) || math.IsInf(result.GuessesLog10, 0) || result.GuessesLog10 < 0 {
+				t.Fatalf("expected a finite number of guesses, got %v", result.GuessesLog10)
+			}
+		})
+	}
+}
@@ -0,0 +1,285 @@
+// Package urlmatch decides whether the URIs saved with a login match the URL of a page being
+// filled, and ranks how closely they do. Base domains come from the Public Suffix List embedded
+// in golang.org/x/net/publicsuffix, so login.example.co.uk and www.example.co.uk share the base
+// domain example.co.uk while example.co.uk and other.co.uk don't.
+package urlmatch
+
+import (
+	"bufio"
+	_ "embed"
+	"errors"
+	"net"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/publicsuffix"
+)
+
+// Match modes. A URI with no mode is matched by base domain.
+const (
+	// the URL has the same base domain as the URI, or one in the same equivalent domain group
+	ModeBaseDomain = "base_domain"
+	// the URL has the same host and port as the URI
+	ModeHost = "host"
+	// the URL starts with the URI
+	ModeStartsWith = "starts_with"
+	// the URI is a regular expression that matches the URL
+	ModeRegex = "regex"
+	// the URI is never matched
+	ModeNever = "never"
+)
+
+// MaxPatternLength is the longest regular expression a URI may hold.
+const MaxPatternLength = 2048
+
+// Quality is how closely a URI matches a URL. Higher is better.
+type Quality int
+
+const (
+	// the URI doesn't match
+	None Quality = iota
+	// the URL's base domain is in the same equivalent domain group as the URI's
+	EquivalentDomain
+	// the URL has the same base domain as the URI
+	BaseDomain
+	// the URL has the same host and port as the URI, or matches its regular expression
+	Host
+	// the URL has the same host as the URI and its path starts with the URI's path
+	Path
+	// the URL is the URI, ignoring case in the scheme and host, default ports and fragments
+	Exact
+)
+
+func (q Quality) String() string {
+	switch q {
+	case EquivalentDomain:
+		return "equivalent_domain"
+	case BaseDomain:
+		return "base_domain"
+	case Host:
+		return "host"
+	case Path:
+		return "path"
+	case Exact:
+		return "exact"
+	default:
+		return "none"
+	}
+}
+
+var (
+	ErrNoHost      = errors.New("the URL must be absolute and have a host")
+	ErrUnknownMode = errors.New("unknown match mode")
+	ErrBadPattern  = errors.New("the regular expression is not valid")
+)
+
+// URL is a parsed and normalized URL.
+type URL struct {
+	raw        string
+	normalized string
+	host       string
+	path       string
+	baseDomain string
+}
+
+// Parse parses an absolute URL with a host. The scheme and host are lower cased, default ports
+// and fragments are dropped and an empty path becomes "/".
+func Parse(raw string) (URL, error) {
+	raw = strings.TrimSpace(raw)
+
+	u, err := url.Parse(raw)
+	if err != nil || u.Scheme == "" || u.Hostname() == "" {
+		return URL{}, ErrNoHost
+	}
+
+	scheme := strings.ToLower(u.Scheme)
+	hostname := strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+
+	host := hostname
+	if strings.Contains(hostname, ":") {
+		host = "[" + hostname + "]"
+	}
+	if port := u.Port(); port != "" && !isDefaultPort(scheme, port) {
+		host += ":" + port
+	}
+
+	path := u.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+
+	normalized := scheme + "://" + host + path
+	if u.RawQuery != "" {
+		normalized += "?" + u.RawQuery
+	}
+
+	return URL{
+		raw:        raw,
+		normalized: normalized,
+		host:       host,
+		path:       path,
+		baseDomain: baseDomain(hostname),
+	}, nil
+}
+
+// String returns the normalized URL.
+func (u URL) String() string {
+	return u.normalized
+}
+
+// BaseDomain returns the registrable domain of the URL's host, which is the host itself for IP
+// addresses, single label hosts like localhost and hosts that are public suffixes.
+func (u URL) BaseDomain() string {
+	return u.baseDomain
+}
+
+// Validate returns an error unless the URI can be matched with the mode: regular expressions must
+// compile, and other URIs must be absolute URLs with a host.
+func Validate(uri string, mode string) error {
+	switch mode {
+	case ModeRegex:
+		if len(uri) > MaxPatternLength {
+			return ErrBadPattern
+		}
+		if _, err := regexp.Compile(uri); err != nil {
+			return ErrBadPattern
+		}
+		return nil
+	case "", ModeBaseDomain, ModeHost, ModeStartsWith, ModeNever:
+		_, err := Parse(uri)
+		return err
+	default:
+		return ErrUnknownMode
+	}
+}
+
+// Matcher matches URIs against URLs, treating the base domains in each of its equivalent domain
+// groups as the same site.
+type Matcher struct {
+	groups map[string]int
+}
+
+// New returns a matcher with the equivalent domain groups. A domain may only be in one group; if
+// it is listed in several, the last one wins.
+func New(equivalentDomains [][]string) *Matcher {
+	m := &Matcher{groups: map[string]int{}}
+
+	for i, group := range equivalentDomains {
+		for _, domain := range group {
+			m.groups[strings.TrimSuffix(strings.ToLower(strings.TrimSpace(domain)), ".")] = i
+		}
+	}
+
+	return m
+}
+
+// Match returns how closely the URI saved with the mode matches the URL, or None if it doesn't.
+// Regular expressions are matched against the URL as it was given, unanchored.
+func (m *Matcher) Match(uri string, mode string, target URL) Quality {
+	switch mode {
+	case ModeNever:
+		return None
+	case ModeRegex:
+		re, err := regexp.Compile(uri)
+		if err != nil || !re.MatchString(target.raw) {
+			return None
+		}
+		if stored, err := Parse(uri); err == nil && stored.normalized == target.normalized {
+			return Exact
+		}
+		return Host
+	}
+
+	stored, err := Parse(uri)
+	if err != nil {
+		return None
+	}
+
+	switch mode {
+	case "", ModeBaseDomain:
+		if stored.baseDomain != target.baseDomain {
+			if m.equivalent(stored.baseDomain, target.baseDomain) {
+				return EquivalentDomain
+			}
+			return None
+		}
+	case ModeHost:
+		if stored.host != target.host {
+			return None
+		}
+	case ModeStartsWith:
+		if !strings.HasPrefix(target.normalized, stored.normalized) {
+			return None
+		}
+	default:
+		return None
+	}
+
+	switch {
+	case stored.normalized == target.normalized:
+		return Exact
+	case stored.host == target.host && stored.path != "/" && strings.HasPrefix(target.path, stored.path):
+		return Path
+	case stored.host == target.host:
+		return Host
+	default:
+		return BaseDomain
+	}
+}
+
+// equivalent reports whether two different base domains are in the same group.
+func (m *Matcher) equivalent(a string, b string) bool {
+	groupA, okA := m.groups[a]
+	groupB, okB := m.groups[b]
+
+	return okA && okB && groupA == groupB
+}
+
+// defaultEquivalentDomains lists well known sites that sign in across several base domains, one
+// comma separated group per line.
+//
+//go:embed equivalent_domains.txt
+var defaultEquivalentDomains string
+
+// DefaultEquivalentDomains returns the built-in equivalent domain groups.
+func DefaultEquivalentDomains() [][]string {
+	groups := [][]string{}
+
+	scanner := bufio.NewScanner(strings.NewReader(defaultEquivalentDomains))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		group := []string{}
+		for _, domain := range strings.Split(line, ",") {
+			if domain = strings.TrimSpace(domain); domain != "" {
+				group = append(group, domain)
+			}
+		}
+		groups = append(groups, group)
+	}
+
+	return groups
+}
+
+// baseDomain returns the registrable domain of the hostname, or the hostname itself when it has
+// none.
+func baseDomain(hostname string) string {
+	if net.ParseIP(hostname) != nil {
+		return hostname
+	}
+
+	domain, err := publicsuffix.EffectiveTLDPlusOne(hostname)
+	if err != nil {
+		return hostname
+	}
+
+	return domain
+}
+
+func isDefaultPort(scheme string, port string) bool {
+	return (scheme == "http" && port == "80") || (scheme == "https" && port == "443")
+}
@@ -0,0 +1,193 @@
+package urlmatch
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		raw        string
+		normalized string
+		baseDomain string
+	}{
+		{"https://login.example.co.uk/signin", "https://login.example.co.uk/signin", "example.co.uk"},
+		{"HTTPS://Login.Example.COM", "https://login.example.com/", "example.com"},
+		{"https://example.com:443/a?b=c#frag", "https://example.com/a?b=c", "example.com"},
+		{"http://example.com:80/", "http://example.com/", "example.com"},
+		{"http://example.com:443/", "http://example.com:443/", "example.com"},
+		{"https://example.com:8443/", "https://example.com:8443/", "example.com"},
+		{"https://example.com./", "https://example.com/", "example.com"},
+		{"http://localhost:8080", "http://localhost:8080/", "localhost"},
+		{"http://192.168.1.1/admin", "http://192.168.1.1/admin", "192.168.1.1"},
+		{"http://[::1]:8080/", "http://[::1]:8080/", "::1"},
+		{"  https://example.com  ", "https://example.com/", "example.com"},
+		{"androidapp://com.example.app", "androidapp://com.example.app/", "example.app"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.raw, func(t *testing.T) {
+			u, err := Parse(tt.raw)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if u.String() != tt.normalized {
+				t.Errorf("expected %s, got %s", tt.normalized, u.String())
+			}
+
+			if u.BaseDomain() != tt.baseDomain {
+				t.Errorf("expected base domain %s, got %s", tt.baseDomain, u.BaseDomain())
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, raw := range []string{"", "example.com", "/login", "https://", "mailto:alice@example.com", "://example.com"} {
+		if _, err := Parse(raw); !errors.Is(err, ErrNoHost) {
+			t.Errorf("%q: expected ErrNoHost, got %v", raw, err)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		uri  string
+		mode string
+		err  error
+	}{
+		{"https://example.com", "", nil},
+		{"https://example.com", ModeHost, nil},
+		{"https://example.com", ModeNever, nil},
+		{`^https://(www\.)?example\.com/`, ModeRegex, nil},
+		{"example.com", ModeBaseDomain, ErrNoHost},
+		{"example.com", ModeStartsWith, ErrNoHost},
+		{"https://example.com/(", ModeRegex, ErrBadPattern},
+		{"https://example.com", "exact", ErrUnknownMode},
+	}
+
+	for _, tt := range tests {
+		if err := Validate(tt.uri, tt.mode); !errors.Is(err, tt.err) {
+			t.Errorf("%s with %q: expected %v, got %v", tt.uri, tt.mode, tt.err, err)
+		}
+	}
+}
+
+func TestMatch(t *testing.T) {
+	matcher := New([][]string{{"google.com", "youtube.com"}, {"example.com", "example.net"}})
+
+	tests := []struct {
+		name    string
+		uri     string
+		mode    string
+		target  string
+		quality Quality
+	}{
+		// base domain, the default
+		{"base domain exact", "https://login.example.co.uk/signin", ModeBaseDomain, "https://login.example.co.uk/signin", Exact},
+		{"base domain path", "https://login.example.co.uk/signin", "", "https://login.example.co.uk/signin/2fa", Path},
+		{"base domain host", "https://login.example.co.uk/signin", "", "https://login.example.co.uk/account", Host},
+		{"base domain subdomain", "https://login.example.co.uk/", "", "https://www.example.co.uk/", BaseDomain},
+		{"base domain apex", "https://login.example.co.uk/", "", "https://example.co.uk/", BaseDomain},
+		{"base domain public suffix sibling", "https://login.example.co.uk/", "", "https://other.co.uk/", None},
+		{"base domain other scheme", "http://example.com/", "", "https://example.com/", Host},
+		{"base domain lookalike", "https://example.com/", "", "https://example.com.evil.com/", None},
+		{"base domain IP", "http://192.168.1.1/", "", "http://192.168.1.2/", None},
+		{"base domain not a URL", "example.com", "", "https://example.com/", None},
+
+		// default ports
+		{"default port on the URI", "https://example.com:443/login", ModeHost, "https://example.com/login", Exact},
+		{"default port on the URL", "https://example.com/login", ModeHost, "https://example.com:443/login", Exact},
+		{"other port", "https://example.com/login", ModeHost, "https://example.com:8443/login", None},
+		{"other port same base domain", "https://example.com/login", ModeBaseDomain, "https://example.com:8443/login", BaseDomain},
+
+		// host
+		{"host exact", "https://login.example.co.uk/", ModeHost, "https://login.example.co.uk/", Exact},
+		{"host path", "https://login.example.co.uk/signin", ModeHost, "https://login.example.co.uk/signin?next=/", Path},
+		{"host other path", "https://login.example.co.uk/signin", ModeHost, "https://login.example.co.uk/account", Host},
+		{"host case", "https://Login.Example.co.uk/", ModeHost, "https://login.example.co.uk/", Exact},
+		{"host subdomain", "https://login.example.co.uk/", ModeHost, "https://www.example.co.uk/", None},
+		{"host apex", "https://login.example.co.uk/", ModeHost, "https://example.co.uk/", None},
+
+		// starts with
+		{"starts with exact", "https://example.com/app", ModeStartsWith, "https://example.com/app", Exact},
+		{"starts with path", "https://example.com/app/", ModeStartsWith, "https://example.com/app/login", Path},
+		{"starts with root", "https://example.com", ModeStartsWith, "https://example.com/login", Host},
+		{"starts with other path", "https://example.com/app/", ModeStartsWith, "https://example.com/other", None},
+		{"starts with lookalike host", "https://example.com", ModeStartsWith, "https://example.com.evil.com/", None},
+		{"starts with lookalike host and path", "https://example.com/login", ModeStartsWith, "https://example.com.evil.com/login", None},
+		{"starts with userinfo", "https://example.com", ModeStartsWith, "https://example.com@evil.com/", None},
+		{"starts with other scheme", "https://example.com/", ModeStartsWith, "http://example.com/", None},
+		{"starts with default port", "https://example.com:443/app", ModeStartsWith, "https://example.com/app/login", Path},
+
+		// regex
+		{"regex match", `^https://(login|www)\.example\.co\.uk/`, ModeRegex, "https://www.example.co.uk/signin", Host},
+		{"regex no match", `^https://(login|www)\.example\.co\.uk/`, ModeRegex, "https://other.co.uk/", None},
+		{"regex anchored lookalike", `^https://example\.com/`, ModeRegex, "https://example.com.evil.com/", None},
+		{"regex unanchored", `example\.com`, ModeRegex, "https://www.example.com/", Host},
+		{"regex exact URL", "https://example.com/", ModeRegex, "https://example.com/", Exact},
+		{"regex invalid", "(", ModeRegex, "https://example.com/", None},
+
+		// never
+		{"never", "https://example.com/", ModeNever, "https://example.com/", None},
+
+		// unknown modes never match
+		{"unknown mode", "https://example.com/", "exact", "https://example.com/", None},
+
+		// equivalent domains
+		{"equivalent domain", "https://accounts.google.com/", "", "https://www.youtube.com/", EquivalentDomain},
+		{"equivalent domain reversed", "https://youtube.com/", ModeBaseDomain, "https://mail.google.com/", EquivalentDomain},
+		{"equivalent domain case", "https://Example.NET/", "", "https://example.com/", EquivalentDomain},
+		{"equivalent domain other group", "https://google.com/", "", "https://example.com/", None},
+		{"equivalent domain not grouped", "https://google.com/", "", "https://gmail.com/", None},
+		{"equivalent domain host mode", "https://google.com/", ModeHost, "https://youtube.com/", None},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			target, err := Parse(tt.target)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if quality := matcher.Match(tt.uri, tt.mode, target); quality != tt.quality {
+				t.Errorf("%s (%q) against %s: expected %s, got %s", tt.uri, tt.mode, tt.target, tt.quality, quality)
+			}
+		})
+	}
+}
+
+func TestNewLastGroupWins(t *testing.T) {
+	matcher := New([][]string{{"a.com", "b.com"}, {"b.com", "c.com"}})
+
+	if matcher.equivalent("a.com", "b.com") {
+		t.Error("expected b.com to have moved to the later group")
+	}
+
+	if !matcher.equivalent("b.com", "c.com") {
+		t.Error("expected b.com and c.com to be equivalent")
+	}
+}
+
+func TestDefaultEquivalentDomains(t *testing.T) {
+	groups := DefaultEquivalentDomains()
+	if len(groups) == 0 {
+		t.Fatal("expected built-in equivalent domain groups")
+	}
+
+	for _, group := range groups {
+		if len(group) < 2 {
+			t.Errorf("expected every group to have at least two domains, got %v", group)
+		}
+	}
+
+	target, err := Parse("https://www.youtube.com/")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if quality := New(groups).Match("https://accounts.google.com/", "", target); quality != EquivalentDomain {
+		t.Errorf("expected google.com and youtube.com to be equivalent, got %s", quality)
+	}
+}
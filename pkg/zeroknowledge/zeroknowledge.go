@@ -0,0 +1,182 @@
+// Package zeroknowledge implements the client side of zero-knowledge vault mode. The master
+// password is stretched with Argon2id into a master key, which never leaves the client. Three
+// independent keys are expanded from it with HKDF-SHA256: an encryption key used to seal
+// note names and values before they are sent, an index key used to hash the blind indexes the
+// server searches them by, and an auth hash that the server stores (hashed again) and checks at
+// login in place of the password.
+//
+// Because the server only receives the auth hash, it can't recover the encryption key, and
+// every note it stores for a zero-knowledge account is opaque ciphertext.
+package zeroknowledge
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"io"
+	"strings"
+
+	"github.com/oalexander6/passman/pkg/models"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/hkdf"
+)
+
+const (
+	saltSize = 16
+	keySize  = 32
+
+	encryptionKeyInfo = "passman:zero-knowledge:encryption"
+	indexKeyInfo      = "passman:zero-knowledge:index"
+	authHashInfo      = "passman:zero-knowledge:auth"
+
+	// ciphertextPrefix marks values sealed by this package, in the form zk1:<base64 nonce and
+	// ciphertext>.
+	ciphertextPrefix = "zk1:"
+)
+
+var (
+	ErrInvalidParams   = errors.New("invalid KDF parameters")
+	ErrDecryptFailed   = errors.New("decryption failed")
+	ErrUnknownEncoding = errors.New("value was not encrypted by a zero-knowledge client")
+)
+
+// Keys are the keys derived from a master password.
+type Keys struct {
+	// EncryptionKey encrypts vault data on the client. It must never be sent to the server.
+	EncryptionKey []byte
+	// IndexKey hashes blind indexes on the client. It must never be sent to the server either,
+	// since with it the server could test guesses of indexed values.
+	IndexKey []byte
+	// AuthHash is sent to the server in place of the password at registration and login.
+	AuthHash string
+}
+
+// NewKDFParams returns parameters with a fresh random salt and the recommended cost settings.
+func NewKDFParams() (models.KDFParams, error) {
+	salt := make([]byte, saltSize)
+	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+		return models.KDFParams{}, err
+	}
+
+	params := models.DefaultKDFParams()
+	params.Salt = base64.StdEncoding.EncodeToString(salt)
+
+	return params, nil
+}
+
+// DeriveKeys stretches the master password with the provided parameters and expands the
+// encryption key, index key and auth hash from the result.
+func DeriveKeys(password string, params models.KDFParams) (Keys, error) {
+	salt, err := base64.StdEncoding.DecodeString(params.Salt)
+	if err != nil || len(salt) == 0 || params.Memory == 0 || params.Iterations == 0 || params.Parallelism == 0 {
+		return Keys{}, ErrInvalidParams
+	}
+
+	masterKey := argon2.IDKey([]byte(password), salt, params.Iterations, params.Memory, params.Parallelism, keySize)
+
+	encryptionKey, err := expandKey(masterKey, encryptionKeyInfo)
+	if err != nil {
+		return Keys{}, err
+	}
+
+	indexKey, err := expandKey(masterKey, indexKeyInfo)
+	if err != nil {
+		return Keys{}, err
+	}
+
+	authHash, err := expandKey(masterKey, authHashInfo)
+	if err != nil {
+		return Keys{}, err
+	}
+
+	return Keys{
+		EncryptionKey: encryptionKey,
+		IndexKey:      indexKey,
+		AuthHash:      base64.StdEncoding.EncodeToString(authHash),
+	}, nil
+}
+
+// Encrypt seals plaintext with AES-256-GCM under the encryption key.
+func (k Keys) Encrypt(plaintext string) (string, error) {
+	gcm, err := newGCM(k.EncryptionKey)
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", err
+	}
+
+	sealed := gcm.Seal(nonce, nonce, []byte(plaintext), nil)
+	return ciphertextPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt opens a value sealed by Encrypt.
+func (k Keys) Decrypt(encrypted string) (string, error) {
+	encoded, ok := strings.CutPrefix(encrypted, ciphertextPrefix)
+	if !ok {
+		return "", ErrUnknownEncoding
+	}
+
+	sealed, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", ErrDecryptFailed
+	}
+
+	gcm, err := newGCM(k.EncryptionKey)
+	if err != nil {
+		return "", err
+	}
+
+	if len(sealed) < gcm.NonceSize() {
+		return "", ErrDecryptFailed
+	}
+
+	plaintext, err := gcm.Open(nil, sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():], nil)
+	if err != nil {
+		return "", ErrDecryptFailed
+	}
+
+	return string(plaintext), nil
+}
+
+// BlindIndex returns the blind index of a value of one of a note's fields, such as
+// models.BlindFieldUsername, to send in the note's search_index.
+func (k Keys) BlindIndex(field string, value string) models.BlindIndex {
+	return models.NewBlindIndex(k.IndexKey, field, value)
+}
+
+// BlindExactTerm returns a search term for notes with a value of the field equal to value, to
+// send as exact=<field>:<token>.
+func (k Keys) BlindExactTerm(field string, value string) models.BlindTerm {
+	return models.NewBlindExactTerm(k.IndexKey, field, value)
+}
+
+// BlindSubstringTerm returns a search term for notes with a value of the field containing
+// value, to send as ngrams=<field>:<token>,<token>. Returns false if value is too short to
+// search for.
+func (k Keys) BlindSubstringTerm(field string, value string) (models.BlindTerm, bool) {
+	return models.NewBlindSubstringTerm(k.IndexKey, field, value)
+}
+
+func expandKey(masterKey []byte, info string) ([]byte, error) {
+	key := make([]byte, keySize)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, masterKey, nil, []byte(info)), key); err != nil {
+		return nil, err
+	}
+
+	return key, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}